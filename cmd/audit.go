@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/audit"
+)
+
+// auditTailOptions holds the flag values for a single audit tail invocation.
+type auditTailOptions struct {
+	File    string
+	Lines   int
+	Follow  bool
+	Tool    string
+	Session string
+	User    string
+	Errors  bool
+	Output  string
+}
+
+var auditTailOpts auditTailOptions
+
+// followInterval is how often audit tail --follow checks for new entries.
+const followInterval = 500 * time.Millisecond
+
+// addAuditTailFlags registers the audit tail flags on fs, bound to o.
+func addAuditTailFlags(fs *pflag.FlagSet, o *auditTailOptions) {
+	fs.StringVar(&o.File, "file", "", "Audit log to read (default audit_log from the config)")
+	fs.IntVarP(&o.Lines, "lines", "n", 20, "Number of most recent entries to print; 0 for all")
+	fs.BoolVarP(&o.Follow, "follow", "f", false, "Keep printing entries as they are appended until interrupted")
+	fs.StringVar(&o.Tool, "tool", "", "Only show calls of this tool")
+	fs.StringVar(&o.Session, "session", "", "Only show calls from this session")
+	fs.StringVar(&o.User, "user", "", "Only show calls by this OIDC user")
+	fs.BoolVar(&o.Errors, "errors", false, "Only show calls that failed")
+	fs.StringVarP(&o.Output, "output", "o", "text", "Output format: text or json (the log's own JSON lines)")
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Review the tool calls recorded by the MCP server",
+	Long: `Review the audit log the Go MCP server keeps when started with -audit-log
+(or with audit_log set in the config): one JSON line per tool call with its
+time, session, user (with -oidc-issuer), arguments, outcome, and duration. Long string arguments, such
+as file contents given to write_file, are shortened.`,
+}
+
+var auditTailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Print the most recent entries of the audit log",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runAuditTail(ctx, auditTailOpts, cmd.OutOrStdout())
+	},
+}
+
+// runAuditTail prints the last o.Lines entries matching the filters, then
+// with o.Follow the ones appended afterwards until ctx is done.
+func runAuditTail(ctx context.Context, o auditTailOptions, stdout io.Writer) error {
+	if o.File == "" {
+		o.File = cfg.AuditLog
+	}
+	if o.File == "" {
+		return errors.New("no audit log given: pass --file or set audit_log in the config")
+	}
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", o.Output)
+	}
+	wanted := func(e audit.Entry) bool {
+		return (o.Tool == "" || e.Tool == o.Tool) && (o.Session == "" || e.Session == o.Session) && (o.User == "" || e.User == o.User) && (!o.Errors || e.Outcome == audit.Error)
+	}
+	show := func(e audit.Entry) {
+		if !wanted(e) {
+			return
+		}
+		if o.Output == "json" {
+			line, _ := json.Marshal(e)
+			fmt.Fprintf(stdout, "%s\n", line)
+			return
+		}
+		args, _ := json.Marshal(e.Args)
+		fmt.Fprintf(stdout, "%s  %-5s %9s  %s %s", e.Time.Local().Format(time.RFC3339), e.Outcome, fmt.Sprintf("%.1fms", e.Duration), e.Tool, args)
+		if e.Session != "" {
+			fmt.Fprintf(stdout, "  session=%s", e.Session)
+		}
+		if e.User != "" {
+			fmt.Fprintf(stdout, "  user=%s", e.User)
+		}
+		if e.Error != "" {
+			fmt.Fprintf(stdout, "\n    %s", e.Error)
+		}
+		fmt.Fprintln(stdout)
+	}
+
+	data, err := os.ReadFile(o.File)
+	if err != nil {
+		return fmt.Errorf("unable to read audit log: %w", err)
+	}
+	// Leave a partly written last line for --follow to pick up whole
+	offset := int64(bytes.LastIndexByte(data, '\n') + 1)
+	var recent []audit.Entry
+	_ = audit.Read(bytes.NewReader(data[:offset]), func(e audit.Entry) {
+		if wanted(e) {
+			recent = append(recent, e)
+		}
+	})
+	if o.Lines > 0 && len(recent) > o.Lines {
+		recent = recent[len(recent)-o.Lines:]
+	}
+	for _, e := range recent {
+		show(e)
+	}
+	if !o.Follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if offset, err = followAudit(o.File, offset, show); err != nil {
+			return err
+		}
+	}
+}
+
+// followAudit prints the complete entries appended to the log at path
+// since offset and returns the offset after them. A log that shrank, as
+// after rotation, is read again from the start.
+func followAudit(path string, offset int64, show func(audit.Entry)) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return offset, fmt.Errorf("unable to read audit log: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return offset, err
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	if info.Size() == offset {
+		return offset, nil
+	}
+	data := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(data, offset); err != nil && err != io.EOF {
+		return offset, err
+	}
+	n := bytes.LastIndexByte(data, '\n') + 1
+	_ = audit.Read(bytes.NewReader(data[:n]), show)
+	return offset + int64(n), nil
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditTailCmd)
+	addAuditTailFlags(auditTailCmd.Flags(), &auditTailOpts)
+}
@@ -0,0 +1,408 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/bookmark"
+	"vscode-helper-file-find/internal/search"
+)
+
+// bookmarkAddOptions holds the flag values for a single bookmark add
+// invocation: the search saved, and how.
+type bookmarkAddOptions struct {
+	Search      searchOptions
+	Description string
+	Force       bool
+}
+
+var (
+	bookmarkAddOpts  bookmarkAddOptions
+	bookmarkRunOpts  searchOptions
+	bookmarkListOpts struct{ Output string }
+)
+
+// addBookmarkAddFlags registers the bookmark add flags on fs, bound to o.
+func addBookmarkAddFlags(fs *pflag.FlagSet, o *bookmarkAddOptions) {
+	addSearchFlags(fs, &o.Search)
+	fs.StringVar(&o.Description, "description", "", "What the search looks for, shown by bookmark list")
+	fs.BoolVar(&o.Force, "force", false, "Replace a bookmark of the same name")
+}
+
+// bookmarkFlag is a search flag and a value it is set to, as a bookmark
+// gives it.
+type bookmarkFlag struct {
+	Name, Value string
+	// Bool is set for flags that take no value on the command line.
+	Bool bool
+}
+
+// bookmarkFlags returns the search flags that reproduce q, in the order
+// search --help lists them. Repeatable flags appear once per value.
+func bookmarkFlags(q bookmark.Query) []bookmarkFlag {
+	var flags []bookmarkFlag
+	add := func(name string, values ...string) {
+		for _, v := range values {
+			flags = append(flags, bookmarkFlag{Name: name, Value: v})
+		}
+	}
+	set := func(name string, on bool) {
+		if on {
+			flags = append(flags, bookmarkFlag{name, "true", true})
+		}
+	}
+	number := func(name string, n int) {
+		if n > 0 {
+			add(name, strconv.Itoa(n))
+		}
+	}
+	text := func(name, s string) {
+		if s != "" {
+			add(name, s)
+		}
+	}
+	add("name", q.Name...)
+	set("fuzzy", q.Fuzzy)
+	add("content", q.Content...)
+	set("all", q.All)
+	add("not-content", q.NotContent...)
+	add("dir", q.Dir)
+	set("regex", q.Regex)
+	set("word", q.Word)
+	number("after-context", q.After)
+	number("before-context", q.Before)
+	set("ignore-case", q.IgnoreCase)
+	set("case-sensitive", q.CaseSensitive)
+	add("exclude", q.Exclude...)
+	set("no-ignore", q.NoIgnore)
+	set("binary", q.Binary)
+	set("follow-symlinks", q.FollowSymlinks)
+	add("type", q.Type...)
+	add("type-not", q.TypeNot...)
+	text("min-size", q.MinSize)
+	text("max-size", q.MaxSize)
+	text("newer-than", q.NewerThan)
+	text("older-than", q.OlderThan)
+	set("archives", q.Archives)
+	number("max-results", q.MaxResults)
+	text("sort", q.Sort)
+	set("reverse", q.Reverse)
+	return flags
+}
+
+// savedFlags are the search flags a bookmark keeps; the rest shape a
+// single run's output or how it is carried out.
+var savedFlags = map[string]bool{
+	"name": true, "fuzzy": true, "content": true, "all": true, "any": true, "not-content": true,
+	"dir": true, "regex": true, "word": true, "after-context": true, "before-context": true,
+	"context": true, "ignore-case": true, "case-sensitive": true, "exclude": true,
+	"no-ignore": true, "binary": true, "follow-symlinks": true, "type": true, "type-not": true,
+	"min-size": true, "max-size": true, "newer-than": true, "older-than": true,
+	"archives": true, "max-results": true, "sort": true, "reverse": true,
+}
+
+// bookmarkQuery returns the query saved for the search flags set on fs,
+// checking that it is one that can run.
+func bookmarkQuery(fs *pflag.FlagSet, o searchOptions) (bookmark.Query, error) {
+	var err error
+	fs.Visit(func(f *pflag.Flag) {
+		if err == nil && !savedFlags[f.Name] && f.Name != "description" && f.Name != "force" {
+			err = fmt.Errorf("--%s cannot be saved in a bookmark; pass it to bookmark run instead", f.Name)
+		}
+	})
+	if err != nil {
+		return bookmark.Query{}, err
+	}
+	if len(o.Dirs) != 1 {
+		return bookmark.Query{}, errors.New("a bookmark searches a single --dir")
+	}
+	var terms []string
+	for _, term := range o.Content {
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	o.Content = terms
+	if len(o.Name) == 0 && len(o.Content) == 0 {
+		return bookmark.Query{}, errors.New("a bookmark needs --name or --content")
+	}
+	if o.IgnoreCase && o.CaseSensitive {
+		return bookmark.Query{}, errors.New("--ignore-case and --case-sensitive cannot be combined")
+	}
+	if o.All && o.Any {
+		return bookmark.Query{}, errors.New("--all and --any cannot be combined")
+	}
+	dir, err := filepath.Abs(o.Dirs[0])
+	if err != nil {
+		return bookmark.Query{}, err
+	}
+	if err := allowed.Check(dir); err != nil {
+		return bookmark.Query{}, err
+	}
+	c, err := cfg.ForDir(dir)
+	if err != nil {
+		return bookmark.Query{}, err
+	}
+	opts := search.Options{Dir: dir, Names: o.Name, Contents: o.Content, NotContents: o.NotContent, Regex: o.Regex, Word: o.Word, Case: o.caseMode(), Types: o.Type, TypesNot: o.TypeNot, TypeDefs: c.Types, Fuzzy: o.Fuzzy, Sort: o.Sort, MaxResults: o.MaxResults}
+	if err := o.applyFilters(&opts, time.Now()); err != nil {
+		return bookmark.Query{}, err
+	}
+	if err := opts.Validate(); err != nil {
+		return bookmark.Query{}, err
+	}
+	return bookmark.Query{
+		Dir:            dir,
+		Name:           o.Name,
+		Content:        o.Content,
+		NotContent:     o.NotContent,
+		All:            o.All,
+		Regex:          o.Regex,
+		Word:           o.Word,
+		Fuzzy:          o.Fuzzy,
+		IgnoreCase:     o.IgnoreCase,
+		CaseSensitive:  o.CaseSensitive,
+		Before:         max(o.Before, o.Context),
+		After:          max(o.After, o.Context),
+		Exclude:        o.Exclude,
+		Type:           o.Type,
+		TypeNot:        o.TypeNot,
+		NoIgnore:       o.NoIgnore,
+		Binary:         o.Binary,
+		Archives:       o.Archives,
+		FollowSymlinks: o.FollowSymlinks,
+		MinSize:        o.MinSize,
+		MaxSize:        o.MaxSize,
+		NewerThan:      o.NewerThan,
+		OlderThan:      o.OlderThan,
+		Sort:           o.Sort,
+		Reverse:        o.Reverse,
+		MaxResults:     o.MaxResults,
+	}, nil
+}
+
+// applyBookmark sets the search flags on fs that reproduce q, leaving
+// those given explicitly alone so that they override the bookmark's.
+func applyBookmark(fs *pflag.FlagSet, q bookmark.Query) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *pflag.Flag) { explicit[f.Name] = true })
+	// Flags that cannot be combined with one given explicitly are
+	// overridden by it too
+	for _, group := range [][]string{{"context", "after-context", "before-context"}, {"ignore-case", "case-sensitive"}, {"all", "any"}} {
+		for _, name := range group {
+			if explicit[name] {
+				for _, other := range group {
+					explicit[other] = true
+				}
+			}
+		}
+	}
+	for _, f := range bookmarkFlags(q) {
+		if explicit[f.Name] {
+			continue
+		}
+		if err := fs.Set(f.Name, f.Value); err != nil {
+			return fmt.Errorf("invalid bookmark: --%s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// commandLine renders flags as search arguments a shell would accept.
+func commandLine(flags []bookmarkFlag) string {
+	args := []string{"search"}
+	for _, f := range flags {
+		if f.Bool {
+			args = append(args, "--"+f.Name)
+		} else {
+			args = append(args, "--"+f.Name, shellQuote(f.Value))
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes s for a POSIX shell unless it is plain enough not to
+// need it.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-./:=,@+") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var bookmarkCmd = &cobra.Command{
+	Use:   "bookmark",
+	Short: "Save searches under a name and run them again",
+	Long: `Save searches made again and again under a name, and run them by it. A
+bookmark keeps the directory searched and what the search looks for: names,
+content terms, and the filters that choose the files. How the results are
+printed is up to each run:
+
+  vscode-helper bookmark add todos --dir ~/src/app --content TODO --content FIXME -t go
+  vscode-helper bookmark run todos --count
+  vscode-helper bookmark list
+  vscode-helper bookmark rm todos
+
+Bookmarks are kept in the user config directory, normally
+~/.config/vscode-helper/bookmarks.json, and the MCP server's list_bookmarks
+and run_bookmark tools share them.`,
+}
+
+var bookmarkAddCmd = &cobra.Command{
+	Use:   "add NAME [search flags]",
+	Short: "Save a search as a bookmark",
+	Long: `Save the search that the search flags given describe under NAME. Relative
+directories are saved as absolute paths, the working directory when --dir is
+not given, and durations such as --newer-than 2d as given, so that they are
+read relative to each run.
+
+Only flags that say what to look for are saved; those that shape the output,
+such as --output or --count, are given to bookmark run instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBookmarkAdd(cmd.Flags(), bookmarkAddOpts, args[0], cmd.OutOrStdout())
+	},
+}
+
+// runBookmarkAdd saves the search flags set on fs as the bookmark name.
+func runBookmarkAdd(fs *pflag.FlagSet, o bookmarkAddOptions, name string, stdout io.Writer) error {
+	if err := bookmark.ValidateName(name); err != nil {
+		return err
+	}
+	q, err := bookmarkQuery(fs, o.Search)
+	if err != nil {
+		return err
+	}
+	store, err := bookmark.Default()
+	if err != nil {
+		return err
+	}
+	if err := store.Add(bookmark.Bookmark{Name: name, Description: o.Description, Query: q}, o.Force); err != nil {
+		if errors.Is(err, bookmark.ErrExists) {
+			return fmt.Errorf("%w; use --force to replace it", err)
+		}
+		return err
+	}
+	fmt.Fprintf(stdout, "Saved bookmark %s: %s\n", name, commandLine(bookmarkFlags(q)))
+	return nil
+}
+
+var bookmarkListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved bookmarks",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBookmarkList(bookmarkListOpts.Output, cmd.OutOrStdout())
+	},
+}
+
+// runBookmarkList prints each bookmark with the search it runs.
+func runBookmarkList(output string, stdout io.Writer) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", output)
+	}
+	store, err := bookmark.Default()
+	if err != nil {
+		return err
+	}
+	bookmarks, err := store.List()
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		if bookmarks == nil {
+			bookmarks = []bookmark.Bookmark{}
+		}
+		b, err := json.MarshalIndent(bookmarks, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+		return nil
+	}
+	for _, b := range bookmarks {
+		fmt.Fprintf(stdout, "%s: %s\n", b.Name, commandLine(bookmarkFlags(b.Query)))
+		if b.Description != "" {
+			fmt.Fprintf(stdout, "  %s\n", b.Description)
+		}
+	}
+	if len(bookmarks) == 0 {
+		return errNoMatches
+	}
+	return nil
+}
+
+var bookmarkRunCmd = &cobra.Command{
+	Use:   "run NAME [search flags]",
+	Short: "Run a saved search",
+	Long: `Run the search saved as NAME, as search would with the bookmark's flags.
+Search flags given here are added: those that shape the output apply to this
+run, and those the bookmark saved override its values, e.g. --dir to search
+another checkout, or --content to look for other terms in the same files:
+
+  vscode-helper bookmark run todos -o json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := bookmark.Default()
+		if err != nil {
+			return err
+		}
+		b, err := store.Get(args[0])
+		if err != nil {
+			return err
+		}
+		if err := applyBookmark(cmd.Flags(), b.Query); err != nil {
+			return err
+		}
+		if err := applySearchConfig(cmd.Flags(), &bookmarkRunOpts); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runSearch(ctx, bookmarkRunOpts, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+var bookmarkRmCmd = &cobra.Command{
+	Use:     "rm NAME...",
+	Aliases: []string{"remove"},
+	Short:   "Delete saved bookmarks",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := bookmark.Default()
+		if err != nil {
+			return err
+		}
+		for _, name := range args {
+			if err := store.Remove(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bookmarkCmd)
+	bookmarkCmd.AddCommand(bookmarkAddCmd, bookmarkListCmd, bookmarkRunCmd, bookmarkRmCmd)
+	addBookmarkAddFlags(bookmarkAddCmd.Flags(), &bookmarkAddOpts)
+	bookmarkListCmd.Flags().StringVarP(&bookmarkListOpts.Output, "output", "o", "text", "Output format: text or json")
+	addSearchFlags(bookmarkRunCmd.Flags(), &bookmarkRunOpts)
+	for _, c := range []*cobra.Command{bookmarkAddCmd, bookmarkRunCmd} {
+		c.MarkFlagsMutuallyExclusive("ignore-case", "case-sensitive")
+		c.MarkFlagsMutuallyExclusive("all", "any")
+	}
+	bookmarkRunCmd.MarkFlagsMutuallyExclusive("content", "content-from-stdin")
+	bookmarkRunCmd.MarkFlagsMutuallyExclusive("files-with-matches", "count")
+}
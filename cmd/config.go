@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/config"
+)
+
+// configPath is the --config flag; cfg is the user configuration loaded
+// from it (or from the default location) before any command runs. Project
+// configuration is layered on top per command, for the directory it works
+// in.
+var (
+	configPath string
+	cfg        config.Config
+)
+
+// applySearchConfig fills in the search options not set on fs from the
+// configuration for the directory searched (the working directory unless
+// --dir is given).
+func applySearchConfig(fs *pflag.FlagSet, o *searchOptions) error {
+	c, err := cfg.ForDir(startDir(fs, o.Dirs[0]))
+	if err != nil {
+		return err
+	}
+	if c.Dir != "" && !fs.Changed("dir") {
+		o.Dirs = []string{c.Dir}
+	}
+	if c.Exclude != nil && !fs.Changed("exclude") {
+		o.Exclude = c.Exclude
+	}
+	if c.MaxResults > 0 && !fs.Changed("max-results") {
+		o.MaxResults = c.MaxResults
+	}
+	if c.Jobs > 0 && !fs.Changed("jobs") {
+		o.Jobs = c.Jobs
+	}
+	if c.CacheTTL > 0 && !fs.Changed("cache-ttl") {
+		o.CacheTTL = c.CacheTTL
+	}
+	o.TypeDefs = c.Types
+	return nil
+}
+
+// applyReplaceConfig fills in the replace options not set on fs from the
+// configuration for the directory searched.
+func applyReplaceConfig(fs *pflag.FlagSet, o *replaceOptions) error {
+	c, err := cfg.ForDir(startDir(fs, o.Dir))
+	if err != nil {
+		return err
+	}
+	if c.Dir != "" && !fs.Changed("dir") {
+		o.Dir = c.Dir
+	}
+	if c.Exclude != nil && !fs.Changed("exclude") {
+		o.Exclude = c.Exclude
+	}
+	return nil
+}
+
+// applyTreeConfig fills in the tree options not set on fs from the
+// configuration for dir.
+func applyTreeConfig(fs *pflag.FlagSet, o *treeOptions, dir string) error {
+	c, err := cfg.ForDir(dir)
+	if err != nil {
+		return err
+	}
+	if c.Exclude != nil && !fs.Changed("exclude") {
+		o.Exclude = c.Exclude
+	}
+	return nil
+}
+
+// applyIndexConfig fills in the index options not set on fs from the
+// configuration for dir.
+func applyIndexConfig(fs *pflag.FlagSet, o *indexOptions, dir string) error {
+	c, err := cfg.ForDir(dir)
+	if err != nil {
+		return err
+	}
+	if c.Index.Trigrams != nil && !fs.Changed("trigrams") {
+		o.Trigrams = *c.Index.Trigrams
+	}
+	return nil
+}
+
+// startDir is the directory whose project configuration applies: the
+// --dir flag when given, otherwise the working directory.
+func startDir(fs *pflag.FlagSet, dir string) string {
+	if fs.Changed("dir") {
+		return dir
+	}
+	return "."
+}
+
+// applyStatsConfig fills in the stats options not set on fs from the
+// configuration for dir.
+func applyStatsConfig(fs *pflag.FlagSet, o *statsOptions, dir string) error {
+	c, err := cfg.ForDir(dir)
+	if err != nil {
+		return err
+	}
+	if c.Exclude != nil && !fs.Changed("exclude") {
+		o.Exclude = c.Exclude
+	}
+	return nil
+}
+
+// applyDupesConfig fills in the dupes options not set on fs from the
+// configuration for dir.
+func applyDupesConfig(fs *pflag.FlagSet, o *dupesOptions, dir string) error {
+	c, err := cfg.ForDir(dir)
+	if err != nil {
+		return err
+	}
+	if c.Exclude != nil && !fs.Changed("exclude") {
+		o.Exclude = c.Exclude
+	}
+	return nil
+}
+
+// applyTodosConfig fills in the todos options not set on fs from the
+// configuration for dir.
+func applyTodosConfig(fs *pflag.FlagSet, o *todosOptions, dir string) error {
+	c, err := cfg.ForDir(dir)
+	if err != nil {
+		return err
+	}
+	if c.Exclude != nil && !fs.Changed("exclude") {
+		o.Exclude = c.Exclude
+	}
+	if c.Todos.Tags != nil && !fs.Changed("tag") {
+		o.Tags = c.Todos.Tags
+	}
+	return nil
+}
+
+// applySymbolsConfig fills in the symbols options not set on fs from the
+// configuration for dir.
+func applySymbolsConfig(fs *pflag.FlagSet, o *symbolsOptions, dir string) error {
+	c, err := cfg.ForDir(dir)
+	if err != nil {
+		return err
+	}
+	if c.Exclude != nil && !fs.Changed("exclude") {
+		o.Exclude = c.Exclude
+	}
+	return nil
+}
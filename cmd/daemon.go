@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/daemon"
+	"vscode-helper-file-find/internal/debug"
+	"vscode-helper-file-find/internal/index"
+)
+
+// daemonOptions holds the flag values for a single daemon invocation.
+type daemonOptions struct {
+	Socket    string
+	Trigrams  bool
+	DebugAddr string
+}
+
+var daemonOpts daemonOptions
+
+// addDaemonFlags registers the daemon flags on fs, bound to o.
+func addDaemonFlags(fs *pflag.FlagSet, o *daemonOptions) {
+	fs.StringVar(&o.Socket, "socket", "", "Listen on this Unix socket path (default $VSCODE_HELPER_SOCKET, else vscode-helper/daemon.sock in $XDG_RUNTIME_DIR or ~/.cache)")
+	fs.BoolVar(&o.Trigrams, "trigrams", false, "Index file contents too, to speed up content searches")
+	fs.StringVar(&o.DebugAddr, "debug-addr", "", "Serve pprof profiles and runtime stats on this address, such as localhost:6060")
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon [dir...]",
+	Short: "Keep indexes in memory and serve searches from them",
+	Long: `Run in the background keeping the index of each dir (default .) in memory
+and up to date as files change, and serve search and open requests on a
+Unix domain socket:
+
+  vscode-helper daemon ~/src/app ~/src/lib &
+  vscode-helper search --content TODO --dir ~/src/app   # served by the daemon
+
+A fresh saved index of a dir is used as it is; otherwise the dir is indexed
+first, as by the index command, and the index saved as it changes.
+
+While the daemon runs, search and the MCP server's search_files send their
+searches to it instead of walking the tree themselves, with the results
+they would have found; --no-daemon (-no-daemon for the MCP server) turns
+this off. Searches of directories the daemon has no index of are walked by
+the daemon, which still spares starting a process.
+
+The socket takes the requests of serve --socket, as newline-delimited JSON.
+Paths in their args are relative to the directory the daemon was started
+in. A request may also carry a search as the CLI sends it:
+
+  {"id": 1, "search": {"dirs": ["/abs/dir"], "options": {"Contents": ["TODO"]}}}
+
+answered with a line per match, {"id": 1, "match": {...}}, lines of
+progress, and a last line with the "summary" or an "error".
+
+--debug-addr localhost:6060 serves the net/http/pprof profiles at
+/debug/pprof/ and, at /debug/stats, runtime stats as JSON: goroutines,
+memory, and the entries and trigrams of each index in memory:
+
+  go tool pprof http://localhost:6060/debug/pprof/heap`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		return runDaemon(cmd.Context(), daemonOpts, args, cmd.ErrOrStderr())
+	},
+}
+
+// runDaemon keeps the indexes of dirs in memory and serves requests until
+// SIGINT or SIGTERM.
+func runDaemon(ctx context.Context, o daemonOptions, dirs []string, stderr io.Writer) error {
+	socket := o.Socket
+	if socket == "" {
+		var err error
+		if socket, err = daemon.SocketPath(); err != nil {
+			return err
+		}
+	}
+	if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on '%s'", socket)
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return err
+	}
+
+	log := newLogger(stderr)
+	if o.DebugAddr != "" {
+		addr, err := debug.Start(o.DebugAddr, nil)
+		if err != nil {
+			return fmt.Errorf("unable to serve debug endpoints: %w", err)
+		}
+		log.Info("serving debug endpoints", "url", "http://"+addr.String()+"/debug/")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for _, dir := range dirs {
+		if err := allowed.Check(dir); err != nil {
+			return err
+		}
+		ix, err := daemonIndex(o, dir, log)
+		if err != nil {
+			return err
+		}
+		// Searches read the copy kept; the watch updates ix and keeps a
+		// new copy after each change
+		index.Keep(ix.Clone())
+		go func() {
+			err := ix.Watch(ctx, func(changes int, err error) {
+				if err != nil {
+					log.Error("updating index failed", "dir", ix.Root, "err", err)
+					return
+				}
+				index.Keep(ix.Clone())
+				log.Debug("updated index", "dir", ix.Root, "changed_paths", changes)
+			})
+			if err != nil {
+				log.Error("unable to watch for changes", "dir", ix.Root, "err", err)
+			}
+		}()
+	}
+	return serveUnix(socket, stderr)
+}
+
+// daemonIndex returns the saved index of dir when it is fresh, and
+// otherwise builds and saves a new one.
+func daemonIndex(o daemonOptions, dir string, log *slog.Logger) (*index.Index, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	ix, err := index.Load(dir)
+	if err == nil && ix.Root == abs && ix.Fresh(dir) && (ix.Postings != nil || !o.Trigrams) {
+		log.Info("loaded index", "dir", ix.Root, "entries", len(ix.Entries))
+		return ix, nil
+	}
+	start := time.Now()
+	if ix, err = index.Build(dir, index.BuildOptions{Trigrams: o.Trigrams}); err != nil {
+		return nil, err
+	}
+	if err := ix.Save(); err != nil {
+		return nil, fmt.Errorf("unable to save index: %w", err)
+	}
+	log.Info("indexed", "dir", ix.Root, "entries", len(ix.Entries), "elapsed", time.Since(start).Round(time.Millisecond))
+	return ix, nil
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	addDaemonFlags(daemonCmd.Flags(), &daemonOpts)
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/gopls"
+	"vscode-helper-file-find/internal/opener"
+)
+
+// definitionOptions holds the flag values for a single definition
+// invocation.
+type definitionOptions struct {
+	Dir    string
+	Open   bool
+	Output string
+}
+
+var definitionOpts definitionOptions
+
+// addDefinitionFlags registers the definition flags on fs, bound to o.
+func addDefinitionFlags(fs *pflag.FlagSet, o *definitionOptions) {
+	fs.StringVarP(&o.Dir, "dir", "d", ".", "Directory in the Go module or workspace to look a name up in")
+	fs.BoolVar(&o.Open, "open", false, "Open the definition in VS Code at its position")
+	fs.StringVarP(&o.Output, "output", "o", "text", "Output format: text or json")
+}
+
+var definitionCmd = &cobra.Command{
+	Use:   "definition <file:line:column | name>",
+	Short: "Find where a Go identifier is defined, with gopls",
+	Long: `Find where the Go identifier at file:line:column is defined, or where the
+functions, methods, and types called name are, by asking gopls, which
+must be installed. The column counts bytes, as the Go compiler's do.
+
+  vscode-helper definition internal/search/search.go:365:12 --open
+  vscode-helper definition Options.Validate
+  vscode-helper definition SearchContext -o json
+
+A name is looked up in the workspace of --dir, matching the name itself or
+Type.Name for a method. --open opens the definition in VS Code at its
+line and column, when exactly one is found.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDefinition(cmd.Context(), definitionOpts, args[0], cmd.OutOrStdout())
+	},
+}
+
+// positionArg matches a file:line:column argument.
+var positionArg = regexp.MustCompile(`^(.+):(\d+):(\d+)$`)
+
+// runDefinition prints, and with o.Open opens, the definitions of arg.
+func runDefinition(ctx context.Context, o definitionOptions, arg string, stdout io.Writer) error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", o.Output)
+	}
+	var locs []gopls.Location
+	if m := positionArg.FindStringSubmatch(arg); m != nil {
+		if err := allowed.Check(m[1]); err != nil {
+			return err
+		}
+		line, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		loc, err := gopls.Definition(ctx, m[1], line, column)
+		if err != nil {
+			return err
+		}
+		locs = []gopls.Location{*loc}
+	} else {
+		if err := allowed.Check(o.Dir); err != nil {
+			return err
+		}
+		found, err := gopls.Symbols(ctx, o.Dir, arg)
+		if err != nil {
+			return err
+		}
+		for _, l := range found {
+			if allowed.Allows(l.Path) {
+				locs = append(locs, l)
+			}
+		}
+	}
+
+	if o.Output == "json" {
+		if locs == nil {
+			locs = []gopls.Location{}
+		}
+		b, err := json.MarshalIndent(locs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+	} else if !o.Open || len(locs) > 1 {
+		for _, l := range locs {
+			where := fmt.Sprintf("%s:%d:%d", displayPath(".", l.Path), l.Line, l.Column)
+			switch {
+			case l.Name != "":
+				fmt.Fprintf(stdout, "%s: %s %s\n", where, l.Kind, l.Name)
+			case l.Description != "":
+				fmt.Fprintf(stdout, "%s: %s\n", where, strings.SplitN(l.Description, "\n", 2)[0])
+			default:
+				fmt.Fprintln(stdout, where)
+			}
+		}
+	}
+	if len(locs) == 0 {
+		return errNoMatches
+	}
+	if !o.Open {
+		return nil
+	}
+	if len(locs) > 1 {
+		return fmt.Errorf("%d definitions of '%s' found; name one as Type.Method, or give its position, to open it", len(locs), arg)
+	}
+	editor, err := opener.NewEditor(editorSpec)
+	if err != nil {
+		return err
+	}
+	l := locs[0]
+	abs, err := opener.Open(l.Path, opener.Options{Line: l.Line, Column: l.Column, Editor: editor, Check: allowed.Check, Record: recordOpen})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Opened in VS Code: %s:%d:%d\n", abs, l.Line, l.Column)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(definitionCmd)
+	addDefinitionFlags(definitionCmd.Flags(), &definitionOpts)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/dupes"
+	"vscode-helper-file-find/internal/opener"
+	"vscode-helper-file-find/internal/search"
+)
+
+// dupesOptions holds the flag values for a single dupes invocation.
+type dupesOptions struct {
+	Include  []string
+	Exclude  []string
+	NoIgnore bool
+	MinSize  string
+	MaxSize  string
+	Open     int
+	Output   string
+}
+
+var dupesOpts dupesOptions
+
+// addDupesFlags registers the dupes flags on fs, bound to o.
+func addDupesFlags(fs *pflag.FlagSet, o *dupesOptions) {
+	fs.StringSliceVarP(&o.Include, "include", "I", nil, "Only compare files matching this name pattern, as search --name takes it (repeatable)")
+	fs.StringArrayVar(&o.Exclude, "exclude", nil, "Skip files and directories matching this glob (repeatable, e.g. 'testdata/**')")
+	fs.BoolVar(&o.NoIgnore, "no-ignore", false, "Don't respect .gitignore, .ignore, or global git excludes")
+	fs.StringVar(&o.MinSize, "min-size", "", "Only compare files of at least this size (e.g. 10K, 1M)")
+	fs.StringVar(&o.MaxSize, "max-size", "", "Only compare files of at most this size (e.g. 500K)")
+	fs.IntVar(&o.Open, "open", 0, "Open the files of the group with this number, as listed, in VS Code")
+	fs.StringVarP(&o.Output, "output", "o", "text", "Output format: text or json")
+}
+
+var dupesCmd = &cobra.Command{
+	Use:   "dupes [dir]",
+	Short: "Find files with identical contents",
+	Long: `Find the files below dir (default .) whose contents are identical and list
+them in numbered groups, those wasting the most space first. Files are
+compared by size first, and only those sharing a size are read and hashed
+with SHA-256, so a large tree is cheap to check. Empty files are not
+compared.
+
+  vscode-helper dupes --include '*.png' --min-size 10K
+  vscode-helper dupes --open 1
+
+--open opens the files of one group in VS Code, to compare and clean them
+up by hand. As search does, dupes skips .git and the files .gitignore
+excludes, unless --no-ignore is given, and the config's excludes apply
+unless --exclude is given. It exits with status 1 when nothing is
+duplicated.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if err := applyDupesConfig(cmd.Flags(), &dupesOpts, dir); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runDupes(ctx, dupesOpts, dir, cmd.OutOrStdout())
+	},
+}
+
+// runDupes prints, and with o.Open opens, the groups of duplicate files in
+// dir.
+func runDupes(ctx context.Context, o dupesOptions, dir string, stdout io.Writer) error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", o.Output)
+	}
+	if o.Open < 0 {
+		return fmt.Errorf("invalid --open %d (groups are numbered from 1)", o.Open)
+	}
+	if info, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("directory '%s' does not exist", dir)
+	} else if err == nil && !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+	if err := allowed.Check(dir); err != nil {
+		return err
+	}
+	opts := search.Options{Dir: dir, Names: o.Include, Excludes: o.Exclude, NoIgnore: o.NoIgnore}
+	var err error
+	if o.MinSize != "" {
+		if opts.MinSize, err = search.ParseSize(o.MinSize); err != nil {
+			return fmt.Errorf("--min-size: %w", err)
+		}
+	}
+	if o.MaxSize != "" {
+		if opts.MaxSize, err = search.ParseSize(o.MaxSize); err != nil {
+			return fmt.Errorf("--max-size: %w", err)
+		}
+	}
+	if allowed != nil {
+		opts.Allow = allowed.Allows
+	}
+	res, err := dupes.Find(ctx, dupes.Options{Search: opts})
+	if err != nil {
+		return err
+	}
+
+	if o.Open > 0 {
+		if o.Open > len(res.Groups) {
+			return fmt.Errorf("no group %d (found %d)", o.Open, len(res.Groups))
+		}
+		editor, err := opener.NewEditor(editorSpec)
+		if err != nil {
+			return err
+		}
+		for _, p := range res.Groups[o.Open-1].Paths {
+			abs, err := opener.Open(filepath.Join(dir, p), opener.Options{ReuseWindow: true, Editor: editor, Check: allowed.Check, Record: recordOpen})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "Opened in VS Code: %s\n", abs)
+		}
+		return nil
+	}
+	if o.Output == "json" {
+		b, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+	} else {
+		for i, g := range res.Groups {
+			fmt.Fprintf(stdout, "[%d] %d files of %s (sha256 %s)\n", i+1, len(g.Paths), plural(int(g.Size), "byte", "bytes"), g.Hash[:12])
+			for _, p := range g.Paths {
+				fmt.Fprintf(stdout, "  %s\n", filepath.Join(dir, p))
+			}
+		}
+		if len(res.Groups) > 0 {
+			fmt.Fprintf(stdout, "\n%s of %s compared, %s wasted\n", plural(len(res.Groups), "group", "groups"), plural(res.Files, "file", "files"), plural(int(res.Wasted), "byte", "bytes"))
+		}
+	}
+	if len(res.Groups) == 0 {
+		return errNoMatches
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(dupesCmd)
+	addDupesFlags(dupesCmd.Flags(), &dupesOpts)
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/git"
+	"vscode-helper-file-find/internal/opener"
+)
+
+// gitChangedOptions holds the flag values for a single git changed
+// invocation.
+type gitChangedOptions struct {
+	Dir      string
+	Against  string
+	NameOnly bool
+	Open     bool
+	Output   string
+}
+
+var gitChangedOpts gitChangedOptions
+
+// maxOpenChanged is the most files git changed --open hands to the editor
+// in one go.
+const maxOpenChanged = 50
+
+// addGitChangedFlags registers the git changed flags on fs, bound to o.
+func addGitChangedFlags(fs *pflag.FlagSet, o *gitChangedOptions) {
+	fs.StringVarP(&o.Dir, "dir", "d", ".", "Only list changed files below this directory")
+	fs.StringVar(&o.Against, "against", "", "List the files changed since the current branch left this branch or commit (e.g. main), not just uncommitted ones")
+	fs.BoolVar(&o.NameOnly, "name-only", false, "Print only the paths, without their status")
+	fs.BoolVar(&o.Open, "open", false, "Open the changed files in VS Code (deleted ones aside)")
+	fs.StringVarP(&o.Output, "output", "o", "text", "Output format: text or json")
+}
+
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Work with the files git reports as changed",
+}
+
+var gitChangedCmd = &cobra.Command{
+	Use:   "changed",
+	Short: "List or open the files changed in the git work tree",
+	Long: `List the files that differ from the last commit, whether staged or not,
+along with new files git does not ignore, with their status: added,
+modified, deleted, or untracked. A renamed file is listed as deleted under
+its old name and added under its new one.
+
+With --against REF the list covers everything changed since the current
+branch left REF, as "git diff REF..." does, plus the uncommitted changes,
+which is the surface a pull request against REF would show:
+
+  vscode-helper git changed --against main
+  vscode-helper git changed --against main --open
+
+search --changed searches the same files.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGitChanged(cmd.Context(), gitChangedOpts, cmd.OutOrStdout())
+	},
+}
+
+// runGitChanged prints, and with o.Open opens, the files changed below
+// o.Dir. Paths are printed as search prints them: joined to o.Dir.
+func runGitChanged(ctx context.Context, o gitChangedOptions, stdout io.Writer) error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", o.Output)
+	}
+	if _, err := os.Stat(o.Dir); os.IsNotExist(err) {
+		return fmt.Errorf("directory '%s' does not exist", o.Dir)
+	}
+	if err := allowed.Check(o.Dir); err != nil {
+		return err
+	}
+	changes, err := git.Changed(ctx, o.Dir, o.Against)
+	if err != nil {
+		return err
+	}
+	for i := range changes {
+		changes[i].Path = displayPath(o.Dir, changes[i].Path)
+	}
+	if o.Open {
+		var open []string
+		for _, c := range changes {
+			if c.Status != git.Deleted {
+				open = append(open, c.Path)
+			}
+		}
+		if len(open) > maxOpenChanged {
+			return fmt.Errorf("%d files changed, more than the %d --open opens; narrow them with --dir", len(open), maxOpenChanged)
+		}
+		editor, err := opener.NewEditor(editorSpec)
+		if err != nil {
+			return err
+		}
+		for _, path := range open {
+			abs, err := opener.Open(path, opener.Options{ReuseWindow: true, Editor: editor, Check: allowed.Check, Record: recordOpen})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "Opened in VS Code: %s\n", abs)
+		}
+		return nil
+	}
+	if o.Output == "json" {
+		if changes == nil {
+			changes = []git.Change{}
+		}
+		b, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+		return nil
+	}
+	for _, c := range changes {
+		if o.NameOnly {
+			fmt.Fprintln(stdout, c.Path)
+		} else {
+			fmt.Fprintf(stdout, "%-9s  %s\n", c.Status, c.Path)
+		}
+	}
+	if len(changes) == 0 {
+		return errNoMatches
+	}
+	return nil
+}
+
+// changedFiles returns the files changed below dirs, as git changed lists
+// them with against, leaving out deleted ones.
+func changedFiles(ctx context.Context, dirs []string, against string) ([]string, error) {
+	files := []string{}
+	for _, dir := range dirs {
+		changes, err := git.Changed(ctx, dir, against)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range changes {
+			if c.Status != git.Deleted {
+				files = append(files, c.Path)
+			}
+		}
+	}
+	return files, nil
+}
+
+// runHistory prints the lines that commits added or removed carrying the
+// single content term, newest commit first, for search --history.
+func runHistory(ctx context.Context, o searchOptions, terms []string, stdout, stderr io.Writer) error {
+	if len(terms) != 1 {
+		return errors.New("--history takes exactly one --content term")
+	}
+	if len(o.Name) > 0 || len(o.NotContent) > 0 || o.All || o.Changed != "" || o.Interactive {
+		return errors.New("--history cannot be combined with --name, --not-content, --all, --changed, or --interactive")
+	}
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", o.Output)
+	}
+	q := git.HistoryQuery{Term: terms[0], Regex: o.Regex, IgnoreCase: o.caseMode().Folds(terms, o.Regex), MaxResults: o.MaxResults}
+	log := newLogger(stderr)
+	log.Info("searching history", "dir", strings.Join(o.Dirs, ","))
+
+	var matches []git.HistoryMatch
+	lastCommit := ""
+	for _, dir := range o.Dirs {
+		if o.MaxResults > 0 {
+			q.MaxResults = o.MaxResults - len(matches)
+			if q.MaxResults == 0 {
+				break
+			}
+		}
+		err := git.History(ctx, dir, q, func(m git.HistoryMatch) {
+			m.Path = displayPath(dir, m.Path)
+			matches = append(matches, m)
+			if o.Output != "text" {
+				return
+			}
+			if m.Commit != lastCommit {
+				fmt.Fprintf(stdout, "%s %s %s: %s\n", m.Commit[:min(len(m.Commit), 12)], m.Date.Format(time.DateOnly), m.Author, m.Subject)
+				lastCommit = m.Commit
+			}
+			sign := "+"
+			if m.Change == git.LineRemoved {
+				sign = "-"
+			}
+			fmt.Fprintf(stdout, "  %s:%d: %s %s\n", m.Path, m.Line, sign, m.Text)
+		})
+		if err != nil {
+			return fmt.Errorf("history search failed: %w", err)
+		}
+	}
+	if o.Output == "json" {
+		if matches == nil {
+			matches = []git.HistoryMatch{}
+		}
+		b, err := json.MarshalIndent(matches, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("search %w; results are partial (%d lines found)", errCancelled, len(matches))
+	}
+	if len(matches) == 0 {
+		log.Info("no matches found")
+		return errNoMatches
+	}
+	return nil
+}
+
+// displayPath returns the absolute path below dir as dir joined with its
+// path relative to dir, the way search reports the files it finds.
+func displayPath(dir, path string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(abs, path)
+	if err != nil {
+		return path
+	}
+	return filepath.Join(dir, rel)
+}
+
+func init() {
+	rootCmd.AddCommand(gitCmd)
+	gitCmd.AddCommand(gitChangedCmd)
+	addGitChangedFlags(gitChangedCmd.Flags(), &gitChangedOpts)
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/location"
+	"vscode-helper-file-find/internal/opener"
+	"vscode-helper-file-find/internal/search"
+)
+
+// gotoOptions holds the flag values for a single goto invocation.
+type gotoOptions struct {
+	Dir   string
+	Print bool
+}
+
+var gotoOpts gotoOptions
+
+// maxGotoCandidates is how many of the files a bare name matches an
+// ambiguous goto lists.
+const maxGotoCandidates = 5
+
+// addGotoFlags registers the goto flags on fs, bound to o.
+func addGotoFlags(fs *pflag.FlagSet, o *gotoOptions) {
+	fs.StringVarP(&o.Dir, "dir", "d", ".", "Directory to look for a relative path in, and below, when it is not found from the working directory")
+	fs.BoolVarP(&o.Print, "print", "p", false, "Print the position found instead of opening it")
+}
+
+var gotoCmd = &cobra.Command{
+	Use:   "goto <path:line[:column] | ->",
+	Short: "Open VS Code at a position pasted from compiler or test output",
+	Long: `Open VS Code at the position in a line of compiler, go vet, or go test
+output, or of a panic trace, pasted as it is:
+
+  vscode-helper goto "pkg/server/handler.go:87:12: undefined: foo"
+  vscode-helper goto "    handler_test.go:42: got 1, want 2"
+  go build ./... 2>&1 | vscode-helper goto -
+
+With - the position is read from the first line of standard input that
+names a file. A relative path is looked for from the working directory,
+then from --dir; go test prints paths relative to the package, so failing
+that, the file below --dir whose path ends with it is used, if there is
+only one.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGoto(cmd.Context(), gotoOpts, args[0], cmd.InOrStdin(), cmd.OutOrStdout())
+	},
+}
+
+// runGoto opens, or with o.Print prints, the position in arg, or in the
+// lines of stdin when arg is "-".
+func runGoto(ctx context.Context, o gotoOptions, arg string, stdin io.Reader, stdout io.Writer) error {
+	var (
+		loc location.Location
+		err error
+	)
+	if arg == "-" {
+		err = errors.New("no position found in standard input")
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			if l, lerr := resolveLocation(ctx, scanner.Text(), o.Dir); lerr == nil {
+				loc, err = l, nil
+				break
+			}
+		}
+		if serr := scanner.Err(); serr != nil && err != nil {
+			return serr
+		}
+	} else {
+		loc, err = resolveLocation(ctx, arg, o.Dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	pos := fmt.Sprintf("%s:%d", loc.Path, loc.Line)
+	if loc.Column > 0 {
+		pos += fmt.Sprintf(":%d", loc.Column)
+	}
+	if o.Print {
+		fmt.Fprintln(stdout, pos)
+		return nil
+	}
+	editor, err := opener.NewEditor(editorSpec)
+	if err != nil {
+		return err
+	}
+	abs, err := opener.Open(loc.Path, opener.Options{Line: loc.Line, Column: loc.Column, Editor: editor, Check: allowed.Check, Record: recordOpen})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Opened in VS Code: %s%s\n", abs, strings.TrimPrefix(pos, loc.Path))
+	return nil
+}
+
+// resolveLocation returns the first position in s whose file can be
+// found, as goto looks for it, with its path made usable from the working
+// directory.
+func resolveLocation(ctx context.Context, s, dir string) (location.Location, error) {
+	locs := location.Parse(s)
+	if len(locs) == 0 {
+		return location.Location{}, fmt.Errorf("no path:line position in '%s'", strings.TrimSpace(s))
+	}
+	var ambiguous error
+	for _, loc := range locs {
+		path, err := findFile(ctx, loc.Path, dir)
+		if err != nil {
+			if ambiguous == nil && !errors.Is(err, os.ErrNotExist) {
+				ambiguous = err
+			}
+			continue
+		}
+		loc.Path = path
+		return loc, nil
+	}
+	if ambiguous != nil {
+		return location.Location{}, ambiguous
+	}
+	return location.Location{}, fmt.Errorf("'%s' does not exist", locs[0].Path)
+}
+
+// findFile returns the file path names: path itself, path in dir, or the
+// one file below dir whose path ends with it. The error wraps
+// os.ErrNotExist when there is none.
+func findFile(ctx context.Context, path, dir string) (string, error) {
+	isFile := func(p string) bool {
+		info, err := os.Stat(p)
+		return err == nil && info.Mode().IsRegular()
+	}
+	if isFile(path) {
+		return path, nil
+	}
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("'%s': %w", path, os.ErrNotExist)
+	}
+	if p := filepath.Join(dir, path); isFile(p) {
+		return p, nil
+	}
+
+	suffix := string(filepath.Separator) + filepath.Clean(path)
+	opts := search.Options{Dir: dir, Names: []string{filepath.Base(path)}}
+	if allowed != nil {
+		opts.Allow = allowed.Allows
+	}
+	var found []string
+	if _, err := search.SearchContext(ctx, opts, func(m search.Match) {
+		if m.Kind == search.NameMatch && strings.HasSuffix(string(filepath.Separator)+filepath.Clean(m.Path), suffix) {
+			found = append(found, m.Path)
+		}
+	}); err != nil {
+		return "", err
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("'%s': %w", path, os.ErrNotExist)
+	case 1:
+		return found[0], nil
+	}
+	sort.Strings(found)
+	list := found[:min(len(found), maxGotoCandidates)]
+	more := ""
+	if len(found) > len(list) {
+		more = fmt.Sprintf(", and %d more", len(found)-len(list))
+	}
+	return "", fmt.Errorf("'%s' matches %d files below '%s': %s%s; give more of its path", path, len(found), dir, strings.Join(list, ", "), more)
+}
+
+func init() {
+	rootCmd.AddCommand(gotoCmd)
+	addGotoFlags(gotoCmd.Flags(), &gotoOpts)
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	helperv1 "vscode-helper-file-find/api/helper/v1"
+	"vscode-helper-file-find/internal/daemon"
+	"vscode-helper-file-find/internal/files"
+	"vscode-helper-file-find/internal/opener"
+	"vscode-helper-file-find/internal/sandbox"
+	"vscode-helper-file-find/internal/search"
+)
+
+// grpcServer implements the Helper service of api/helper/v1 with the same
+// packages, config, and --allow-dir confinement as the commands.
+type grpcServer struct {
+	helperv1.UnimplementedHelperServer
+}
+
+// serveGRPC serves the gRPC API on addr, a TCP host:port or unix:PATH,
+// until interrupted.
+func serveGRPC(addr string, logw io.Writer) error {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, addr = "unix", path
+		// Remove a stale socket left behind by a previous run
+		if st, err := os.Lstat(path); err == nil && st.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(path)
+		}
+		defer os.Remove(path)
+	}
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer()
+	helperv1.RegisterHelperServer(srv, grpcServer{})
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	go func() {
+		<-stop
+		srv.GracefulStop()
+	}()
+
+	fmt.Fprintf(logw, "Serving the gRPC API on %s %s\n", network, ln.Addr())
+	return srv.Serve(ln)
+}
+
+// grpcError returns err as a gRPC status: PermissionDenied outside the
+// allowed directories, NotFound for missing files, and Unknown otherwise.
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, sandbox.ErrOutside):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, fs.ErrNotExist):
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+// checkPath checks that path is allowed and exists.
+func checkPath(path string) error {
+	if err := allowed.Check(path); err != nil {
+		return grpcError(err)
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return status.Errorf(codes.NotFound, "'%s' does not exist", path)
+	}
+	return nil
+}
+
+// Search runs the search in req, in the daemon when one is running, and
+// streams its matches and then its summary.
+func (grpcServer) Search(req *helperv1.SearchRequest, stream helperv1.Helper_SearchServer) error {
+	ctx := stream.Context()
+	dir := req.GetDirectory()
+	start := dir
+	if start == "" {
+		start = "."
+	}
+	c, err := cfg.ForDir(start)
+	if err != nil {
+		return grpcError(err)
+	}
+	if dir == "" {
+		dir = c.Dir
+	}
+	if dir == "" {
+		dir = "."
+	}
+	lines := int(req.GetContextLines())
+	opts := search.Options{
+		Dir: dir, Names: req.GetNames(), Contents: req.GetContents(), Regex: req.GetRegex(), Word: req.GetWord(),
+		Before: lines, After: lines, Excludes: req.GetExclude(), NoIgnore: req.GetNoIgnore(),
+		Types: req.GetTypes(), TypeDefs: c.Types, Fuzzy: req.GetFuzzy(), Sort: req.GetSort(),
+		MaxResults: int(req.GetMaxResults()), Jobs: c.Jobs,
+	}
+	if opts.Excludes == nil {
+		opts.Excludes = c.Exclude
+	}
+	if opts.MaxResults == 0 {
+		opts.MaxResults = c.MaxResults
+	}
+	switch {
+	case req.GetIgnoreCase() && req.GetCaseSensitive():
+		return status.Error(codes.InvalidArgument, "ignore_case and case_sensitive cannot both be set")
+	case req.GetIgnoreCase():
+		opts.Case = search.IgnoreCase
+	case req.GetCaseSensitive():
+		opts.Case = search.CaseSensitive
+	}
+	if err := checkPath(dir); err != nil {
+		return err
+	}
+	if allowed != nil {
+		opts.Allow = allowed.Allows
+	}
+	if err := opts.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	engine := search.Native
+	if d := daemon.Detect(allowed); d != nil {
+		engine = d
+	}
+	var sendErr error
+	sum, err := engine.Search(ctx, opts, []string{dir}, func(m search.Match) {
+		if sendErr == nil {
+			sendErr = stream.Send(&helperv1.SearchResponse{Result: &helperv1.SearchResponse_Match{Match: &helperv1.Match{
+				Path: m.Path, Line: int32(m.Line), Column: int32(m.Column), Kind: m.Kind.String(),
+				Text: m.Text, MatchedText: m.MatchedText, Score: int32(m.Score),
+			}}})
+		}
+	})
+	switch {
+	case err != nil:
+		return grpcError(err)
+	case sendErr != nil:
+		return sendErr
+	case sum.Cancelled:
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return stream.Send(&helperv1.SearchResponse{Result: &helperv1.SearchResponse_Summary{Summary: &helperv1.Summary{
+		Files: int32(sum.Files), Scanned: int32(sum.Scanned), BinarySkipped: int32(sum.BinarySkipped),
+		LargeSkipped: int32(sum.LargeSkipped), Truncated: sum.Truncated,
+	}}})
+}
+
+// Open opens req's path in the editor.
+func (grpcServer) Open(ctx context.Context, req *helperv1.OpenRequest) (*helperv1.OpenResponse, error) {
+	editor, err := opener.NewEditor(editorSpec)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err := checkPath(req.GetPath()); err != nil {
+		return nil, err
+	}
+	abs, err := opener.OpenContext(ctx, req.GetPath(), opener.Options{
+		Line: int(req.GetLine()), Column: int(req.GetColumn()), NewWindow: req.GetNewWindow(), ReuseWindow: req.GetReuseWindow(),
+		Editor: editor, Check: allowed.Check, Record: recordOpen,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &helperv1.OpenResponse{Path: abs}, nil
+}
+
+// Read returns the part of the file req selects.
+func (grpcServer) Read(ctx context.Context, req *helperv1.ReadRequest) (*helperv1.ReadResponse, error) {
+	if err := checkPath(req.GetPath()); err != nil {
+		return nil, err
+	}
+	res, err := files.Read(req.GetPath(), files.ReadOptions{StartLine: int(req.GetStartLine()), EndLine: int(req.GetEndLine()), MaxBytes: int(req.GetMaxBytes())})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &helperv1.ReadResponse{Path: res.Path, Content: res.Content, StartLine: int32(res.StartLine), EndLine: int32(res.EndLine), Truncated: res.Truncated}, nil
+}
+
+// ListDir lists the entries below req's directory.
+func (grpcServer) ListDir(ctx context.Context, req *helperv1.ListDirRequest) (*helperv1.ListDirResponse, error) {
+	dir := req.GetPath()
+	if dir == "" {
+		dir = "."
+	}
+	if err := checkPath(dir); err != nil {
+		return nil, err
+	}
+	res, err := files.List(dir, files.ListOptions{Depth: int(req.GetDepth()), MaxEntries: int(req.GetMaxEntries())})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &helperv1.ListDirResponse{Path: res.Path, Truncated: res.Truncated}
+	for _, e := range res.Entries {
+		resp.Entries = append(resp.Entries, &helperv1.Entry{Path: e.Path, Type: e.Type, Size: e.Size, Mtime: timestamppb.New(e.ModTime)})
+	}
+	return resp, nil
+}
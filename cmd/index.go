@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/index"
+)
+
+// indexOptions holds the flag values for a single index invocation.
+type indexOptions struct {
+	Trigrams bool
+	Status   bool
+	Remove   bool
+	Watch    bool
+}
+
+var indexOpts indexOptions
+
+// addIndexFlags registers the index flags on fs, bound to o.
+func addIndexFlags(fs *pflag.FlagSet, o *indexOptions) {
+	fs.BoolVar(&o.Trigrams, "trigrams", false, "Also index file contents to speed up content searches")
+	fs.BoolVar(&o.Status, "status", false, "Report on the index covering the directory instead of building one")
+	fs.BoolVar(&o.Remove, "remove", false, "Delete the index of the directory")
+	fs.BoolVar(&o.Watch, "watch", false, "After building, keep the index current as files change until interrupted")
+}
+
+var indexCmd = &cobra.Command{
+	Use:   "index [dir]",
+	Short: "Build an on-disk file index to speed up searches",
+	Long: `Build an index of a directory tree so that search does not have to walk it.
+
+The index records every file and directory below dir (except .git) with its
+size and modification time, and is stored under ~/.cache/vscode-helper.
+With --trigrams it also records which three-byte sequences each file
+contains, letting content searches for literal terms skip files that cannot
+match.
+
+search uses the index covering its --dir whenever no directory in it has
+changed since the index was built, and falls back to walking the tree
+otherwise. Files edited since then are always rescanned. Rerun index to
+refresh it, or pass --no-index to search to bypass it.
+
+With --watch the command keeps running after the build, watching the tree
+for changes and updating the saved index within a fraction of a second, so
+searches (including those from the MCP server) keep using it.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if err := applyIndexConfig(cmd.Flags(), &indexOpts, dir); err != nil {
+			return err
+		}
+		return runIndex(indexOpts, dir, cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+// runIndex builds, reports on, or removes the index of dir.
+func runIndex(o indexOptions, dir string, stdout, stderr io.Writer) error {
+	if err := allowed.Check(dir); err != nil {
+		return err
+	}
+	switch {
+	case o.Remove:
+		if err := index.Remove(dir); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Removed index of %s\n", dir)
+	case o.Status:
+		ix, err := index.Load(dir)
+		if errors.Is(err, index.ErrNotFound) {
+			fmt.Fprintf(stdout, "No index covers %s\n", dir)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		state := "stale"
+		if ix.Fresh(dir) {
+			state = "fresh"
+		}
+		fmt.Fprintf(stdout, "Index of %s: %d entries, trigrams: %t, built %s, %s\n",
+			ix.Root, len(ix.Entries), ix.Postings != nil, ix.Built.Format(time.RFC3339), state)
+	default:
+		start := time.Now()
+		ix, err := index.Build(dir, index.BuildOptions{Trigrams: o.Trigrams})
+		if err != nil {
+			return err
+		}
+		if err := ix.Save(); err != nil {
+			return fmt.Errorf("unable to save index: %w", err)
+		}
+		fmt.Fprintf(stdout, "Indexed %d entries in %s in %s\n", len(ix.Entries), ix.Root, time.Since(start).Round(time.Millisecond))
+		if o.Watch {
+			return watchIndex(ix, stderr)
+		}
+	}
+	return nil
+}
+
+// watchIndex keeps ix up to date until SIGINT or SIGTERM.
+func watchIndex(ix *index.Index, stderr io.Writer) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log := newLogger(stderr)
+	log.Info("watching for changes (Ctrl-C to stop)", "dir", ix.Root)
+	err := ix.Watch(ctx, func(changes int, err error) {
+		if err != nil {
+			log.Error("updating index failed", "err", err)
+			return
+		}
+		log.Info("updated index", "changed_paths", changes)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to watch %s: %w", ix.Root, err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	addIndexFlags(indexCmd.Flags(), &indexOpts)
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"vscode-helper-file-find/internal/files"
+	"vscode-helper-file-find/internal/opener"
+	"vscode-helper-file-find/internal/search"
+)
+
+// previewContext is how many lines around a content match the picker's
+// preview shows on each side.
+const previewContext = 5
+
+// matchesMsg delivers results to the picker as the search finds them.
+type matchesMsg []search.Match
+
+// searchDoneMsg reports that the search has finished.
+type searchDoneMsg struct {
+	sum search.Summary
+	err error
+}
+
+// picker is the bubbletea model behind search --interactive: a filterable
+// list of matches with a preview of the selected one.
+type picker struct {
+	column bool
+
+	all      []search.Match
+	visible  []int // indexes into all that pass the filter
+	filter   string
+	cursor   int // position in visible
+	top      int // first visible row shown
+	done     bool
+	sum      search.Summary
+	err      error
+	chosen   *search.Match
+	width    int
+	height   int
+	previews map[int][]string
+}
+
+func newPicker(column bool) *picker {
+	return &picker{column: column, width: 80, height: 24, previews: make(map[int][]string)}
+}
+
+func (p *picker) Init() tea.Cmd { return nil }
+
+func (p *picker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		if msg.Width > 0 && msg.Height > 0 {
+			p.width, p.height = msg.Width, msg.Height
+		}
+	case matchesMsg:
+		for _, m := range msg {
+			p.all = append(p.all, m)
+			if p.keep(m) {
+				p.visible = append(p.visible, len(p.all)-1)
+			}
+		}
+	case searchDoneMsg:
+		p.done, p.sum, p.err = true, msg.sum, msg.err
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyEsc:
+			return p, tea.Quit
+		case tea.KeyEnter:
+			if len(p.visible) > 0 {
+				m := p.all[p.visible[p.cursor]]
+				p.chosen = &m
+			}
+			return p, tea.Quit
+		case tea.KeyUp, tea.KeyCtrlP, tea.KeyCtrlK:
+			p.move(-1)
+		case tea.KeyDown, tea.KeyCtrlN, tea.KeyCtrlJ:
+			p.move(1)
+		case tea.KeyPgUp:
+			p.move(-p.listHeight())
+		case tea.KeyPgDown:
+			p.move(p.listHeight())
+		case tea.KeyBackspace:
+			if r := []rune(p.filter); len(r) > 0 {
+				p.setFilter(string(r[:len(r)-1]))
+			}
+		case tea.KeyCtrlU:
+			p.setFilter("")
+		case tea.KeyRunes, tea.KeySpace:
+			p.setFilter(p.filter + string(msg.Runes))
+		}
+	}
+	return p, nil
+}
+
+// keep reports whether m passes the current filter, a fuzzy query matched
+// against the match as displayed.
+func (p *picker) keep(m search.Match) bool {
+	_, ok := search.FuzzyScore(p.filter, m.Format(p.column), search.SmartCase)
+	return ok
+}
+
+func (p *picker) setFilter(f string) {
+	p.filter = f
+	p.visible = p.visible[:0]
+	for i, m := range p.all {
+		if p.keep(m) {
+			p.visible = append(p.visible, i)
+		}
+	}
+	p.cursor, p.top = 0, 0
+}
+
+func (p *picker) move(delta int) {
+	p.cursor = max(0, min(p.cursor+delta, len(p.visible)-1))
+	if p.cursor < p.top {
+		p.top = p.cursor
+	}
+	if h := p.listHeight(); p.cursor >= p.top+h {
+		p.top = p.cursor - h + 1
+	}
+}
+
+// listHeight is the number of result rows; the rest of the screen holds the
+// prompt, the preview, and the help line.
+func (p *picker) listHeight() int {
+	return max(1, (p.height-3)/2)
+}
+
+func (p *picker) View() string {
+	var b strings.Builder
+	status := fmt.Sprintf("%d/%d", len(p.visible), len(p.all))
+	if !p.done {
+		status += " (searching...)"
+	} else if p.err != nil {
+		status += " (error: " + p.err.Error() + ")"
+	}
+	b.WriteString(p.fit("> "+p.filter+"  "+status) + "\n")
+
+	h := p.listHeight()
+	for row := p.top; row < p.top+h; row++ {
+		if row >= len(p.visible) {
+			b.WriteString("\n")
+			continue
+		}
+		line := p.fit("  " + p.all[p.visible[row]].Format(p.column))
+		if row == p.cursor {
+			line = "\x1b[7m" + line + "\x1b[0m" // reverse video
+		}
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString(p.fit(strings.Repeat("─", p.width)) + "\n")
+	preview := p.preview()
+	for i := 0; i < p.height-h-3; i++ {
+		if i < len(preview) {
+			b.WriteString(p.fit(preview[i]))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(p.fit("↑/↓ move • type to filter • enter open in VS Code • esc quit"))
+	return b.String()
+}
+
+// preview returns the lines around the selected match, read once and
+// cached.
+func (p *picker) preview() []string {
+	if len(p.visible) == 0 {
+		return nil
+	}
+	i := p.visible[p.cursor]
+	if lines, ok := p.previews[i]; ok {
+		return lines
+	}
+	m := p.all[i]
+	start, end := 1, p.height
+	if m.Line > 0 {
+		start, end = max(1, m.Line-previewContext), m.Line+previewContext
+	}
+	var lines []string
+	res, err := files.Read(m.Path, files.ReadOptions{StartLine: start, EndLine: end, MaxBytes: 64 << 10})
+	if err != nil {
+		lines = []string{"(" + err.Error() + ")"}
+	} else {
+		for n, text := range strings.Split(strings.TrimSuffix(res.Content, "\n"), "\n") {
+			mark := " "
+			if start+n == m.Line {
+				mark = ">"
+			}
+			lines = append(lines, fmt.Sprintf("%s%5d  %s", mark, start+n, strings.ReplaceAll(text, "\t", "    ")))
+		}
+	}
+	p.previews[i] = lines
+	return lines
+}
+
+// fit truncates s to the terminal width.
+func (p *picker) fit(s string) string {
+	if r := []rune(s); len(r) > p.width {
+		return string(r[:max(0, p.width-1)]) + "…"
+	}
+	return s
+}
+
+// runInteractive runs the search of dirs behind a terminal picker and opens the
+// chosen match in VS Code. Quitting without a choice is not an error unless
+// the search itself failed. The search is cancelled as soon as the picker
+// closes.
+func runInteractive(ctx context.Context, opts search.Options, dirs []string, column bool, stdout io.Writer) error {
+	editor, err := opener.NewEditor(editorSpec)
+	if err != nil {
+		return err
+	}
+	model := newPicker(column)
+	prog := tea.NewProgram(model, tea.WithAltScreen(), tea.WithInputTTY(), tea.WithOutput(stdout))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		// Deliver results in batches so huge result sets stay responsive
+		var batch matchesMsg
+		last := time.Now()
+		sum, err := search.SearchDirs(ctx, opts, dirs, func(m search.Match) {
+			if m.Kind == search.ContextLine {
+				return
+			}
+			batch = append(batch, m)
+			if len(batch) == 256 || time.Since(last) > 100*time.Millisecond {
+				prog.Send(batch)
+				batch, last = nil, time.Now()
+			}
+		})
+		if len(batch) > 0 {
+			prog.Send(batch)
+		}
+		prog.Send(searchDoneMsg{sum: sum, err: err})
+	}()
+
+	_, err = prog.Run()
+	cancel()
+	if err != nil {
+		return fmt.Errorf("unable to start interactive mode: %w", err)
+	}
+	if model.chosen == nil {
+		return model.err
+	}
+	absPath, err := opener.Open(model.chosen.Path, opener.Options{Line: model.chosen.Line, Column: model.chosen.Column, Editor: editor, Check: allowed.Check, Record: recordOpen})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Opened in VS Code: %s\n", absPath)
+	return nil
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/files"
+)
+
+// listOptions holds the flag values for a single list invocation.
+type listOptions struct {
+	Depth      int
+	MaxEntries int
+}
+
+var listOpts listOptions
+
+// addListFlags registers the list flags on fs, bound to o.
+func addListFlags(fs *pflag.FlagSet, o *listOptions) {
+	fs.IntVarP(&o.Depth, "depth", "L", 1, "Number of directory levels to list")
+	fs.IntVar(&o.MaxEntries, "max-entries", files.DefaultMaxEntries, "Maximum number of entries to print")
+}
+
+var listCmd = &cobra.Command{
+	Use:     "list [dir]",
+	Aliases: []string{"ls"},
+	Short:   "List directory entries with type, size, and modification time",
+	Args:    cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		return runList(listOpts, dir, cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+// runList prints the entries under dir to stdout, one per line.
+func runList(o listOptions, dir string, stdout, stderr io.Writer) error {
+	if err := allowed.Check(dir); err != nil {
+		return err
+	}
+	res, err := files.List(dir, files.ListOptions{Depth: o.Depth, MaxEntries: o.MaxEntries})
+	if err != nil {
+		return err
+	}
+	for _, e := range res.Entries {
+		name := e.Path
+		if e.Type == "directory" {
+			name += "/"
+		}
+		fmt.Fprintf(stdout, "%-9s %10d  %s  %s\n", e.Type, e.Size, e.ModTime.Format(time.RFC3339), name)
+	}
+	if res.Truncated {
+		newLogger(stderr).Info("listing truncated", "entries", len(res.Entries))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	addListFlags(listCmd.Flags(), &listOpts)
+}
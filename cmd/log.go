@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Logging flags shared by every command. Diagnostics are logged to stderr
+// so that stdout carries only results.
+var (
+	verbose   bool
+	quiet     bool
+	logFormat string
+)
+
+// logLevel is the minimum level logged given --verbose and --quiet.
+func logLevel() slog.Level {
+	switch {
+	case verbose:
+		return slog.LevelDebug
+	case quiet:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// newLogger returns a logger writing to w in the --log-format format.
+func newLogger(w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: logLevel()}
+	if logFormat == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(&plainHandler{w: w, level: opts.Level, mu: new(sync.Mutex)})
+}
+
+// validateLogFlags checks the logging flags.
+func validateLogFlags() error {
+	if verbose && quiet {
+		return fmt.Errorf("--verbose and --quiet cannot both be given")
+	}
+	if logFormat != "text" && logFormat != "json" {
+		return fmt.Errorf("unknown log format '%s' (expected text or json)", logFormat)
+	}
+	return nil
+}
+
+// plainHandler writes one line per record for people reading a terminal:
+// the message prefixed by the level (none for info), then the attributes
+// as key=value pairs. There are no timestamps.
+type plainHandler struct {
+	w      io.Writer
+	level  slog.Leveler
+	attrs  string // preformatted " key=value" pairs from WithAttrs
+	prefix string // group prefix for keys, from WithGroup
+	mu     *sync.Mutex
+}
+
+func (h *plainHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *plainHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("Error: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("Warning: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("Debug: ")
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.prefix, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *plainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		writeAttr(&b, h.prefix, a)
+	}
+	h2 := *h
+	h2.attrs += b.String()
+	return &h2
+}
+
+func (h *plainHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.prefix += name + "."
+	return &h2
+}
+
+// writeAttr appends " key=value" for a, quoting values that contain spaces.
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, g := range a.Value.Group() {
+			writeAttr(b, prefix+a.Key+".", g)
+		}
+		return
+	}
+	v := a.Value.String()
+	if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+		v = strconv.Quote(v)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, v)
+}
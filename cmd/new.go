@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/files"
+	"vscode-helper-file-find/internal/opener"
+)
+
+// newOptions holds the flag values for a single new invocation.
+type newOptions struct {
+	Content    string
+	Force      bool
+	Open       bool
+	hasContent bool // --content was given, possibly empty
+}
+
+var newOpts newOptions
+
+// addNewFlags registers the new flags on fs, bound to o.
+func addNewFlags(fs *pflag.FlagSet, o *newOptions) {
+	fs.StringVarP(&o.Content, "content", "c", "", "Content of the file (default: read from stdin unless it is a terminal)")
+	fs.BoolVarP(&o.Force, "force", "f", false, "Overwrite the file if it already exists")
+	fs.BoolVar(&o.Open, "open", false, "Open the file in VS Code afterwards")
+}
+
+var newCmd = &cobra.Command{
+	Use:   "new [file]",
+	Short: "Create a file, with its parent directories",
+	Long: `Create a file with the given content, creating missing parent directories.
+
+The content comes from --content, or else from stdin when it is not a
+terminal; otherwise the file is created empty. An existing file is left
+alone unless --force is given, in which case it is replaced atomically and
+keeps its permissions.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		newOpts.hasContent = cmd.Flags().Changed("content")
+		return runNew(newOpts, args[0], cmd.InOrStdin(), cmd.OutOrStdout())
+	},
+}
+
+// runNew writes the file at path and optionally opens it.
+func runNew(o newOptions, path string, stdin io.Reader, stdout io.Writer) error {
+	content := []byte(o.Content)
+	if f, ok := stdin.(*os.File); !o.hasContent && (!ok || !isTerminal(f)) {
+		var err error
+		if content, err = io.ReadAll(stdin); err != nil {
+			return fmt.Errorf("unable to read content from stdin: %w", err)
+		}
+	}
+	if err := allowed.Check(path); err != nil {
+		return err
+	}
+	res, err := files.Write(path, content, files.WriteOptions{Overwrite: o.Force})
+	if err != nil {
+		return err
+	}
+	verb := "Wrote"
+	if res.Created {
+		verb = "Created"
+	}
+	fmt.Fprintf(stdout, "%s %s (%d bytes)\n", verb, res.Path, res.Bytes)
+	if !o.Open {
+		return nil
+	}
+	editor, err := opener.NewEditor(editorSpec)
+	if err != nil {
+		return err
+	}
+	if _, err := opener.Open(res.Path, opener.Options{Editor: editor, Check: allowed.Check, Record: recordOpen}); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Opened in VS Code: %s\n", res.Path)
+	return nil
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func init() {
+	rootCmd.AddCommand(newCmd)
+	addNewFlags(newCmd.Flags(), &newOpts)
+}
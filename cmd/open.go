@@ -1,63 +1,211 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
-)
+	"github.com/spf13/pflag"
 
-var (
-	openDir bool
+	"vscode-helper-file-find/internal/git"
+	"vscode-helper-file-find/internal/opener"
 )
 
+// openOptions holds the flag values for a single open invocation.
+type openOptions struct {
+	Dir         bool
+	Workspace   bool
+	NewWindow   bool
+	ReuseWindow bool
+	Wait        bool
+	Remote      string
+	Rev         string
+	Blame       bool
+}
+
+var openOpts openOptions
+
+// addOpenFlags registers the open flags on fs, bound to o.
+func addOpenFlags(fs *pflag.FlagSet, o *openOptions) {
+	fs.BoolVarP(&o.Dir, "dir", "d", false, "Open the containing directory instead of the file")
+	fs.BoolVarP(&o.Workspace, "workspace", "w", false, "Open the enclosing .code-workspace or git root, with the file in it")
+	fs.BoolVarP(&o.NewWindow, "new-window", "n", false, "Force a new VS Code window (code -n)")
+	fs.BoolVarP(&o.ReuseWindow, "reuse-window", "r", false, "Open in the last active VS Code window (code -r)")
+	fs.BoolVar(&o.Wait, "wait", false, "Block until the file is closed in VS Code (code --wait)")
+	fs.StringVar(&o.Remote, "remote", "", "Open the absolute folder path on this SSH host (Remote - SSH)")
+	fs.StringVar(&o.Rev, "rev", "", "Open a read-only copy of the file as of this git commit or branch")
+	fs.BoolVar(&o.Blame, "blame", false, "Open git blame's annotation of the file, as of --rev if given")
+}
+
 var openCmd = &cobra.Command{
-	Use:   "open [file]",
+	Use:   "open [file | -]",
 	Short: "Open file or directory in VS Code",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		path := args[0]
-
-		// Check if path exists
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			fmt.Printf("Error: '%s' does not exist\n", path)
-			return
+	Long: `Open a file or directory in VS Code.
+
+With --workspace the command walks up from the path to the nearest directory
+containing a .code-workspace file or a .git directory and opens that
+workspace or folder, with the file open in it, so the window picks up the
+project's settings. Without a project the path is opened on its own.
+
+With --wait the command does not return until the file has been closed in
+VS Code, which makes it usable as $EDITOR or in "edit this, then continue"
+scripts.
+
+With --remote HOST the path is an absolute folder path on the SSH host HOST
+(as understood by ssh, e.g. user@host or an alias from ~/.ssh/config) and is
+opened through the Remote - SSH extension. Nothing is checked locally.
+
+With --rev REV the file as of the git commit or branch REV is written to a
+read-only copy in the temporary directory, which is opened instead; the file
+need not exist in the work tree any more. --blame opens the output of git
+blame for the file, as of REV when --rev is given:
+
+  vscode-helper open --rev HEAD~3 internal/search/search.go
+  vscode-helper open --blame internal/search/search.go
+
+With - the paths to open are read from standard input, one per line, or
+NUL-separated when the input holds a NUL byte (as from find -print0 or
+git diff -z), and opened in one window: the last active one, or a new one
+with --new-window. Paths that do not exist or may not be opened are
+skipped with a warning:
+
+  git diff --name-only | vscode-helper open -
+  rg -l TODO -0 | vscode-helper open -`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if args[0] == "-" {
+			return runOpenStdin(cmd.Context(), openOpts, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr())
 		}
+		return runOpen(cmd.Context(), openOpts, args[0], cmd.OutOrStdout())
+	},
+}
+
+// runOpen opens path in VS Code according to o, writing status to stdout.
+func runOpen(ctx context.Context, o openOptions, path string, stdout io.Writer) error {
+	editor, err := opener.NewEditor(editorSpec)
+	if err != nil {
+		return err
+	}
+	if o.Rev != "" || o.Blame {
+		return openRevision(ctx, o, path, editor, stdout)
+	}
+	absPath, err := opener.Open(path, opener.Options{Dir: o.Dir, Workspace: o.Workspace, NewWindow: o.NewWindow, ReuseWindow: o.ReuseWindow, Wait: o.Wait, Remote: o.Remote, Editor: editor, Check: allowed.Check, Record: recordOpen})
+	if err != nil {
+		return err
+	}
+	if o.Wait {
+		fmt.Fprintf(stdout, "Closed in VS Code: %s\n", absPath)
+		return nil
+	}
+	fmt.Fprintf(stdout, "Opened in VS Code: %s\n", absPath)
+	return nil
+}
+
+// maxOpenStdin is the most paths open - hands to the editor at once.
+const maxOpenStdin = 50
 
-		// If --dir flag is set, get the containing directory
-		if openDir {
-			fileInfo, err := os.Stat(path)
-			if err != nil {
-				fmt.Printf("Error: Unable to get file info: %v\n", err)
-				return
-			}
-			if !fileInfo.IsDir() {
-				path = filepath.Dir(path)
-			}
+// runOpenStdin opens the paths listed on stdin in one VS Code window.
+func runOpenStdin(ctx context.Context, o openOptions, stdin io.Reader, stdout, stderr io.Writer) error {
+	if o.Rev != "" || o.Blame || o.Remote != "" || o.Wait {
+		return errors.New("open - cannot be combined with --rev, --blame, --remote, or --wait")
+	}
+	if o.NewWindow && o.ReuseWindow {
+		return errors.New("--new-window and --reuse-window cannot both be given")
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	sep := "\n"
+	if bytes.IndexByte(data, 0) >= 0 {
+		sep = "\x00"
+	}
+	log := newLogger(stderr)
+	var paths []string
+	for _, p := range strings.Split(string(data), sep) {
+		p = strings.TrimSuffix(p, "\r")
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		if _, err := os.Stat(p); err != nil {
+			log.Warn("skipping path that does not exist", "path", p)
+			continue
 		}
+		if err := allowed.Check(p); err != nil {
+			log.Warn("skipping path", "path", p, "error", err)
+			continue
+		}
+		paths = append(paths, p)
+	}
+	if len(paths) == 0 {
+		return errors.New("no paths to open on standard input")
+	}
+	if len(paths) > maxOpenStdin {
+		return fmt.Errorf("%d paths given, more than the %d open - opens at once", len(paths), maxOpenStdin)
+	}
 
-		// Get absolute path
-		absPath, err := filepath.Abs(path)
+	editor, err := opener.NewEditor(editorSpec)
+	if err != nil {
+		return err
+	}
+	// Other editors have no windows to choose from
+	_, vscode := editor.(opener.VSCode)
+	for i, p := range paths {
+		// The first path picks the window, the rest follow it there
+		opts := opener.Options{Dir: o.Dir, Workspace: o.Workspace, ReuseWindow: vscode, Editor: editor, Check: allowed.Check, Record: recordOpen}
+		if i == 0 && o.NewWindow {
+			opts.NewWindow, opts.ReuseWindow = true, false
+		}
+		abs, err := opener.OpenContext(ctx, p, opts)
 		if err != nil {
-			fmt.Printf("Error: Unable to get absolute path: %v\n", err)
-			return
+			return err
 		}
+		fmt.Fprintf(stdout, "Opened in VS Code: %s\n", abs)
+	}
+	return nil
+}
 
-		// Open in VS Code using 'code' command
-		vscodeCmd := exec.Command("code", absPath)
-		if err := vscodeCmd.Run(); err != nil {
-			fmt.Printf("Error: Failed to open VS Code: %v\n", err)
-			fmt.Println("Make sure VS Code is installed and 'code' command is available in PATH")
-			return
-		}
+// openRevision opens a copy of path as of o.Rev, or its blame with
+// o.Blame, extracted by git.ExtractRevision.
+func openRevision(ctx context.Context, o openOptions, path string, editor opener.Opener, stdout io.Writer) error {
+	if o.Dir || o.Workspace || o.Remote != "" {
+		return errors.New("--rev and --blame cannot be combined with --dir, --workspace, or --remote")
+	}
+	if err := allowed.Check(path); err != nil {
+		return err
+	}
+	rev, err := git.ExtractRevision(ctx, path, o.Rev, o.Blame)
+	if err != nil {
+		return err
+	}
+	// The copy lies outside any allowed directory; the file it was taken
+	// from has been checked
+	if _, err := opener.Open(rev.Path, opener.Options{NewWindow: o.NewWindow, ReuseWindow: o.ReuseWindow, Wait: o.Wait, Editor: editor}); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Opened in VS Code: %s (%s)\n", rev.Path, revisionLabel(rev))
+	return nil
+}
 
-		fmt.Printf("Opened in VS Code: %s\n", absPath)
-	},
+// revisionLabel describes what an extracted revision is a copy of.
+func revisionLabel(rev *git.Revision) string {
+	at := "the work tree"
+	if rev.Commit != "" {
+		at = rev.Commit[:min(len(rev.Commit), 12)]
+	}
+	if rev.Blame {
+		return fmt.Sprintf("blame of %s at %s", rev.Source, at)
+	}
+	return fmt.Sprintf("%s at %s", rev.Source, at)
 }
 
 func init() {
 	rootCmd.AddCommand(openCmd)
-	openCmd.Flags().BoolVarP(&openDir, "dir", "d", false, "Open the containing directory instead of the file")
+	addOpenFlags(openCmd.Flags(), &openOpts)
+	openCmd.MarkFlagsMutuallyExclusive("new-window", "reuse-window")
 }
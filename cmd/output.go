@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"vscode-helper-file-find/internal/search"
+)
+
+// resultWriter renders search results in one output format to stdout. Begin
+// is called before the search starts, Match once per result in order, and
+// End after the search completes with the number of files that matched.
+// Status such as the directory searched is logged to stderr instead.
+type resultWriter interface {
+	Begin(dirs []string)
+	Match(m search.Match)
+	End(files int)
+}
+
+// newResultWriter returns the writer for the named output format, or with
+// --files-with-matches or --count the one listing files in that format.
+func newResultWriter(format string, w io.Writer, o searchOptions) (resultWriter, error) {
+	color, err := useColor(o.Color, w)
+	if err != nil {
+		return nil, err
+	}
+	// Each result ends with eol: a newline, or NUL for xargs -0
+	eol := "\n"
+	if o.Print0 {
+		if format != "" && format != "text" {
+			return nil, fmt.Errorf("--print0 cannot be combined with --output %s", format)
+		}
+		eol = "\x00"
+	}
+	if o.Format != "" {
+		if format != "" && format != "text" || o.FilesWithMatches || o.Count {
+			return nil, fmt.Errorf("--format cannot be combined with --output, --files-with-matches, or --count")
+		}
+		return newTemplateWriter(w, o.Format, eol)
+	}
+	if o.FilesWithMatches || o.Count {
+		if format != "" && format != "text" && format != "json" {
+			return nil, fmt.Errorf("unknown output format '%s' (expected text or json)", format)
+		}
+		return &fileWriter{w: w, counts: o.Count, json: format == "json", color: color, eol: eol}, nil
+	}
+	switch format {
+	case "", "text":
+		return &textWriter{w: w, column: o.Regex, context: o.Before > 0 || o.After > 0, color: color, eol: eol}, nil
+	case "json":
+		return &jsonWriter{w: w}, nil
+	case "sarif":
+		return &sarifWriter{w: w}, nil
+	case "csv", "tsv":
+		cw := csv.NewWriter(w)
+		if format == "tsv" {
+			cw.Comma = '\t'
+		}
+		return &csvWriter{w: cw}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format '%s' (expected text, json, sarif, csv, or tsv)", format)
+	}
+}
+
+// ANSI escapes for --color: paths in magenta, line and column numbers in
+// green, and the matched text in bold red, as ripgrep colors them.
+const (
+	colorPath  = "\x1b[35m"
+	colorLine  = "\x1b[32m"
+	colorMatch = "\x1b[1;31m"
+	colorReset = "\x1b[0m"
+)
+
+// useColor reports whether output to w is colored under the --color mode:
+// always, never, or auto, which colors a terminal unless NO_COLOR is set or
+// TERM is dumb.
+func useColor(mode string, w io.Writer) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "", "auto":
+		f, ok := w.(*os.File)
+		return ok && isTerminal(f) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb", nil
+	default:
+		return false, fmt.Errorf("unknown color mode '%s' (expected auto, always, or never)", mode)
+	}
+}
+
+// paint wraps s in the escape code when color is set.
+func paint(color bool, code, s string) string {
+	if !color {
+		return s
+	}
+	return code + s + colorReset
+}
+
+// textWriter prints one line per match, as grep does. With context enabled,
+// non-contiguous groups of lines are separated by "--".
+type textWriter struct {
+	w       io.Writer
+	column  bool
+	context bool
+	color   bool
+	eol     string
+	prev    *search.Match
+}
+
+func (t *textWriter) Begin(dirs []string) {}
+
+func (t *textWriter) Match(m search.Match) {
+	if t.context && t.prev != nil && search.NeedsSeparator(*t.prev, m) {
+		fmt.Fprint(t.w, "--"+t.eol)
+	}
+	t.prev = &m
+	if !t.color {
+		fmt.Fprint(t.w, m.Format(t.column)+t.eol)
+		return
+	}
+	fmt.Fprint(t.w, formatColor(m, t.column)+t.eol)
+}
+
+// formatColor renders m as Match.Format does, with the path, numbers, and
+// matched text colored.
+func formatColor(m search.Match, column bool) string {
+	path := paint(true, colorPath, m.Path)
+	switch {
+	case m.Kind == search.NameMatch:
+		return path
+	case m.Kind == search.ContextLine:
+		return fmt.Sprintf("%s-%s- %s", path, paint(true, colorLine, fmt.Sprint(m.Line)), m.Text)
+	}
+	text := m.Text
+	if start := m.Column - 1; start >= 0 && start+len(m.MatchedText) <= len(text) && m.MatchedText != "" {
+		text = text[:start] + paint(true, colorMatch, m.MatchedText) + text[start+len(m.MatchedText):]
+	}
+	var b strings.Builder
+	b.WriteString(path + ":" + paint(true, colorLine, fmt.Sprint(m.Line)) + ":")
+	if column {
+		b.WriteString(paint(true, colorLine, fmt.Sprint(m.Column)) + ":")
+	}
+	b.WriteString(" " + text)
+	return b.String()
+}
+
+func (t *textWriter) End(files int) {}
+
+// jsonWriter prints a JSON array of matches, one element per line, so the
+// output can be streamed while remaining a single valid document.
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) Begin(dirs []string) {
+	fmt.Fprint(j.w, "[")
+}
+
+func (j *jsonWriter) Match(m search.Match) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	if j.count > 0 {
+		fmt.Fprint(j.w, ",")
+	}
+	fmt.Fprintf(j.w, "\n  %s", b)
+	j.count++
+}
+
+func (j *jsonWriter) End(files int) {
+	if j.count > 0 {
+		fmt.Fprintln(j.w)
+	}
+	fmt.Fprintln(j.w, "]")
+}
+
+// csvWriter prints a header row and then a row of path, line, column, and
+// text per name or content match, for spreadsheets. Rows of name matches
+// hold only the path; context lines are left out, having no place in a
+// table of matches.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Begin(dirs []string) {
+	c.w.Write([]string{"path", "line", "column", "text"})
+	c.w.Flush()
+}
+
+func (c *csvWriter) Match(m search.Match) {
+	switch m.Kind {
+	case search.NameMatch:
+		c.w.Write([]string{m.Path, "", "", ""})
+	case search.ContentMatch:
+		c.w.Write([]string{m.Path, strconv.Itoa(m.Line), strconv.Itoa(m.Column), m.Text})
+	default:
+		return
+	}
+	c.w.Flush()
+}
+
+func (c *csvWriter) End(files int) {}
+
+// fileWriter prints each file with results once instead of the results, as
+// grep -l does, or with counts followed by its number of results (context
+// lines aside), as grep -c does. Results arrive grouped by file, so a file
+// is complete once the next one starts.
+type fileWriter struct {
+	w      io.Writer
+	counts bool
+	json   bool
+	color  bool
+	eol    string
+
+	path    string // file whose results are being counted
+	n       int    // results counted for path
+	written int    // files printed
+}
+
+// fileEntry is a JSON element of fileWriter's output.
+type fileEntry struct {
+	Path  string `json:"path"`
+	Count *int   `json:"count,omitempty"`
+}
+
+func (f *fileWriter) Begin(dirs []string) {
+	if f.json {
+		fmt.Fprint(f.w, "[")
+	}
+}
+
+func (f *fileWriter) Match(m search.Match) {
+	if m.Kind == search.ContextLine {
+		return
+	}
+	if m.Path != f.path {
+		f.flush()
+		f.path, f.n = m.Path, 0
+		if !f.counts {
+			// Nothing more to learn about the file, so print it right away
+			f.print(m.Path, 0)
+		}
+	}
+	f.n++
+}
+
+func (f *fileWriter) End(files int) {
+	f.flush()
+	if f.json {
+		if f.written > 0 {
+			fmt.Fprintln(f.w)
+		}
+		fmt.Fprintln(f.w, "]")
+	}
+}
+
+// flush prints the count of the file in progress.
+func (f *fileWriter) flush() {
+	if f.counts && f.path != "" {
+		f.print(f.path, f.n)
+	}
+}
+
+func (f *fileWriter) print(path string, n int) {
+	defer func() { f.written++ }()
+	if !f.json {
+		if f.counts {
+			fmt.Fprintf(f.w, "%s:%d%s", paint(f.color, colorPath, path), n, f.eol)
+		} else {
+			fmt.Fprint(f.w, paint(f.color, colorPath, path)+f.eol)
+		}
+		return
+	}
+	e := fileEntry{Path: path}
+	if f.counts {
+		e.Count = &n
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if f.written > 0 {
+		fmt.Fprint(f.w, ",")
+	}
+	fmt.Fprintf(f.w, "\n  %s", b)
+}
+
+// templateWriter prints each match through a --format template, one per
+// line. Matches the template renders as nothing are left out.
+type templateWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+	eol  string
+}
+
+// newTemplateWriter parses the --format template and tries it on an empty
+// match, so that unknown fields are reported before the search starts.
+func newTemplateWriter(w io.Writer, format, eol string) (*templateWriter, error) {
+	tmpl, err := template.New("format").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --format template: %w", err)
+	}
+	if err := tmpl.Execute(io.Discard, search.Match{}); err != nil {
+		return nil, fmt.Errorf("invalid --format template: %w", err)
+	}
+	return &templateWriter{w: w, tmpl: tmpl, eol: eol}, nil
+}
+
+func (t *templateWriter) Begin(dirs []string) {}
+
+func (t *templateWriter) Match(m search.Match) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, m); err != nil || b.Len() == 0 {
+		return
+	}
+	fmt.Fprint(t.w, b.String()+t.eol)
+}
+
+func (t *templateWriter) End(files int) {}
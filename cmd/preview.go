@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-runewidth"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/files"
+)
+
+// previewOptions holds the flag values for a single preview invocation.
+type previewOptions struct {
+	StartLine int
+	EndLine   int
+	Line      int
+	Context   int
+	Color     string
+	Style     string
+	Width     int
+	MaxBytes  int
+}
+
+var previewOpts previewOptions
+
+// previewTabWidth is how many columns a tab advances to in a preview.
+const previewTabWidth = 4
+
+// addPreviewFlags registers the preview flags on fs, bound to o.
+func addPreviewFlags(fs *pflag.FlagSet, o *previewOptions) {
+	fs.IntVarP(&o.StartLine, "start-line", "s", 0, "First line to print (1-based)")
+	fs.IntVarP(&o.EndLine, "end-line", "e", 0, "Last line to print, inclusive (default: end of file)")
+	fs.IntVarP(&o.Line, "line", "n", 0, "Print the lines around this one, and mark it")
+	fs.IntVarP(&o.Context, "context", "C", 5, "Lines to print before and after --line")
+	fs.StringVar(&o.Color, "color", "auto", "Highlight syntax: auto (when stdout is a terminal and NO_COLOR is unset), always, or never")
+	fs.StringVar(&o.Style, "style", "monokai", "Highlighting style, e.g. monokai, github-dark, dracula, or solarized-light")
+	fs.IntVar(&o.Width, "width", 0, "Cut lines longer than this many columns (default: the terminal's width; no limit elsewhere)")
+	fs.IntVar(&o.MaxBytes, "max-bytes", files.DefaultMaxBytes, "Maximum number of bytes of the file to print")
+}
+
+var previewCmd = &cobra.Command{
+	Use:   "preview <file>",
+	Short: "Print a file or a range of its lines with line numbers and highlighting",
+	Long: `Print a file, or the lines --start-line to --end-line of it, with line
+numbers and, on a terminal, syntax highlighting, to inspect a match without
+switching to VS Code. --line prints --context lines around a line, which is
+marked, as search reports it:
+
+  vscode-helper preview internal/search/search.go --line 365
+  vscode-helper preview main.go -s 10 -e 40 --style github-dark
+
+The language is chosen from the file name, or else guessed from the
+content. Lines wider than the terminal are cut to fit, marked with …;
+--width sets another limit. Output stops at --max-bytes, as read's does.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPreview(cmd.Flags(), previewOpts, args[0], cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+// runPreview prints the selected lines of path to stdout.
+func runPreview(fs *pflag.FlagSet, o previewOptions, path string, stdout, stderr io.Writer) error {
+	if o.Line > 0 {
+		if fs.Changed("start-line") || fs.Changed("end-line") {
+			return errors.New("--line cannot be combined with --start-line or --end-line")
+		}
+		o.StartLine, o.EndLine = max(o.Line-o.Context, 1), o.Line+o.Context
+	}
+	color, err := useColor(o.Color, stdout)
+	if err != nil {
+		return err
+	}
+	style, ok := styles.Registry[o.Style]
+	if !ok {
+		return fmt.Errorf("unknown style '%s' (e.g. monokai, github-dark, dracula, or solarized-light)", o.Style)
+	}
+	if err := allowed.Check(path); err != nil {
+		return err
+	}
+	res, err := files.Read(path, files.ReadOptions{StartLine: o.StartLine, EndLine: o.EndLine, MaxBytes: o.MaxBytes})
+	if err != nil {
+		return err
+	}
+	if strings.Contains(res.Content, "\x00") {
+		return fmt.Errorf("'%s' is a binary file", path)
+	}
+
+	width := o.Width
+	if width == 0 {
+		if f, ok := stdout.(*os.File); ok && isTerminal(f) {
+			width, _, _ = term.GetSize(f.Fd())
+		}
+	}
+	gutter := len(strconv.Itoa(max(res.EndLine, 1)))
+
+	lexer := lexers.Match(path)
+	if lexer == nil {
+		lexer = lexers.Get(files.DetectLanguage(path))
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(res.Content)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	// Tokens are kept together across lines, so that a comment or string
+	// spanning several is highlighted throughout
+	tokens, err := chroma.Coalesce(lexer).Tokenise(nil, strings.TrimSuffix(res.Content, "\n"))
+	if err != nil {
+		return err
+	}
+	formatter := formatters.TTY256
+	if ct := os.Getenv("COLORTERM"); ct == "truecolor" || ct == "24bit" {
+		formatter = formatters.TTY16m
+	}
+
+	n := res.StartLine
+	for _, line := range chroma.SplitTokensIntoLines(tokens.Tokens()) {
+		mark := "│"
+		if n == o.Line {
+			mark = paint(color, colorMatch, ">")
+		}
+		fmt.Fprintf(stdout, "%s %s ", paint(color, colorLine, fmt.Sprintf("%*d", gutter, n)), mark)
+		line = fitLine(line, width-gutter-3)
+		if color {
+			if err := formatter.Format(stdout, style, chroma.Literator(line...)); err != nil {
+				return err
+			}
+		} else {
+			for _, t := range line {
+				io.WriteString(stdout, t.Value)
+			}
+		}
+		fmt.Fprintln(stdout)
+		n++
+	}
+	if res.Truncated {
+		newLogger(stderr).Info("output truncated", "bytes", o.MaxBytes, "end_line", res.EndLine)
+	}
+	return nil
+}
+
+// fitLine expands the tabs in the tokens of a line, drops its newline, and
+// cuts it to width columns, ending it with … when cut. A width below one
+// leaves the line whole.
+func fitLine(line []chroma.Token, width int) []chroma.Token {
+	expanded := make([]chroma.Token, 0, len(line))
+	col := 0
+	for _, t := range line {
+		var b strings.Builder
+		for _, r := range strings.TrimRight(t.Value, "\r\n") {
+			if r == '\t' {
+				w := previewTabWidth - col%previewTabWidth
+				b.WriteString(strings.Repeat(" ", w))
+				col += w
+				continue
+			}
+			b.WriteRune(r)
+			col += runewidth.RuneWidth(r)
+		}
+		expanded = append(expanded, chroma.Token{Type: t.Type, Value: b.String()})
+	}
+	if width < 1 || col <= width {
+		return expanded
+	}
+	// Cut one column short, to leave room for the mark
+	out := make([]chroma.Token, 0, len(expanded)+1)
+	col = 0
+	for _, t := range expanded {
+		w := runewidth.StringWidth(t.Value)
+		if col+w <= width-1 {
+			out = append(out, t)
+			col += w
+			continue
+		}
+		out = append(out, chroma.Token{Type: t.Type, Value: runewidth.Truncate(t.Value, width-1-col, "")})
+		break
+	}
+	return append(out, chroma.Token{Type: chroma.Comment, Value: "…"})
+}
+
+func init() {
+	rootCmd.AddCommand(previewCmd)
+	addPreviewFlags(previewCmd.Flags(), &previewOpts)
+}
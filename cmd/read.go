@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/files"
+)
+
+// readOptions holds the flag values for a single read invocation.
+type readOptions struct {
+	StartLine int
+	EndLine   int
+	MaxBytes  int
+}
+
+var readOpts readOptions
+
+// addReadFlags registers the read flags on fs, bound to o.
+func addReadFlags(fs *pflag.FlagSet, o *readOptions) {
+	fs.IntVarP(&o.StartLine, "start-line", "s", 0, "First line to print (1-based)")
+	fs.IntVarP(&o.EndLine, "end-line", "e", 0, "Last line to print, inclusive (default: end of file)")
+	fs.IntVar(&o.MaxBytes, "max-bytes", files.DefaultMaxBytes, "Maximum number of bytes to print")
+}
+
+var readCmd = &cobra.Command{
+	Use:     "read [file]",
+	Aliases: []string{"cat"},
+	Short:   "Print a file or a range of its lines",
+	Long: `Print the contents of a file, optionally limited to a line range.
+
+Output stops at --max-bytes; whole lines are printed unless a single line is
+longer than the limit. A note is printed to stderr when output is truncated.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRead(readOpts, args[0], cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+// runRead prints the selected part of path to stdout.
+func runRead(o readOptions, path string, stdout, stderr io.Writer) error {
+	if err := allowed.Check(path); err != nil {
+		return err
+	}
+	res, err := files.Read(path, files.ReadOptions{
+		StartLine: o.StartLine,
+		EndLine:   o.EndLine,
+		MaxBytes:  o.MaxBytes,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, res.Content)
+	if res.Truncated {
+		limit := o.MaxBytes
+		if limit <= 0 {
+			limit = files.DefaultMaxBytes
+		}
+		newLogger(stderr).Info("output truncated", "bytes", limit, "end_line", res.EndLine)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(readCmd)
+	addReadFlags(readCmd.Flags(), &readOpts)
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/opener"
+	"vscode-helper-file-find/internal/recent"
+)
+
+// recentOptions holds the flag values for a single recent invocation.
+type recentOptions struct {
+	Limit  int
+	Open   bool
+	Clear  bool
+	Output string
+}
+
+var recentOpts recentOptions
+
+// addRecentFlags registers the recent flags on fs, bound to o.
+func addRecentFlags(fs *pflag.FlagSet, o *recentOptions) {
+	fs.IntVarP(&o.Limit, "limit", "n", 20, "Number of files to list; 0 for all")
+	fs.BoolVar(&o.Open, "open", false, "Open the most recent file listed in VS Code")
+	fs.BoolVar(&o.Clear, "clear", false, "Forget every file in the history")
+	fs.StringVarP(&o.Output, "output", "o", "text", "Output format: text or json")
+}
+
+var recentCmd = &cobra.Command{
+	Use:   "recent [query]",
+	Short: "List or reopen the files recently opened in VS Code",
+	Long: `List the files opened through vscode-helper, by open, search --interactive,
+new --open, git changed --open, or the MCP server's tools, most recently
+opened first, with when they were last opened and how many times. Files
+that no longer exist are left out.
+
+A query keeps the files whose path matches it as a fuzzy query, as search
+--fuzzy matches names, and --open opens the first of them:
+
+  vscode-helper recent
+  vscode-helper recent --open usrsvc
+
+The history holds the last 500 files, in the user cache directory.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := ""
+		if len(args) == 1 {
+			query = args[0]
+		}
+		return runRecent(recentOpts, query, cmd.OutOrStdout())
+	},
+}
+
+// runRecent lists, opens, or clears the recent files history.
+func runRecent(o recentOptions, query string, stdout io.Writer) error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", o.Output)
+	}
+	store, err := recent.Default()
+	if err != nil {
+		return err
+	}
+	if o.Clear {
+		return store.Clear()
+	}
+	limit := o.Limit
+	if o.Open {
+		limit = 1
+	}
+	entries, err := store.Find(strings.TrimSpace(query), limit, allowed.Allows)
+	if err != nil {
+		return fmt.Errorf("unable to read the recent files history: %w", err)
+	}
+	if len(entries) == 0 {
+		return errNoMatches
+	}
+	if o.Open {
+		editor, err := opener.NewEditor(editorSpec)
+		if err != nil {
+			return err
+		}
+		abs, err := opener.Open(entries[0].Path, opener.Options{Editor: editor, Check: allowed.Check, Record: recordOpen})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Opened in VS Code: %s\n", abs)
+		return nil
+	}
+	if o.Output == "json" {
+		b, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+		return nil
+	}
+	for _, e := range entries {
+		fmt.Fprintf(stdout, "%s %4d  %s\n", e.Last.Local().Format("2006-01-02 15:04"), e.Count, e.Path)
+	}
+	return nil
+}
+
+// recordOpen adds the absolute path to the recent files history. An open
+// that succeeded is not failed for want of a history, so errors are only
+// logged.
+func recordOpen(path string) {
+	store, err := recent.Default()
+	if err == nil {
+		err = store.Record(path)
+	}
+	if err != nil {
+		newLogger(os.Stderr).Debug("unable to record the opened file", "path", path, "err", err)
+	}
+}
+
+// frecencyBoosts returns the frecency of the files in the recent files
+// history below dirs, for search.Options.Boost. Without a history results
+// are merely left in their usual order, so errors are only logged.
+func frecencyBoosts(dirs []string) map[string]float64 {
+	store, err := recent.Default()
+	if err != nil {
+		return nil
+	}
+	boosts, err := store.Boosts(dirs)
+	if err != nil {
+		newLogger(os.Stderr).Debug("unable to read the recent files history", "err", err)
+	}
+	return boosts
+}
+
+func init() {
+	rootCmd.AddCommand(recentCmd)
+	addRecentFlags(recentCmd.Flags(), &recentOpts)
+	recentCmd.MarkFlagsMutuallyExclusive("open", "clear")
+}
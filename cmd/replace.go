@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/replace"
+	"vscode-helper-file-find/internal/search"
+)
+
+// replaceOptions holds the flag values for a single replace invocation.
+type replaceOptions struct {
+	Content       string
+	With          string
+	Dir           string
+	Name          []string
+	Regex         bool
+	IgnoreCase    bool
+	CaseSensitive bool
+	Exclude       []string
+	NoIgnore      bool
+	DryRun        bool
+	Backup        bool
+}
+
+var replaceOpts replaceOptions
+
+// addReplaceFlags registers the replace flags on fs, bound to o.
+func addReplaceFlags(fs *pflag.FlagSet, o *replaceOptions) {
+	fs.StringVarP(&o.Content, "content", "c", "", "Text to replace")
+	fs.StringVarP(&o.With, "with", "w", "", "Replacement text ($1, ${name} expand capture groups with --regex)")
+	fs.StringVarP(&o.Dir, "dir", "d", ".", "Directory to search in")
+	fs.StringSliceVarP(&o.Name, "name", "n", nil, "Only change files whose name matches this glob (repeatable or comma-separated; prefix with ! to negate)")
+	fs.BoolVarP(&o.Regex, "regex", "r", false, "Treat --content as a regular expression")
+	fs.BoolVarP(&o.IgnoreCase, "ignore-case", "i", false, "Match case-insensitively")
+	fs.BoolVarP(&o.CaseSensitive, "case-sensitive", "s", false, "Match case-sensitively")
+	fs.StringArrayVar(&o.Exclude, "exclude", nil, "Skip files and directories matching this glob (repeatable)")
+	fs.BoolVar(&o.NoIgnore, "no-ignore", false, "Don't respect .gitignore, .ignore, or global git excludes")
+	fs.BoolVar(&o.DryRun, "dry-run", false, "Print a diff of the changes without writing files")
+	fs.BoolVar(&o.Backup, "backup", false, "Save each original file as <file>.bak before rewriting it")
+}
+
+var replaceCmd = &cobra.Command{
+	Use:   "replace",
+	Short: "Replace text in files",
+	Long: `Find content matches and rewrite the files that contain them.
+
+Files are selected as by search: ignore rules, --exclude, and binary file
+detection apply, and --name narrows the files to change. Matching follows
+search's smart-case rules unless --ignore-case or --case-sensitive is given.
+
+With --regex, --content is a Go regular expression and --with may refer to
+capture groups:
+
+  vscode-helper replace --regex --content 'Get(\w+)ByID' --with 'Find${1}' --name '*.go'
+
+Use --dry-run to preview the changes as a unified diff first.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyReplaceConfig(cmd.Flags(), &replaceOpts); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runReplace(ctx, replaceOpts, cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+// runReplace rewrites the matching files, or prints their diffs with
+// --dry-run, followed by a summary. It returns errNoMatches if nothing
+// matched, and an errCancelled error if ctx is done first.
+func runReplace(ctx context.Context, o replaceOptions, stdout, stderr io.Writer) error {
+	if _, err := os.Stat(o.Dir); os.IsNotExist(err) {
+		return fmt.Errorf("directory '%s' does not exist", o.Dir)
+	}
+	if err := allowed.Check(o.Dir); err != nil {
+		return err
+	}
+	if o.Content == "" {
+		return errors.New("--content is required")
+	}
+	if o.IgnoreCase && o.CaseSensitive {
+		return errors.New("--ignore-case and --case-sensitive cannot be combined")
+	}
+
+	mode := search.SmartCase
+	switch {
+	case o.IgnoreCase:
+		mode = search.IgnoreCase
+	case o.CaseSensitive:
+		mode = search.CaseSensitive
+	}
+	opts := replace.Options{
+		Search: search.Options{
+			Dir:      o.Dir,
+			Names:    o.Name,
+			Contents: []string{o.Content},
+			Regex:    o.Regex,
+			Case:     mode,
+			Excludes: o.Exclude,
+			NoIgnore: o.NoIgnore,
+		},
+		Replacement: o.With,
+		DryRun:      o.DryRun,
+		Backup:      o.Backup,
+	}
+	if allowed != nil {
+		opts.Search.Allow = allowed.Allows
+	}
+	sum, err := replace.RunContext(ctx, opts, func(c replace.FileChange) {
+		if o.DryRun {
+			fmt.Fprint(stdout, c.Diff)
+			return
+		}
+		fmt.Fprintf(stdout, "%s: %d replacements\n", c.Path, c.Replacements)
+	})
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return fmt.Errorf("replace %w after %d files", errCancelled, sum.Files)
+	}
+	if err != nil {
+		return err
+	}
+
+	msg := "replaced"
+	if o.DryRun {
+		msg = "would replace (dry run)"
+	}
+	log := newLogger(stderr)
+	for _, p := range sum.Skipped {
+		log.Warn("skipping file that is not UTF-8", "path", p)
+	}
+	log.Info(msg, "occurrences", sum.Replacements, "files", sum.Files)
+	if sum.Files == 0 {
+		return errNoMatches
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(replaceCmd)
+	addReplaceFlags(replaceCmd.Flags(), &replaceOpts)
+}
@@ -1,25 +1,115 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"vscode-helper-file-find/internal/config"
+	"vscode-helper-file-find/internal/opener"
+	"vscode-helper-file-find/internal/sandbox"
+)
+
+// editorSpec is the --editor flag, shared by every command that opens files.
+var editorSpec string
+
+// allowDirs is the --allow-dir flag; allowed is the sandbox built from it,
+// or from the config, which every command touching files checks paths
+// against. It is nil, allowing everything, when neither sets directories.
+var (
+	allowDirs []string
+	allowed   *sandbox.Sandbox
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "vscode-finder",
 	Short: "A CLI tool to search and open files in VSCode",
 	Long:  `vscode-finder lets you search for files by name or content, and open them directly in VSCode.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateLogFlags(); err != nil {
+			return usageError{err}
+		}
+		var err error
+		if cfg, err = config.Load(configPath); err != nil {
+			return err
+		}
+		if cfg.Editor != "" && !cmd.Flags().Changed("editor") {
+			editorSpec = cfg.Editor
+		}
+		if !cmd.Flags().Changed("allow-dir") {
+			allowDirs = cfg.AllowDirs
+		}
+		if allowed, err = sandbox.New(allowDirs); err != nil {
+			return err
+		}
+		return nil
+	},
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+// Exit codes, as for grep.
+const (
+	exitMatch   = 0 // Success; for search and replace, something matched
+	exitNoMatch = 1 // The command ran but nothing matched
+	exitError   = 2 // Bad usage or a failure such as an I/O error
+
+	exitCancelled = 130 // Stopped by SIGINT or SIGTERM, as a shell reports Ctrl-C
+)
+
+// errNoMatches is returned by commands that ran successfully but found
+// nothing. It is reported by the exit code alone.
+var errNoMatches = errors.New("no matches found")
+
+// errCancelled is wrapped by the errors of commands stopped early by SIGINT
+// or SIGTERM.
+var errCancelled = errors.New("cancelled")
+
+// usageError marks an error in how the command was invoked, which is
+// followed by a pointer to the help.
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// exitCode returns the process exit code for the error a command returned.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitMatch
+	case errors.Is(err, errNoMatches):
+		return exitNoMatch
+	case errors.Is(err, errCancelled):
+		return exitCancelled
+	default:
+		return exitError
+	}
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil && !errors.Is(err, errNoMatches) {
+		fmt.Fprintf(rootCmd.ErrOrStderr(), "Error: %v\n", err)
+		var usage usageError
+		if errors.As(err, &usage) {
+			fmt.Fprintf(rootCmd.ErrOrStderr(), "Run '%s --help' for usage.\n", cmd.CommandPath())
+		}
 	}
+	os.Exit(exitCode(err))
 }
 
 func init() {
 	// Subcommands will be added here
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Log debug details to stderr")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Log only warnings and errors to stderr")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format for stderr: text or json")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file with default settings (default ~/.config/vscode-helper/config.yaml)")
+	rootCmd.PersistentFlags().StringArrayVar(&allowDirs, "allow-dir", nil, "Only search, read, write, and open paths inside this directory; repeatable")
+	rootCmd.PersistentFlags().StringVar(&editorSpec, "editor", "", "Editor to open files with: "+strings.Join(opener.Editors, ", ")+", or a command template such as 'vim +{line} {path}' (default code)")
 }
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"vscode-helper-file-find/internal/search"
+)
+
+// sarifWriter prints the results as a SARIF 2.1.0 log with one result per
+// name or content match, for code-scanning dashboards and SARIF viewers.
+// The log is a single document, so it is written once the search ends.
+type sarifWriter struct {
+	w       io.Writer
+	results []sarifResult
+}
+
+// sarifRuleID identifies the one rule every result is reported under.
+const sarifRuleID = "search-match"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name           string      `json:"name"`
+			InformationURI string      `json:"informationUri"`
+			Rules          []sarifRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifact `json:"originalUriBaseIds,omitempty"`
+	ColumnKind         string                   `json:"columnKind"`
+	Results            []sarifResult            `json:"results"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifArtifact struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation sarifArtifact `json:"artifactLocation"`
+		Region           *sarifRegion  `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine   int           `json:"startLine"`
+	StartColumn int           `json:"startColumn,omitempty"`
+	EndColumn   int           `json:"endColumn,omitempty"`
+	Snippet     *sarifMessage `json:"snippet,omitempty"`
+}
+
+func (s *sarifWriter) Begin(dirs []string) {}
+
+func (s *sarifWriter) Match(m search.Match) {
+	if m.Kind == search.ContextLine {
+		return
+	}
+	var loc sarifLocation
+	loc.PhysicalLocation.ArtifactLocation = sarifURI(m.Path)
+	msg := "File name matches"
+	if m.Kind == search.ContentMatch {
+		msg = fmt.Sprintf("Matched %q", m.MatchedText)
+		r := &sarifRegion{StartLine: m.Line, Snippet: &sarifMessage{Text: m.Text}}
+		if start := m.Column - 1; start >= 0 && start+len(m.MatchedText) <= len(m.Text) {
+			// Columns are counted in characters, as the run's columnKind says
+			r.StartColumn = utf8.RuneCountInString(m.Text[:start]) + 1
+			r.EndColumn = r.StartColumn + utf8.RuneCountInString(m.MatchedText)
+		}
+		loc.PhysicalLocation.Region = r
+	}
+	s.results = append(s.results, sarifResult{RuleID: sarifRuleID, Level: "note", Message: sarifMessage{Text: msg}, Locations: []sarifLocation{loc}})
+}
+
+func (s *sarifWriter) End(files int) {
+	var run sarifRun
+	run.Tool.Driver.Name = "vscode-helper"
+	run.Tool.Driver.InformationURI = "https://github.com/sarmad-abualkaz/vscode-helper"
+	run.Tool.Driver.Rules = []sarifRule{{ID: sarifRuleID, ShortDescription: sarifMessage{Text: "Search match"}}}
+	if wd, err := os.Getwd(); err == nil {
+		run.OriginalURIBaseIDs = map[string]sarifArtifact{"SRCROOT": {URI: fileURI(wd) + "/"}}
+	}
+	run.ColumnKind = "unicodeCodePoints"
+	run.Results = s.results
+	if run.Results == nil {
+		run.Results = []sarifResult{}
+	}
+	b, err := json.MarshalIndent(sarifLog{Schema: "https://json.schemastore.org/sarif-2.1.0.json", Version: "2.1.0", Runs: []sarifRun{run}}, "", "  ")
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(s.w, string(b))
+}
+
+// sarifURI returns the artifact location of path: a file URI when it is
+// absolute, and otherwise one relative to the working directory, SRCROOT.
+func sarifURI(path string) sarifArtifact {
+	if filepath.IsAbs(path) {
+		return sarifArtifact{URI: fileURI(path)}
+	}
+	return sarifArtifact{URI: (&url.URL{Path: filepath.ToSlash(path)}).EscapedPath(), URIBaseID: "SRCROOT"}
+}
+
+// fileURI returns the file URI of an absolute path.
+func fileURI(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		// A Windows drive path, C:/src
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
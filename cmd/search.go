@@ -2,99 +2,759 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"io/fs"
+	"io"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
-)
+	"github.com/spf13/pflag"
 
-var (
-	searchName    string
-	searchContent string
-	searchDir     string
+	"vscode-helper-file-find/internal/cache"
+	"vscode-helper-file-find/internal/daemon"
+	"vscode-helper-file-find/internal/opener"
+	"vscode-helper-file-find/internal/search"
 )
 
+// searchOptions holds the flag values for a single search. The search command
+// binds its flags to searchOpts; the serve command parses each request into a
+// fresh value.
+type searchOptions struct {
+	Name       []string
+	Content    []string
+	NotContent []string
+	All        bool
+	Any        bool
+	Dirs       []string
+	Changed    string
+	History    bool
+
+	ContentFromStdin bool
+	WarnOver         int
+	Regex            bool
+	Word             bool
+	Jobs             int
+	NoIgnore         bool
+	Output           string
+	FilesWithMatches bool
+	Count            bool
+	Color            string
+	Format           string
+	Print0           bool
+	Sort             string
+	Reverse          bool
+	Exclude          []string
+	IgnoreCase       bool
+	CaseSensitive    bool
+	After            int
+	Before           int
+	Context          int
+	NoIndex          bool
+	NoFrecency       bool
+	Binary           bool
+	FollowSymlinks   bool
+	MaxResults       int
+	Type             []string
+	TypeNot          []string
+	TypeList         bool
+	MinSize          string
+	MaxSize          string
+	NewerThan        string
+	OlderThan        string
+	MaxFileSize      string
+	MaxLineSize      string
+	Encoding         string
+	Archives         bool
+	Engine           string
+	CacheTTL         time.Duration
+	ArchiveBudget    string
+	Fuzzy            bool
+	Interactive      bool
+	Open             string
+	NoDaemon         bool
+
+	// TypeDefs are the file types defined in the config, not a flag.
+	TypeDefs map[string][]string
+}
+
+// caseMode maps the case flags onto a search.CaseMode.
+func (o searchOptions) caseMode() search.CaseMode {
+	switch {
+	case o.IgnoreCase:
+		return search.IgnoreCase
+	case o.CaseSensitive:
+		return search.CaseSensitive
+	default:
+		return search.SmartCase
+	}
+}
+
+var searchOpts searchOptions
+
+// addSearchFlags registers the search flags on fs, bound to o.
+func addSearchFlags(fs *pflag.FlagSet, o *searchOptions) {
+	fs.StringSliceVarP(&o.Name, "name", "n", nil, "Search files by name pattern (repeatable; prefix with ! to exclude)")
+	fs.BoolVar(&o.Fuzzy, "fuzzy", false, "Treat --name as a fuzzy query and rank results by match quality")
+	fs.StringArrayVarP(&o.Content, "content", "c", nil, "Search files by content (repeatable; a line matches if it contains any term)")
+	fs.BoolVar(&o.All, "all", false, "Only match files that contain every --content term")
+	fs.BoolVar(&o.Any, "any", false, "Match files that contain any --content term (the default)")
+	fs.StringArrayVar(&o.NotContent, "not-content", nil, "Skip files that contain this text (repeatable)")
+	fs.StringArrayVarP(&o.Dirs, "dir", "d", []string{"."}, "Directory to search in (repeatable; each file is reported once)")
+	fs.StringVar(&o.Changed, "changed", "", "Only search the files git reports as changed (see git changed); --changed=REF also those changed since the branch left REF")
+	fs.Lookup("changed").NoOptDefVal = "HEAD"
+	fs.BoolVar(&o.History, "history", false, "Search the git history for commits that added or removed --content (git log -S, or -G with --regex, which takes POSIX extended syntax)")
+	fs.BoolVar(&o.ContentFromStdin, "content-from-stdin", false, "Read content search terms from stdin, one per line")
+	fs.IntVar(&o.WarnOver, "warn-over", 0, "Print a warning to stderr when more than N files match (0 disables)")
+	fs.BoolVarP(&o.Regex, "regex", "r", false, "Treat content terms as regular expressions and report match columns")
+	fs.BoolVarP(&o.Word, "word", "w", false, "Only match content terms as whole words, not inside longer ones")
+	fs.IntVarP(&o.After, "after-context", "A", 0, "Print N lines of context after each content match")
+	fs.IntVarP(&o.Before, "before-context", "B", 0, "Print N lines of context before each content match")
+	fs.IntVarP(&o.Context, "context", "C", 0, "Print N lines of context around each content match")
+	fs.BoolVarP(&o.IgnoreCase, "ignore-case", "i", false, "Match names and content case-insensitively")
+	fs.BoolVarP(&o.CaseSensitive, "case-sensitive", "s", false, "Match names and content case-sensitively")
+	fs.StringArrayVar(&o.Exclude, "exclude", nil, "Skip files and directories matching this glob (repeatable, e.g. '*.min.js' or 'dist/**')")
+	fs.BoolVar(&o.NoIgnore, "no-ignore", false, "Don't respect .gitignore, .ignore, or global git excludes")
+	fs.BoolVar(&o.Binary, "binary", false, "Also search the content of binary files")
+	fs.BoolVarP(&o.FollowSymlinks, "follow-symlinks", "L", false, "Descend into symbolic links to directories")
+	fs.StringSliceVarP(&o.Type, "type", "t", nil, "Only search files of these types (repeatable or comma-separated, e.g. go or md,yaml; see --type-list)")
+	fs.StringSliceVarP(&o.TypeNot, "type-not", "T", nil, "Skip files of these types")
+	fs.BoolVar(&o.TypeList, "type-list", false, "List the file types known to --type and exit")
+	fs.StringVar(&o.MinSize, "min-size", "", "Only search files of at least this size (e.g. 10K, 1M)")
+	fs.StringVar(&o.MaxSize, "max-size", "", "Only search files of at most this size (e.g. 512K)")
+	fs.StringVar(&o.NewerThan, "newer-than", "", "Only search files modified within this duration or since this time (e.g. 2d, 36h, 2024-05-01)")
+	fs.StringVar(&o.OlderThan, "older-than", "", "Only search files last modified longer ago than this duration or before this time")
+	fs.StringVar(&o.MaxFileSize, "max-filesize", "", "Skip the content of files larger than this (e.g. 50M); the number skipped is reported")
+	fs.StringVar(&o.MaxLineSize, "max-line-size", "1M", "Match lines longer than this in chunks of this size, as in minified files")
+	fs.BoolVar(&o.Archives, "archives", false, "Also search inside zip, jar, tar, and tar.gz archives, reporting entries as archive.zip!inner/path")
+	fs.StringVar(&o.ArchiveBudget, "archive-budget", "", "Decompress at most this much of each archive (default 256M)")
+	fs.StringVar(&o.Encoding, "encoding", "auto", "Encoding of the files searched: auto, utf-8, utf-16le, utf-16be, latin1, or windows-1252")
+	fs.BoolVar(&o.NoIndex, "no-index", false, "Walk the directory even when a fresh index covers it")
+	fs.BoolVar(&o.NoDaemon, "no-daemon", false, "Search in this process even when a daemon is running")
+	fs.BoolVar(&o.NoFrecency, "no-frecency", false, "Keep results in walk order instead of putting files often and recently opened first")
+	fs.IntVarP(&o.MaxResults, "max-results", "m", 0, "Stop after N matches (0 for no limit)")
+	fs.BoolVar(&o.Interactive, "interactive", false, "Pick a result in a terminal UI and open it in VS Code")
+	fs.StringVar(&o.Open, "open", "", "Open matches in VS Code at their line instead of printing them: first, all (each file once), or pick (as --interactive)")
+	fs.Lookup("open").NoOptDefVal = "first"
+	fs.StringVarP(&o.Output, "output", "o", "text", "Output format: text, json, sarif (a SARIF 2.1.0 log for code-scanning tools), csv, or tsv")
+	fs.BoolVarP(&o.FilesWithMatches, "files-with-matches", "l", false, "Print only the path of each matching file, once")
+	fs.BoolVar(&o.Count, "count", false, "Print each matching file with its number of matches, as path:count")
+	fs.StringVar(&o.Format, "format", "", "Print each result through this Go template, e.g. '{{.Path}}:{{.Line}}' (see --help for the fields)")
+	fs.StringVar(&o.Sort, "sort", "", "Sort results by file: path, mtime, or size (ascending), or score (best first); default is walk order")
+	fs.BoolVar(&o.Reverse, "reverse", false, "Reverse the --sort order")
+	fs.BoolVarP(&o.Print0, "print0", "0", false, "End each result with a NUL byte instead of a newline, for xargs -0")
+	fs.StringVar(&o.Color, "color", "auto", "Highlight paths, line numbers, and matches: auto (when stdout is a terminal and NO_COLOR is unset), always, or never")
+	fs.StringVar(&o.Engine, "engine", search.EngineNative, "Search engine: native, or rg to run content searches with ripgrep when it is installed")
+	fs.DurationVar(&o.CacheTTL, "cache-ttl", 0, "Reuse the results of the same search made within this long (e.g. 5m) while no directory or matching file changed; 0 disables")
+	fs.IntVarP(&o.Jobs, "jobs", "j", 0, "Number of files to scan in parallel (default: number of CPUs)")
+}
+
+// applyFilters parses the size and modification time flags, and the file,
+// line, and archive size limits, into opts, reading durations as time before now.
+func (o searchOptions) applyFilters(opts *search.Options, now time.Time) error {
+	var err error
+	if o.MaxFileSize != "" {
+		if opts.MaxFileSize, err = search.ParseSize(o.MaxFileSize); err != nil {
+			return fmt.Errorf("--max-filesize: %w", err)
+		}
+	}
+	if o.ArchiveBudget != "" {
+		if opts.ArchiveBudget, err = search.ParseSize(o.ArchiveBudget); err != nil {
+			return fmt.Errorf("--archive-budget: %w", err)
+		}
+	}
+	if o.MaxLineSize != "" {
+		n, err := search.ParseSize(o.MaxLineSize)
+		if err != nil || n < 1 || n > 1<<30 {
+			return fmt.Errorf("--max-line-size: invalid size %q (want 1 byte to 1G)", o.MaxLineSize)
+		}
+		opts.MaxLineSize = int(n)
+	}
+	if o.MinSize != "" {
+		if opts.MinSize, err = search.ParseSize(o.MinSize); err != nil {
+			return fmt.Errorf("--min-size: %w", err)
+		}
+	}
+	if o.MaxSize != "" {
+		if opts.MaxSize, err = search.ParseSize(o.MaxSize); err != nil {
+			return fmt.Errorf("--max-size: %w", err)
+		}
+	}
+	if o.NewerThan != "" {
+		if opts.NewerThan, err = search.ParseTime(o.NewerThan, now); err != nil {
+			return fmt.Errorf("--newer-than: %w", err)
+		}
+	}
+	if o.OlderThan != "" {
+		if opts.OlderThan, err = search.ParseTime(o.OlderThan, now); err != nil {
+			return fmt.Errorf("--older-than: %w", err)
+		}
+	}
+	return nil
+}
+
+// cachedSearch runs the search through the result cache, answering it
+// from there when the same search was made within --cache-ttl and nothing
+// it saw has changed since.
+func cachedSearch(ctx context.Context, engine search.Engine, opts search.Options, o searchOptions, fn func(search.Match), log *slog.Logger) (search.Summary, error) {
+	c, err := cache.New(o.CacheTTL)
+	if err != nil {
+		return engine.Search(ctx, opts, o.Dirs, fn)
+	}
+	wd, _ := os.Getwd()
+	key := opts
+	key.Allow, key.Progress = nil, nil
+	// Times given relative to now are keyed as given, so that they match
+	// from one run to the next
+	key.NewerThan, key.OlderThan = time.Time{}, time.Time{}
+	sum, hit, err := c.Search(cache.Key(wd, o.Dirs, engine.Name(), allowDirs, key, o.NewerThan, o.OlderThan), o.Dirs, func(fn func(search.Match)) (search.Summary, error) {
+		return engine.Search(ctx, opts, o.Dirs, fn)
+	}, fn)
+	if hit {
+		log.Debug("results served from cache", "ttl", o.CacheTTL)
+	}
+	return sum, err
+}
+
+// printTypes prints each file type and its patterns, one per line in name
+// order.
+func printTypes(w io.Writer, types map[string][]string) {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s: %s\n", name, strings.Join(types[name], ", "))
+	}
+}
+
+// readContentTerms reads search terms from r, one per line. Blank lines are
+// ignored and a trailing carriage return is stripped so CRLF input works.
+func readContentTerms(r io.Reader) ([]string, error) {
+	var terms []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		term := strings.TrimSuffix(scanner.Text(), "\r")
+		if term == "" {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
 var searchCmd = &cobra.Command{
 	Use:   "search",
 	Short: "Search for files by name or content",
-	Run: func(cmd *cobra.Command, args []string) {
-		// Validate search directory
-		if _, err := os.Stat(searchDir); os.IsNotExist(err) {
-			fmt.Printf("Error: Directory '%s' does not exist\n", searchDir)
-			return
+	Long: `Search for files by name or content.
+
+Files and directories excluded by .gitignore, .ignore, .git/info/exclude, or
+git's global excludes file are skipped, as is the .git directory itself.
+Use --no-ignore to search everything.
+
+Binary files, detected by a NUL byte in their first 8 KiB, are not searched
+for content; the number skipped is reported on stderr. Use --binary to
+search them anyway. With --max-filesize, files larger than the given size
+are not searched for content either, and are counted the same way.
+
+Lines longer than --max-line-size (1M by default), as in minified or
+generated files, are matched in chunks of that size; a match is reported
+with the chunk it falls in as its text, and one spanning two chunks is
+missed.
+
+With --archives, zip archives (including .jar, .war, and .ear), tar
+archives, and gzipped tar archives (.tar.gz, .tgz) are opened and their
+entries searched like files, nested archives included. A match inside one
+is reported as the archive's path and the entry's joined by '!':
+
+  vscode-helper search --archives --content 'log4j' -d dist
+  dist/app.jar!META-INF/MANIFEST.MF:4: Class-Path: lib/log4j-core.jar
+
+--name patterns match the entry's path within the archive. To guard against
+archive bombs, at most --archive-budget bytes (256M by default) are
+decompressed from each archive, and archives are opened at most three
+deep.
+
+--engine rg hands content searches to ripgrep, when it is installed, which
+is faster on large trees. Results then come in the order rg finds them
+unless --sort path or --sort mtime is given. Searches rg cannot run as
+described, such as those with --name, --all, --not-content, size or time
+filters, or --archives, fall back to the native engine, which is also used
+when rg is missing; the reason is logged on stderr.
+
+Files are searched as text in their own encoding and matches printed in
+UTF-8. By default a byte order mark identifies UTF-8 and UTF-16 files,
+UTF-16 without one is recognised by its NULs, and files that are not valid
+UTF-8 are read as Windows-1252 (a superset of Latin-1). --encoding names
+the encoding of every file instead, e.g. --encoding utf-16le.
+
+Symbolic links to files are searched; links to directories are skipped
+unless --follow-symlinks is given. When following links each directory is
+visited once, so link cycles and links back into the tree are not searched
+twice.
+
+--dir may be repeated to search several directories in one run; they are
+searched in the order given and each file is reported once, even when one
+directory lies inside another. --max-results counts across all of them.
+
+--changed limits the search to the files "vscode-helper git changed" lists
+for each --dir, and --changed=REF to those it lists with --against REF:
+
+  vscode-helper search --changed=main --content 'TODO'
+
+--history searches the git history instead of the files: it lists the
+commits, newest first, that added or removed the --content term anywhere
+below --dir (git log -S), or with --regex a line matching it (git log -G),
+with each such line's path and number before or after the commit. git
+reads a --regex term as a POSIX extended regular expression, so write
+[0-9] for \d and [[:alnum:]_] for \w; --ignore-case stands for (?i):
+
+  vscode-helper search --history --content 'MaxRetries'
+  3f2c1a9e07b4 2024-05-01 Jane Doe: Drop the retry loop
+    internal/client/client.go:42: - const MaxRetries = 3
+
+When an index built by "vscode-helper index" covers --dir and is still fresh,
+files are listed from it instead of walking the tree; see "index --help".
+
+--cache-ttl keeps the results of each search, under the user cache
+directory, for the given time. Running the same search again within it
+prints the kept results without searching, unless a directory below --dir
+or a file with results has changed since; an edit that makes some other
+file match is not noticed until the results expire.
+
+--exclude skips files and whole directory subtrees. A pattern without a slash
+matches a base name at any depth; one containing a slash is matched against
+the path relative to --dir, and '**' matches across directories:
+
+  vscode-helper search --content TODO --exclude '*.min.js' --exclude 'dist/**'
+
+--name accepts glob patterns matched against the file's base name. Repeat
+the flag or separate patterns with commas to give several. A pattern
+containing a slash is matched against the path relative to --dir instead,
+and '**' in it matches any number of directories:
+
+  vscode-helper search --name 'cmd/**/*_test.go'
+  vscode-helper search --name '**/Dockerfile'
+
+A leading '!' negates a pattern: a file matches when it matches at least one
+positive pattern and no negated one, or, when only negated patterns are
+given, when it matches none of them:
+
+  vscode-helper search --name '*.go' --name '!*_test.go'
+  vscode-helper search --name '!*.md'
+
+With --fuzzy, --name is a fuzzy query instead of a glob: a file matches when
+the query's characters appear in its name in order, and results are listed
+best match first. Matches at word starts and consecutive characters rank
+higher:
+
+  vscode-helper search --fuzzy --name usrsvc    # finds user_service.go
+
+--interactive lists results in a terminal UI as they are found. Type to
+filter them (fuzzy), move with the arrow keys while a preview shows the
+surrounding lines, and press Enter to open the selection in VS Code at the
+matching line.
+
+--open skips the printing and opens the results in VS Code at the matching
+line: --open (or --open=first) the first match, ending the search there,
+--open=all the first match in each matching file, up to 50 files, and
+--open=pick the one chosen as with --interactive:
+
+  vscode-helper search --content 'func NewServer' --open
+  vscode-helper search --content TODO --dir internal/cache --open=all
+
+Matching is smart-case by default: name patterns and content terms are
+matched case-insensitively unless they contain an uppercase letter. Use
+--ignore-case or --case-sensitive to force either behavior.
+
+--files-with-matches (-l) prints each matching file once instead of its
+lines, and --count prints each with its number of matching lines as
+path:count (a file matched by name counts 1), like grep's -l and -c. With
+--output json they print [{"path": ...}] and [{"path": ..., "count": N}].
+
+--output sarif prints a SARIF 2.1.0 log with a result per name or content
+match, content matches carrying their line, columns, and text, for
+code-scanning dashboards and VS Code's SARIF viewer:
+
+  vscode-helper search --content 'TODO' --output sarif > todo.sarif
+
+--output csv and --output tsv print a table with a header row and the
+columns path, line, column, and text, for triage in a spreadsheet. A file
+matched by name has only its path; context lines are left out.
+
+--format prints each result through a Go text/template instead, one line
+per result. The fields are .Path, .Line, .Column, .MatchedText, .Text,
+.Kind (name, content, or context), and .Score (fuzzy matches), so output
+can be shaped for editors, scripts, or quickfix lists. Results the
+template renders as nothing are left out:
+
+  vscode-helper search --content TODO --format '{{.Path}}:{{.Line}}:{{.Column}}: {{.Text}}'
+  vscode-helper search --content TODO -C 1 --format '{{if ne .Kind.String "context"}}{{.Path}}:{{.Line}}{{end}}'
+
+Results come in walk order, which is sorted by name within each directory
+and so the same from run to run. --sort orders them by file instead: path,
+mtime, or size ascending, or score (fuzzy matches) best first, with
+--reverse flipping it. A file's own lines stay in order. Sorting waits for
+the whole search before printing, and with several --dir roots applies
+within each:
+
+  vscode-helper search --name '*.log' --sort mtime --reverse -m 5
+
+Without --sort, the files opened recently or often through vscode-helper
+(see "recent --help") come first, most preferred first, ahead of the rest
+in walk order; --max-results then keeps them. Like sorting, this holds
+the results back until the search ends, but only when such a file lies
+below --dir. --no-frecency leaves every result in walk order.
+
+--print0 (-0) ends each result with a NUL byte instead of a newline, so
+that paths containing spaces or newlines can be handed to xargs -0. Name
+searches and --files-with-matches print bare paths:
+
+  vscode-helper search --name '*.log' -0 | xargs -0 rm
+  vscode-helper search --content TODO -l -0 | xargs -0 code
+
+--color highlights paths, line numbers, and the matched text with ANSI
+colors. The default, auto, colors only when stdout is a terminal, NO_COLOR
+is unset, and TERM is not "dumb"; always and never force it either way,
+e.g. to keep colors through a pager with --color always | less -R.
+
+-A, -B, and -C print context lines after, before, or around content matches.
+Context lines are shown as path-line- text and non-adjacent groups are
+separated by "--".
+
+--type limits the search to files of the given types, each a set of name
+patterns such as go (*.go) or yaml (*.yaml, *.yml); --type-not skips them.
+--type-list prints the known types. More can be defined, or built-in ones
+redefined, under types in the config:
+
+  vscode-helper search --type go --content 'http.Handler'
+  vscode-helper search --type-not md,txt --content TODO
+
+--min-size and --max-size limit the search to files within a size range,
+given in bytes or with a K, M, or G suffix (powers of 1024). --newer-than
+and --older-than limit it by modification time, given as a duration before
+now (36h, 2d, 1w) or as a date or time (2024-05-01, 2024-05-01T09:30). Given
+without --name or --content, these filters and --type list every file
+that passes:
+
+  vscode-helper search --name '*.yaml' --newer-than 2d
+  vscode-helper search --min-size 10M
+
+--content may be repeated; a line matches if it contains any of the terms.
+With --all only files containing every term are reported (the terms may be
+on different lines), and --any, the default, reports files containing any.
+--not-content rules out files containing its text, whatever else matched.
+Together they make per-file queries in a single pass:
+
+  vscode-helper search --content http.Handler --not-content Deprecated
+  vscode-helper search --all --content ctx.Done --content time.After
+
+--word only matches terms as whole words, so --content cache does not match
+cached or CacheKey. It works with literal terms and with --regex alike.
+
+With --content-from-stdin the content terms are read from standard input,
+one per line, instead of from --content. Blank lines are ignored and a line
+matches if it contains any of the terms. This is useful for terms that are
+awkward to quote on the command line:
+
+  vscode-helper search --content-from-stdin <<'EOF'
+  fmt.Printf("%s
+  EOF
+
+With --regex the content terms are compiled as Go regular expressions
+(RE2 syntax) and each match is reported as path:line:column. Combined with
+--content-from-stdin, every stdin line becomes an alternative of one pattern:
+
+  vscode-helper search --regex --content 'func \w+Handler\('`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applySearchConfig(cmd.Flags(), &searchOpts); err != nil {
+			return err
 		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runSearch(ctx, searchOpts, cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
 
-		fmt.Printf("Searching in: %s\n", searchDir)
-		matches := make(map[string]bool)
+// runSearch performs the search described by o. Content terms are read from
+// stdin when requested; results go to stdout and warnings to stderr. It
+// returns errNoMatches if nothing matched, and an errCancelled error after
+// printing the results found so far if ctx is done first.
+func runSearch(ctx context.Context, o searchOptions, stdin io.Reader, stdout, stderr io.Writer) error {
+	if o.TypeList {
+		printTypes(stdout, search.FileTypes(o.TypeDefs))
+		return nil
+	}
 
-		err := filepath.Walk(searchDir, func(path string, info fs.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+	// Validate search directories
+	for _, dir := range o.Dirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return fmt.Errorf("directory '%s' does not exist", dir)
+		}
+		if err := allowed.Check(dir); err != nil {
+			return err
+		}
+	}
 
-			// Skip directories
-			if info.IsDir() {
-				return nil
-			}
+	if o.IgnoreCase && o.CaseSensitive {
+		return errors.New("--ignore-case and --case-sensitive cannot be combined")
+	}
 
-			// Check filename match if searchName is provided
-			if searchName != "" {
-				matched, err := filepath.Match(strings.ToLower(searchName), strings.ToLower(filepath.Base(path)))
-				if err != nil {
-					return err
-				}
-				if matched {
-					matches[path] = true
-				}
-			}
+	if len(o.Content) > 0 && o.ContentFromStdin {
+		return errors.New("--content and --content-from-stdin cannot be combined")
+	}
+	if o.All && o.Any {
+		return errors.New("--all and --any cannot be combined")
+	}
+	if o.FilesWithMatches && o.Count {
+		return errors.New("--files-with-matches and --count cannot be combined")
+	}
 
-			// Check content match if searchContent is provided
-			if searchContent != "" && !matches[path] {
-				file, err := os.Open(path)
-				if err != nil {
-					return nil // Skip files we can't open
-				}
-				defer file.Close()
-
-				scanner := bufio.NewScanner(file)
-				lineNum := 1
-				for scanner.Scan() {
-					if strings.Contains(scanner.Text(), searchContent) {
-						matches[path] = true
-						fmt.Printf("%s:%d: %s\n", path, lineNum, scanner.Text())
-					}
-					lineNum++
-				}
-			}
+	var contentTerms []string
+	for _, term := range o.Content {
+		if term != "" {
+			contentTerms = append(contentTerms, term)
+		}
+	}
+	if o.ContentFromStdin {
+		terms, err := readContentTerms(stdin)
+		if err != nil {
+			return fmt.Errorf("unable to read content from stdin: %w", err)
+		}
+		if len(terms) == 0 {
+			return errors.New("no content terms provided on stdin")
+		}
+		contentTerms = terms
+	}
+
+	if o.History {
+		return runHistory(ctx, o, contentTerms, stdout, stderr)
+	}
 
-			return nil
-		})
+	opts := search.Options{
+		Dir:         o.Dirs[0],
+		Names:       o.Name,
+		Contents:    contentTerms,
+		NotContents: o.NotContent,
+		AllContents: o.All,
+		Regex:       o.Regex,
+		Word:        o.Word,
+		Case:        o.caseMode(),
+		Before:      max(o.Before, o.Context),
+		After:       max(o.After, o.Context),
+		Jobs:        o.Jobs,
+		Excludes:    o.Exclude,
+		NoIgnore:    o.NoIgnore,
+		NoIndex:     o.NoIndex,
+		Binary:      o.Binary,
+		Encoding:    o.Encoding,
+		Archives:    o.Archives,
+		Types:       o.Type,
+		TypesNot:    o.TypeNot,
+		TypeDefs:    o.TypeDefs,
 
+		FollowSymlinks: o.FollowSymlinks,
+		MaxResults:     o.MaxResults,
+		Fuzzy:          o.Fuzzy,
+		Sort:           o.Sort,
+		Reverse:        o.Reverse,
+	}
+	if err := o.applyFilters(&opts, time.Now()); err != nil {
+		return err
+	}
+	if !o.NoFrecency {
+		opts.Boost = frecencyBoosts(o.Dirs)
+	}
+	if o.Changed != "" {
+		against := o.Changed
+		if against == "HEAD" {
+			// Uncommitted changes, which git status also finds before the
+			// first commit
+			against = ""
+		}
+		files, err := changedFiles(ctx, o.Dirs, against)
 		if err != nil {
-			fmt.Printf("Error during search: %v\n", err)
-			return
+			return err
+		}
+		opts.Files = files
+	}
+	if o.FilesWithMatches || o.Count {
+		// Only files are listed, so context lines would go unused
+		opts.Before, opts.After = 0, 0
+	}
+	if allowed != nil {
+		opts.Allow = allowed.Allows
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	engine, err := search.NewEngine(o.Engine)
+	if err != nil {
+		return err
+	}
+	if engine == search.Native && !o.NoDaemon {
+		if d := daemon.Detect(allowed); d != nil {
+			engine = d
+		}
+	}
+	if o.Open != "" {
+		if o.Output != "text" && o.Output != "" || o.Format != "" || o.FilesWithMatches || o.Count || o.Interactive {
+			return errors.New("--open cannot be combined with --output, --format, --files-with-matches, --count, or --interactive")
+		}
+		switch o.Open {
+		case "pick":
+			return runInteractive(ctx, opts, o.Dirs, o.Regex, stdout)
+		case "first", "all":
+			return runSearchOpen(ctx, engine, opts, o, stdout)
 		}
+		return fmt.Errorf("unknown --open mode '%s' (expected first, all, or pick)", o.Open)
+	}
+	if o.Interactive {
+		if o.Output != "text" && o.Output != "" || o.FilesWithMatches || o.Count {
+			return errors.New("--interactive cannot be combined with --output, --files-with-matches, or --count")
+		}
+		return runInteractive(ctx, opts, o.Dirs, o.Regex, stdout)
+	}
 
-		// Print results if no content matches were already printed
-		if searchContent == "" {
-			for path := range matches {
-				fmt.Println(path)
-			}
+	o.Before, o.After = opts.Before, opts.After
+	out, err := newResultWriter(o.Output, stdout, o)
+	if err != nil {
+		return err
+	}
+
+	log := newLogger(stderr)
+	log.Info("searching", "dir", strings.Join(o.Dirs, ","))
+	log.Debug("search options", "names", o.Name, "contents", len(opts.Contents), "regex", o.Regex, "excludes", o.Exclude, "jobs", o.Jobs, "no_index", o.NoIndex)
+	if d, ok := engine.(*daemon.Client); ok {
+		log.Debug("searching in the daemon", "socket", d.Socket)
+	}
+	start := time.Now()
+	out.Begin(o.Dirs)
+	var sum search.Summary
+	if o.CacheTTL > 0 {
+		sum, err = cachedSearch(ctx, engine, opts, o, out.Match, log)
+	} else {
+		sum, err = engine.Search(ctx, opts, o.Dirs, out.Match)
+	}
+	out.End(sum.Files)
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+	if sum.Fallback != "" {
+		log.Info("searched with the native engine", "engine", engine.Name(), "reason", sum.Fallback)
+	}
+	if sum.Cancelled {
+		return fmt.Errorf("search %w; results are partial (%d files matched)", errCancelled, sum.Files)
+	}
+	log.Debug("search finished", "files", sum.Files, "elapsed", time.Since(start).Round(time.Millisecond))
+
+	if sum.Truncated {
+		log.Info("stopped at --max-results; more matches remain", "max_results", o.MaxResults)
+	}
+	if sum.BinarySkipped > 0 {
+		log.Info("skipped binary files (use --binary to search them)", "count", sum.BinarySkipped)
+	}
+	if sum.LargeSkipped > 0 {
+		log.Info("skipped large files (raise --max-filesize to search them)", "count", sum.LargeSkipped)
+	}
+	if sum.ArchivesLimited > 0 {
+		log.Info("searched only part of some archives (raise --archive-budget to search more)", "count", sum.ArchivesLimited)
+	}
+	if o.WarnOver > 0 && sum.Files > o.WarnOver {
+		log.Warn("matches exceed the --warn-over threshold; consider a more specific --name or --content", "matches", sum.Files, "threshold", o.WarnOver)
+	}
+	if sum.Files == 0 {
+		log.Info("no matches found")
+		return errNoMatches
+	}
+	return nil
+}
+
+// maxOpenMatches is the most files search --open=all hands to the editor
+// at once.
+const maxOpenMatches = 50
+
+// runSearchOpen runs the search and opens its first match, or with
+// --open=all the first match in each file, in the editor. A first match
+// ends the search.
+func runSearchOpen(ctx context.Context, engine search.Engine, opts search.Options, o searchOptions, stdout io.Writer) error {
+	editor, err := opener.NewEditor(editorSpec)
+	if err != nil {
+		return err
+	}
+	searchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		found []search.Match
+		seen  = map[string]bool{}
+	)
+	collect := func(m search.Match) {
+		if m.Kind == search.ContextLine || seen[m.Path] || o.Open == "first" && len(found) > 0 {
+			return
+		}
+		seen[m.Path] = true
+		found = append(found, m)
+		if o.Open == "first" {
+			cancel()
 		}
+	}
+	var sum search.Summary
+	if o.CacheTTL > 0 {
+		sum, err = cachedSearch(searchCtx, engine, opts, o, collect, newLogger(io.Discard))
+	} else {
+		sum, err = engine.Search(searchCtx, opts, o.Dirs, collect)
+	}
+	if err != nil && len(found) == 0 {
+		return fmt.Errorf("search failed: %w", err)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("search %w; nothing was opened", errCancelled)
+	}
+	if sum.Cancelled && o.Open == "all" {
+		return fmt.Errorf("search %w; nothing was opened", errCancelled)
+	}
+	if len(found) == 0 {
+		return errNoMatches
+	}
+	if len(found) > maxOpenMatches {
+		return fmt.Errorf("%d files matched, more than the %d --open=all opens; narrow the search", len(found), maxOpenMatches)
+	}
 
-		if len(matches) == 0 {
-			fmt.Println("No matches found")
+	// Other editors have no windows to choose from
+	_, vscode := editor.(opener.VSCode)
+	for _, m := range found {
+		abs, err := opener.Open(m.Path, opener.Options{Line: m.Line, Column: m.Column, ReuseWindow: vscode && len(found) > 1, Editor: editor, Check: allowed.Check, Record: recordOpen})
+		if err != nil {
+			return err
 		}
-	},
+		pos := ""
+		if m.Line > 0 {
+			pos = fmt.Sprintf(":%d", m.Line)
+			if m.Column > 0 {
+				pos += fmt.Sprintf(":%d", m.Column)
+			}
+		}
+		fmt.Fprintf(stdout, "Opened in VS Code: %s%s\n", abs, pos)
+	}
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(searchCmd)
 
-	searchCmd.Flags().StringVarP(&searchName, "name", "n", "", "Search files by name pattern")
-	searchCmd.Flags().StringVarP(&searchContent, "content", "c", "", "Search files by content")
-	searchCmd.Flags().StringVarP(&searchDir, "dir", "d", ".", "Directory to search in")
+	addSearchFlags(searchCmd.Flags(), &searchOpts)
+	searchCmd.MarkFlagsMutuallyExclusive("content", "content-from-stdin")
+	searchCmd.MarkFlagsMutuallyExclusive("ignore-case", "case-sensitive")
+	searchCmd.MarkFlagsMutuallyExclusive("all", "any")
+	searchCmd.MarkFlagsMutuallyExclusive("files-with-matches", "count")
 }
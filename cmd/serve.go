@@ -0,0 +1,482 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/daemon"
+)
+
+var (
+	serveSocket   string
+	serveGRPCAddr string
+)
+
+// serveRequest is one request of the serve protocol. Requests and responses
+// are newline-delimited JSON objects. Args holds the command line exactly as
+// it would be passed to the helper binary, e.g. ["search", "--name", "*.go"];
+// Stdin supplies standard input for flags such as --content-from-stdin.
+// A request may instead carry a Search, as the daemon's clients send it,
+// which is answered with a line per match and a last one with the summary.
+type serveRequest struct {
+	ID     json.RawMessage       `json:"id,omitempty"`
+	Args   []string              `json:"args"`
+	Stdin  string                `json:"stdin,omitempty"`
+	Search *daemon.SearchRequest `json:"search,omitempty"`
+}
+
+// serveResponse answers a serveRequest with the same ID. Stdout and Stderr
+// carry what the equivalent CLI invocation would have printed and ExitCode
+// the code it would have exited with; Error is only set when the request
+// itself could not be run (bad JSON, unknown command, invalid flags).
+type serveResponse struct {
+	ID       json.RawMessage `json:"id,omitempty"`
+	Stdout   string          `json:"stdout"`
+	Stderr   string          `json:"stderr,omitempty"`
+	ExitCode int             `json:"exit_code"`
+	Error    string          `json:"error,omitempty"`
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run as a long-lived helper serving search, open, and other command requests",
+	Long: `Run as a long-lived helper that serves search, open, and other requests without
+forking a new process per call.
+
+Requests and responses are newline-delimited JSON. Each request carries the
+helper arguments and optional stdin:
+
+  {"id": 1, "args": ["search", "--name", "*.go"], "stdin": ""}
+
+and is answered with the output the CLI would have produced. The args may
+run search, open, stat, read, list, index (but not index --watch), replace,
+new, git changed, and recent; others are refused with an error listing
+these.
+
+  {"id": 1, "stdout": "main.go\n...", "stderr": "searching dir=.\n", "exit_code": 0}
+
+"exit_code" follows the CLI: 0 when something matched, 1 when nothing did,
+and 2 on errors. "error" is only set, with exit code 2, when the request
+could not be run at all. Requests on a
+connection are answered in order.
+
+By default requests are read from stdin and responses written to stdout.
+With --socket the helper listens on a Unix domain socket instead and serves
+connections concurrently.
+
+With --grpc the helper serves the gRPC API of api/helper/v1/helper.proto
+instead, on a TCP address or unix:PATH: Search, which streams matches, Open,
+Read, and ListDir, confined to --allow-dir as the commands are. Nothing
+authenticates its callers, so listen on localhost:
+
+  vscode-helper serve --grpc localhost:9090`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if serveGRPCAddr != "" {
+			if serveSocket != "" {
+				return errors.New("--grpc and --socket cannot both be given")
+			}
+			return serveGRPC(serveGRPCAddr, cmd.ErrOrStderr())
+		}
+		if serveSocket == "" {
+			return serveConn(cmd.InOrStdin(), cmd.OutOrStdout())
+		}
+		return serveUnix(serveSocket, cmd.ErrOrStderr())
+	},
+}
+
+// serveUnix listens on a Unix socket at path until interrupted.
+func serveUnix(path string, logw io.Writer) error {
+	// Remove a stale socket left behind by a previous run
+	if st, err := os.Lstat(path); err == nil && st.Mode()&os.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-stop
+		ln.Close()
+	}()
+
+	fmt.Fprintf(logw, "Serving helper requests on unix socket %s\n", path)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := serveConn(conn, conn); err != nil {
+				fmt.Fprintf(logw, "Error: connection: %v\n", err)
+			}
+		}()
+	}
+}
+
+// serveConn answers requests read from r until EOF.
+func serveConn(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(w)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req serveRequest
+		var resp serveResponse
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = "invalid request: " + err.Error()
+		} else if req.Search != nil {
+			if err := daemon.Answer(context.Background(), w, req.ID, *req.Search); err != nil {
+				return err
+			}
+			continue
+		} else {
+			resp = handleServeRequest(req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// serveCommands are the commands a request's args may start with.
+var serveCommands = []string{
+	"search", "open", "stat", "read", "list", "index", "replace", "new", "git changed",
+	"recent", "tree", "preview", "stats", "dupes", "todos", "symbols", "definition", "goto",
+}
+
+// handleServeRequest runs a single request in-process.
+func handleServeRequest(req serveRequest) serveResponse {
+	resp := serveResponse{ID: req.ID, ExitCode: exitError}
+	if len(req.Args) == 0 {
+		resp.Error = "missing command in args"
+		return resp
+	}
+
+	var stdout, stderr bytes.Buffer
+	name, rest := req.Args[0], req.Args[1:]
+	if name == "git" && len(rest) > 0 && rest[0] == "changed" {
+		name, rest = "git changed", rest[1:]
+	}
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	var err error
+	switch name {
+	case "search":
+		var o searchOptions
+		addSearchFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if err := applySearchConfig(fs, &o); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		// Already in the process that would be asked
+		o.NoDaemon = true
+		err = runSearch(context.Background(), o, strings.NewReader(req.Stdin), &stdout, &stderr)
+	case "open":
+		var o openOptions
+		addOpenFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() != 1 {
+			resp.Error = fmt.Sprintf("open accepts 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		err = runOpen(context.Background(), o, fs.Arg(0), &stdout)
+	case "stat":
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() != 1 {
+			resp.Error = fmt.Sprintf("stat accepts 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		err = runStat(fs.Arg(0), &stdout, &stderr)
+	case "read", "cat":
+		var o readOptions
+		addReadFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() != 1 {
+			resp.Error = fmt.Sprintf("%s accepts 1 arg, received %d", name, fs.NArg())
+			return resp
+		}
+		err = runRead(o, fs.Arg(0), &stdout, &stderr)
+	case "list", "ls":
+		var o listOptions
+		addListFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() > 1 {
+			resp.Error = fmt.Sprintf("%s accepts at most 1 arg, received %d", name, fs.NArg())
+			return resp
+		}
+		dir := "."
+		if fs.NArg() == 1 {
+			dir = fs.Arg(0)
+		}
+		err = runList(o, dir, &stdout, &stderr)
+	case "index":
+		var o indexOptions
+		addIndexFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() > 1 {
+			resp.Error = fmt.Sprintf("index accepts at most 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		dir := "."
+		if fs.NArg() == 1 {
+			dir = fs.Arg(0)
+		}
+		if err := applyIndexConfig(fs, &o, dir); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if o.Watch {
+			resp.Error = "index --watch runs until stopped, so it cannot be served; run it on its own"
+			return resp
+		}
+		err = runIndex(o, dir, &stdout, &stderr)
+	case "replace":
+		var o replaceOptions
+		addReplaceFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if err := applyReplaceConfig(fs, &o); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		err = runReplace(context.Background(), o, &stdout, &stderr)
+	case "new":
+		var o newOptions
+		addNewFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() != 1 {
+			resp.Error = fmt.Sprintf("new accepts 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		o.hasContent = fs.Changed("content")
+		err = runNew(o, fs.Arg(0), strings.NewReader(req.Stdin), &stdout)
+	case "git changed":
+		var o gitChangedOptions
+		addGitChangedFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() != 0 {
+			resp.Error = fmt.Sprintf("git changed accepts no args, received %d", fs.NArg())
+			return resp
+		}
+		err = runGitChanged(context.Background(), o, &stdout)
+	case "recent":
+		var o recentOptions
+		addRecentFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() > 1 {
+			resp.Error = fmt.Sprintf("recent accepts at most 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		err = runRecent(o, fs.Arg(0), &stdout)
+	case "tree":
+		var o treeOptions
+		addTreeFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() > 1 {
+			resp.Error = fmt.Sprintf("tree accepts at most 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		dir := "."
+		if fs.NArg() == 1 {
+			dir = fs.Arg(0)
+		}
+		if err := applyTreeConfig(fs, &o, dir); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		err = runTree(context.Background(), o, dir, &stdout)
+	case "preview":
+		var o previewOptions
+		addPreviewFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() != 1 {
+			resp.Error = fmt.Sprintf("preview accepts 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		err = runPreview(fs, o, fs.Arg(0), &stdout, &stderr)
+	case "stats":
+		var o statsOptions
+		addStatsFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() > 1 {
+			resp.Error = fmt.Sprintf("stats accepts at most 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		dir := "."
+		if fs.NArg() == 1 {
+			dir = fs.Arg(0)
+		}
+		if err := applyStatsConfig(fs, &o, dir); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		err = runStats(context.Background(), o, dir, &stdout)
+	case "dupes":
+		var o dupesOptions
+		addDupesFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() > 1 {
+			resp.Error = fmt.Sprintf("dupes accepts at most 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		dir := "."
+		if fs.NArg() == 1 {
+			dir = fs.Arg(0)
+		}
+		if err := applyDupesConfig(fs, &o, dir); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		err = runDupes(context.Background(), o, dir, &stdout)
+	case "todos":
+		var o todosOptions
+		addTodosFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() > 1 {
+			resp.Error = fmt.Sprintf("todos accepts at most 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		dir := "."
+		if fs.NArg() == 1 {
+			dir = fs.Arg(0)
+		}
+		if err := applyTodosConfig(fs, &o, dir); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		err = runTodos(context.Background(), o, dir, &stdout)
+	case "symbols":
+		var o symbolsOptions
+		addSymbolsFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() > 1 {
+			resp.Error = fmt.Sprintf("symbols accepts at most 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		dir := "."
+		if fs.NArg() == 1 {
+			dir = fs.Arg(0)
+		}
+		if err := applySymbolsConfig(fs, &o, dir); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		err = runSymbols(context.Background(), o, dir, &stdout, &stderr)
+	case "definition":
+		var o definitionOptions
+		addDefinitionFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() != 1 {
+			resp.Error = fmt.Sprintf("definition accepts 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		err = runDefinition(context.Background(), o, fs.Arg(0), &stdout)
+	case "goto":
+		var o gotoOptions
+		addGotoFlags(fs, &o)
+		if err := fs.Parse(rest); err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		if fs.NArg() != 1 {
+			resp.Error = fmt.Sprintf("goto accepts 1 arg, received %d", fs.NArg())
+			return resp
+		}
+		err = runGoto(context.Background(), o, fs.Arg(0), strings.NewReader(req.Stdin), &stdout)
+	default:
+		resp.Error = fmt.Sprintf("unknown command %q; serve runs %s", req.Args[0], strings.Join(serveCommands, ", "))
+		return resp
+	}
+
+	if err != nil && !errors.Is(err, errNoMatches) {
+		fmt.Fprintf(&stderr, "Error: %v\n", err)
+	}
+	resp.Stdout = stdout.String()
+	resp.Stderr = stderr.String()
+	resp.ExitCode = exitCode(err)
+	return resp
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveSocket, "socket", "", "Listen on this Unix socket path instead of stdin/stdout")
+	serveCmd.Flags().StringVar(&serveGRPCAddr, "grpc", "", "Serve the gRPC API on this address (host:port or unix:PATH) instead")
+}
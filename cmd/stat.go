@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"vscode-helper-file-find/internal/files"
+)
+
+var statCmd = &cobra.Command{
+	Use:   "stat [path]",
+	Short: "Show file metadata and text characteristics",
+	Long: `Show metadata for a file or directory, including whether git tracks it
+when it is inside a work tree. For regular files the output also includes
+the language guessed from the name or #! line, and text characteristics
+detected from the first 1 MiB: line-ending style (LF, CRLF, mixed, or none),
+UTF-8 validity, BOM presence, and whether the file ends with a newline, plus
+the line count. Files containing NUL bytes in their first 8 KiB are reported as
+binary and not analysed further.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStat(args[0], cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+// runStat writes metadata for path to stdout.
+func runStat(path string, stdout, stderr io.Writer) error {
+	if err := allowed.Check(path); err != nil {
+		return err
+	}
+	fi, err := files.Info(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Path: %s\n", fi.Path)
+	fmt.Fprintf(stdout, "Type: %s\n", fi.Type)
+	if fi.Target != "" {
+		fmt.Fprintf(stdout, "Target: %s\n", fi.Target)
+	}
+	fmt.Fprintf(stdout, "Size: %d\n", fi.Size)
+	fmt.Fprintf(stdout, "Mode: %s\n", fi.Mode)
+	fmt.Fprintf(stdout, "Modified: %s\n", fi.ModTime.Format(time.RFC3339))
+	if fi.GitTracked != nil {
+		fmt.Fprintf(stdout, "Git tracked: %t\n", *fi.GitTracked)
+	}
+
+	ti := fi.Text
+	if ti == nil {
+		return nil
+	}
+	if fi.Language != "" {
+		fmt.Fprintf(stdout, "Language: %s\n", fi.Language)
+	}
+	fmt.Fprintf(stdout, "Binary: %t\n", ti.Binary)
+	if ti.Binary {
+		return nil
+	}
+	fmt.Fprintf(stdout, "Lines: %d\n", fi.Lines)
+	fmt.Fprintf(stdout, "Line endings: %s\n", ti.LineEnding)
+	fmt.Fprintf(stdout, "Valid UTF-8: %t\n", ti.ValidUTF8)
+	fmt.Fprintf(stdout, "BOM: %t\n", ti.BOM)
+	fmt.Fprintf(stdout, "Trailing newline: %t\n", ti.TrailingNewline)
+	if ti.Truncated {
+		newLogger(stderr).Info("text analysis covered only the start of the file", "bytes", files.TextSampleSize)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(statCmd)
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/search"
+	"vscode-helper-file-find/internal/stats"
+)
+
+// statsOptions holds the flag values for a single stats invocation.
+type statsOptions struct {
+	Top      int
+	Include  []string
+	Exclude  []string
+	NoIgnore bool
+	Output   string
+}
+
+var statsOpts statsOptions
+
+// addStatsFlags registers the stats flags on fs, bound to o.
+func addStatsFlags(fs *pflag.FlagSet, o *statsOptions) {
+	fs.IntVarP(&o.Top, "top", "n", stats.DefaultTop, "Number of largest and newest files to list")
+	fs.StringSliceVarP(&o.Include, "include", "I", nil, "Only count files matching this name pattern, as search --name takes it (repeatable)")
+	fs.StringArrayVar(&o.Exclude, "exclude", nil, "Skip files and directories matching this glob (repeatable, e.g. 'testdata/**')")
+	fs.BoolVar(&o.NoIgnore, "no-ignore", false, "Don't respect .gitignore, .ignore, or global git excludes")
+	fs.StringVarP(&o.Output, "output", "o", "text", "Output format: text or json")
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [dir]",
+	Short: "Count the files and lines of a project by language",
+	Long: `Count the files below dir (default .) and their lines, in all and for each
+language, and list the largest and the most recently modified files:
+
+  vscode-helper stats
+  vscode-helper stats --include '*.go' --top 5 -o json
+
+Languages are those VS Code would pick from the file names, or the
+extension when it knows none; files with a NUL byte in their first 8 KiB
+are counted as binary, with no lines. As search does, stats skips .git and
+the files .gitignore excludes, unless --no-ignore is given, and the
+config's excludes apply unless --exclude is given.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if err := applyStatsConfig(cmd.Flags(), &statsOpts, dir); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runStats(ctx, statsOpts, dir, cmd.OutOrStdout())
+	},
+}
+
+// runStats prints the statistics of dir.
+func runStats(ctx context.Context, o statsOptions, dir string, stdout io.Writer) error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", o.Output)
+	}
+	if info, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("directory '%s' does not exist", dir)
+	} else if err == nil && !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+	if err := allowed.Check(dir); err != nil {
+		return err
+	}
+	opts := search.Options{Dir: dir, Names: o.Include, Excludes: o.Exclude, NoIgnore: o.NoIgnore}
+	if allowed != nil {
+		opts.Allow = allowed.Allows
+	}
+	s, err := stats.Collect(ctx, stats.Options{Search: opts, Top: o.Top})
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+		return nil
+	}
+
+	fmt.Fprintf(stdout, "%s: %s, %s, %s\n", s.Dir, plural(s.Files, "file", "files"), plural(s.Lines, "line", "lines"), plural(int(s.Bytes), "byte", "bytes"))
+	if len(s.Languages) == 0 {
+		return nil
+	}
+	names := make([]string, len(s.Languages))
+	width := len("Language")
+	for i, l := range s.Languages {
+		names[i] = l.Name
+		if names[i] == "" {
+			names[i] = "(none)"
+		}
+		if len(l.Extensions) > 0 && l.Extensions[0] != l.Name {
+			names[i] += " (" + strings.Join(l.Extensions, ", ") + ")"
+		}
+		width = max(width, len(names[i]))
+	}
+	fmt.Fprintf(stdout, "\n%-*s  %6s  %8s  %10s\n", width, "Language", "Files", "Lines", "Bytes")
+	for i, l := range s.Languages {
+		fmt.Fprintf(stdout, "%-*s  %6d  %8d  %10d\n", width, names[i], l.Files, l.Lines, l.Bytes)
+	}
+
+	if len(s.Largest) > 0 {
+		fmt.Fprintln(stdout, "\nLargest files:")
+		for _, f := range s.Largest {
+			fmt.Fprintf(stdout, "  %10d  %s\n", f.Size, f.Path)
+		}
+	}
+	if len(s.Newest) > 0 {
+		fmt.Fprintln(stdout, "\nNewest files:")
+		for _, f := range s.Newest {
+			fmt.Fprintf(stdout, "  %s  %s\n", f.ModTime.Local().Format("2006-01-02 15:04"), f.Path)
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	addStatsFlags(statsCmd.Flags(), &statsOpts)
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/search"
+	"vscode-helper-file-find/internal/symbols"
+)
+
+// symbolsOptions holds the flag values for a single symbols invocation.
+type symbolsOptions struct {
+	Name       string
+	Kinds      []string
+	IgnoreCase bool
+	Include    []string
+	Exclude    []string
+	NoIgnore   bool
+	MaxResults int
+	Output     string
+}
+
+var symbolsOpts symbolsOptions
+
+// addSymbolsFlags registers the symbols flags on fs, bound to o.
+func addSymbolsFlags(fs *pflag.FlagSet, o *symbolsOptions) {
+	fs.StringVarP(&o.Name, "name", "n", "", "Name defined, a glob such as 'New*', or Type.Method (required)")
+	fs.StringSliceVarP(&o.Kinds, "kind", "k", nil, "Only find these kinds: function, method, type, const, or var (repeatable or comma-separated)")
+	fs.BoolVarP(&o.IgnoreCase, "ignore-case", "i", false, "Match the name regardless of case")
+	fs.StringSliceVarP(&o.Include, "include", "I", nil, "Only parse files matching this name pattern, as search --name takes it (repeatable)")
+	fs.StringArrayVar(&o.Exclude, "exclude", nil, "Skip files and directories matching this glob (repeatable, e.g. 'vendor/**')")
+	fs.BoolVar(&o.NoIgnore, "no-ignore", false, "Don't respect .gitignore, .ignore, or global git excludes")
+	fs.IntVar(&o.MaxResults, "max-results", 0, "Maximum number of definitions to print (0 for no limit)")
+	fs.StringVarP(&o.Output, "output", "o", "text", "Output format: text or json")
+}
+
+var symbolsCmd = &cobra.Command{
+	Use:   "symbols [dir]",
+	Short: "Find where functions, methods, and types are defined",
+	Long: `Find the definitions of a name in the source files below dir (default .),
+as path:line:column: and the line each starts on. The files are parsed, so
+a definition is found apart from the places the name is used, which a text
+search cannot tell:
+
+  vscode-helper symbols --name Search
+  vscode-helper symbols --name 'Server.*' --kind method
+  vscode-helper symbols -n 'new*' -i -o json
+
+Go is parsed for functions, methods (of types and of interfaces), types,
+and top-level constants and variables. As search does, symbols skips .git
+and the files .gitignore excludes, unless --no-ignore is given, and the
+config's excludes apply unless --exclude is given. It exits with status 1
+when nothing is found.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if err := applySymbolsConfig(cmd.Flags(), &symbolsOpts, dir); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runSymbols(ctx, symbolsOpts, dir, cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+// runSymbols prints the definitions matching o in dir.
+func runSymbols(ctx context.Context, o symbolsOptions, dir string, stdout, stderr io.Writer) error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", o.Output)
+	}
+	if o.Name == "" {
+		return errors.New("--name is required")
+	}
+	if o.MaxResults < 0 {
+		return fmt.Errorf("invalid --max-results %d (must be at least 0)", o.MaxResults)
+	}
+	if info, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("directory '%s' does not exist", dir)
+	} else if err == nil && !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+	if err := allowed.Check(dir); err != nil {
+		return err
+	}
+	opts := search.Options{Dir: dir, Names: o.Include, Excludes: o.Exclude, NoIgnore: o.NoIgnore}
+	if allowed != nil {
+		opts.Allow = allowed.Allows
+	}
+	res, err := symbols.Find(ctx, symbols.Options{Search: opts, Name: o.Name, IgnoreCase: o.IgnoreCase, Kinds: o.Kinds, Limit: o.MaxResults})
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		b, err := json.MarshalIndent(res.Symbols, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+	} else {
+		for _, s := range res.Symbols {
+			fmt.Fprintf(stdout, "%s:%d:%d: %s\n", s.Path, s.Line, s.Column, s.Text)
+		}
+	}
+	log := newLogger(stderr)
+	if res.Failed > 0 {
+		log.Warn("some files could not be parsed", "files", res.Failed)
+	}
+	if res.Truncated {
+		log.Info("output truncated", "max_results", o.MaxResults)
+	}
+	if len(res.Symbols) == 0 {
+		return errNoMatches
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(symbolsCmd)
+	addSymbolsFlags(symbolsCmd.Flags(), &symbolsOpts)
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/opener"
+	"vscode-helper-file-find/internal/search"
+	"vscode-helper-file-find/internal/todos"
+)
+
+// todosOptions holds the flag values for a single todos invocation.
+type todosOptions struct {
+	Tags     []string
+	GroupBy  string
+	Blame    bool
+	Include  []string
+	Exclude  []string
+	NoIgnore bool
+	Open     int
+	Output   string
+}
+
+var todosOpts todosOptions
+
+// addTodosFlags registers the todos flags on fs, bound to o.
+func addTodosFlags(fs *pflag.FlagSet, o *todosOptions) {
+	fs.StringSliceVarP(&o.Tags, "tag", "t", todos.DefaultTags, "Marker to look for, as a whole word and case-sensitively (repeatable or comma-separated)")
+	fs.StringVar(&o.GroupBy, "group-by", "file", "Group the items by file, by author (implies --blame), or not at all: file, author, or none")
+	fs.BoolVar(&o.Blame, "blame", false, "Show who last changed each line, from git blame")
+	fs.StringSliceVarP(&o.Include, "include", "I", nil, "Only scan files matching this name pattern, as search --name takes it (repeatable)")
+	fs.StringArrayVar(&o.Exclude, "exclude", nil, "Skip files and directories matching this glob (repeatable, e.g. 'vendor/**')")
+	fs.BoolVar(&o.NoIgnore, "no-ignore", false, "Don't respect .gitignore, .ignore, or global git excludes")
+	fs.IntVar(&o.Open, "open", 0, "Open the item with this number, as listed, in VS Code at its line")
+	fs.StringVarP(&o.Output, "output", "o", "text", "Output format: text or json")
+}
+
+var todosCmd = &cobra.Command{
+	Use:   "todos [dir]",
+	Short: "List the TODO, FIXME, and HACK markers left in files",
+	Long: `List the TODO, FIXME, and HACK markers in the files below dir (default .),
+numbered and grouped by file, with the text after each. A marker written
+TODO(name): shows name as its owner.
+
+  vscode-helper todos --tag TODO,XXX --include '*.go'
+  vscode-helper todos --group-by author
+  vscode-helper todos --open 3
+
+--tag replaces the markers looked for, as todos.tags in the config does.
+--blame shows who last changed each line, from git blame, and --group-by
+author groups the items by that. --open opens one item, by the number it is
+listed with, in VS Code at its line. As search does, todos skips .git,
+binary files, and the files .gitignore excludes, unless --no-ignore is
+given, and the config's excludes apply unless --exclude is given. It exits
+with status 1 when no marker is found.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if err := applyTodosConfig(cmd.Flags(), &todosOpts, dir); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runTodos(ctx, todosOpts, dir, cmd.OutOrStdout())
+	},
+}
+
+// todoGroup is a heading in the todos listing and the items under it.
+type todoGroup struct {
+	Key   string
+	Items []todos.Item
+}
+
+// runTodos prints, and with o.Open opens one of, the markers in dir.
+func runTodos(ctx context.Context, o todosOptions, dir string, stdout io.Writer) error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", o.Output)
+	}
+	if o.GroupBy != "file" && o.GroupBy != "author" && o.GroupBy != "none" {
+		return fmt.Errorf("unknown grouping '%s' (expected file, author, or none)", o.GroupBy)
+	}
+	if o.Open < 0 {
+		return fmt.Errorf("invalid --open %d (items are numbered from 1)", o.Open)
+	}
+	if info, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("directory '%s' does not exist", dir)
+	} else if err == nil && !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+	if err := allowed.Check(dir); err != nil {
+		return err
+	}
+	opts := search.Options{Dir: dir, Names: o.Include, Excludes: o.Exclude, NoIgnore: o.NoIgnore}
+	if allowed != nil {
+		opts.Allow = allowed.Allows
+	}
+	res, err := todos.Scan(ctx, todos.Options{Search: opts, Tags: o.Tags, Blame: o.Blame || o.GroupBy == "author"})
+	if err != nil {
+		return err
+	}
+	groups := groupTodos(res.Items, o.GroupBy)
+
+	if o.Open > 0 {
+		n := o.Open
+		for _, g := range groups {
+			if n > len(g.Items) {
+				n -= len(g.Items)
+				continue
+			}
+			it := g.Items[n-1]
+			editor, err := opener.NewEditor(editorSpec)
+			if err != nil {
+				return err
+			}
+			abs, err := opener.Open(it.Path, opener.Options{Line: it.Line, Editor: editor, Check: allowed.Check, Record: recordOpen})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "Opened in VS Code: %s:%d\n", abs, it.Line)
+			return nil
+		}
+		return fmt.Errorf("no item %d (found %d)", o.Open, len(res.Items))
+	}
+	if o.Output == "json" {
+		b, err := json.MarshalIndent(res.Items, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+	} else {
+		n := 0
+		for i, g := range groups {
+			if g.Key != "" {
+				if i > 0 {
+					fmt.Fprintln(stdout)
+				}
+				fmt.Fprintf(stdout, "%s (%d)\n", g.Key, len(g.Items))
+			}
+			for _, it := range g.Items {
+				n++
+				fmt.Fprintf(stdout, "%s\n", formatTodo(n, it, o.GroupBy))
+			}
+		}
+	}
+	if len(res.Items) == 0 {
+		return errNoMatches
+	}
+	return nil
+}
+
+// groupTodos groups items, ordered by path and line, as groupBy asks: one
+// group per file, one per author in name order, or a single group without
+// a heading.
+func groupTodos(items []todos.Item, groupBy string) []todoGroup {
+	if groupBy == "none" {
+		return []todoGroup{{Items: items}}
+	}
+	var groups []todoGroup
+	index := map[string]int{}
+	for _, it := range items {
+		key := it.Path
+		if groupBy == "author" {
+			key = it.Author
+			if key == "" {
+				key = "(unknown)"
+			}
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, todoGroup{Key: key})
+		}
+		groups[i].Items = append(groups[i].Items, it)
+	}
+	if groupBy == "author" {
+		// Lines nobody is known to have written come last
+		sort.SliceStable(groups, func(i, j int) bool {
+			a, b := groups[i].Key, groups[j].Key
+			if (a == "(unknown)") != (b == "(unknown)") {
+				return b == "(unknown)"
+			}
+			return a < b
+		})
+	}
+	return groups
+}
+
+// formatTodo renders the nth item, led by its path unless it is grouped
+// under it.
+func formatTodo(n int, it todos.Item, groupBy string) string {
+	tag := it.Tag
+	if it.Owner != "" {
+		tag += "(" + it.Owner + ")"
+	}
+	where := fmt.Sprintf("%s:%d", it.Path, it.Line)
+	if groupBy == "file" {
+		where = fmt.Sprintf("%d", it.Line)
+	}
+	s := fmt.Sprintf("[%d] %s: %s %s", n, where, tag, it.Text)
+	if groupBy != "none" {
+		s = "  " + s
+	}
+	if it.Author != "" && groupBy != "author" {
+		s += " — " + it.Author
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(todosCmd)
+	addTodosFlags(todosCmd.Flags(), &todosOpts)
+}
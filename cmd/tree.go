@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"vscode-helper-file-find/internal/search"
+	"vscode-helper-file-find/internal/tree"
+)
+
+// treeOptions holds the flag values for a single tree invocation.
+type treeOptions struct {
+	Depth      int
+	Include    []string
+	Exclude    []string
+	NoIgnore   bool
+	MaxEntries int
+	Output     string
+}
+
+var treeOpts treeOptions
+
+// addTreeFlags registers the tree flags on fs, bound to o.
+func addTreeFlags(fs *pflag.FlagSet, o *treeOptions) {
+	fs.IntVarP(&o.Depth, "depth", "L", 3, "Number of directory levels to print; 0 for all")
+	fs.StringSliceVarP(&o.Include, "include", "I", nil, "Only count files matching this name pattern, as search --name takes it (repeatable)")
+	fs.StringArrayVar(&o.Exclude, "exclude", nil, "Skip files and directories matching this glob (repeatable, e.g. 'testdata/**')")
+	fs.BoolVar(&o.NoIgnore, "no-ignore", false, "Don't respect .gitignore, .ignore, or global git excludes")
+	fs.IntVar(&o.MaxEntries, "max-entries", tree.DefaultMaxEntries, "Maximum number of entries to print, shallower ones first")
+	fs.StringVarP(&o.Output, "output", "o", "text", "Output format: text or json (the tree as nested objects)")
+}
+
+var treeCmd = &cobra.Command{
+	Use:   "tree [dir]",
+	Short: "Print the directory tree with the number of files in each directory",
+	Long: `Print the directories and files below dir (default .) as a tree, down to
+--depth levels, with each directory's count of the files below it at any
+depth, so that the shape of a large project shows at a glance:
+
+  vscode-helper tree -L 2
+  vscode-helper tree --include '*.go' --exclude 'testdata/**'
+
+As search does, tree skips .git and the files .gitignore excludes, unless
+--no-ignore is given, and the config's excludes apply unless --exclude is
+given. Directories holding no file included are left out. Past
+--max-entries the remaining entries of each directory are counted instead
+of printed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if err := applyTreeConfig(cmd.Flags(), &treeOpts, dir); err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runTree(ctx, treeOpts, dir, cmd.OutOrStdout())
+	},
+}
+
+// runTree prints the tree of dir.
+func runTree(ctx context.Context, o treeOptions, dir string, stdout io.Writer) error {
+	if o.Output != "text" && o.Output != "json" {
+		return fmt.Errorf("unknown output format '%s' (expected text or json)", o.Output)
+	}
+	if info, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("directory '%s' does not exist", dir)
+	} else if err == nil && !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+	if err := allowed.Check(dir); err != nil {
+		return err
+	}
+	opts := search.Options{Dir: dir, Names: o.Include, Excludes: o.Exclude, NoIgnore: o.NoIgnore}
+	if allowed != nil {
+		opts.Allow = allowed.Allows
+	}
+	root, err := tree.Build(ctx, tree.Options{Search: opts, Depth: o.Depth, MaxEntries: o.MaxEntries})
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		b, err := json.MarshalIndent(root, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, string(b))
+		return nil
+	}
+	fmt.Fprintln(stdout, root.Name)
+	printTree(stdout, root, "")
+	fmt.Fprintf(stdout, "\n%s, %s\n", plural(root.Dirs, "directory", "directories"), plural(root.Files, "file", "files"))
+	return nil
+}
+
+// printTree prints the entries of n, each line starting with prefix.
+func printTree(w io.Writer, n *tree.Node, prefix string) {
+	for i, c := range n.Children {
+		last := i == len(n.Children)-1 && n.Omitted == 0
+		branch, indent := "├── ", "│   "
+		if last {
+			branch, indent = "└── ", "    "
+		}
+		if c.Type == "directory" {
+			fmt.Fprintf(w, "%s%s%s/ (%s)\n", prefix, branch, c.Name, plural(c.Files, "file", "files"))
+			printTree(w, c, prefix+indent)
+		} else {
+			fmt.Fprintf(w, "%s%s%s\n", prefix, branch, c.Name)
+		}
+	}
+	if n.Omitted > 0 {
+		fmt.Fprintf(w, "%s└── … %d more\n", prefix, n.Omitted)
+	}
+}
+
+// plural returns n followed by the singular or plural noun.
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
+
+func init() {
+	rootCmd.AddCommand(treeCmd)
+	addTreeFlags(treeCmd.Flags(), &treeOpts)
+}
@@ -0,0 +1,138 @@
+// Package audit records MCP tool calls in an append-only JSON lines file,
+// one Entry per line, so that what an agent did can be reviewed later with
+// 'vscode-helper audit tail'.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Outcomes of a tool call.
+const (
+	OK    = "ok"
+	Error = "error"
+)
+
+// maxArgString is the longest string argument recorded in full; longer
+// ones, such as the content given to write_file, are shortened.
+const maxArgString = 256
+
+// Entry is one tool call.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session,omitempty"`
+	User     string    `json:"user,omitempty"`
+	Tool     string    `json:"tool"`
+	Args     any       `json:"args,omitempty"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+	Duration float64   `json:"duration_ms"`
+}
+
+// Log appends entries to a file. A nil *Log discards them.
+type Log struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open opens the log at path for appending, creating it and its directory
+// if needed. The file is readable by its owner only, since arguments can
+// include file contents.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %w", err)
+	}
+	return &Log{f: f}, nil
+}
+
+// Record appends e, shortening long string arguments. Each entry is
+// written with a single write so concurrent servers sharing the file do
+// not interleave lines.
+func (l *Log) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	e.Args = shorten(e.Args)
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.f.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+// shorten returns args as generic JSON values with strings longer than
+// maxArgString cut down and marked with their full length, and arguments
+// left at their zero value omitted.
+func shorten(args any) any {
+	if args == nil {
+		return nil
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+	return shortenValue(v)
+}
+
+func shortenValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		if len(v) > maxArgString {
+			return fmt.Sprintf("%s... (%d bytes)", v[:maxArgString], len(v))
+		}
+	case []any:
+		for i := range v {
+			v[i] = shortenValue(v[i])
+		}
+	case map[string]any:
+		for k, e := range v {
+			if e == nil || e == "" || e == false || e == 0.0 {
+				delete(v, k)
+				continue
+			}
+			v[k] = shortenValue(e)
+		}
+	}
+	return v
+}
+
+// Read calls fn with each entry in r, in order. Lines that are not valid
+// entries, such as one cut short by a crash, are skipped.
+func Read(r io.Reader, fn func(Entry)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Tool == "" {
+			continue
+		}
+		fn(e)
+	}
+	return scanner.Err()
+}
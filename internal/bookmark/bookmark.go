@@ -0,0 +1,183 @@
+// Package bookmark keeps named searches, shared by the CLI and the MCP
+// server, so that searches made again and again can be run by name.
+package bookmark
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Query is a saved search: the directory searched and the options that
+// say what to look for. Options that only shape the output are left to
+// each run.
+type Query struct {
+	// Dir is the absolute path of the directory searched.
+	Dir        string   `json:"dir"`
+	Name       []string `json:"name,omitempty"`
+	Content    []string `json:"content,omitempty"`
+	NotContent []string `json:"not_content,omitempty"`
+	// All only matches files that contain every Content term.
+	All            bool     `json:"all,omitempty"`
+	Regex          bool     `json:"regex,omitempty"`
+	Word           bool     `json:"word,omitempty"`
+	Fuzzy          bool     `json:"fuzzy,omitempty"`
+	IgnoreCase     bool     `json:"ignore_case,omitempty"`
+	CaseSensitive  bool     `json:"case_sensitive,omitempty"`
+	Before         int      `json:"before,omitempty"`
+	After          int      `json:"after,omitempty"`
+	Exclude        []string `json:"exclude,omitempty"`
+	Type           []string `json:"type,omitempty"`
+	TypeNot        []string `json:"type_not,omitempty"`
+	NoIgnore       bool     `json:"no_ignore,omitempty"`
+	Binary         bool     `json:"binary,omitempty"`
+	Archives       bool     `json:"archives,omitempty"`
+	FollowSymlinks bool     `json:"follow_symlinks,omitempty"`
+	MinSize        string   `json:"min_size,omitempty"`
+	MaxSize        string   `json:"max_size,omitempty"`
+	// NewerThan and OlderThan are kept as given, so that a duration such
+	// as 2d is read relative to each run.
+	NewerThan  string `json:"newer_than,omitempty"`
+	OlderThan  string `json:"older_than,omitempty"`
+	Sort       string `json:"sort,omitempty"`
+	Reverse    bool   `json:"reverse,omitempty"`
+	MaxResults int    `json:"max_results,omitempty"`
+}
+
+// Bookmark is a named Query.
+type Bookmark struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Query       Query     `json:"query"`
+	Created     time.Time `json:"created"`
+}
+
+// ErrExists is returned by Add for a name already taken.
+var ErrExists = errors.New("already exists")
+
+// validName restricts names to what is easy to type and quote.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateName reports whether name can name a bookmark.
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid bookmark name '%s' (use letters, digits, '.', '_', and '-')", name)
+	}
+	return nil
+}
+
+// Store is a set of bookmarks kept in a JSON file.
+type Store struct {
+	Path string
+}
+
+// Default returns the store in the user configuration directory, normally
+// ~/.config/vscode-helper/bookmarks.json.
+func Default() (*Store, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{Path: filepath.Join(dir, "vscode-helper", "bookmarks.json")}, nil
+}
+
+// List returns the bookmarks in name order. A store that has not been
+// written yet is empty.
+func (s *Store) List() ([]Bookmark, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var bookmarks []Bookmark
+	if err := json.Unmarshal(data, &bookmarks); err != nil {
+		return nil, fmt.Errorf("invalid bookmarks file %s: %w", s.Path, err)
+	}
+	return bookmarks, nil
+}
+
+// Get returns the bookmark called name.
+func (s *Store) Get(name string) (Bookmark, error) {
+	bookmarks, err := s.List()
+	if err != nil {
+		return Bookmark{}, err
+	}
+	for _, b := range bookmarks {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return Bookmark{}, fmt.Errorf("no bookmark named '%s'", name)
+}
+
+// Add saves b, setting its creation time. A bookmark of the same name is
+// only replaced with replace.
+func (s *Store) Add(b Bookmark, replace bool) error {
+	if err := ValidateName(b.Name); err != nil {
+		return err
+	}
+	bookmarks, err := s.List()
+	if err != nil {
+		return err
+	}
+	b.Created = time.Now().UTC()
+	if i := slices.IndexFunc(bookmarks, func(o Bookmark) bool { return o.Name == b.Name }); i >= 0 {
+		if !replace {
+			return fmt.Errorf("bookmark '%s' %w", b.Name, ErrExists)
+		}
+		bookmarks[i] = b
+	} else {
+		bookmarks = append(bookmarks, b)
+	}
+	slices.SortFunc(bookmarks, func(a, b Bookmark) int { return strings.Compare(a.Name, b.Name) })
+	return s.write(bookmarks)
+}
+
+// Remove deletes the bookmark called name.
+func (s *Store) Remove(name string) error {
+	bookmarks, err := s.List()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(bookmarks, func(b Bookmark) bool { return b.Name == name })
+	if i < 0 {
+		return fmt.Errorf("no bookmark named '%s'", name)
+	}
+	return s.write(slices.Delete(bookmarks, i, i+1))
+}
+
+// write replaces the bookmarks with bookmarks. The file is written to a
+// temporary name and renamed, so that readers never see half of it.
+func (s *Store) write(bookmarks []Bookmark) error {
+	if bookmarks == nil {
+		bookmarks = []Bookmark{}
+	}
+	data, err := json.MarshalIndent(bookmarks, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), ".bookmarks-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
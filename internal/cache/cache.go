@@ -0,0 +1,249 @@
+// Package cache keeps the results of recent searches on disk so that the
+// same search, repeated while nothing it looked at has changed, is answered
+// without walking or scanning again.
+//
+// A cached result is reused while it is younger than the cache's TTL, no
+// directory below the searched roots has been modified, and no file with a
+// result has changed size or modification time. Adding, removing, or
+// renaming a file touches its directory, so those are noticed; an edit to
+// a file that had no results, making it match, is only noticed once the
+// entry expires.
+package cache
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"vscode-helper-file-find/internal/index"
+	"vscode-helper-file-find/internal/search"
+)
+
+// version is bumped whenever the on-disk format changes; entries written
+// by other versions are ignored.
+const version = 1
+
+// MaxMatches is the most results an entry holds; bigger results are not
+// cached, as reading them back would save little.
+const MaxMatches = 10000
+
+// Cache is a directory of cached search results.
+type Cache struct {
+	Dir string
+	TTL time.Duration
+
+	hits, misses atomic.Int64
+}
+
+// Stats describe a cache's use since it was created and what it holds.
+type Stats struct {
+	Dir    string
+	TTL    time.Duration
+	Hits   int64
+	Misses int64
+	// Entries and Bytes count the entries on disk, expired ones included
+	// until the next save prunes them.
+	Entries int
+	Bytes   int64
+}
+
+// entry is a cached search result.
+type entry struct {
+	Version int
+	Key     string
+	Created time.Time
+	// Dirs fingerprints the directories below the searched roots
+	Dirs string
+	// Files holds the size and modification time of each file with results
+	Files   map[string]fileStamp
+	Matches []search.Match
+	Summary search.Summary
+}
+
+type fileStamp struct {
+	Size    int64
+	ModTime int64 // Unix nanoseconds
+}
+
+// New returns the cache in the user cache directory, normally
+// ~/.cache/vscode-helper/results, whose entries last for ttl.
+func New(ttl time.Duration) (*Cache, error) {
+	dir, err := index.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: filepath.Join(filepath.Dir(dir), "results"), TTL: ttl}, nil
+}
+
+// Key returns a cache key for a search described by parts, such as its
+// options with the function fields cleared and the directories searched.
+// Parts are compared by their printed form.
+func Key(parts ...any) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d %+v", version, parts)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Search reports to fn the results of the search with key over dirs: the
+// cached ones when they are still valid, and otherwise those of run, which
+// are then cached unless the search failed or was cancelled. hit reports
+// which it was.
+func (c *Cache) Search(key string, dirs []string, run func(fn func(search.Match)) (search.Summary, error), fn func(search.Match)) (sum search.Summary, hit bool, err error) {
+	path := filepath.Join(c.Dir, key[:32]+".gob.gz")
+	if e, err := readEntry(path); err == nil && c.valid(e, key, dirs) {
+		c.hits.Add(1)
+		for _, m := range e.Matches {
+			fn(m)
+		}
+		return e.Summary, true, nil
+	}
+	c.misses.Add(1)
+
+	e := &entry{Version: version, Key: key, Created: time.Now(), Files: map[string]fileStamp{}}
+	// Fingerprint before searching, so that changes made during the
+	// search invalidate the entry
+	e.Dirs = fingerprint(dirs)
+	sum, err = run(func(m search.Match) {
+		if len(e.Matches) <= MaxMatches {
+			e.Matches = append(e.Matches, m)
+		}
+		fn(m)
+	})
+	if err != nil || sum.Cancelled || len(e.Matches) > MaxMatches {
+		return sum, false, err
+	}
+	e.Summary = sum
+	for _, m := range e.Matches {
+		if _, ok := e.Files[m.Path]; !ok {
+			e.Files[m.Path] = stamp(m.Path)
+		}
+	}
+	// Caching is best effort; the search itself succeeded
+	_ = c.save(path, e)
+	return sum, false, nil
+}
+
+// Stats returns the hits and misses of c's searches and the size of its
+// directory.
+func (c *Cache) Stats() Stats {
+	st := Stats{Dir: c.Dir, TTL: c.TTL, Hits: c.hits.Load(), Misses: c.misses.Load()}
+	entries, _ := os.ReadDir(c.Dir)
+	for _, d := range entries {
+		if info, err := d.Info(); err == nil && strings.HasSuffix(d.Name(), ".gob.gz") {
+			st.Entries++
+			st.Bytes += info.Size()
+		}
+	}
+	return st
+}
+
+// valid reports whether e is a live entry for key over dirs.
+func (c *Cache) valid(e *entry, key string, dirs []string) bool {
+	if e.Version != version || e.Key != key || time.Since(e.Created) > c.TTL {
+		return false
+	}
+	for path, s := range e.Files {
+		if stamp(path) != s {
+			return false
+		}
+	}
+	return fingerprint(dirs) == e.Dirs
+}
+
+// stamp returns the size and modification time of the file at path, or
+// the zero stamp if it cannot be read. The path of an entry in an archive
+// is stamped by the archive.
+func stamp(path string) fileStamp {
+	path, _, _ = strings.Cut(path, search.ArchiveSeparator)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+}
+
+// fingerprint hashes the path and modification time of every directory
+// below dirs, .git directories aside.
+func fingerprint(dirs []string) string {
+	h := sha256.New()
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if info, err := d.Info(); err == nil {
+				fmt.Fprintf(h, "%s\x00%d\n", path, info.ModTime().UnixNano())
+			}
+			return nil
+		})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func readEntry(path string) (*entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err := gob.NewDecoder(zr).Decode(&e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// save writes e to path, replacing any earlier entry, and removes entries
+// that have expired.
+func (c *Cache) save(path string, e *entry) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	c.prune()
+	tmp, err := os.CreateTemp(c.Dir, ".result-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	if err := gob.NewEncoder(zw).Encode(e); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// prune removes the entries older than the TTL.
+func (c *Cache) prune() {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return
+	}
+	for _, d := range entries {
+		if info, err := d.Info(); err == nil && strings.HasSuffix(d.Name(), ".gob.gz") && time.Since(info.ModTime()) > c.TTL {
+			os.Remove(filepath.Join(c.Dir, d.Name()))
+		}
+	}
+}
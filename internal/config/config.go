@@ -0,0 +1,233 @@
+// Package config loads the optional configuration files that supply
+// defaults shared by the CLI and the MCP server: a per-user file, and a
+// per-project .vscode-helper.yaml that teams can commit alongside the code.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds defaults for settings not given explicitly. Zero values mean
+// "no default": the built-in behavior applies.
+type Config struct {
+	// Dir is the directory searched when none is given. A leading ~ is
+	// expanded to the home directory.
+	Dir string `yaml:"dir"`
+	// Exclude are glob patterns skipped by every search, as with --exclude.
+	// They are replaced, not extended, by patterns given explicitly.
+	Exclude []string `yaml:"exclude"`
+	// Editor selects the editor as with --editor.
+	Editor string `yaml:"editor"`
+	// MaxResults caps the number of matches a search returns.
+	MaxResults int `yaml:"max_results"`
+	// Jobs is the number of files scanned in parallel.
+	Jobs int `yaml:"jobs"`
+	// Types adds file types for --type, or redefines built-in ones, each
+	// naming glob patterns matched against base names.
+	Types map[string][]string `yaml:"types"`
+	// CacheTTL is how long search results are cached and reused, as with
+	// --cache-ttl. Zero disables the cache.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	// Index holds defaults for the index command.
+	Index IndexConfig `yaml:"index"`
+	// Todos holds defaults for the todos command and list_todos tool.
+	Todos TodosConfig `yaml:"todos"`
+	// AllowDirs confines file operations to these directories, as with
+	// --allow-dir. A leading ~ is expanded to the home directory.
+	AllowDirs []string `yaml:"allow_dirs"`
+	// AuditLog is the file the MCP server records tool calls in, as with
+	// -audit-log, and that audit tail reads. A leading ~ is expanded to the
+	// home directory.
+	AuditLog string `yaml:"audit_log"`
+	// ToolTimeout is how long an MCP tool call may run, as with a
+	// -tool-timeout of a bare duration.
+	ToolTimeout time.Duration `yaml:"tool_timeout"`
+	// AllowedOrigins are the origins whose web pages may call the MCP
+	// server in HTTP mode, as with -allowed-origin.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// IndexConfig holds defaults for building indexes.
+type IndexConfig struct {
+	// Trigrams, if set, says whether to index file contents, as with
+	// --trigrams.
+	Trigrams *bool `yaml:"trigrams"`
+}
+
+// TodosConfig holds defaults for finding TODO markers.
+type TodosConfig struct {
+	// Tags are the markers looked for, as with --tag.
+	Tags []string `yaml:"tags"`
+}
+
+// ProjectFile is the name of the per-project configuration file.
+const ProjectFile = ".vscode-helper.yaml"
+
+// DefaultPath returns the configuration file read when none is named,
+// normally ~/.config/vscode-helper/config.yaml.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vscode-helper", "config.yaml"), nil
+}
+
+// Load reads the configuration file at path, or at DefaultPath if path is
+// empty. A missing default file is not an error and yields an empty Config;
+// a missing file named explicitly is. Unknown keys are rejected so typos do
+// not go unnoticed.
+func Load(path string) (Config, error) {
+	explicit := path != ""
+	if !explicit {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return Config{}, nil // Nowhere to look, so nothing to load
+		}
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && !explicit {
+		return Config{}, nil
+	}
+	c, err := parse(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if c.Dir, err = expandHome(c.Dir); err != nil {
+		return Config{}, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if c.AuditLog, err = expandHome(c.AuditLog); err != nil {
+		return Config{}, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	for i, dir := range c.AllowDirs {
+		if c.AllowDirs[i], err = expandHome(dir); err != nil {
+			return Config{}, fmt.Errorf("invalid config %s: %w", path, err)
+		}
+	}
+	return c, nil
+}
+
+// FindProject returns the project configuration file governing dir: the
+// nearest ProjectFile in dir or its ancestors, not looking above the
+// enclosing git repository root. It returns "" if there is none.
+func FindProject(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		if info, err := os.Stat(filepath.Join(d, ProjectFile)); err == nil && info.Mode().IsRegular() {
+			return filepath.Join(d, ProjectFile)
+		}
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil || filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+// ForDir returns the configuration for work in dir: c with the settings of
+// the project configuration governing dir, if any, taking precedence. A
+// relative dir in the project file is resolved against the directory
+// holding it. A project file cannot choose the editor, the allowed
+// directories, or the audit log, since it may come from a repository the
+// user merely cloned.
+func (c Config) ForDir(dir string) (Config, error) {
+	path := FindProject(dir)
+	if path == "" {
+		return c, nil
+	}
+	p, err := parse(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if p.Editor != "" {
+		return Config{}, fmt.Errorf("invalid config %s: editor can only be set in the user config", path)
+	}
+	if p.AllowDirs != nil {
+		return Config{}, fmt.Errorf("invalid config %s: allow_dirs can only be set in the user config", path)
+	}
+	if p.AuditLog != "" {
+		return Config{}, fmt.Errorf("invalid config %s: audit_log can only be set in the user config", path)
+	}
+	if p.ToolTimeout != 0 {
+		return Config{}, fmt.Errorf("invalid config %s: tool_timeout can only be set in the user config", path)
+	}
+	if p.AllowedOrigins != nil {
+		return Config{}, fmt.Errorf("invalid config %s: allowed_origins can only be set in the user config", path)
+	}
+	if p.Dir != "" {
+		if !filepath.IsAbs(p.Dir) {
+			p.Dir = filepath.Join(filepath.Dir(path), p.Dir)
+		}
+		c.Dir = p.Dir
+	}
+	if p.Exclude != nil {
+		c.Exclude = p.Exclude
+	}
+	if p.MaxResults > 0 {
+		c.MaxResults = p.MaxResults
+	}
+	if p.Jobs > 0 {
+		c.Jobs = p.Jobs
+	}
+	if p.CacheTTL > 0 {
+		c.CacheTTL = p.CacheTTL
+	}
+	if p.Types != nil {
+		// Merged by name, so a project can add a type without repeating
+		// the user's
+		types := make(map[string][]string, len(c.Types)+len(p.Types))
+		for name, globs := range c.Types {
+			types[name] = globs
+		}
+		for name, globs := range p.Types {
+			types[name] = globs
+		}
+		c.Types = types
+	}
+	if p.Index.Trigrams != nil {
+		c.Index.Trigrams = p.Index.Trigrams
+	}
+	if p.Todos.Tags != nil {
+		c.Todos.Tags = p.Todos.Tags
+	}
+	return c, nil
+}
+
+// parse reads and validates the configuration file at path.
+func parse(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("unable to read config: %w", err)
+	}
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && err != io.EOF {
+		return Config{}, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if c.MaxResults < 0 || c.Jobs < 0 || c.CacheTTL < 0 || c.ToolTimeout < 0 {
+		return Config{}, fmt.Errorf("invalid config %s: max_results, jobs, cache_ttl, and tool_timeout must not be negative", path)
+	}
+	return c, nil
+}
+
+// expandHome replaces a leading ~ in path with the home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
@@ -0,0 +1,250 @@
+// Package daemon runs searches in the vscode-helper daemon: a long-lived
+// process that keeps the indexes of the directories it serves in memory,
+// sparing each search a process start and a cold walk. The CLI and the MCP
+// server reach it over a Unix domain socket, in the protocol of the serve
+// command.
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"vscode-helper-file-find/internal/sandbox"
+	"vscode-helper-file-find/internal/search"
+)
+
+// EnvSocket is the environment variable that names the daemon's socket in
+// place of SocketPath's default.
+const EnvSocket = "VSCODE_HELPER_SOCKET"
+
+// dialTimeout bounds how long Detect waits for the daemon to accept.
+const dialTimeout = 200 * time.Millisecond
+
+// SocketPath returns where the daemon listens: $VSCODE_HELPER_SOCKET, or
+// vscode-helper/daemon.sock in $XDG_RUNTIME_DIR, or failing that in the
+// user cache directory.
+func SocketPath() (string, error) {
+	if p := os.Getenv(EnvSocket); p != "" {
+		return p, nil
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, "vscode-helper", "daemon.sock"), nil
+}
+
+// SearchRequest asks for a search of Dirs, as search.SearchDirs runs it.
+// Dirs and the paths in Options are absolute, since the daemon has a
+// working directory of its own. A func cannot be sent, so in place of
+// Options.Allow it is sent the directories of the sandbox to confine the
+// search to.
+type SearchRequest struct {
+	Options search.Options `json:"options"`
+	Dirs    []string       `json:"dirs"`
+	Allow   []string       `json:"allow,omitempty"`
+}
+
+// SearchResponse is a line of the answer to a SearchRequest: a match, the
+// progress of the search, or, last, its summary and any error.
+type SearchResponse struct {
+	ID       json.RawMessage  `json:"id,omitempty"`
+	Match    *search.Match    `json:"match,omitempty"`
+	Progress *search.Progress `json:"progress,omitempty"`
+	Summary  *search.Summary  `json:"summary,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// Answer runs req and writes its answer to w as newline-delimited
+// SearchResponses carrying id. Progress is always sent, so that a caller
+// gone away is noticed, and the search cancelled, by the failed write.
+func Answer(ctx context.Context, w io.Writer, id json.RawMessage, req SearchRequest) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	var werr error
+	send := func(r SearchResponse, flush bool) {
+		if werr != nil {
+			return
+		}
+		r.ID = id
+		if werr = enc.Encode(r); werr == nil && flush {
+			werr = bw.Flush()
+		}
+		if werr != nil {
+			cancel()
+		}
+	}
+
+	opts := req.Options
+	sb, err := sandbox.New(req.Allow)
+	if err != nil {
+		send(SearchResponse{Summary: &search.Summary{}, Error: err.Error()}, true)
+		return werr
+	}
+	if sb != nil {
+		opts.Allow = sb.Allows
+	}
+	opts.Progress = func(p search.Progress) {
+		send(SearchResponse{Progress: &p}, true)
+	}
+	sum, err := search.SearchDirs(ctx, opts, req.Dirs, func(m search.Match) {
+		send(SearchResponse{Match: &m}, false)
+	})
+	last := SearchResponse{Summary: &sum}
+	if err != nil {
+		last.Error = err.Error()
+	}
+	send(last, true)
+	return werr
+}
+
+// Client is a search.Engine that runs searches in the daemon.
+type Client struct {
+	// Socket is the path the daemon listens on.
+	Socket string
+	// Sandbox, if set, confines the searches, standing in for
+	// Options.Allow.
+	Sandbox *sandbox.Sandbox
+}
+
+// Detect returns a Client of the daemon listening at SocketPath, confining
+// searches to sb, or nil when no daemon is running.
+func Detect(sb *sandbox.Sandbox) *Client {
+	path, err := SocketPath()
+	if err != nil {
+		return nil
+	}
+	if st, err := os.Stat(path); err != nil || st.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", path, dialTimeout)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+	return &Client{Socket: path, Sandbox: sb}
+}
+
+// Name returns "daemon".
+func (c *Client) Name() string { return "daemon" }
+
+// Search runs the search in the daemon, reporting its results to fn with
+// paths as a search of dirs from the working directory would give them.
+// When the daemon cannot take the search, it is run in this process
+// instead, saying why in Summary.Fallback.
+func (c *Client) Search(ctx context.Context, opts search.Options, dirs []string, fn func(search.Match)) (search.Summary, error) {
+	fallback := func(reason string) (search.Summary, error) {
+		sum, err := search.SearchDirs(ctx, opts, dirs, fn)
+		sum.Fallback = reason
+		return sum, err
+	}
+	if opts.Allow != nil && c.Sandbox == nil {
+		return fallback("the search is confined by a check the daemon cannot be sent")
+	}
+	if len(dirs) == 0 {
+		dirs = []string{opts.Dir}
+	}
+	req := SearchRequest{Options: opts, Allow: c.Sandbox.Dirs()}
+	req.Options.Dir = ""
+	abs := make([]string, len(dirs))
+	for i, d := range dirs {
+		if d == "" {
+			d = "."
+		}
+		a, err := filepath.Abs(d)
+		if err != nil {
+			return search.Summary{}, err
+		}
+		abs[i] = a
+	}
+	req.Dirs = abs
+	if opts.Files != nil {
+		req.Options.Files = make([]string, len(opts.Files))
+		for i, f := range opts.Files {
+			a, err := filepath.Abs(f)
+			if err != nil {
+				return search.Summary{}, err
+			}
+			req.Options.Files[i] = a
+		}
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.Socket)
+	if err != nil {
+		return fallback(fmt.Sprintf("the daemon is not reachable: %v", err))
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if err := json.NewEncoder(conn).Encode(struct {
+		Search SearchRequest `json:"search"`
+	}{req}); err != nil {
+		return fallback(fmt.Sprintf("the daemon is not reachable: %v", err))
+	}
+
+	// Paths come back below the absolute directories; each is given back
+	// below the directory it was asked for as
+	relative := func(p string) string {
+		best := -1
+		for i, a := range abs {
+			if (p == a || strings.HasPrefix(p, a+string(filepath.Separator))) && (best < 0 || len(a) > len(abs[best])) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return p
+		}
+		rel := strings.TrimPrefix(p[len(abs[best]):], string(filepath.Separator))
+		if rel == "" {
+			return dirs[best]
+		}
+		return filepath.Join(dirs[best], rel)
+	}
+	files := make(map[string]bool)
+	dec := json.NewDecoder(conn)
+	for {
+		var r SearchResponse
+		if err := dec.Decode(&r); err != nil {
+			if ctx.Err() != nil {
+				return search.Summary{Files: len(files), Cancelled: true}, nil
+			}
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return search.Summary{Files: len(files)}, fmt.Errorf("reading from the daemon: %w", err)
+		}
+		switch {
+		case r.Match != nil:
+			m := *r.Match
+			m.Path = relative(m.Path)
+			if m.Kind != search.ContextLine {
+				files[m.Path] = true
+			}
+			fn(m)
+		case r.Progress != nil:
+			if opts.Progress != nil {
+				opts.Progress(*r.Progress)
+			}
+		case r.Summary != nil:
+			if r.Error != "" {
+				return *r.Summary, errors.New(r.Error)
+			}
+			return *r.Summary, nil
+		}
+	}
+}
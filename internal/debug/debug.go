@@ -0,0 +1,115 @@
+// Package debug serves the endpoints of --debug-addr, for profiling the
+// daemon and the MCP server in place: the net/http/pprof profiles under
+// /debug/pprof/, and runtime stats, with the sizes of the indexes kept in
+// memory and the use of the result cache, as JSON at /debug/stats.
+package debug
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"vscode-helper-file-find/internal/cache"
+	"vscode-helper-file-find/internal/index"
+)
+
+// started is when the process started serving, for the uptime.
+var started = time.Now()
+
+// Stats are the runtime stats served at /debug/stats.
+type Stats struct {
+	Goroutines int     `json:"goroutines"`
+	Uptime     string  `json:"uptime"`
+	GoVersion  string  `json:"go_version"`
+	Memory     Memory  `json:"memory"`
+	Indexes    []Index `json:"indexes"`
+	// Cache is nil when the process has no result cache.
+	Cache *Cache `json:"cache"`
+}
+
+// Memory is a summary of runtime.MemStats.
+type Memory struct {
+	HeapAlloc   uint64 `json:"heap_alloc_bytes"`
+	HeapObjects uint64 `json:"heap_objects"`
+	Sys         uint64 `json:"sys_bytes"`
+	GCCycles    uint32 `json:"gc_cycles"`
+}
+
+// Index describes an index kept in memory.
+type Index struct {
+	Root    string    `json:"root"`
+	Built   time.Time `json:"built"`
+	Entries int       `json:"entries"`
+	// Trigrams and Postings are 0 unless it indexes file contents.
+	Trigrams int `json:"trigrams"`
+	Postings int `json:"postings"`
+}
+
+// Cache describes the result cache.
+type Cache struct {
+	Dir     string `json:"dir"`
+	TTL     string `json:"ttl"`
+	Hits    int64  `json:"hits"`
+	Misses  int64  `json:"misses"`
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Collect returns the stats of the process, with those of c when it is
+// not nil.
+func Collect(c *cache.Cache) Stats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	st := Stats{
+		Goroutines: runtime.NumGoroutine(),
+		Uptime:     time.Since(started).Round(time.Second).String(),
+		GoVersion:  runtime.Version(),
+		Memory:     Memory{HeapAlloc: ms.HeapAlloc, HeapObjects: ms.HeapObjects, Sys: ms.Sys, GCCycles: ms.NumGC},
+		Indexes:    []Index{},
+	}
+	for _, ix := range index.Resident() {
+		d := Index{Root: ix.Root, Built: ix.Built, Entries: len(ix.Entries), Trigrams: len(ix.Postings)}
+		for _, ids := range ix.Postings {
+			d.Postings += len(ids)
+		}
+		st.Indexes = append(st.Indexes, d)
+	}
+	if c != nil {
+		cs := c.Stats()
+		st.Cache = &Cache{Dir: cs.Dir, TTL: cs.TTL.String(), Hits: cs.Hits, Misses: cs.Misses, Entries: cs.Entries, Bytes: cs.Bytes}
+	}
+	return st
+}
+
+// Handler serves /debug/pprof/ and /debug/stats, reporting on c.
+func Handler(c *cache.Cache) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("GET /debug/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(Collect(c))
+	})
+	return mux
+}
+
+// Start listens on addr and serves Handler(c) there in the background for
+// the life of the process, returning the address listened on.
+func Start(addr string, c *cache.Cache) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// No write timeout: CPU profiles and traces run for as long as asked
+	srv := &http.Server{Handler: Handler(c), ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	return ln.Addr(), nil
+}
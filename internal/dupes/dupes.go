@@ -0,0 +1,171 @@
+// Package dupes finds files with identical contents: files are grouped by
+// size first, and only those sharing a size are hashed with SHA-256.
+package dupes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+
+	"vscode-helper-file-find/internal/search"
+)
+
+// Options controls which files Find compares.
+type Options struct {
+	// Search selects the files: those it would report by name, every file
+	// by default. Its MinSize and MaxSize bound the sizes compared.
+	Search search.Options
+}
+
+// Group is a set of files with the same contents.
+type Group struct {
+	Size int64  `json:"size"`
+	Hash string `json:"sha256"`
+	// Paths are relative to the directory searched, using '/' separators,
+	// in order.
+	Paths []string `json:"paths"`
+}
+
+// Result lists the duplicates in a directory.
+type Result struct {
+	Dir string `json:"dir"`
+	// Files counts the files compared, Hashed those that shared a size with
+	// another and so were read.
+	Files  int `json:"files"`
+	Hashed int `json:"hashed"`
+	// Wasted is the bytes the copies take beyond one file of each group.
+	Wasted int64 `json:"wasted"`
+	// Groups are ordered by the bytes they waste, most first.
+	Groups []Group `json:"groups"`
+}
+
+// Find returns the groups of files in opts.Search.Dir that opts selects and
+// whose contents are identical. Empty files are not compared. It stops
+// early, returning the error of ctx, once ctx is done.
+func Find(ctx context.Context, opts Options) (*Result, error) {
+	so := opts.Search
+	if len(so.Names) == 0 {
+		so.Names = []string{"*"}
+	}
+	so.Contents, so.Fuzzy, so.Sort, so.Boost, so.Offset, so.MaxResults = nil, false, "", nil, 0, 0
+	dir := so.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	res := &Result{Dir: dir, Groups: []Group{}}
+	bySize := map[int64][]string{}
+	sum, err := search.SearchContext(ctx, so, func(m search.Match) {
+		if m.Kind != search.NameMatch {
+			return
+		}
+		info, err := os.Stat(m.Path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+			return
+		}
+		res.Files++
+		bySize[info.Size()] = append(bySize[info.Size()], m.Path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sum.Cancelled {
+		return nil, ctx.Err()
+	}
+
+	type file struct {
+		path string
+		size int64
+		hash string
+	}
+	candidates := make(chan file)
+	var (
+		mu     sync.Mutex
+		hashed []file
+		wg     sync.WaitGroup
+	)
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range candidates {
+				if ctx.Err() != nil {
+					continue
+				}
+				h, err := hashFile(f.path)
+				if err != nil {
+					continue
+				}
+				f.hash = h
+				mu.Lock()
+				hashed = append(hashed, f)
+				mu.Unlock()
+			}
+		}()
+	}
+	for size, paths := range bySize {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, p := range paths {
+			candidates <- file{path: p, size: size}
+		}
+		res.Hashed += len(paths)
+	}
+	close(candidates)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	groups := map[string]*Group{}
+	for _, f := range hashed {
+		g := groups[f.hash]
+		if g == nil {
+			g = &Group{Size: f.size, Hash: f.hash}
+			groups[f.hash] = g
+		}
+		rel := f.path
+		if r, err := filepath.Rel(dir, f.path); err == nil {
+			rel = filepath.ToSlash(r)
+		}
+		g.Paths = append(g.Paths, rel)
+	}
+	for _, g := range groups {
+		if len(g.Paths) < 2 {
+			continue
+		}
+		sort.Strings(g.Paths)
+		res.Groups = append(res.Groups, *g)
+		res.Wasted += g.Size * int64(len(g.Paths)-1)
+	}
+	sort.Slice(res.Groups, func(i, j int) bool {
+		a, b := res.Groups[i], res.Groups[j]
+		wa, wb := a.Size*int64(len(a.Paths)-1), b.Size*int64(len(b.Paths)-1)
+		if wa != wb {
+			return wa > wb
+		}
+		return a.Paths[0] < b.Paths[0]
+	})
+	return res, nil
+}
+
+// hashFile returns the hex SHA-256 of the contents of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,217 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+)
+
+// TextSampleSize bounds how much of a file is read to detect its text
+// characteristics.
+const TextSampleSize = 1 << 20
+
+// binarySniffSize is how much of the sample is checked for NUL bytes when
+// deciding whether a file is binary.
+const binarySniffSize = 8 << 10
+
+// TextInfo describes the text characteristics of a file, detected from a
+// bounded read. For binary files only Binary is meaningful.
+type TextInfo struct {
+	Binary          bool   `json:"binary"`
+	LineEnding      string `json:"line_ending,omitempty"` // "LF", "CRLF", "mixed", or "none"
+	ValidUTF8       bool   `json:"valid_utf8,omitempty"`
+	BOM             bool   `json:"bom,omitempty"`
+	TrailingNewline bool   `json:"trailing_newline,omitempty"`
+	// Truncated is set when the analysis covered only the first
+	// TextSampleSize bytes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// FileInfo is the metadata returned by Info.
+type FileInfo struct {
+	Path    string    `json:"path"` // absolute
+	Type    string    `json:"type"` // as for EntryType
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mtime"`
+	// Target is where a symlink points.
+	Target string `json:"symlink_target,omitempty"`
+	// Language is the VS Code language identifier guessed from the name or
+	// a #! line, if any.
+	Language string `json:"language,omitempty"`
+	// Lines counts the lines of a text file.
+	Lines int `json:"lines,omitempty"`
+	// GitTracked reports whether git tracks the path (for a directory, any
+	// file below it). It is nil outside a git work tree or without git.
+	GitTracked *bool `json:"git_tracked,omitempty"`
+	// Text is set for regular files.
+	Text *TextInfo `json:"text,omitempty"`
+}
+
+// Info returns metadata for path without following a final symlink. For
+// regular files it also detects text characteristics, the language, and,
+// for text files, the line count.
+func Info(path string) (*FileInfo, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("'%s' does not exist", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file info: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get absolute path: %w", err)
+	}
+
+	fi := &FileInfo{
+		Path:       abs,
+		Type:       EntryType(info.Mode()),
+		Size:       info.Size(),
+		Mode:       info.Mode().String(),
+		ModTime:    info.ModTime(),
+		GitTracked: gitTracked(abs, info.IsDir()),
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		fi.Target, _ = os.Readlink(path)
+	}
+	if !info.Mode().IsRegular() {
+		return fi, nil
+	}
+
+	ti, err := DetectText(path, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %w", err)
+	}
+	fi.Text = &ti
+	fi.Language = DetectLanguage(path)
+	if !ti.Binary {
+		if fi.Lines, err = countLines(path); err != nil {
+			return nil, fmt.Errorf("unable to read file: %w", err)
+		}
+	}
+	return fi, nil
+}
+
+// DetectText reads up to TextSampleSize bytes of the file at path and
+// reports its line-ending style, UTF-8 validity, BOM presence, and whether
+// the file ends with a newline.
+func DetectText(path string, size int64) (TextInfo, error) {
+	var ti TextInfo
+	f, err := os.Open(path)
+	if err != nil {
+		return ti, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, TextSampleSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return ti, err
+	}
+	buf = buf[:n]
+	ti.Truncated = size > int64(n)
+
+	if bytes.IndexByte(buf[:min(len(buf), binarySniffSize)], 0) >= 0 {
+		ti.Binary = true
+		return ti, nil
+	}
+
+	ti.BOM = bytes.HasPrefix(buf, []byte{0xEF, 0xBB, 0xBF})
+
+	// Ignore a multi-byte sequence cut off by the sample boundary
+	sample := buf
+	if ti.Truncated && len(sample) > 0 {
+		if i := lastRuneStart(sample); !utf8.FullRune(sample[i:]) {
+			sample = sample[:i]
+		}
+	}
+	ti.ValidUTF8 = utf8.Valid(sample)
+
+	crlf := bytes.Count(buf, []byte("\r\n"))
+	lf := bytes.Count(buf, []byte("\n")) - crlf
+	switch {
+	case crlf > 0 && lf > 0:
+		ti.LineEnding = "mixed"
+	case crlf > 0:
+		ti.LineEnding = "CRLF"
+	case lf > 0:
+		ti.LineEnding = "LF"
+	default:
+		ti.LineEnding = "none"
+	}
+
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, size-1); err != nil && err != io.EOF {
+			return ti, err
+		}
+		ti.TrailingNewline = last[0] == '\n'
+	}
+	return ti, nil
+}
+
+// lastRuneStart returns the index of the first byte of the last
+// (possibly incomplete) UTF-8 sequence in b.
+func lastRuneStart(b []byte) int {
+	i := len(b) - 1
+	for i > 0 && !utf8.RuneStart(b[i]) {
+		i--
+	}
+	return i
+}
+
+// countLines returns the number of lines in the file at path, counting a
+// final line without a newline.
+func countLines(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	lines, last := 0, byte('\n')
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			lines += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if last != '\n' {
+		lines++
+	}
+	return lines, nil
+}
+
+// gitTracked asks git whether it tracks the absolute path. It returns nil
+// when the path is not in a git work tree or git is not available.
+func gitTracked(abs string, isDir bool) *bool {
+	dir := filepath.Dir(abs)
+	if isDir {
+		dir = abs
+	}
+	cmd := exec.Command("git", "-C", dir, "ls-files", "--error-unmatch", "--", abs)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	tracked := err == nil
+	var exitErr *exec.ExitError
+	if err != nil && (!errors.As(err, &exitErr) || !bytes.Contains(stderr.Bytes(), []byte("did not match"))) {
+		return nil // Not a repository, or no git
+	}
+	return &tracked
+}
@@ -0,0 +1,94 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectText(t *testing.T) {
+	bom := "\xEF\xBB\xBF"
+	tests := []struct {
+		name    string
+		content string
+		want    TextInfo
+	}{
+		{
+			name:    "LF",
+			content: "one\ntwo\n",
+			want:    TextInfo{LineEnding: "LF", ValidUTF8: true, TrailingNewline: true},
+		},
+		{
+			name:    "CRLF",
+			content: "one\r\ntwo\r\n",
+			want:    TextInfo{LineEnding: "CRLF", ValidUTF8: true, TrailingNewline: true},
+		},
+		{
+			name:    "mixed",
+			content: "one\r\ntwo\n",
+			want:    TextInfo{LineEnding: "mixed", ValidUTF8: true, TrailingNewline: true},
+		},
+		{
+			name:    "CRLF without final newline",
+			content: "one\r\ntwo",
+			want:    TextInfo{LineEnding: "CRLF", ValidUTF8: true},
+		},
+		{
+			name:    "no final newline",
+			content: "one\ntwo",
+			want:    TextInfo{LineEnding: "LF", ValidUTF8: true},
+		},
+		{
+			name:    "single line without newline",
+			content: "one",
+			want:    TextInfo{LineEnding: "none", ValidUTF8: true},
+		},
+		{
+			name:    "BOM",
+			content: bom + "one\ntwo\n",
+			want:    TextInfo{LineEnding: "LF", ValidUTF8: true, BOM: true, TrailingNewline: true},
+		},
+		{
+			name:    "BOM with CRLF and no final newline",
+			content: bom + "one\r\ntwo",
+			want:    TextInfo{LineEnding: "CRLF", ValidUTF8: true, BOM: true},
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    TextInfo{LineEnding: "none", ValidUTF8: true},
+		},
+		{
+			name:    "invalid UTF-8",
+			content: "caf\xE9\n",
+			want:    TextInfo{LineEnding: "LF", TrailingNewline: true},
+		},
+		{
+			name:    "binary",
+			content: "one\x00two\n",
+			want:    TextInfo{Binary: true},
+		},
+		{
+			name:    "larger than the sample",
+			content: strings.Repeat("line\r\n", TextSampleSize/6+10) + "end",
+			want:    TextInfo{LineEnding: "CRLF", ValidUTF8: true, Truncated: true},
+		},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "f"+string(rune('a'+i)))
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := DetectText(path, int64(len(tt.content)))
+			if err != nil {
+				t.Fatalf("DetectText: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectText(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package files
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DetectLanguage guesses the VS Code language identifier of the file at
+// path from its name, or else from a #! line. It returns "" if unknown.
+func DetectLanguage(path string) string {
+	base := filepath.Base(path)
+	if lang, ok := languageNames[base]; ok {
+		return lang
+	}
+	if lang, ok := languageExts[filepath.Ext(base)]; ok {
+		return lang
+	}
+	return shebangLanguage(path)
+}
+
+// shebangLanguage returns the language named by the interpreter on the
+// file's #! line.
+func shebangLanguage(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(io.LimitReader(f, 256)).ReadString('\n')
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" && len(fields) > 1 {
+		interp = fields[1]
+	}
+	return shebangInterpreters[strings.TrimRight(interp, "0123456789.")]
+}
+
+// languageNames maps well-known file names to languages.
+var languageNames = map[string]string{
+	"Dockerfile":     "dockerfile",
+	"Makefile":       "makefile",
+	"GNUmakefile":    "makefile",
+	"CMakeLists.txt": "cmake",
+	"go.mod":         "go.mod",
+	"go.sum":         "go.sum",
+	".gitignore":     "ignore",
+	".dockerignore":  "ignore",
+	".bashrc":        "shellscript",
+	".zshrc":         "shellscript",
+}
+
+// languageExts maps file extensions to languages.
+var languageExts = map[string]string{
+	".go":    "go",
+	".py":    "python",
+	".js":    "javascript",
+	".mjs":   "javascript",
+	".cjs":   "javascript",
+	".jsx":   "javascriptreact",
+	".ts":    "typescript",
+	".tsx":   "typescriptreact",
+	".json":  "json",
+	".jsonc": "jsonc",
+	".md":    "markdown",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+	".toml":  "toml",
+	".xml":   "xml",
+	".html":  "html",
+	".htm":   "html",
+	".css":   "css",
+	".scss":  "scss",
+	".less":  "less",
+	".sh":    "shellscript",
+	".bash":  "shellscript",
+	".zsh":   "shellscript",
+	".ps1":   "powershell",
+	".c":     "c",
+	".h":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".cxx":   "cpp",
+	".hpp":   "cpp",
+	".cs":    "csharp",
+	".java":  "java",
+	".kt":    "kotlin",
+	".kts":   "kotlin",
+	".swift": "swift",
+	".rs":    "rust",
+	".rb":    "ruby",
+	".php":   "php",
+	".lua":   "lua",
+	".pl":    "perl",
+	".r":     "r",
+	".R":     "r",
+	".scala": "scala",
+	".sql":   "sql",
+	".proto": "proto3",
+	".tf":    "terraform",
+	".vue":   "vue",
+	".dart":  "dart",
+	".ex":    "elixir",
+	".exs":   "elixir",
+	".erl":   "erlang",
+	".hs":    "haskell",
+	".clj":   "clojure",
+	".ini":   "ini",
+	".bat":   "bat",
+	".cmd":   "bat",
+	".tex":   "latex",
+	".txt":   "plaintext",
+}
+
+// shebangInterpreters maps interpreter names, optionally followed by a
+// version number (python3, python3.12), to languages.
+var shebangInterpreters = map[string]string{
+	"sh":     "shellscript",
+	"bash":   "shellscript",
+	"zsh":    "shellscript",
+	"dash":   "shellscript",
+	"python": "python",
+	"node":   "javascript",
+	"ruby":   "ruby",
+	"perl":   "perl",
+	"php":    "php",
+	"lua":    "lua",
+	"pwsh":   "powershell",
+}
@@ -0,0 +1,127 @@
+package files
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultMaxEntries is the entry limit used by List when none is given.
+const DefaultMaxEntries = 1000
+
+// ListOptions controls a directory listing.
+type ListOptions struct {
+	// Depth is how many directory levels to descend. Zero or one lists only
+	// the immediate entries.
+	Depth int
+	// MaxEntries caps the number of entries returned. Zero means
+	// DefaultMaxEntries.
+	MaxEntries int
+}
+
+// Entry describes a single directory entry.
+type Entry struct {
+	// Path is relative to the listed directory, using '/' separators.
+	Path    string    `json:"path"`
+	Type    string    `json:"type"` // "file", "directory", "symlink", or "other"
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// ListResult is the listing returned by List.
+type ListResult struct {
+	Path    string  `json:"path"`
+	Entries []Entry `json:"entries"`
+	// Truncated is set when more than MaxEntries entries were found.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// EntryType classifies a file mode as "file", "directory", "symlink", or
+// "other".
+func EntryType(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	case mode.IsRegular():
+		return "file"
+	default:
+		return "other"
+	}
+}
+
+// List returns the entries under dir in lexical walk order, descending up to
+// opts.Depth levels. Symlinks are reported but not followed.
+func List(dir string, opts ListOptions) (*ListResult, error) {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("'%s' does not exist", dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file info: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("'%s' is not a directory", dir)
+	}
+
+	depth := max(opts.Depth, 1)
+	limit := opts.MaxEntries
+	if limit <= 0 {
+		limit = DefaultMaxEntries
+	}
+
+	res := &ListResult{Path: dir, Entries: []Entry{}}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subdirectories are listed but not descended into
+			if path != dir {
+				return nil
+			}
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if len(res.Entries) == limit {
+			res.Truncated = true
+			return filepath.SkipAll
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Entry vanished during the walk
+		}
+		res.Entries = append(res.Entries, Entry{
+			Path:    filepath.ToSlash(rel),
+			Type:    EntryType(info.Mode()),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		if d.IsDir() && entryDepth(rel) >= depth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// entryDepth returns how many levels below the listed directory rel is; an
+// immediate entry has depth 1.
+func entryDepth(rel string) int {
+	n := 1
+	for _, c := range rel {
+		if c == filepath.Separator {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,103 @@
+// Package files implements file inspection helpers shared by the CLI and the
+// MCP server.
+package files
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultMaxBytes is the content limit used by Read when none is given.
+const DefaultMaxBytes = 256 << 10
+
+// ReadOptions selects what part of a file Read returns.
+type ReadOptions struct {
+	// StartLine is the first 1-based line to return. Zero means line 1.
+	StartLine int
+	// EndLine is the last 1-based line to return, inclusive. Zero means the
+	// end of the file.
+	EndLine int
+	// MaxBytes caps the returned content. Zero means DefaultMaxBytes.
+	MaxBytes int
+}
+
+// ReadResult is the content returned by Read.
+type ReadResult struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	// StartLine and EndLine are the 1-based line range actually returned.
+	// EndLine is less than StartLine when no lines were returned.
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+	// Truncated is set when the requested range exceeded MaxBytes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Read returns the lines StartLine through EndLine of the file at path,
+// stopping early if the content would exceed MaxBytes. Whole lines are
+// returned unless a single line is longer than the limit.
+func Read(path string, opts ReadOptions) (*ReadResult, error) {
+	if opts.StartLine < 0 || opts.EndLine < 0 {
+		return nil, errors.New("line numbers must be positive")
+	}
+	start := max(opts.StartLine, 1)
+	if opts.EndLine > 0 && opts.EndLine < start {
+		return nil, fmt.Errorf("end line %d is before start line %d", opts.EndLine, start)
+	}
+	limit := opts.MaxBytes
+	if limit <= 0 {
+		limit = DefaultMaxBytes
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("'%s' does not exist", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file info: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("'%s' is a directory", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := &ReadResult{Path: path, StartLine: start, EndLine: start - 1}
+	var content strings.Builder
+	r := bufio.NewReader(f)
+	for lineNum := 1; opts.EndLine == 0 || lineNum <= opts.EndLine; lineNum++ {
+		line, err := r.ReadString('\n')
+		if line == "" && err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if lineNum >= start {
+			if content.Len()+len(line) > limit {
+				if content.Len() == 0 {
+					// A single oversized line: return its prefix
+					content.WriteString(line[:limit])
+					res.EndLine = lineNum
+				}
+				res.Truncated = true
+				break
+			}
+			content.WriteString(line)
+			res.EndLine = lineNum
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	res.Content = content.String()
+	return res, nil
+}
@@ -0,0 +1,77 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WriteOptions controls how Write treats an existing file.
+type WriteOptions struct {
+	// Overwrite replaces an existing file. Without it Write refuses to
+	// touch one.
+	Overwrite bool
+	// Mode, if not zero, sets the permissions of the file written, new or
+	// overwritten.
+	Mode os.FileMode
+}
+
+// WriteResult describes the file written by Write.
+type WriteResult struct {
+	Path  string `json:"path"`
+	Bytes int    `json:"bytes"`
+	// Created is set when the file did not exist before.
+	Created bool `json:"created"`
+}
+
+// Write stores content in the file at path, creating missing parent
+// directories. The content is written to a temporary file in the same
+// directory and renamed into place, so readers never see a partial file.
+// Unless opts.Mode is set, an overwritten file keeps its permissions and a
+// new one gets 0644.
+func Write(path string, content []byte, opts WriteOptions) (*WriteResult, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get absolute path: %w", err)
+	}
+	mode := os.FileMode(0o644)
+	info, err := os.Stat(abs)
+	switch {
+	case err == nil && info.IsDir():
+		return nil, fmt.Errorf("'%s' is a directory", path)
+	case err == nil && !opts.Overwrite:
+		return nil, fmt.Errorf("'%s' already exists", path)
+	case err == nil:
+		mode = info.Mode().Perm()
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("unable to get file info: %w", err)
+	}
+	if opts.Mode != 0 {
+		mode = opts.Mode
+	}
+
+	dir := filepath.Dir(abs)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(abs)+".*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), abs); err != nil {
+		return nil, err
+	}
+	return &WriteResult{Path: abs, Bytes: len(content), Created: info == nil}, nil
+}
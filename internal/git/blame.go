@@ -0,0 +1,30 @@
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// Authors returns the author of each line of the file at path as git blame
+// has it, the first line's first. Lines not committed yet are git's "Not
+// Committed Yet".
+func Authors(ctx context.Context, path string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := run(ctx, filepath.Dir(abs), "blame", "--line-porcelain", "--", filepath.Base(abs))
+	if err != nil {
+		return nil, err
+	}
+	// Each line's headers precede it, and the line itself starts with a
+	// tab, so it cannot be taken for a header
+	var authors []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if name, ok := strings.CutPrefix(line, "author "); ok {
+			authors = append(authors, name)
+		}
+	}
+	return authors, nil
+}
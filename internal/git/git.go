@@ -0,0 +1,165 @@
+// Package git asks the git command line about the work trees that files
+// and searched directories belong to.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Statuses of a Change.
+const (
+	Added     = "added"
+	Modified  = "modified"
+	Deleted   = "deleted"
+	Untracked = "untracked"
+)
+
+// Change is a file that differs from the commit it is compared with.
+type Change struct {
+	// Path is the absolute path of the file.
+	Path string `json:"path"`
+	// Status is one of Added, Modified, Deleted, or Untracked. A renamed
+	// file is a deletion and an addition.
+	Status string `json:"status"`
+}
+
+// errNoGit is returned when there is no git to run.
+var errNoGit = errors.New("git is not installed or not in PATH")
+
+// run runs git with args in dir and returns its standard output.
+func run(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, errNoGit
+	}
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s failed: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// Root returns the top directory of the work tree dir is in.
+func Root(ctx context.Context, dir string) (string, error) {
+	out, err := run(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", workTreeError(dir, err)
+	}
+	return filepath.Clean(strings.TrimSpace(string(out))), nil
+}
+
+// Changed returns the files below dir that differ from HEAD, staged or
+// not, and those git does not track but does not ignore either. With
+// against, a commit or branch, it returns those that changed since the
+// current branch left it instead, as git diff against...; uncommitted
+// changes and untracked files are included in that case too. The files
+// are sorted by path.
+func Changed(ctx context.Context, dir, against string) ([]Change, error) {
+	// git reports paths relative to the top of the work tree, which are
+	// turned into ones below dir as given rather than git's resolved root,
+	// so that they match those a walk of dir finds
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := run(ctx, dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, workTreeError(dir, err)
+	}
+	prefix := strings.TrimSpace(string(out))
+	var changes []Change
+	add := func(rel, status string) {
+		rel = strings.TrimPrefix(rel, prefix)
+		changes = append(changes, Change{Path: filepath.Join(abs, filepath.FromSlash(rel)), Status: status})
+	}
+	if against == "" {
+		out, err = run(ctx, dir, "status", "--porcelain", "-z", "--untracked-files=all", "--no-renames", "--", ".")
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range split(out) {
+			if len(entry) < 4 {
+				continue
+			}
+			add(entry[3:], statusOf(entry[:2]))
+		}
+	} else {
+		base, err := run(ctx, dir, "merge-base", "--end-of-options", against, "HEAD")
+		if err != nil {
+			return nil, fmt.Errorf("unable to compare with '%s': %w", against, err)
+		}
+		out, err := run(ctx, dir, "diff", "--name-status", "-z", "--no-renames", strings.TrimSpace(string(base)), "--", ".")
+		if err != nil {
+			return nil, err
+		}
+		fields := split(out)
+		for i := 0; i+1 < len(fields); i += 2 {
+			add(fields[i+1], statusOf(fields[i]))
+		}
+		out, err = run(ctx, dir, "ls-files", "-z", "--others", "--exclude-standard", "--full-name", "--", ".")
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range split(out) {
+			add(rel, Untracked)
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int { return strings.Compare(a.Path, b.Path) })
+	return changes, nil
+}
+
+// workTreeError is the error for dir when git rev-parse failed there with
+// err, which unless git is missing means dir is not in a work tree.
+func workTreeError(dir string, err error) error {
+	if errors.Is(err, errNoGit) {
+		return err
+	}
+	return fmt.Errorf("'%s' is not in a git work tree", dir)
+}
+
+// split splits NUL-terminated output into its fields.
+func split(out []byte) []string {
+	s := strings.TrimSuffix(string(out), "\x00")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\x00")
+}
+
+// statusOf returns the status of a file from its code in git status
+// --porcelain (two letters, staged and unstaged) or git diff --name-status
+// (one).
+func statusOf(code string) string {
+	if code == "??" {
+		return Untracked
+	}
+	if len(code) == 2 && code[1] == 'D' {
+		// Gone from the work tree, whatever was staged
+		return Deleted
+	}
+	// Otherwise the staged change says more than a later edit: an added
+	// file that was modified since is still new
+	for _, c := range code {
+		switch c {
+		case 'A':
+			return Added
+		case 'D':
+			return Deleted
+		case 'M', 'T', 'U':
+			return Modified
+		}
+	}
+	return Modified
+}
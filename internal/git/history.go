@@ -0,0 +1,204 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Changes made to a line, in a HistoryMatch.
+const (
+	LineAdded   = "added"
+	LineRemoved = "removed"
+)
+
+// HistoryQuery describes a search of the commit history.
+type HistoryQuery struct {
+	// Term is the text looked for: a commit matches when it changes the
+	// number of times the term occurs in a file, as git log -S finds.
+	Term string
+	// Regex treats Term as a regular expression, and a commit matches when
+	// it adds or removes a line matching it, as git log -G finds. git reads
+	// it as a POSIX extended regular expression, so the RE2 syntax it lacks,
+	// such as \d, \w, or (?i), is refused.
+	Regex bool
+	// IgnoreCase matches Term regardless of case.
+	IgnoreCase bool
+	// MaxResults, if positive, stops the search after that many lines.
+	MaxResults int
+}
+
+// HistoryMatch is a line carrying the term that a commit added or removed.
+type HistoryMatch struct {
+	Commit  string    `json:"commit"`
+	Date    time.Time `json:"date"`
+	Author  string    `json:"author"`
+	Subject string    `json:"subject"`
+	// Path is the absolute path of the file the line was in.
+	Path string `json:"path"`
+	// Line is the line's number in the file after the commit when added,
+	// and before it when removed.
+	Line   int    `json:"line"`
+	Change string `json:"change"`
+	Text   string `json:"text"`
+}
+
+// hunkHeader matches the start of a hunk of a unified diff, capturing the
+// first old and new line numbers and how many of each follow.
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// commitPrefix starts the header line git log writes for each commit with
+// the format History gives it.
+const commitPrefix = "\x00commit "
+
+// History reports to fn, newest commit first, the lines carrying q.Term
+// that commits added to or removed from the files below dir. It stops
+// early, without error, once ctx is done.
+func History(ctx context.Context, dir string, q HistoryQuery, fn func(HistoryMatch)) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return errNoGit
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if _, err := run(ctx, dir, "rev-parse", "--show-prefix"); err != nil {
+		return workTreeError(dir, err)
+	}
+	pattern := q.Term
+	if !q.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	} else if _, err := regexp.CompilePOSIX(pattern); err != nil {
+		// git would read the pattern otherwise and skip commits the lines
+		// are then filtered from
+		return fmt.Errorf("invalid regular expression for git (POSIX extended syntax, so no \\d, \\w, or (?i)): %w", err)
+	}
+	if q.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regular expression: %w", err)
+	}
+
+	args := []string{"-C", dir, "-c", "core.quotePath=false", "log", "-p", "-U0", "--no-color", "--no-ext-diff", "--no-renames", "--relative",
+		"--format=" + strings.ReplaceAll(commitPrefix, "\x00", "%x00") + "%H%x00%aI%x00%an%x00%s"}
+	if q.Regex {
+		args = append(args, "-G", q.Term)
+	} else {
+		args = append(args, "-S", q.Term)
+	}
+	if q.IgnoreCase {
+		args = append(args, "--regexp-ignore-case")
+	}
+	args = append(args, "--", ".")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to run git: %w", err)
+	}
+
+	var commit HistoryMatch
+	var oldPath, newPath string
+	// Lines of the current hunk are told from headers by counting them off
+	oldLine, newLine, oldLeft, newLeft, results := 0, 0, 0, 0, 0
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		inHunk := oldLeft > 0 && strings.HasPrefix(line, "-") || newLeft > 0 && strings.HasPrefix(line, "+")
+		switch {
+		case inHunk:
+			m := commit
+			if line[0] == '-' {
+				m.Path, m.Line, m.Change = oldPath, oldLine, LineRemoved
+				oldLine++
+				oldLeft--
+			} else {
+				m.Path, m.Line, m.Change = newPath, newLine, LineAdded
+				newLine++
+				newLeft--
+			}
+			m.Text = line[1:]
+			if m.Path == "" || !re.MatchString(m.Text) {
+				continue
+			}
+			m.Path = filepath.Join(abs, filepath.FromSlash(m.Path))
+			fn(m)
+			if results++; q.MaxResults > 0 && results == q.MaxResults {
+				cancel()
+			}
+		case strings.HasPrefix(line, commitPrefix):
+			fields := strings.SplitN(line[len(commitPrefix):], "\x00", 4)
+			if len(fields) < 4 {
+				continue
+			}
+			date, _ := time.Parse(time.RFC3339, fields[1])
+			commit = HistoryMatch{Commit: fields[0], Date: date, Author: fields[2], Subject: fields[3]}
+		case strings.HasPrefix(line, "diff --git "):
+			oldPath, newPath = "", ""
+		case strings.HasPrefix(line, "--- "):
+			oldPath = diffPath(line[4:], "a/")
+		case strings.HasPrefix(line, "+++ "):
+			newPath = diffPath(line[4:], "b/")
+		case strings.HasPrefix(line, "@@"):
+			if m := hunkHeader.FindStringSubmatch(line); m != nil {
+				oldLine, oldLeft = hunkRange(m[1], m[2])
+				newLine, newLeft = hunkRange(m[3], m[4])
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		// Stopped at MaxResults, or by the caller
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("git log failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// hunkRange returns the first line and line count of one side of a hunk
+// header, in which a missing count means one line.
+func hunkRange(start, count string) (int, int) {
+	n, _ := strconv.Atoi(start)
+	if count == "" {
+		return n, 1
+	}
+	c, _ := strconv.Atoi(count)
+	return n, c
+}
+
+// diffPath returns the path in a ---/+++ line of a diff, without the given
+// prefix, or "" for /dev/null.
+func diffPath(s, prefix string) string {
+	if s == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(s, `"`) {
+		// Paths with control characters stay quoted
+		if u, err := strconv.Unquote(s); err == nil {
+			s = u
+		}
+	}
+	return strings.TrimPrefix(s, prefix)
+}
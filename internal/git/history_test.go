@@ -0,0 +1,60 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRepo returns a repository with one commit adding a file of lines.
+func newRepo(t *testing.T, lines ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "retry.go"), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "retry.go"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "Add retries"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	return dir
+}
+
+func TestHistoryRegexRefusesRE2Syntax(t *testing.T) {
+	dir := newRepo(t, "const MaxRetries = 3")
+	var matches []HistoryMatch
+	err := History(context.Background(), dir, HistoryQuery{Term: `\d+`, Regex: true}, func(m HistoryMatch) {
+		matches = append(matches, m)
+	})
+	if err == nil || !strings.Contains(err.Error(), "POSIX extended") {
+		t.Fatalf(`History(\d+) error = %v, want the POSIX syntax error`, err)
+	}
+	if len(matches) != 0 {
+		t.Errorf(`History(\d+) reported %d matches, want none`, len(matches))
+	}
+}
+
+func TestHistoryRegexPOSIX(t *testing.T) {
+	dir := newRepo(t, "package retry", "const MaxRetries = 3")
+	var matches []HistoryMatch
+	err := History(context.Background(), dir, HistoryQuery{Term: `[0-9]+`, Regex: true}, func(m HistoryMatch) {
+		matches = append(matches, m)
+	})
+	if err != nil {
+		t.Fatalf("History([0-9]+): %v", err)
+	}
+	if len(matches) != 1 || matches[0].Line != 2 || matches[0].Change != LineAdded || matches[0].Text != "const MaxRetries = 3" {
+		t.Errorf("History([0-9]+) = %+v, want line 2 added", matches)
+	}
+}
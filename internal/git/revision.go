@@ -0,0 +1,107 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RevisionDir is the directory, below the system temporary directory,
+// that ExtractRevision writes files to.
+const RevisionDir = "vscode-helper-revisions"
+
+// Revision is a file as of a commit, extracted by ExtractRevision.
+type Revision struct {
+	// Path is the absolute path of the extracted copy.
+	Path string `json:"path"`
+	// Source is the absolute path of the file in the work tree.
+	Source string `json:"source"`
+	// Commit is the full hash of the commit, empty for the blame of the
+	// work tree.
+	Commit string `json:"commit,omitempty"`
+	// Blame is set when the copy is git blame's annotation of the file.
+	Blame bool `json:"blame,omitempty"`
+}
+
+// ExtractRevision writes the file at path as of rev, a commit or branch,
+// to a read-only copy under RevisionDir and returns where. With blame the
+// copy is git blame's annotation of the file as of rev instead, and rev
+// may be empty for that of the work tree; it is named after the file with
+// .blame appended. The file need not exist in the work tree any more.
+func ExtractRevision(ctx context.Context, path, rev string, blame bool) (*Revision, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	// The file may be gone, so git runs in its directory's nearest
+	// existing ancestor
+	dir := filepath.Dir(abs)
+	for {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	commit := ""
+	if rev != "" || !blame {
+		if rev == "" {
+			rev = "HEAD"
+		}
+		out, err := run(ctx, dir, "rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+		if err != nil {
+			if _, rootErr := Root(ctx, dir); rootErr != nil {
+				return nil, rootErr
+			}
+			return nil, fmt.Errorf("unknown revision '%s'", rev)
+		}
+		commit = strings.TrimSpace(string(out))
+	}
+
+	short := "work-tree"
+	if commit != "" {
+		short = commit[:min(len(commit), 12)]
+	}
+
+	var content []byte
+	name := filepath.Base(abs)
+	if blame {
+		args := []string{"blame", "--date=short"}
+		if commit != "" {
+			args = append(args, commit)
+		}
+		if content, err = run(ctx, dir, append(args, "--", rel)...); err != nil {
+			return nil, err
+		}
+		name += ".blame"
+	} else if content, err = run(ctx, dir, "show", commit+":./"+rel); err != nil {
+		return nil, fmt.Errorf("'%s' is not in commit %s", path, short)
+	}
+
+	// Copies of different files of one name, from one commit, are kept
+	// apart by a hash of the source path
+	h := fnv.New32a()
+	h.Write([]byte(abs))
+	target := filepath.Join(os.TempDir(), RevisionDir, short, fmt.Sprintf("%08x", h.Sum32()), name)
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return nil, err
+	}
+	// Replace any earlier copy, which is read-only
+	os.Remove(target)
+	if err := os.WriteFile(target, content, 0o400); err != nil {
+		return nil, err
+	}
+	return &Revision{Path: target, Source: abs, Commit: commit, Blame: blame}, nil
+}
@@ -0,0 +1,144 @@
+// Package gopls asks gopls, the Go language server, where Go identifiers
+// are defined. gopls is run headless, from its command line, once per
+// question, in the module of the file asked about.
+package gopls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Location is where an identifier is defined.
+type Location struct {
+	// Path is the absolute path of the file.
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	// Name and Kind, such as Function or Struct, are set for the
+	// definitions found by name.
+	Name string `json:"name,omitempty"`
+	Kind string `json:"kind,omitempty"`
+	// Description is what gopls shows on hover for a definition found from
+	// a position: its declaration and doc comment.
+	Description string `json:"description,omitempty"`
+}
+
+// errNoGopls is returned when there is no gopls to run.
+var errNoGopls = errors.New("gopls is not installed or not in PATH (install it with 'go install golang.org/x/tools/gopls@latest')")
+
+// run runs gopls with args in dir and returns its standard output.
+func run(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("gopls"); err != nil {
+		return nil, errNoGopls
+	}
+	cmd := exec.CommandContext(ctx, "gopls", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("gopls %s failed: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("gopls %s failed: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// Definition returns where the identifier at line and column, both 1-based
+// and the column in bytes, of the Go file at path is defined.
+func Definition(ctx context.Context, path string, line, column int) (*Location, error) {
+	if line < 1 || column < 1 {
+		return nil, errors.New("line and column must be at least 1")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return nil, fmt.Errorf("'%s' does not exist", path)
+	}
+	out, err := run(ctx, filepath.Dir(abs), "definition", "-json", fmt.Sprintf("%s:%d:%d", abs, line, column))
+	if err != nil {
+		return nil, err
+	}
+	var def struct {
+		Span struct {
+			URI   string `json:"uri"`
+			Start struct {
+				Line   int `json:"line"`
+				Column int `json:"column"`
+			} `json:"start"`
+		} `json:"span"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(out, &def); err != nil {
+		return nil, fmt.Errorf("unexpected output from gopls definition: %w", err)
+	}
+	target, err := uriPath(def.Span.URI)
+	if err != nil {
+		return nil, err
+	}
+	return &Location{Path: target, Line: def.Span.Start.Line, Column: def.Span.Start.Column, Description: strings.TrimSpace(def.Description)}, nil
+}
+
+// symbolLine matches a line of gopls workspace_symbol: a span such as
+// path:12:6-9, the symbol's name, and its kind.
+var symbolLine = regexp.MustCompile(`^(.+):(\d+):(\d+)(?:-[\d:]+)? (\S+) (\S+)$`)
+
+// Symbols returns the definitions named name in the workspace of dir: the
+// Go module or workspace it is in. A method matches by its own name or as
+// Type.Method.
+func Symbols(ctx context.Context, dir, name string) ([]Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("a symbol name is required")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := run(ctx, abs, "workspace_symbol", "-matcher=casesensitive", name)
+	if err != nil {
+		return nil, err
+	}
+	var locs []Location
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		m := symbolLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		// The matcher is for substrings; only the name itself, possibly
+		// qualified by package or type, is kept
+		if m[4] != name && !strings.HasSuffix(m[4], "."+name) {
+			continue
+		}
+		l, _ := strconv.Atoi(m[2])
+		c, _ := strconv.Atoi(m[3])
+		path := m[1]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(abs, path)
+		}
+		locs = append(locs, Location{Path: path, Line: l, Column: c, Name: m[4], Kind: m[5]})
+	}
+	return locs, nil
+}
+
+// uriPath returns the local path of a file:// URI.
+func uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", fmt.Errorf("unexpected location '%s' from gopls", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
@@ -0,0 +1,307 @@
+// Package index implements a persistent on-disk file index that lets search
+// skip walking large trees. An index records every entry below its root
+// along with sizes and modification times, and optionally a trigram index
+// of file contents used to rule out files that cannot contain a term.
+package index
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// version is bumped whenever the on-disk format changes; indexes written by
+// other versions are ignored.
+const version = 2
+
+// MaxTrigramFileSize is the largest file whose contents are added to the
+// trigram index. Larger files are always scanned.
+const MaxTrigramFileSize = 1 << 20
+
+// ErrNotFound is returned by Load when no index covers a directory.
+var ErrNotFound = errors.New("no index found")
+
+// Entry is a file or directory recorded in an index.
+type Entry struct {
+	// Path is relative to the index root, using '/' separators.
+	Path    string
+	Mode    fs.FileMode
+	Size    int64
+	ModTime int64 // Unix nanoseconds
+	// Trigrams is set when the file's contents are in the trigram index
+	// under ID.
+	Trigrams bool
+	ID       uint32
+}
+
+// Index is a snapshot of a directory tree.
+type Index struct {
+	Version int
+	// Root is the absolute path of the indexed directory.
+	Root        string
+	RootModTime int64
+	Built       time.Time
+	// Entries are every file and directory below Root except .git, in the
+	// order filepath.WalkDir visits them.
+	Entries []Entry
+	// Postings maps a trigram to the IDs of the files containing it. It is
+	// nil unless the index was built with trigrams. IDs of files removed or
+	// rewritten by Update are left behind and simply never looked up.
+	Postings map[uint32][]uint32
+	NextID   uint32
+}
+
+// BuildOptions controls how an index is built.
+type BuildOptions struct {
+	// Trigrams also indexes file contents for faster content searches.
+	Trigrams bool
+}
+
+// CacheDir returns the directory where indexes are stored, normally
+// ~/.cache/vscode-helper/index.
+func CacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vscode-helper", "index"), nil
+}
+
+// pathFor returns the index file for the absolute directory root.
+func pathFor(root string) (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".gob.gz"), nil
+}
+
+// Build walks dir and returns an index of it. Ignore rules are not applied
+// so the index can serve searches with and without them.
+func Build(dir string, opts BuildOptions) (*Index, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("directory '%s' does not exist", dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get file info: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("'%s' is not a directory", dir)
+	}
+
+	ix := &Index{Version: version, Root: root, RootModTime: info.ModTime().UnixNano(), Built: time.Now()}
+	if opts.Trigrams {
+		ix.Postings = make(map[uint32][]uint32)
+	}
+	entries, err := ix.scan(root)
+	if err != nil {
+		return nil, err
+	}
+	ix.Entries = entries
+	return ix, nil
+}
+
+// scan walks the tree at path, which is root or lies below it, and returns
+// its entries, adding file contents to the trigram index if it has one.
+// Unreadable entries are left out.
+func (ix *Index) scan(path string) ([]Entry, error) {
+	var entries []Entry
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p != path {
+				return nil
+			}
+			return err
+		}
+		if p == ix.Root {
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Entry vanished during the walk
+		}
+		rel, err := filepath.Rel(ix.Root, p)
+		if err != nil {
+			return err
+		}
+		e := Entry{Path: filepath.ToSlash(rel), Mode: info.Mode(), Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		if ix.Postings != nil && info.Mode().IsRegular() && info.Size() <= MaxTrigramFileSize {
+			e.ID = ix.NextID
+			if e.Trigrams = ix.addTrigrams(p, e.ID); e.Trigrams {
+				ix.NextID++
+			}
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+// Save writes ix to the cache directory, replacing any earlier index of the
+// same root.
+func (ix *Index) Save() error {
+	path, err := pathFor(ix.Root)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".index-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	if err := gob.NewEncoder(zw).Encode(ix); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load returns the index covering dir: the index of dir itself or of its
+// nearest indexed ancestor, the one held in memory by Keep if there is
+// one. It returns ErrNotFound if there is none.
+func Load(dir string) (*Index, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		if ix := kept(d); ix != nil {
+			return ix, nil
+		}
+		path, err := pathFor(d)
+		if err != nil {
+			return nil, err
+		}
+		ix, err := readIndex(path)
+		if err == nil && ix.Version == version && ix.Root == d {
+			return ix, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if filepath.Dir(d) == d {
+			return nil, ErrNotFound
+		}
+	}
+}
+
+// Remove deletes the index of dir, if any.
+func Remove(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	path, err := pathFor(abs)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func readIndex(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt index %s: %w", path, err)
+	}
+	var ix Index
+	if err := gob.NewDecoder(zr).Decode(&ix); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("corrupt index %s: %w", path, err)
+	}
+	return &ix, nil
+}
+
+// Fresh reports whether the part of the index below dir still matches the
+// file system. Adding, removing, or renaming an entry changes its parent
+// directory's modification time, so only directories need to be checked.
+// Edits to file contents are not detected here; see Filter.
+func (ix *Index) Fresh(dir string) bool {
+	prefix, ok := ix.prefix(dir)
+	if !ok {
+		return false
+	}
+	found := prefix == ""
+	if found && !unchanged(ix.Root, ix.RootModTime) {
+		return false
+	}
+	for _, e := range ix.Entries {
+		if !e.Mode.IsDir() || !under(e.Path, prefix) {
+			continue
+		}
+		if !unchanged(filepath.Join(ix.Root, filepath.FromSlash(e.Path)), e.ModTime) {
+			return false
+		}
+		found = found || e.Path == prefix
+	}
+	// A directory created after the index was built is not covered by it
+	return found
+}
+
+// unchanged reports whether the directory at path still has the recorded
+// modification time.
+func unchanged(path string, modTime int64) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.IsDir() && info.ModTime().UnixNano() == modTime
+}
+
+// prefix returns the path of dir relative to the index root, or "" for the
+// root itself. ok is false if dir is outside the index.
+func (ix *Index) prefix(dir string) (prefix string, ok bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(ix.Root, abs)
+	if err != nil || rel == ".." || len(rel) > 2 && rel[:3] == ".."+string(filepath.Separator) {
+		return "", false
+	}
+	if rel == "." {
+		return "", true
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// under reports whether the index path p is inside the directory prefix
+// (or equal to it). The empty prefix is the index root.
+func under(p, prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	return p == prefix || len(p) > len(prefix) && p[:len(prefix)] == prefix && p[len(prefix)] == '/'
+}
@@ -0,0 +1,53 @@
+package index
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// resident holds the indexes kept in memory by Keep, by root.
+var (
+	residentMu sync.RWMutex
+	resident   = make(map[string]*Index)
+)
+
+// Keep holds ix in memory for the life of the process, so that Load
+// returns it for the directories below its root without reading the saved
+// index. ix must not be changed afterwards: keep a Clone of an index that
+// is still being updated, and Keep a new Clone after each update.
+func Keep(ix *Index) {
+	residentMu.Lock()
+	defer residentMu.Unlock()
+	resident[ix.Root] = ix
+}
+
+// kept returns the index held in memory for root, if any.
+func kept(root string) *Index {
+	residentMu.RLock()
+	defer residentMu.RUnlock()
+	return resident[root]
+}
+
+// Clone returns a copy of ix that later Updates of ix leave unchanged.
+func (ix *Index) Clone() *Index {
+	c := *ix
+	c.Entries = slices.Clone(ix.Entries)
+	// Update only appends to the postings, past the end of the lists the
+	// copy sees
+	c.Postings = maps.Clone(ix.Postings)
+	return &c
+}
+
+// Resident returns the indexes held in memory by Keep, by root. They must
+// not be changed.
+func Resident() []*Index {
+	residentMu.RLock()
+	defer residentMu.RUnlock()
+	roots := slices.Sorted(maps.Keys(resident))
+	ixs := make([]*Index, len(roots))
+	for i, root := range roots {
+		ixs[i] = resident[root]
+	}
+	return ixs
+}
@@ -0,0 +1,114 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// addTrigrams records the trigrams of the file at path under entry id and
+// reports whether the file was readable.
+func (ix *Index) addTrigrams(path string, id uint32) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	seen := make(map[uint32]bool)
+	for i := 0; i+3 <= len(data); i++ {
+		t := trigram(data[i], data[i+1], data[i+2])
+		if !seen[t] {
+			seen[t] = true
+			ix.Postings[t] = append(ix.Postings[t], id)
+		}
+	}
+	return true
+}
+
+// trigram packs three bytes into a key, folding ASCII letters to lower case
+// so one index serves both case-sensitive and case-insensitive searches.
+func trigram(a, b, c byte) uint32 {
+	return uint32(lowerASCII(a))<<16 | uint32(lowerASCII(b))<<8 | uint32(lowerASCII(c))
+}
+
+func lowerASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// Filter returns a function reporting whether the file at path, as passed
+// to a Walk of dir, may contain any of the literal terms and so needs to be
+// scanned. Files whose size or modification time changed since the index
+// was built, and files too large for the trigram index, always need
+// scanning. fold says whether the terms are matched case-insensitively.
+//
+// Filter returns nil when the index cannot narrow the search: it was built
+// without trigrams, a term is shorter than three bytes, or a
+// case-insensitive term could match non-ASCII text (such as 'K', the Kelvin
+// sign, for "k").
+func (ix *Index) Filter(dir string, terms []string, fold bool) func(path string) bool {
+	prefix, ok := ix.prefix(dir)
+	if ix.Postings == nil || !ok || len(terms) == 0 {
+		return nil
+	}
+	for _, term := range terms {
+		if len(term) < 3 || fold && !foldsToASCII(term) {
+			return nil
+		}
+	}
+
+	candidates := make(map[uint32]bool)
+	for _, term := range terms {
+		for id := range ix.containing(term) {
+			candidates[id] = true
+		}
+	}
+
+	files := make(map[string]Entry)
+	for _, e := range ix.Entries {
+		if e.Mode.IsRegular() && under(e.Path, prefix) {
+			rel := strings.TrimPrefix(strings.TrimPrefix(e.Path, prefix), "/")
+			files[filepath.Join(dir, filepath.FromSlash(rel))] = e
+		}
+	}
+
+	return func(path string) bool {
+		e, ok := files[path]
+		if !ok || !e.Trigrams || candidates[e.ID] {
+			return true
+		}
+		info, err := os.Stat(path)
+		return err != nil || info.Size() != e.Size || info.ModTime().UnixNano() != e.ModTime
+	}
+}
+
+// containing returns the ids of the files whose trigrams include every
+// trigram of term.
+func (ix *Index) containing(term string) map[uint32]bool {
+	var set map[uint32]bool
+	for i := 0; i+3 <= len(term); i++ {
+		next := make(map[uint32]bool)
+		for _, id := range ix.Postings[trigram(term[i], term[i+1], term[i+2])] {
+			if set == nil || set[id] {
+				next[id] = true
+			}
+		}
+		if set = next; len(set) == 0 {
+			break
+		}
+	}
+	return set
+}
+
+// foldsToASCII reports whether every case variant of term is ASCII, so that
+// folding the index's ASCII letters is enough to find it.
+func foldsToASCII(term string) bool {
+	for i := 0; i < len(term); i++ {
+		switch c := lowerASCII(term[i]); {
+		case c >= 0x80, c == 'k', c == 's':
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Update refreshes the index for the given paths, each of which was
+// created, modified, or removed since the index was built. The subtree at
+// each path is rescanned and its parent directory's modification time is
+// brought up to date, so the index is Fresh again once every change has
+// been applied. Paths outside the index are ignored.
+func (ix *Index) Update(paths []string) error {
+	changed := make(map[string]bool)
+	for _, p := range paths {
+		if rel, ok := ix.prefix(p); ok {
+			changed[rel] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	// Drop the changed subtrees, then scan them again
+	inChanged := func(p string) bool {
+		for ; p != ""; p = parent(p) {
+			if changed[p] {
+				return true
+			}
+		}
+		return false
+	}
+	ix.Entries = slices.DeleteFunc(ix.Entries, func(e Entry) bool { return inChanged(e.Path) })
+	dirs := make(map[string]bool)
+	for rel := range changed {
+		if rel == "" {
+			dirs[""] = true
+			continue
+		}
+		dirs[parent(rel)] = true
+		if inChanged(parent(rel)) {
+			continue // Rescanned along with its ancestor
+		}
+		if _, err := os.Lstat(ix.path(rel)); err != nil {
+			continue // Removed
+		}
+		entries, err := ix.scan(ix.path(rel))
+		if err != nil {
+			return err
+		}
+		ix.Entries = append(ix.Entries, entries...)
+	}
+	slices.SortFunc(ix.Entries, func(a, b Entry) int { return comparePaths(a.Path, b.Path) })
+
+	// Creating or removing an entry changes its directory's mtime
+	for i, e := range ix.Entries {
+		if dirs[e.Path] {
+			if info, err := os.Lstat(ix.path(e.Path)); err == nil {
+				ix.Entries[i].ModTime = info.ModTime().UnixNano()
+			}
+		}
+	}
+	if dirs[""] {
+		if info, err := os.Stat(ix.Root); err == nil {
+			ix.RootModTime = info.ModTime().UnixNano()
+		}
+	}
+	return nil
+}
+
+// path returns the file system path of the index path rel.
+func (ix *Index) path(rel string) string {
+	return filepath.Join(ix.Root, filepath.FromSlash(rel))
+}
+
+// comparePaths orders index paths as filepath.WalkDir visits them: a
+// directory's entries come right after it and before its next sibling, so
+// '/' sorts before every other byte.
+func comparePaths(a, b string) int {
+	return strings.Compare(strings.ReplaceAll(a, "/", "\x00"), strings.ReplaceAll(b, "/", "\x00"))
+}
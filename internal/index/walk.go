@@ -0,0 +1,84 @@
+package index
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Walk replays the entries below dir in the order filepath.WalkDir would
+// visit them, calling fn with paths joined onto dir exactly as WalkDir
+// would. fn may return filepath.SkipDir to prune a directory or
+// filepath.SkipAll to stop. Entries are reported as they were when the index
+// was built; callers should check Fresh first.
+func (ix *Index) Walk(dir string, fn fs.WalkDirFunc) error {
+	prefix, ok := ix.prefix(dir)
+	if !ok {
+		return fs.ErrNotExist
+	}
+	rootEntry := Entry{Path: prefix, Mode: fs.ModeDir | 0o755, ModTime: ix.RootModTime}
+	if err := fn(dir, dirEntry{rootEntry}, nil); err != nil {
+		if err == filepath.SkipDir || err == filepath.SkipAll {
+			return nil
+		}
+		return err
+	}
+
+	skip := ""
+	for _, e := range ix.Entries {
+		if e.Path == prefix || !under(e.Path, prefix) {
+			continue
+		}
+		if skip != "" && under(e.Path, skip) {
+			continue
+		}
+		rel := e.Path
+		if prefix != "" {
+			rel = strings.TrimPrefix(e.Path, prefix+"/")
+		}
+		err := fn(filepath.Join(dir, filepath.FromSlash(rel)), dirEntry{e}, nil)
+		switch {
+		case err == filepath.SkipDir && e.Mode.IsDir():
+			skip = e.Path
+		case err == filepath.SkipDir:
+			// Skip the remaining entries of the file's directory
+			if skip = parent(e.Path); skip == prefix {
+				return nil
+			}
+		case err == filepath.SkipAll:
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+	return nil
+}
+
+func parent(p string) string {
+	if i := strings.LastIndexByte(p, '/'); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// dirEntry presents an Entry as an fs.DirEntry and fs.FileInfo.
+type dirEntry struct{ e Entry }
+
+func (d dirEntry) Name() string {
+	return d.e.Path[strings.LastIndexByte(d.e.Path, '/')+1:]
+}
+
+func (d dirEntry) IsDir() bool { return d.e.Mode.IsDir() }
+
+func (d dirEntry) Type() fs.FileMode { return d.e.Mode.Type() }
+
+func (d dirEntry) Info() (fs.FileInfo, error) { return d, nil }
+
+func (d dirEntry) Size() int64 { return d.e.Size }
+
+func (d dirEntry) Mode() fs.FileMode { return d.e.Mode }
+
+func (d dirEntry) ModTime() time.Time { return time.Unix(0, d.e.ModTime) }
+
+func (d dirEntry) Sys() any { return nil }
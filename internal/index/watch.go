@@ -0,0 +1,92 @@
+package index
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long Watch waits after a change for further changes, so
+// that a burst of events (a checkout, a build) results in a single update.
+const watchDelay = 200 * time.Millisecond
+
+// Watch keeps ix current as files below its root change, until ctx is
+// done. Changes are batched, applied with Update, and saved; onUpdate, if
+// non-nil, is called after each batch with the number of changed paths and
+// any error from applying or saving them.
+func (ix *Index) Watch(ctx context.Context, onUpdate func(changes int, err error)) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := watchTree(w, ix.Root); err != nil {
+		return err
+	}
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(watchDelay)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			if onUpdate != nil {
+				onUpdate(0, err)
+			}
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Base(ev.Name) == ".git" || ev.Op == fsnotify.Chmod {
+				continue
+			}
+			if ev.Has(fsnotify.Create) {
+				// New directories need watches of their own
+				watchTree(w, ev.Name)
+			}
+			pending[ev.Name] = true
+			timer.Reset(watchDelay)
+		case <-timer.C:
+			paths := make([]string, 0, len(pending))
+			for p := range pending {
+				paths = append(paths, p)
+			}
+			clear(pending)
+			err := ix.Update(paths)
+			if err == nil {
+				err = ix.Save()
+			}
+			if onUpdate != nil {
+				onUpdate(len(paths), err)
+			}
+		}
+	}
+}
+
+// watchTree adds a watch for root and every directory below it except .git.
+// Entries that vanish or cannot be read are skipped.
+func watchTree(w *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		return w.Add(path)
+	})
+}
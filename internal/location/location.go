@@ -0,0 +1,69 @@
+// Package location reads file positions out of the text tools print about
+// them: compiler errors, go vet and go test output, and panic traces.
+package location
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Location is a position in a file.
+type Location struct {
+	Path string `json:"path"`
+	// Line and Column are 1-based; Column is zero when not given.
+	Line   int `json:"line"`
+	Column int `json:"column,omitempty"`
+}
+
+// position matches path:line or path:line:column, optionally followed by
+// a colon, as in "handler.go:87:12: undefined: x".
+var position = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?:?$`)
+
+// Parse returns the positions s might hold, in the order to try them; s is
+// a line such as
+//
+//	pkg/server/handler.go:87:12: undefined: foo
+//	    handler_test.go:42: got 1, want 2
+//	/home/me/src/app/main.go:17 +0x1d
+//
+// The text up to the message is tried first, so that a path with spaces
+// is kept, then each word of s, since other text, such as a timestamp, may
+// come first. Which of them names a file is for the caller to find out.
+func Parse(s string) []Location {
+	s = strings.TrimSpace(s)
+	// A message follows the position after ": "; the position itself has
+	// no space after a colon
+	head := s
+	if i := strings.Index(s, ": "); i >= 0 {
+		head = s[:i]
+	}
+	var locs []Location
+	if loc, ok := parseWord(head); ok {
+		locs = append(locs, loc)
+	}
+	for _, w := range strings.Fields(s) {
+		if loc, ok := parseWord(w); ok && !slices.Contains(locs, loc) {
+			locs = append(locs, loc)
+		}
+	}
+	return locs
+}
+
+// parseWord parses w as a position and nothing else.
+func parseWord(w string) (Location, bool) {
+	m := position.FindStringSubmatch(w)
+	if m == nil {
+		return Location{}, false
+	}
+	loc := Location{Path: strings.TrimPrefix(m[1], "file://")}
+	loc.Line, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		loc.Column, _ = strconv.Atoi(m[3])
+	}
+	if loc.Line < 1 || loc.Path == "" {
+		return Location{}, false
+	}
+	return loc, true
+}
@@ -0,0 +1,93 @@
+// Package logfile writes a log to a file that is rotated when it grows past
+// a size, keeping a few of the old ones, so that a server left running for
+// weeks does not fill the disk.
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File is a log file, rotated on write once it would exceed its maximum
+// size: path is renamed to path.1, path.1 to path.2, and so on, dropping
+// the oldest beyond the backups kept, and a new path is started.
+type File struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open opens the log at path for appending, creating it and its directory
+// if needed, to be rotated past maxSize bytes (never, when 0) keeping
+// backups old files. The files are readable by their owner only, since
+// messages can name files and users.
+func Open(path string, maxSize int64, backups int) (*File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("unable to open log file: %w", err)
+	}
+	l := &File{path: path, maxSize: maxSize, backups: backups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *File) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("unable to open log file: %w", err)
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, which a logger passes a line at a time, rotating the
+// file first if p would take it past its maximum size.
+func (l *File) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the old files up by one and starts a new file.
+func (l *File) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	if l.backups > 0 {
+		for i := l.backups - 1; i > 0; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+		}
+		if err := os.Rename(l.path, l.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(l.path); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// Close closes the log file.
+func (l *File) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
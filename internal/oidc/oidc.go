@@ -0,0 +1,346 @@
+// Package oidc validates the ID and access tokens an OpenID Connect
+// provider issues, JWTs signed with the keys it publishes, so that the MCP
+// server can let in the users of an identity provider in place of, or
+// alongside, static bearer tokens.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keysTTL is how long fetched signing keys are used before they are
+// fetched again, and minRefresh how often at most a token signed with an
+// unknown key can cause a fetch, for a provider that has rotated its keys.
+const (
+	keysTTL    = time.Hour
+	minRefresh = time.Minute
+)
+
+// leeway is the clock skew allowed when checking exp and nbf.
+const leeway = time.Minute
+
+// Claims are the claims of a valid token used here.
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	Expiry            int64    `json:"exp"`
+	NotBefore         int64    `json:"nbf"`
+	Email             string   `json:"email"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// User returns who the token was issued to, for the audit log: the
+// preferred username, else the email address, else the subject.
+func (c *Claims) User() string {
+	switch {
+	case c.PreferredUsername != "":
+		return c.PreferredUsername
+	case c.Email != "":
+		return c.Email
+	}
+	return c.Subject
+}
+
+// audience is the aud claim, a string or an array of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		*a = audience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// Verifier checks tokens issued by one provider for one audience.
+type Verifier struct {
+	issuer, audience string
+	jwksURI          string
+	client           *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// New returns a Verifier of the tokens issuer issues for audience, after
+// reading the provider's discovery document and signing keys.
+func New(ctx context.Context, issuer, audience string) (*Verifier, error) {
+	if audience == "" {
+		return nil, errors.New("an OIDC audience is required")
+	}
+	v := &Verifier{issuer: issuer, audience: audience, client: &http.Client{Timeout: 10 * time.Second}}
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.get(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("unable to discover OIDC provider: %w", err)
+	}
+	if discovery.Issuer != issuer {
+		return nil, fmt.Errorf("OIDC provider's issuer is '%s', not '%s'", discovery.Issuer, issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC provider has no jwks_uri")
+	}
+	v.jwksURI = discovery.JWKSURI
+	if err := v.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Verify checks that token is a JWT signed by one of the provider's keys,
+// issued by it for the audience and currently valid, and returns its
+// claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	now := time.Now()
+	switch {
+	case c.Issuer != v.issuer:
+		return nil, fmt.Errorf("token is issued by '%s'", c.Issuer)
+	case !slices.Contains(c.Audience, v.audience):
+		return nil, errors.New("token is not for this audience")
+	case c.Expiry == 0 || now.After(time.Unix(c.Expiry, 0).Add(leeway)):
+		return nil, errors.New("token has expired")
+	case c.NotBefore != 0 && now.Add(leeway).Before(time.Unix(c.NotBefore, 0)):
+		return nil, errors.New("token is not valid yet")
+	}
+	return &c, nil
+}
+
+// key returns the signing key kid, fetching the keys again when they are
+// stale, or when kid is unknown and they were not fetched just now.
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	age := time.Since(v.fetched)
+	key := v.pick(kid)
+	if age > keysTTL || (key == nil && age > minRefresh) {
+		if err := v.refreshLocked(ctx); err != nil && key == nil {
+			return nil, err
+		}
+		key = v.pick(kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("token is signed with unknown key '%s'", kid)
+	}
+	return key, nil
+}
+
+// pick returns key kid, or the only key when kid is empty.
+func (v *Verifier) pick(kid string) crypto.PublicKey {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k
+		}
+	}
+	return v.keys[kid]
+}
+
+func (v *Verifier) refresh(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.refreshLocked(ctx)
+}
+
+// refreshLocked fetches the provider's JSON Web Key Set, keeping the
+// signing keys of the types it supports.
+func (v *Verifier) refreshLocked(ctx context.Context) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.get(ctx, v.jwksURI, &set); err != nil {
+		return fmt.Errorf("unable to fetch OIDC signing keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("OIDC provider publishes no usable signing keys")
+	}
+	v.keys, v.fetched = keys, time.Now()
+	return nil
+}
+
+// get fetches url and decodes its JSON body into v.
+func (v *Verifier) get(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jwk is a JSON Web Key, with the members of RSA, EC, and OKP public keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", k.Crv)
+		}
+		x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported curve '%s'", k.Crv)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type '%s'", k.Kty)
+}
+
+// verifySignature checks sig, made with alg, over signed against key.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write([]byte(signed))
+		digest = h.Sum(nil)
+	}
+	bad := errors.New("token signature is invalid")
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch {
+		case strings.HasPrefix(alg, "RS") && hash != 0:
+			if rsa.VerifyPKCS1v15(k, hash, digest, sig) != nil {
+				return bad
+			}
+			return nil
+		case strings.HasPrefix(alg, "PS") && hash != 0:
+			if rsa.VerifyPSS(k, hash, digest, sig, nil) != nil {
+				return bad
+			}
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if strings.HasPrefix(alg, "ES") && hash != 0 {
+			size := (k.Curve.Params().BitSize + 7) / 8
+			if len(sig) != 2*size {
+				return bad
+			}
+			r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+			if !ecdsa.Verify(k, digest, r, s) {
+				return bad
+			}
+			return nil
+		}
+	case ed25519.PublicKey:
+		if alg == "EdDSA" {
+			if !ed25519.Verify(k, []byte(signed), sig) {
+				return bad
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("token algorithm '%s' does not match its key", alg)
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT into v.
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+
+package opener
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// codeCommand returns the command to run for the VS Code CLI name.
+func codeCommand(name string) string {
+	return name
+}
+
+// hostPath returns the absolute path as the VS Code CLI expects it. Inside
+// WSL the 'code' on PATH is the Windows CLI, which cannot resolve paths on
+// mounted Windows drives (/mnt/c/...), so those are translated to Windows
+// paths with wslpath. Paths in the Linux file system are left alone; the
+// CLI opens them through the WSL extension.
+func hostPath(path string) (string, error) {
+	if !inWSL() || !onWindowsDrive(path) {
+		return path, nil
+	}
+	out, err := exec.Command("wslpath", "-w", path).Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to translate '%s' with wslpath: %w", path, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// inWSL reports whether the process runs under the Windows Subsystem for
+// Linux.
+func inWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	_, err := os.Stat("/proc/sys/fs/binfmt_misc/WSLInterop")
+	return err == nil
+}
+
+// onWindowsDrive reports whether the absolute path is on a Windows drive
+// mounted by WSL, such as /mnt/c or /mnt/c/Users.
+func onWindowsDrive(path string) bool {
+	rest, ok := strings.CutPrefix(path, "/mnt/")
+	if !ok || rest == "" || rest[0] < 'a' || rest[0] > 'z' {
+		return false
+	}
+	return len(rest) == 1 || rest[1] == '/'
+}
@@ -0,0 +1,55 @@
+//go:build windows
+
+package opener
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// installDirs are the directories, relative to %LOCALAPPDATA%\Programs (a
+// per-user install) or %ProgramFiles% (a system install), where each CLI in
+// Editors is installed by default.
+var installDirs = map[string]string{
+	"code":          "Microsoft VS Code",
+	"code-insiders": "Microsoft VS Code Insiders",
+	"codium":        "VSCodium",
+}
+
+// codeCommand returns the command to run for the VS Code CLI name. On
+// Windows the CLI is a batch file, name.cmd, in the installation's bin
+// directory; name.exe is the editor itself and is only used when it is all
+// that is on PATH. The default install locations are tried when PATH has
+// neither.
+func codeCommand(name string) string {
+	for _, file := range []string{name + ".cmd", name + ".exe"} {
+		if p, err := exec.LookPath(file); err == nil {
+			return p
+		}
+	}
+	var installs []string
+	if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+		installs = append(installs, filepath.Join(dir, "Programs", installDirs[name]))
+	}
+	if dir := os.Getenv("ProgramFiles"); dir != "" {
+		installs = append(installs, filepath.Join(dir, installDirs[name]))
+	}
+	for _, dir := range installs {
+		if p := filepath.Join(dir, "bin", name+".cmd"); installDirs[name] != "" && fileExists(p) {
+			return p
+		}
+	}
+	return name
+}
+
+// hostPath returns the absolute path as the VS Code CLI expects it. Drive
+// letter (C:\...) and UNC (\\server\share\...) paths are passed through.
+func hostPath(path string) (string, error) {
+	return path, nil
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
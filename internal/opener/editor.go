@@ -0,0 +1,180 @@
+package opener
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Target is a resolved request to open something in an editor.
+type Target struct {
+	// Path is the absolute path of the file or directory, in the form the
+	// editor expects (see hostPath). It is empty when FolderURI is set.
+	Path  string
+	IsDir bool
+	// Line, if positive, is the 1-based line of the file to show, and
+	// Column, if positive, the 1-based column on it.
+	Line   int
+	Column int
+	// Workspace, if set, is the project (folder or .code-workspace file) to
+	// open, with Path open in it when Path is a file.
+	Workspace string
+	// FolderURI, if set, is a remote folder to open instead of a local path.
+	FolderURI string
+
+	NewWindow   bool
+	ReuseWindow bool
+	Wait        bool
+}
+
+// An Opener launches an editor on a Target.
+type Opener interface {
+	Open(ctx context.Context, t Target) error
+}
+
+// Editors are the names NewEditor accepts for VS Code and its variants, all
+// of which share the same CLI.
+var Editors = []string{"code", "code-insiders", "codium"}
+
+// placeholder matches the placeholders of a command template.
+var placeholder = regexp.MustCompile(`\{[a-z]+\}`)
+
+// DefaultEditor opens paths with the 'code' CLI.
+var DefaultEditor Opener = VSCode{Command: "code"}
+
+// NewEditor returns the Opener for spec: one of Editors, or otherwise a
+// command template such as "subl {path}:{line}:{column}" or
+// "vim +{line} {path}".
+// A template without a {path} placeholder gets the path appended. The empty
+// spec selects DefaultEditor.
+func NewEditor(spec string) (Opener, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return DefaultEditor, nil
+	}
+	for _, name := range Editors {
+		if spec == name {
+			return VSCode{Command: name}, nil
+		}
+	}
+	for _, m := range placeholder.FindAllString(spec, -1) {
+		if m != "{path}" && m != "{line}" && m != "{column}" {
+			return nil, fmt.Errorf("unknown placeholder %s in editor '%s' (expected {path}, {line}, or {column})", m, spec)
+		}
+	}
+	tmpl := Template{Args: strings.Fields(spec)}
+	if !strings.Contains(spec, "{path}") {
+		tmpl.Args = append(tmpl.Args, "{path}")
+	}
+	return tmpl, nil
+}
+
+// LookPath returns the path of the program the Opener o runs, as
+// exec.LookPath finds it, or an error if it cannot be found.
+func LookPath(o Opener) (string, error) {
+	switch o := o.(type) {
+	case VSCode:
+		return exec.LookPath(codeCommand(o.Command))
+	case Template:
+		return exec.LookPath(o.Args[0])
+	}
+	return "", fmt.Errorf("unknown editor %T", o)
+}
+
+// VSCode opens targets with a VS Code-compatible CLI: code, code-insiders,
+// or codium.
+type VSCode struct {
+	Command string
+}
+
+func (v VSCode) Open(ctx context.Context, t Target) error {
+	var args []string
+	switch {
+	case t.FolderURI != "":
+		args = []string{"--folder-uri", t.FolderURI}
+	case t.Line > 0 && t.Column > 0 && !t.IsDir:
+		args = []string{"--goto", fmt.Sprintf("%s:%d:%d", t.Path, t.Line, t.Column)}
+	case t.Line > 0 && !t.IsDir:
+		args = []string{"--goto", fmt.Sprintf("%s:%d", t.Path, t.Line)}
+	default:
+		args = []string{t.Path}
+	}
+	if t.Workspace != "" {
+		if t.IsDir {
+			args = []string{t.Workspace}
+		} else {
+			// Open the project, then the file inside it
+			args = append([]string{t.Workspace}, args...)
+		}
+	}
+
+	switch {
+	case t.NewWindow:
+		args = append([]string{"--new-window"}, args...)
+	case t.ReuseWindow:
+		args = append([]string{"--reuse-window"}, args...)
+	}
+	if t.Wait {
+		args = append([]string{"--wait"}, args...)
+	}
+
+	if err := exec.CommandContext(ctx, codeCommand(v.Command), args...).Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("stopped waiting for %s: %w", v.Command, ctx.Err())
+		}
+		return fmt.Errorf("failed to open VS Code: %w (make sure VS Code is installed and '%s' command is available in PATH)", err, v.Command)
+	}
+	return nil
+}
+
+// Template opens targets by running a command built from Args, in which
+// {path} is replaced by the path (or the workspace, when one is set) and
+// {line} and {column} by the line and column numbers, 1 if none was given.
+// Placeholders are replaced within each argument, so paths containing
+// spaces stay one argument. The command is always waited for and, when run
+// from a terminal, attached to it, which suits terminal editors. Window
+// selection and remote folders are not supported.
+type Template struct {
+	Args []string
+}
+
+func (tm Template) Open(ctx context.Context, t Target) error {
+	if t.FolderURI != "" {
+		return fmt.Errorf("editor '%s' cannot open remote folders", tm.Args[0])
+	}
+	if t.NewWindow || t.ReuseWindow {
+		return fmt.Errorf("editor '%s' does not support choosing a window", tm.Args[0])
+	}
+	path, line, column := t.Path, max(t.Line, 1), max(t.Column, 1)
+	if t.Workspace != "" {
+		path, line, column = t.Workspace, 1, 1
+	}
+	r := strings.NewReplacer("{path}", path, "{line}", strconv.Itoa(line), "{column}", strconv.Itoa(column))
+	args := make([]string, len(tm.Args))
+	for i, a := range tm.Args {
+		args[i] = r.Replace(a)
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if isTerminal(os.Stdin) {
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("stopped waiting for %s: %w", args[0], ctx.Err())
+		}
+		return fmt.Errorf("failed to run editor '%s': %w", args[0], err)
+	}
+	return nil
+}
+
+// isTerminal reports whether f is a character device such as a terminal;
+// the MCP stdio transport, for one, must not be handed to the editor.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,177 @@
+// Package opener opens files and directories in VS Code.
+package opener
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+)
+
+// Options controls how a path is opened.
+type Options struct {
+	// Dir opens the containing directory when the path is a file.
+	Dir bool
+	// Line, if positive, places the cursor on that 1-based line of a file,
+	// and Column, if also positive, on that 1-based column of it.
+	Line   int
+	Column int
+	// Workspace opens the project containing the path, found by
+	// FindWorkspace, with the file (if the path is one) open in it. When no
+	// project is found the path is opened on its own.
+	Workspace bool
+	// NewWindow forces a new VS Code window (code -n); ReuseWindow opens in
+	// the last active window (code -r). Setting neither leaves the choice to
+	// VS Code's window.openFilesInNewWindow setting.
+	NewWindow   bool
+	ReuseWindow bool
+	// Remote, if set, names an SSH host; the path is then an absolute
+	// folder path on that host, opened with the Remote - SSH extension via a
+	// vscode-remote:// folder URI. Workspace, Dir, and Line do not apply.
+	Remote string
+	// Wait blocks until the opened files are closed in VS Code (code
+	// --wait).
+	Wait bool
+	// Editor opens the resolved target; nil means DefaultEditor.
+	Editor Opener
+	// Check, if set, vets the absolute local path about to be opened; an
+	// error stops the open. A workspace it rejects is not used, as if none
+	// had been found.
+	Check func(path string) error
+	// Record, if set, is called with the absolute local path once it has
+	// been opened, before any workspace is substituted for it, so that the
+	// caller can keep a history of opened files.
+	Record func(path string)
+}
+
+// Open opens path with opts.Editor, the 'code' CLI unless set, and returns
+// the absolute path that was opened: the workspace file or folder when
+// opts.Workspace found one, the folder URI for a remote path, otherwise path
+// itself.
+func Open(path string, opts Options) (string, error) {
+	return OpenContext(context.Background(), path, opts)
+}
+
+// OpenContext is like Open but stops waiting, killing the editor process,
+// when ctx is done. This matters mostly with opts.Wait.
+func OpenContext(ctx context.Context, path string, opts Options) (string, error) {
+	if opts.NewWindow && opts.ReuseWindow {
+		return "", fmt.Errorf("new window and reuse window cannot both be requested")
+	}
+
+	editor := opts.Editor
+	if editor == nil {
+		editor = DefaultEditor
+	}
+	t := Target{NewWindow: opts.NewWindow, ReuseWindow: opts.ReuseWindow, Wait: opts.Wait}
+	var opened, local string
+	var err error
+	if opts.Remote != "" {
+		t.FolderURI, err = remoteURI(path, opts.Remote)
+		opened = t.FolderURI
+	} else {
+		opened, local, err = resolveLocal(path, opts, &t)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := editor.Open(ctx, t); err != nil {
+		return "", err
+	}
+	if opts.Record != nil && local != "" {
+		opts.Record(local)
+	}
+	return opened, nil
+}
+
+// resolveLocal fills in t for opening the local path and returns the
+// absolute path that will be opened and that of the path itself, which
+// differ when a workspace is opened in its place.
+func resolveLocal(path string, opts Options, t *Target) (opened, abs string, err error) {
+	// Check if path exists
+	fileInfo, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", "", fmt.Errorf("'%s' does not exist", path)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get file info: %w", err)
+	}
+
+	// Open the containing directory if requested
+	if opts.Dir && !fileInfo.IsDir() {
+		path = filepath.Dir(path)
+		fileInfo, err = os.Stat(path)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to get file info: %w", err)
+		}
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get absolute path: %w", err)
+	}
+	if opts.Check != nil {
+		if err := opts.Check(absPath); err != nil {
+			return "", "", err
+		}
+	}
+
+	if t.Path, err = hostPath(absPath); err != nil {
+		return "", "", err
+	}
+	t.IsDir = fileInfo.IsDir()
+	t.Line = opts.Line
+	if t.Line > 0 {
+		t.Column = opts.Column
+	}
+	opened = absPath
+	if opts.Workspace {
+		if ws := FindWorkspace(absPath); ws != "" && ws != absPath && (opts.Check == nil || opts.Check(ws) == nil) {
+			if t.Workspace, err = hostPath(ws); err != nil {
+				return "", "", err
+			}
+			opened = ws
+		}
+	}
+	return opened, absPath, nil
+}
+
+// remoteURI returns the vscode-remote:// URI of the folder at the absolute
+// path on an SSH host, as opened by the Remote - SSH extension.
+func remoteURI(path, host string) (string, error) {
+	if strings.ContainsAny(host, "/ \t") {
+		return "", fmt.Errorf("invalid remote host '%s'", host)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("remote path '%s' must be absolute", path)
+	}
+	return "vscode-remote://ssh-remote+" + host + (&url.URL{Path: pathpkg.Clean(path)}).EscapedPath(), nil
+}
+
+// FindWorkspace returns the project that contains the absolute path: the
+// nearest enclosing directory holding a .code-workspace file (the first one
+// in lexical order if there are several), or a git repository root,
+// whichever is closer. At the same level a workspace file wins. It returns
+// "" if there is neither.
+func FindWorkspace(path string) string {
+	dir := path
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	for {
+		if matches, _ := filepath.Glob(filepath.Join(dir, "*.code-workspace")); len(matches) > 0 {
+			return matches[0]
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,192 @@
+// Package recent keeps a history of the files opened through the CLI and
+// the MCP server, so that they can be listed and opened again.
+package recent
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"vscode-helper-file-find/internal/search"
+)
+
+// MaxEntries is how many files the history keeps; the least recently
+// opened are dropped first.
+const MaxEntries = 500
+
+// Entry is a file in the history.
+type Entry struct {
+	// Path is the absolute path of the file.
+	Path string `json:"path"`
+	// Count is how many times it has been opened.
+	Count int `json:"count"`
+	// Last is when it was last opened.
+	Last time.Time `json:"last"`
+}
+
+// Store is a history kept in a JSON file.
+type Store struct {
+	Path string
+}
+
+// Default returns the store in the user cache directory, normally
+// ~/.cache/vscode-helper/recent.json.
+func Default() (*Store, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{Path: filepath.Join(dir, "vscode-helper", "recent.json")}, nil
+}
+
+// List returns the history, most recently opened first. A store that has
+// not been written yet is empty.
+func (s *Store) List() ([]Entry, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Find returns up to limit entries, or all with limit 0, most recent
+// first, for files that still exist and that keep, if set, accepts. With
+// a query only those whose path matches it as a fuzzy query are returned.
+func (s *Store) Find(query string, limit int, keep func(path string) bool) ([]Entry, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var found []Entry
+	for _, e := range entries {
+		if limit > 0 && len(found) == limit {
+			break
+		}
+		if query != "" {
+			if _, ok := search.FuzzyScore(query, e.Path, search.SmartCase); !ok {
+				continue
+			}
+		}
+		if keep != nil && !keep(e.Path) {
+			continue
+		}
+		if _, err := os.Stat(e.Path); err != nil {
+			continue
+		}
+		found = append(found, e)
+	}
+	return found, nil
+}
+
+// Frecency returns how strongly the file of e is preferred at now: the
+// number of times it was opened, weighted by how recently it last was, as
+// browsers rank their history.
+func (e Entry) Frecency(now time.Time) float64 {
+	age := now.Sub(e.Last)
+	weight := 0.1
+	switch {
+	case age < 4*time.Hour:
+		weight = 1
+	case age < 24*time.Hour:
+		weight = 0.7
+	case age < 7*24*time.Hour:
+		weight = 0.5
+	case age < 30*24*time.Hour:
+		weight = 0.3
+	case age < 90*24*time.Hour:
+		weight = 0.2
+	}
+	return float64(e.Count) * weight
+}
+
+// Boosts returns the frecency of each file in the history that lies below
+// one of dirs, keyed by absolute path, as search.Options.Boost takes it.
+// It returns nil when there are none.
+func (s *Store) Boosts(dirs []string) (map[string]float64, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var roots []string
+	for _, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			roots = append(roots, abs)
+		}
+	}
+	var boosts map[string]float64
+	now := time.Now()
+	for _, e := range entries {
+		for _, root := range roots {
+			if rel, err := filepath.Rel(root, e.Path); err == nil && filepath.IsLocal(rel) {
+				if boosts == nil {
+					boosts = map[string]float64{}
+				}
+				boosts[e.Path] = e.Frecency(now)
+				break
+			}
+		}
+	}
+	return boosts, nil
+}
+
+// Record notes that the file at the absolute path was opened now.
+func (s *Store) Record(path string) error {
+	// A history that cannot be read starts afresh rather than blocking
+	// every later open
+	entries, _ := s.List()
+	e := Entry{Path: path}
+	if i := slices.IndexFunc(entries, func(e Entry) bool { return e.Path == path }); i >= 0 {
+		e = entries[i]
+		entries = slices.Delete(entries, i, i+1)
+	}
+	e.Count++
+	e.Last = time.Now().UTC()
+	entries = append([]Entry{e}, entries...)
+	if len(entries) > MaxEntries {
+		entries = entries[:MaxEntries]
+	}
+	return s.write(entries)
+}
+
+// Clear empties the history.
+func (s *Store) Clear() error {
+	if err := os.Remove(s.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// write replaces the history with entries. The file is written to a
+// temporary name and renamed, so that readers never see half of it.
+func (s *Store) write(entries []Entry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), ".recent-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
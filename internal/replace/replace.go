@@ -0,0 +1,258 @@
+// Package replace implements search-and-replace across files, built on the
+// search package's matching rules.
+package replace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vscode-helper-file-find/internal/files"
+	"vscode-helper-file-find/internal/search"
+)
+
+// Options describes a replacement.
+type Options struct {
+	// Search selects the files and the text to replace: every match of its
+	// content terms, using its regex and case settings, is replaced. Name
+	// patterns, excludes, and ignore rules limit which files are touched.
+	Search search.Options
+	// Replacement is the new text. With Search.Regex set, $1 or ${name}
+	// expand to capture groups; otherwise it is used literally.
+	Replacement string
+	// DryRun computes the changes and their diffs without writing files.
+	DryRun bool
+	// Backup saves each original file as <path>.bak before rewriting it.
+	Backup bool
+}
+
+// FileChange describes the replacements made, or that would be made, in
+// one file.
+type FileChange struct {
+	Path         string `json:"path"`
+	Replacements int    `json:"replacements"`
+	// Diff is a unified diff of the change.
+	Diff string `json:"diff"`
+}
+
+// Summary reports totals for a replacement run.
+type Summary struct {
+	Files        int `json:"files"`
+	Replacements int `json:"replacements"`
+	// Skipped lists the files with matches left unchanged because they are
+	// not UTF-8 but UTF-16 or Windows-1252 text, which is only rewritten as
+	// UTF-8.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Run replaces every content match below opts.Search.Dir, calling fn with
+// each changed file in walk order. Unless opts.DryRun is set, each file is
+// written to a temporary file that is renamed over it, keeping its
+// permissions, so no reader sees it half rewritten. A file that cannot be
+// rewritten stops the run with an error; files already changed stay
+// changed. Archive entries are not searched, and files in other encodings
+// than UTF-8 are left unchanged and listed in Summary.Skipped.
+func Run(opts Options, fn func(FileChange)) (Summary, error) {
+	return RunContext(context.Background(), opts, fn)
+}
+
+// RunContext is like Run but stops with ctx.Err() once ctx is done. A run
+// cancelled while files are still being collected changes nothing; one
+// cancelled while rewriting leaves the files already changed as they are.
+func RunContext(ctx context.Context, opts Options, fn func(FileChange)) (Summary, error) {
+	var sum Summary
+	so := opts.Search
+	if len(so.Contents) == 0 {
+		return sum, fmt.Errorf("no content to replace")
+	}
+	if enc, err := search.ParseEncoding(so.Encoding); err != nil {
+		return sum, err
+	} else if enc != "" && enc != search.EncodingUTF8 {
+		return sum, fmt.Errorf("unable to replace in %s files: only UTF-8 files are rewritten", enc)
+	}
+	re, err := search.ContentRegexp(so.Contents, so.Regex, so.Case)
+	if err != nil {
+		return sum, fmt.Errorf("invalid regular expression: %w", err)
+	}
+
+	// Collect the files with matches first so rewriting cannot disturb the
+	// walk. Search treats names and content as alternatives; here a name
+	// pattern narrows the files instead.
+	names, err := search.CompileNames(so.Names, so.Case)
+	if err != nil {
+		return sum, fmt.Errorf("invalid name pattern: %w", err)
+	}
+	dir := so.Dir
+	if dir == "" {
+		dir = "."
+	}
+	narrow := len(so.Names) > 0
+	so.Names, so.Fuzzy, so.Archives = nil, false, false
+	so.Before, so.After, so.Offset, so.MaxResults = 0, 0, 0, 0
+	var paths []string
+	found, err := search.SearchContext(ctx, so, func(m search.Match) {
+		if len(paths) > 0 && paths[len(paths)-1] == m.Path {
+			return
+		}
+		if narrow {
+			rel, err := filepath.Rel(dir, m.Path)
+			if err != nil || !names.Match(filepath.ToSlash(rel)) {
+				return
+			}
+		}
+		paths = append(paths, m.Path)
+	})
+	if err != nil {
+		return sum, err
+	}
+	if found.Cancelled {
+		return sum, ctx.Err()
+	}
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return sum, err
+		}
+		// Rewrite the target of a link rather than replace the link
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return sum, err
+		}
+		info, err := os.Stat(target)
+		if err != nil {
+			return sum, err
+		}
+		old, err := os.ReadFile(target)
+		if err != nil {
+			return sum, err
+		}
+		if search.DetectEncoding(old[:min(len(old), search.BinarySniffSize)]) != search.EncodingUTF8 {
+			sum.Skipped = append(sum.Skipped, path)
+			continue
+		}
+		oldLines := splitLines(string(old))
+		newLines := make([]string, len(oldLines))
+		count := 0
+		for i, line := range oldLines {
+			text, eol := cutEOL(line)
+			n := len(re.FindAllStringIndex(text, -1))
+			if n > 0 {
+				if so.Regex {
+					text = re.ReplaceAllString(text, opts.Replacement)
+				} else {
+					text = re.ReplaceAllLiteralString(text, opts.Replacement)
+				}
+				count += n
+			}
+			newLines[i] = text + eol
+		}
+		if count == 0 {
+			continue
+		}
+		if !opts.DryRun {
+			wo := files.WriteOptions{Overwrite: true, Mode: info.Mode().Perm()}
+			if opts.Backup {
+				if _, err := files.Write(path+".bak", old, wo); err != nil {
+					return sum, fmt.Errorf("unable to write backup: %w", err)
+				}
+			}
+			if _, err := files.Write(target, []byte(strings.Join(newLines, "")), wo); err != nil {
+				return sum, err
+			}
+		}
+		sum.Files++
+		sum.Replacements += count
+		fn(FileChange{Path: path, Replacements: count, Diff: unifiedDiff(path, oldLines, newLines)})
+	}
+	return sum, nil
+}
+
+// splitLines splits s into lines, each keeping its line terminator.
+func splitLines(s string) []string {
+	var lines []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+	return lines
+}
+
+// cutEOL splits a line into its text and its "\n" or "\r\n" terminator, as
+// search matches lines without their terminators.
+func cutEOL(line string) (text, eol string) {
+	if t, ok := strings.CutSuffix(line, "\r\n"); ok {
+		return t, "\r\n"
+	}
+	if t, ok := strings.CutSuffix(line, "\n"); ok {
+		return t, "\n"
+	}
+	return line, ""
+}
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// unifiedDiff renders the changes between old and new, which hold the same
+// number of lines with line i of new replacing line i of old, as a unified
+// diff.
+func unifiedDiff(path string, old, new []string) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", path, path)
+
+	var changed []int
+	for i := range old {
+		if old[i] != new[i] {
+			changed = append(changed, i)
+		}
+	}
+	for h := 0; h < len(changed); {
+		// Extend the hunk while the next change is within its context
+		first, last := changed[h], changed[h]
+		for h++; h < len(changed) && changed[h]-last <= 2*diffContext; h++ {
+			last = changed[h]
+		}
+		start, end := max(0, first-diffContext), min(len(old), last+diffContext+1)
+
+		var body bytes.Buffer
+		oldCount, newCount := 0, 0
+		for i := start; i < end; i++ {
+			if old[i] == new[i] {
+				writeDiffLine(&body, " ", old[i])
+				oldCount++
+				newCount++
+				continue
+			}
+			writeDiffLine(&body, "-", old[i])
+			oldCount++
+			for _, l := range splitLines(new[i]) {
+				writeDiffLine(&body, "+", l)
+				newCount++
+			}
+		}
+		// Lines before this hunk are unchanged in count except where a
+		// replacement introduced newlines
+		newStart := start
+		for i := 0; i < start; i++ {
+			newStart += len(splitLines(new[i])) - 1
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", start+1, oldCount, newStart+1, newCount)
+		b.Write(body.Bytes())
+	}
+	return b.String()
+}
+
+func writeDiffLine(b *bytes.Buffer, prefix, line string) {
+	text, eol := cutEOL(line)
+	b.WriteString(prefix + text + "\n")
+	if eol == "" {
+		b.WriteString("\\ No newline at end of file\n")
+	}
+}
@@ -0,0 +1,129 @@
+package replace
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vscode-helper-file-find/internal/search"
+)
+
+// writeFile creates the file name in dir holding content.
+func writeFile(t *testing.T, dir, name, content string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunKeepsModeAndBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "run.sh", "echo foo\n", 0o750)
+	opts := Options{
+		Search:      search.Options{Dir: dir, Contents: []string{"foo"}},
+		Replacement: "bar",
+		Backup:      true,
+	}
+	sum, err := Run(opts, func(FileChange) {})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if sum.Files != 1 || sum.Replacements != 1 {
+		t.Errorf("Run = %+v, want one replacement in one file", sum)
+	}
+	for name, want := range map[string]string{path: "echo bar\n", path + ".bak": "echo foo\n"} {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(name), b, want)
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o750 {
+			t.Errorf("%s has mode %v, want %v", filepath.Base(name), info.Mode().Perm(), os.FileMode(0o750))
+		}
+	}
+}
+
+func TestRunRewritesLinkTarget(t *testing.T) {
+	dir, other := t.TempDir(), t.TempDir()
+	target := writeFile(t, other, "a.txt", "foo\n", 0o644)
+	link := filepath.Join(dir, "a.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+	opts := Options{Search: search.Options{Dir: dir, Contents: []string{"foo"}}, Replacement: "bar"}
+	if _, err := Run(opts, func(FileChange) {}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("link was replaced by a regular file")
+	}
+	if b, _ := os.ReadFile(target); string(b) != "bar\n" {
+		t.Errorf("link target = %q, want it rewritten", b)
+	}
+}
+
+func TestRunSkipsNonUTF8(t *testing.T) {
+	dir := t.TempDir()
+	utf8Path := writeFile(t, dir, "a.txt", "foo\n", 0o644)
+	// "foo\n" in UTF-16LE with a byte order mark, and in Windows-1252
+	// after a non-ASCII letter
+	utf16 := "\xFF\xFEf\x00o\x00o\x00\n\x00"
+	utf16Path := writeFile(t, dir, "b.txt", utf16, 0o644)
+	cp1252Path := writeFile(t, dir, "c.txt", "caf\xE9 foo\n", 0o644)
+	opts := Options{Search: search.Options{Dir: dir, Contents: []string{"foo"}}, Replacement: "bar"}
+	sum, err := Run(opts, func(FileChange) {})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if sum.Files != 1 || len(sum.Skipped) != 2 || sum.Skipped[0] != utf16Path || sum.Skipped[1] != cp1252Path {
+		t.Errorf("Run = %+v, want a.txt changed and b.txt and c.txt skipped", sum)
+	}
+	for path, want := range map[string]string{utf8Path: "bar\n", utf16Path: utf16, cp1252Path: "caf\xE9 foo\n"} {
+		if b, _ := os.ReadFile(path); string(b) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), b, want)
+		}
+	}
+
+	opts.Search.Encoding = "utf-16le"
+	if _, err := Run(opts, func(FileChange) {}); err == nil {
+		t.Error("Run accepted a UTF-16 encoding")
+	}
+}
+
+func TestRunIgnoresArchives(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "a.txt", "foo\n", 0o644)
+	f, err := os.Create(filepath.Join(dir, "b.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("inner.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("foo\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	opts := Options{Search: search.Options{Dir: dir, Contents: []string{"foo"}, Archives: true}, Replacement: "bar"}
+	var changed []string
+	sum, err := Run(opts, func(c FileChange) { changed = append(changed, c.Path) })
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if sum.Files != 1 || len(changed) != 1 || changed[0] != path {
+		t.Errorf("Run changed %q, want only a.txt", changed)
+	}
+}
@@ -0,0 +1,103 @@
+// Package sandbox confines file operations to a set of allowed directories,
+// as configured with --allow-dir. Paths are compared after resolving
+// symbolic links, so neither ".." nor a link inside an allowed directory
+// can reach outside it.
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutside is wrapped by the errors Check returns for paths outside the
+// allowed directories.
+var ErrOutside = errors.New("outside the allowed directories")
+
+// Sandbox is a set of allowed directories. A nil *Sandbox allows every
+// path, so callers need not check whether confinement is configured.
+type Sandbox struct {
+	dirs []string // absolute, with symlinks resolved
+}
+
+// New returns a Sandbox allowing dirs and everything below them, or nil if
+// dirs is empty. Each directory must exist.
+func New(dirs []string) (*Sandbox, error) {
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+	s := &Sandbox{}
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if abs, err = filepath.EvalSymlinks(abs); err != nil {
+			return nil, fmt.Errorf("allowed directory '%s' does not exist", dir)
+		}
+		if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("allowed directory '%s' is not a directory", dir)
+		}
+		s.dirs = append(s.dirs, abs)
+	}
+	return s, nil
+}
+
+// Dirs returns the allowed directories, resolved, in the order given.
+func (s *Sandbox) Dirs() []string {
+	if s == nil {
+		return nil
+	}
+	return s.dirs
+}
+
+// Check returns an error wrapping ErrOutside unless path is one of the
+// allowed directories or lies below one once symlinks are resolved. A path
+// that does not exist yet, such as a file about to be created, is checked
+// by its nearest existing ancestor.
+func (s *Sandbox) Check(path string) error {
+	if s == nil {
+		return nil
+	}
+	resolved, err := resolve(path)
+	if err != nil {
+		return err
+	}
+	for _, dir := range s.dirs {
+		if rel, err := filepath.Rel(dir, resolved); err == nil && (rel == "." || filepath.IsLocal(rel)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("'%s' is %w (%s)", path, ErrOutside, strings.Join(s.dirs, ", "))
+}
+
+// Allows reports whether Check accepts path.
+func (s *Sandbox) Allows(path string) bool {
+	return s.Check(path) == nil
+}
+
+// resolve returns the absolute form of path with symlinks resolved in the
+// longest prefix of it that exists. A dangling link is an error since
+// creating a file through it would land wherever it points.
+func resolve(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var rest []string
+	for p := abs; ; p = filepath.Dir(p) {
+		if _, err := os.Lstat(p); err == nil {
+			resolved, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return "", fmt.Errorf("unable to resolve '%s': %w", path, err)
+			}
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if filepath.Dir(p) == p {
+			return abs, nil
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+	}
+}
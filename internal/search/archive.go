@@ -0,0 +1,216 @@
+package search
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ArchiveSeparator joins the path of an archive and that of an entry
+// within it in reported matches, as in dist/app.jar!com/example/App.class.
+const ArchiveSeparator = "!"
+
+// DefaultArchiveBudget is how many bytes are decompressed from an archive,
+// including any nested in it, when Options.ArchiveBudget is not set.
+const DefaultArchiveBudget = 256 << 20
+
+// maxArchiveDepth is how deeply archives within archives are opened; the
+// archive found on disk is at depth 1.
+const maxArchiveDepth = 3
+
+// Kinds of archive recognised by archiveKind.
+const (
+	archiveZip   = "zip"
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+)
+
+// archiveKind returns the kind of archive a file is by its name, or "" for
+// files that are not archives.
+func archiveKind(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		return archiveTarGz
+	case strings.HasSuffix(name, ".tar"):
+		return archiveTar
+	}
+	switch path.Ext(name) {
+	case ".zip", ".jar", ".war", ".ear":
+		return archiveZip
+	}
+	return ""
+}
+
+func (o Options) archiveBudget() int64 {
+	if o.ArchiveBudget > 0 {
+		return o.ArchiveBudget
+	}
+	return DefaultArchiveBudget
+}
+
+// errBudget is returned by a budgetReader that has run out.
+var errBudget = errors.New("archive size budget exhausted")
+
+// budgetReader reads from r until the bytes *left run out. One budget is
+// shared by everything decompressed from an archive, including archives
+// nested in it, so that no arrangement of entries, however deep or highly
+// compressed, costs more.
+type budgetReader struct {
+	r    io.Reader
+	left *int64
+}
+
+func (b budgetReader) Read(p []byte) (int, error) {
+	if *b.left <= 0 {
+		return 0, errBudget
+	}
+	if int64(len(p)) > *b.left {
+		p = p[:*b.left]
+	}
+	n, err := b.r.Read(p)
+	*b.left -= int64(n)
+	return n, err
+}
+
+// archiveScan searches the entries of an archive as scanFile searches a
+// file, matching names against the path of each entry within the archive.
+type archiveScan struct {
+	ctx   context.Context
+	q     contentQuery
+	opts  Options
+	names *NameMatcher
+	left  int64
+
+	binary, large int
+	// limited is set when the budget ran out before every entry was read
+	limited bool
+}
+
+// scanArchive returns the matches within the archive at path, of the given
+// kind, and what was skipped on the way.
+func scanArchive(ctx context.Context, path, kind string, q contentQuery, names *NameMatcher, opts Options) ([]Match, *archiveScan) {
+	a := &archiveScan{ctx: ctx, q: q, opts: opts, names: names, left: opts.archiveBudget()}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, a
+	}
+	defer file.Close()
+	var matches []Match
+	if kind != archiveZip {
+		matches = a.tar(path, file, kind, 1)
+	} else if info, err := file.Stat(); err == nil {
+		matches = a.zip(path, file, info.Size(), 1)
+	}
+	a.done()
+	return matches, a
+}
+
+// archive searches an entry that is itself an archive of the given kind,
+// reading it whole first if its format requires.
+func (a *archiveScan) archive(path, kind string, r io.Reader, depth int) []Match {
+	if kind != archiveZip {
+		return a.tar(path, r, kind, depth)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		a.limited = a.limited || errors.Is(err, errBudget)
+		return nil
+	}
+	return a.zip(path, bytes.NewReader(data), int64(len(data)), depth)
+}
+
+func (a *archiveScan) zip(path string, r io.ReaderAt, size int64, depth int) []Match {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil
+	}
+	var matches []Match
+	for _, f := range zr.File {
+		if a.done() {
+			break
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		matches = append(matches, a.entry(path, f.Name, budgetReader{rc, &a.left}, int64(f.UncompressedSize64), depth)...)
+		rc.Close()
+	}
+	return matches
+}
+
+func (a *archiveScan) tar(path string, r io.Reader, kind string, depth int) []Match {
+	if kind == archiveTarGz {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil
+		}
+		defer gz.Close()
+		r = budgetReader{gz, &a.left}
+	}
+	tr := tar.NewReader(r)
+	var matches []Match
+	for !a.done() {
+		h, err := tr.Next()
+		if err != nil {
+			a.limited = a.limited || errors.Is(err, errBudget)
+			break
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		matches = append(matches, a.entry(path, h.Name, tr, h.Size, depth)...)
+	}
+	return matches
+}
+
+// done reports whether to stop reading entries, noting whether the budget
+// has run out.
+func (a *archiveScan) done() bool {
+	if a.left <= 0 {
+		a.limited = true
+	}
+	return a.limited || a.ctx.Err() != nil
+}
+
+// entry searches the entry name, of the given size, read from r in the
+// archive at path.
+func (a *archiveScan) entry(path, name string, r io.Reader, size int64, depth int) []Match {
+	full := path + ArchiveSeparator + name
+	if kind := archiveKind(name); kind != "" && depth < maxArchiveDepth {
+		return a.archive(full, kind, r, depth+1)
+	}
+	if a.names != nil && a.names.Match(name) {
+		if a.q.not != nil {
+			o := a.opts
+			o.Before, o.After = 0, 0
+			if scanText(a.ctx, full, r, nil, contentQuery{not: a.q.not}, o).rejected {
+				return nil
+			}
+		}
+		return []Match{{Kind: NameMatch, Path: full}}
+	}
+	if a.q.match == nil {
+		return nil
+	}
+	if a.opts.MaxFileSize > 0 && size > a.opts.MaxFileSize {
+		a.large++
+		return nil
+	}
+	res := scanText(a.ctx, full, r, nil, a.q, a.opts)
+	if res.binary {
+		a.binary++
+	}
+	return res.matches
+}
@@ -0,0 +1,247 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings accepted by Options.Encoding, besides the empty string and
+// "auto", which detect the encoding of each file.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingLatin1      = "latin1"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// encodingAliases maps the accepted spellings of each encoding, in lower
+// case, to its name.
+var encodingAliases = map[string]string{
+	"":             "",
+	"auto":         "",
+	"utf8":         EncodingUTF8,
+	"utf-8":        EncodingUTF8,
+	"utf16le":      EncodingUTF16LE,
+	"utf-16le":     EncodingUTF16LE,
+	"utf16be":      EncodingUTF16BE,
+	"utf-16be":     EncodingUTF16BE,
+	"latin1":       EncodingLatin1,
+	"latin-1":      EncodingLatin1,
+	"iso-8859-1":   EncodingLatin1,
+	"windows-1252": EncodingWindows1252,
+	"cp1252":       EncodingWindows1252,
+}
+
+// ParseEncoding returns the name of the encoding s, which may be spelled
+// as any of its common aliases; "auto" and "" return "".
+func ParseEncoding(s string) (string, error) {
+	enc, ok := encodingAliases[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		return "", fmt.Errorf("unknown encoding '%s' (expected auto, utf-8, utf-16le, utf-16be, latin1, or windows-1252)", s)
+	}
+	return enc, nil
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// DetectEncoding returns the encoding a search without Options.Encoding
+// assumes for a file starting with head, which should hold its first
+// BinarySniffSize bytes.
+func DetectEncoding(head []byte) string {
+	enc, _ := detectEncoding(head, "")
+	return enc
+}
+
+// detectEncoding returns the encoding of a file starting with head and the
+// length of its byte order mark, if any. A given encoding other than ""
+// is used as is, though a matching mark is still skipped. Otherwise a
+// mark decides; failing that, text with a NUL in every other byte is taken
+// for UTF-16, and text without NULs that is not valid UTF-8 for
+// Windows-1252, with which Latin-1 text agrees but for rarely used control
+// characters.
+func detectEncoding(head []byte, given string) (string, int) {
+	switch {
+	case bytes.HasPrefix(head, bomUTF8) && (given == "" || given == EncodingUTF8):
+		return EncodingUTF8, len(bomUTF8)
+	case bytes.HasPrefix(head, bomUTF16LE) && (given == "" || given == EncodingUTF16LE):
+		return EncodingUTF16LE, len(bomUTF16LE)
+	case bytes.HasPrefix(head, bomUTF16BE) && (given == "" || given == EncodingUTF16BE):
+		return EncodingUTF16BE, len(bomUTF16BE)
+	case given != "":
+		return given, 0
+	}
+	if enc, ok := sniffUTF16(head); ok {
+		return enc, 0
+	}
+	if bytes.IndexByte(head, 0) < 0 && !validUTF8Prefix(head) {
+		return EncodingWindows1252, 0
+	}
+	return EncodingUTF8, 0
+}
+
+// sniffUTF16 reports whether head looks like UTF-16 without a byte order
+// mark, as mostly ASCII text does: a NUL in nearly every high byte and in
+// almost no low byte.
+func sniffUTF16(head []byte) (string, bool) {
+	pairs := len(head) / 2
+	if pairs < 4 {
+		return "", false
+	}
+	var evenNUL, oddNUL int
+	for i := 0; i+1 < len(head); i += 2 {
+		if head[i] == 0 {
+			evenNUL++
+		}
+		if head[i+1] == 0 {
+			oddNUL++
+		}
+	}
+	switch {
+	case oddNUL*10 >= pairs*9 && evenNUL*10 < pairs:
+		return EncodingUTF16LE, true
+	case evenNUL*10 >= pairs*9 && oddNUL*10 < pairs:
+		return EncodingUTF16BE, true
+	}
+	return "", false
+}
+
+// validUTF8Prefix reports whether head is valid UTF-8, allowing it to end
+// partway through a character.
+func validUTF8Prefix(head []byte) bool {
+	for len(head) > 0 {
+		r, size := utf8.DecodeRune(head)
+		if r == utf8.RuneError && size <= 1 {
+			return !utf8.FullRune(head)
+		}
+		head = head[size:]
+	}
+	return true
+}
+
+// decodeReader returns a reader of the content of r, in encoding enc,
+// as UTF-8. UTF-8 content is passed through as is.
+func decodeReader(r io.Reader, enc string) io.Reader {
+	d := &decoder{r: bufio.NewReader(r)}
+	switch enc {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		d.next = d.utf16Rune(enc == EncodingUTF16BE)
+	case EncodingLatin1:
+		d.next = d.byteRune(nil)
+	case EncodingWindows1252:
+		d.next = d.byteRune(&windows1252)
+	default:
+		return r
+	}
+	return d
+}
+
+// decoder is an io.Reader that transcodes the runes next reads from r to
+// UTF-8.
+type decoder struct {
+	r    *bufio.Reader
+	next func() (rune, error)
+	out  []byte
+	buf  []byte
+	err  error
+}
+
+// decodeBatch is how many runes a decoder transcodes per fill.
+const decodeBatch = 4096
+
+func (d *decoder) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.buf = d.buf[:0]
+		for range decodeBatch {
+			r, err := d.next()
+			if err != nil {
+				d.err = err
+				break
+			}
+			d.buf = utf8.AppendRune(d.buf, r)
+		}
+		d.out = d.buf
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// utf16Rune returns a next function decoding UTF-16 with the given byte
+// order. Unpaired surrogates, and an odd byte at the end, decode as
+// U+FFFD.
+func (d *decoder) utf16Rune(bigEndian bool) func() (rune, error) {
+	var pending uint16
+	havePending := false
+	unit := func() (uint16, error) {
+		if havePending {
+			havePending = false
+			return pending, nil
+		}
+		var b [2]byte
+		if n, err := io.ReadFull(d.r, b[:]); err != nil {
+			if n == 1 {
+				return utf8.RuneError, nil
+			}
+			return 0, err
+		}
+		if bigEndian {
+			return uint16(b[0])<<8 | uint16(b[1]), nil
+		}
+		return uint16(b[1])<<8 | uint16(b[0]), nil
+	}
+	return func() (rune, error) {
+		u, err := unit()
+		if err != nil {
+			return 0, err
+		}
+		if !utf16.IsSurrogate(rune(u)) {
+			return rune(u), nil
+		}
+		u2, err := unit()
+		if err != nil {
+			return utf8.RuneError, nil
+		}
+		if r := utf16.DecodeRune(rune(u), rune(u2)); r != utf8.RuneError {
+			return r, nil
+		}
+		// Not a pair; u2 may start the next character
+		pending, havePending = u2, true
+		return utf8.RuneError, nil
+	}
+}
+
+// byteRune returns a next function decoding a single-byte encoding in
+// which bytes are code points but for those from 0x80 to 0x9F, which high
+// maps when set.
+func (d *decoder) byteRune(high *[32]rune) func() (rune, error) {
+	return func() (rune, error) {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if high != nil && b >= 0x80 && b < 0xA0 {
+			return high[b-0x80], nil
+		}
+		return rune(b), nil
+	}
+}
+
+// windows1252 holds the characters of Windows-1252 from 0x80 to 0x9F. The
+// five bytes it leaves undefined keep their Latin-1 meaning.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
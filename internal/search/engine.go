@@ -0,0 +1,44 @@
+package search
+
+import (
+	"context"
+	"fmt"
+)
+
+// Engine runs searches described by Options over one or more directories,
+// reporting results to fn as SearchDirs does.
+type Engine interface {
+	// Name is the name NewEngine knows the engine by.
+	Name() string
+	Search(ctx context.Context, opts Options, dirs []string, fn func(Match)) (Summary, error)
+}
+
+// Names of the engines NewEngine returns.
+const (
+	EngineNative  = "native"
+	EngineRipgrep = "rg"
+)
+
+// Native is the built-in engine: SearchDirs.
+var Native Engine = nativeEngine{}
+
+type nativeEngine struct{}
+
+func (nativeEngine) Name() string { return EngineNative }
+
+func (nativeEngine) Search(ctx context.Context, opts Options, dirs []string, fn func(Match)) (Summary, error) {
+	return SearchDirs(ctx, opts, dirs, fn)
+}
+
+// NewEngine returns the engine called name; "" is Native. An engine other
+// than Native may hand a search it cannot run to Native, saying why in
+// Summary.Fallback.
+func NewEngine(name string) (Engine, error) {
+	switch name {
+	case "", EngineNative:
+		return Native, nil
+	case EngineRipgrep:
+		return ripgrep{}, nil
+	}
+	return nil, fmt.Errorf("unknown search engine '%s' (expected native or rg)", name)
+}
@@ -0,0 +1,26 @@
+//go:build !unix
+
+package search
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// fileID identifies a file independently of the path used to reach it.
+// Without inodes, the fully resolved path stands in.
+type fileID struct {
+	path string
+}
+
+// fileIdentity returns the resolved path of the file at path.
+func fileIdentity(path string, info fs.FileInfo) (fileID, bool) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fileID{}, false
+	}
+	if abs, err := filepath.Abs(real); err == nil {
+		real = abs
+	}
+	return fileID{path: real}, true
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package search
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID identifies a file independently of the path used to reach it.
+type fileID struct {
+	dev, ino uint64
+}
+
+// fileIdentity returns the device and inode of info, the file at path.
+func fileIdentity(path string, info fs.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
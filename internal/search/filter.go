@@ -0,0 +1,109 @@
+package search
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// filtered reports whether any of the file list, file type, size, and
+// modification time filters are set.
+func (o Options) filtered() bool {
+	return o.Files != nil || len(o.Types) > 0 || len(o.TypesNot) > 0 || o.statFiltered()
+}
+
+// statFiltered reports whether any of the size and modification time
+// filters are set.
+func (o Options) statFiltered() bool {
+	return o.MinSize > 0 || o.MaxSize > 0 || !o.NewerThan.IsZero() || !o.OlderThan.IsZero()
+}
+
+// keepFile reports whether the file at path passes the size and
+// modification time filters. A symbolic link is judged by its target.
+func (o Options) keepFile(path string, d fs.DirEntry) bool {
+	var info fs.FileInfo
+	var err error
+	if d.Type()&fs.ModeSymlink != 0 {
+		info, err = os.Stat(path)
+	} else {
+		info, err = d.Info()
+	}
+	if err != nil {
+		return false
+	}
+	switch {
+	case o.MinSize > 0 && info.Size() < o.MinSize:
+		return false
+	case o.MaxSize > 0 && info.Size() > o.MaxSize:
+		return false
+	case !o.NewerThan.IsZero() && !info.ModTime().After(o.NewerThan):
+		return false
+	case !o.OlderThan.IsZero() && !info.ModTime().Before(o.OlderThan):
+		return false
+	}
+	return true
+}
+
+// sizeUnits are the suffixes ParseSize accepts, in powers of 1024.
+var sizeUnits = map[string]int64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+}
+
+// ParseSize parses a file size such as 512, 10K, or 1.5MB. Units are
+// powers of 1024 and case-insensitive.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if err != nil || !ok || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (want bytes or a number with a K, M, or G suffix)", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// timeLayouts are the timestamp forms ParseTime accepts besides durations.
+// Those without a zone are read in local time.
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ParseTime parses a point in time given either as a duration before now,
+// such as 36h, 2d, or 1w, or as a timestamp such as 2024-05-01 or
+// 2024-05-01T09:30:00Z.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if d, ok := parseAge(s); ok {
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (want a duration such as 2d or 36h, or a date such as 2024-05-01)", s)
+}
+
+// parseAge parses a non-negative duration, allowing d (days) and w (weeks)
+// as units besides those of time.ParseDuration.
+func parseAge(s string) (time.Duration, bool) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			f, err := strconv.ParseFloat(n, 64)
+			if err != nil || f < 0 {
+				return 0, false
+			}
+			return time.Duration(f * float64(unit)), true
+		}
+	}
+	d, err := time.ParseDuration(s)
+	return d, err == nil && d >= 0
+}
@@ -0,0 +1,94 @@
+package search
+
+import (
+	"unicode"
+)
+
+// Fuzzy scoring weights, loosely following fzf: every matched character
+// scores, with bonuses for matches at word boundaries and for runs of
+// consecutive characters, and a penalty for the gaps in between.
+const (
+	fuzzyMatch       = 16
+	fuzzyBoundary    = 8
+	fuzzyConsecutive = 8
+	fuzzyGapStart    = 3
+	fuzzyGapExtend   = 1
+)
+
+// FuzzyScore reports whether the characters of query appear in name in
+// order, not necessarily adjacent, and scores the best such alignment:
+// "usrsvc" matches "user_service.go". Higher scores are better matches.
+// mode selects case sensitivity as for MatchName.
+func FuzzyScore(query, name string, mode CaseMode) (score int, ok bool) {
+	q, n := []rune(query), []rune(name)
+	if len(q) == 0 || len(q) > len(n) {
+		return 0, len(q) == 0
+	}
+	fold := mode.fold([]string{query}, false)
+	eq := func(a, b rune) bool {
+		if fold {
+			return unicode.ToLower(a) == unicode.ToLower(b)
+		}
+		return a == b
+	}
+
+	// best[j] is the best score for the query so far with its last
+	// character matched at n[j], or none.
+	const none = -1 << 30
+	best := make([]int, len(n))
+	for j := range n {
+		best[j] = none
+		if eq(q[0], n[j]) {
+			best[j] = fuzzyMatch + fuzzyBonus(n, j)
+		}
+	}
+	for i := 1; i < len(q); i++ {
+		next := make([]int, len(n))
+		for j := range n {
+			next[j] = none
+			if !eq(q[i], n[j]) {
+				continue
+			}
+			for k := i - 1; k < j; k++ {
+				if best[k] == none {
+					continue
+				}
+				s := best[k]
+				if gap := j - k - 1; gap == 0 {
+					s += fuzzyConsecutive
+				} else {
+					s -= fuzzyGapStart + (gap-1)*fuzzyGapExtend
+				}
+				next[j] = max(next[j], s)
+			}
+			if next[j] != none {
+				next[j] += fuzzyMatch + fuzzyBonus(n, j)
+			}
+		}
+		best = next
+	}
+
+	score = none
+	for _, s := range best {
+		score = max(score, s)
+	}
+	return score, score != none
+}
+
+// fuzzyBonus scores n[j] as the start of a word: the first character, one
+// following a separator, or an uppercase letter following a lowercase one.
+func fuzzyBonus(n []rune, j int) int {
+	if j == 0 {
+		return fuzzyBoundary * 2
+	}
+	prev, cur := n[j-1], n[j]
+	switch {
+	case prev == '_' || prev == '-' || prev == '.' || prev == ' ' || prev == '/':
+		return fuzzyBoundary
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return fuzzyBoundary
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && unicode.IsLetter(cur):
+		return fuzzyBoundary / 2
+	}
+	return 0
+}
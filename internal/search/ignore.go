@@ -0,0 +1,328 @@
+package search
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFileNames are the per-directory ignore files honored during a walk,
+// in the order they are applied. Later files take precedence.
+var ignoreFileNames = []string{".gitignore", ".ignore"}
+
+// ignoreRule is a single compiled gitignore pattern.
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreSet is the list of rules read from one directory.
+type ignoreSet struct {
+	base  string // directory the patterns are relative to
+	rules []ignoreRule
+}
+
+// ignorer decides whether paths are excluded by gitignore-style rules. Rules
+// are collected from global excludes, .git/info/exclude, and the .gitignore
+// and .ignore files of every directory from the repository root down to the
+// path being checked. As in git, the last matching rule wins.
+type ignorer struct {
+	global []ignoreSet
+	dirs   map[string][]ignoreSet
+}
+
+// newIgnorer prepares an ignorer for a walk starting at root. Ignore files in
+// the directories between the enclosing git repository root (if any) and
+// root are loaded up front; directories below root are loaded as the walk
+// reaches them via loadDir.
+func newIgnorer(root string) *ignorer {
+	ig := &ignorer{dirs: make(map[string][]ignoreSet)}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		abs = root
+	}
+
+	top := findRepoRoot(abs)
+	base := top
+	if base == "" {
+		base = abs
+	}
+	if path := globalExcludesFile(); path != "" {
+		if set, ok := readIgnoreFile(path, base); ok {
+			ig.global = append(ig.global, set)
+		}
+	}
+	if top != "" {
+		if set, ok := readIgnoreFile(filepath.Join(top, ".git", "info", "exclude"), top); ok {
+			ig.global = append(ig.global, set)
+		}
+		// Parent directories of root inside the repository
+		rel, err := filepath.Rel(top, abs)
+		if err == nil && rel != "." {
+			dir := top
+			ig.global = append(ig.global, readDirIgnores(dir)...)
+			parts := strings.Split(rel, string(filepath.Separator))
+			for _, part := range parts[:len(parts)-1] {
+				dir = filepath.Join(dir, part)
+				ig.global = append(ig.global, readDirIgnores(dir)...)
+			}
+		}
+	}
+	return ig
+}
+
+// loadDir reads the ignore files in dir. It must be called for a directory
+// before any of its entries are checked.
+func (ig *ignorer) loadDir(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	if sets := readDirIgnores(abs); len(sets) > 0 {
+		ig.dirs[abs] = sets
+	}
+}
+
+// ignored reports whether path should be skipped.
+func (ig *ignorer) ignored(path string, isDir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	// Collect rule sets from the outermost directory inwards
+	sets := append([]ignoreSet(nil), ig.global...)
+	var chain []string
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		chain = append(chain, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		sets = append(sets, ig.dirs[chain[i]]...)
+	}
+
+	ignored := false
+	for _, set := range sets {
+		rel, err := filepath.Rel(set.base, abs)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if state, ok := set.match(filepath.ToSlash(rel), isDir); ok {
+			ignored = state
+		}
+	}
+	return ignored
+}
+
+// compileExcludes compiles exclude patterns relative to root. Invalid
+// patterns are dropped.
+func compileExcludes(root string, patterns []string) ignoreSet {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		abs = root
+	}
+	set := ignoreSet{base: abs}
+	for _, pattern := range patterns {
+		// "dir/**" excludes everything below dir; prune dir itself instead
+		if trimmed := strings.TrimSuffix(pattern, "/**"); trimmed != pattern && trimmed != "" {
+			pattern = trimmed + "/"
+		}
+		if rule, ok := parseIgnoreLine(pattern); ok {
+			set.rules = append(set.rules, rule)
+		}
+	}
+	return set
+}
+
+// matches reports whether path is matched by the set. As in git, the last
+// matching rule wins.
+func (set ignoreSet) matches(path string, isDir bool) bool {
+	if len(set.rules) == 0 {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(set.base, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	matched, _ := set.match(filepath.ToSlash(rel), isDir)
+	return matched
+}
+
+// match evaluates the set's rules against rel, a slash-separated path
+// relative to set.base. It reports whether the last matching rule excludes
+// the path, and whether any rule matched at all.
+func (set ignoreSet) match(rel string, isDir bool) (excluded, ok bool) {
+	for _, rule := range set.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(rel) {
+			excluded, ok = !rule.negate, true
+		}
+	}
+	return excluded, ok
+}
+
+// findRepoRoot returns the nearest ancestor of dir (inclusive) containing a
+// .git entry, or "" if there is none.
+func findRepoRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// globalExcludesFile returns git's core.excludesFile, falling back to the
+// default $XDG_CONFIG_HOME/git/ignore location.
+func globalExcludesFile() string {
+	if out, err := exec.Command("git", "config", "--path", "--get", "core.excludesFile").Output(); err == nil {
+		if path := strings.TrimSpace(string(out)); path != "" {
+			return path
+		}
+	}
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		config = filepath.Join(home, ".config")
+	}
+	return filepath.Join(config, "git", "ignore")
+}
+
+// readDirIgnores reads the ignore files present in dir.
+func readDirIgnores(dir string) []ignoreSet {
+	var sets []ignoreSet
+	for _, name := range ignoreFileNames {
+		if set, ok := readIgnoreFile(filepath.Join(dir, name), dir); ok {
+			sets = append(sets, set)
+		}
+	}
+	return sets
+}
+
+// readIgnoreFile parses the gitignore-style file at path with patterns
+// relative to base. It reports false if the file cannot be read or has no
+// rules.
+func readIgnoreFile(path, base string) (ignoreSet, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ignoreSet{}, false
+	}
+	defer f.Close()
+
+	set := ignoreSet{base: base}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text()); ok {
+			set.rules = append(set.rules, rule)
+		}
+	}
+	return set, len(set.rules) > 0
+}
+
+// parseIgnoreLine compiles a single gitignore line.
+func parseIgnoreLine(line string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// A slash anywhere but the end anchors the pattern to the base directory
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	prefix := "^(?:.*/)?"
+	if anchored {
+		prefix = "^"
+	}
+	re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// globToRegexp translates a gitignore glob into a regular expression body.
+// '*' and '?' do not cross '/', while '**' matches across directories.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				switch {
+				case i+1 < len(glob) && glob[i+1] == '/':
+					// "**/" matches zero or more directories
+					i++
+					b.WriteString("(?:.*/)?")
+				default:
+					b.WriteString(".*")
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,374 @@
+package search
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CaseMode selects how letter case is treated when matching.
+type CaseMode int
+
+const (
+	// SmartCase matches case-insensitively unless the patterns contain an
+	// uppercase letter.
+	SmartCase CaseMode = iota
+	// IgnoreCase always matches case-insensitively.
+	IgnoreCase
+	// CaseSensitive always matches case-sensitively.
+	CaseSensitive
+)
+
+// Folds reports whether a search under m matches patterns regardless of
+// case, for callers that match the same terms some other way.
+func (m CaseMode) Folds(patterns []string, regex bool) bool {
+	return m.fold(patterns, regex)
+}
+
+// fold reports whether patterns should be matched case-insensitively. With
+// regex set, escape sequences such as \W or \S do not count as uppercase.
+func (m CaseMode) fold(patterns []string, regex bool) bool {
+	switch m {
+	case IgnoreCase:
+		return true
+	case CaseSensitive:
+		return false
+	}
+	for _, p := range patterns {
+		if hasUpper(p, regex) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasUpper reports whether pattern contains an uppercase letter, ignoring
+// the character following a backslash when regex is set.
+func hasUpper(pattern string, regex bool) bool {
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case regex && r == '\\':
+			escaped = true
+		case unicode.IsUpper(r):
+			return true
+		}
+	}
+	return false
+}
+
+// NameMatcher matches paths against name patterns; see CompileNames.
+type NameMatcher struct {
+	patterns    []namePattern
+	fold        bool
+	hasPositive bool
+}
+
+// namePattern is one compiled name pattern. A pattern without a slash is
+// matched against the base name with filepath.Match; one with a slash
+// against the whole relative path with path set.
+type namePattern struct {
+	glob   string
+	path   *regexp.Regexp
+	negate bool
+}
+
+// CompileNames compiles name patterns. A pattern without a slash matches a
+// file's base name; one with a slash is matched against the path relative
+// to the search root, where '**' matches any number of directories, so
+// cmd/**/*_test.go and **/Dockerfile work as in gitignore. Patterns with a
+// leading '!' are negated. Case is handled according to mode, judged
+// across all of the patterns.
+func CompileNames(patterns []string, mode CaseMode) (*NameMatcher, error) {
+	m := &NameMatcher{fold: mode.fold(patterns, false)}
+	for _, pattern := range patterns {
+		var p namePattern
+		if p.negate = strings.HasPrefix(pattern, "!"); p.negate {
+			pattern = pattern[1:]
+		} else {
+			m.hasPositive = true
+		}
+		if m.fold {
+			pattern = strings.ToLower(pattern)
+		}
+		if strings.Contains(pattern, "/") {
+			re, err := regexp.Compile("^" + globToRegexp(strings.TrimPrefix(pattern, "/")) + "$")
+			if err != nil {
+				return nil, fmt.Errorf("syntax error in pattern %q", pattern)
+			}
+			p.path = re
+		} else {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, err
+			}
+			p.glob = pattern
+		}
+		m.patterns = append(m.patterns, p)
+	}
+	return m, nil
+}
+
+// Match reports whether rel, a slash-separated path relative to the search
+// root, matches. It matches when it matches at least one positive pattern
+// and no negated pattern; if only negated patterns are given, when it
+// matches none of them.
+func (m *NameMatcher) Match(rel string) bool {
+	if m.fold {
+		rel = strings.ToLower(rel)
+	}
+	base := path.Base(rel)
+	positive := false
+	for _, p := range m.patterns {
+		var matched bool
+		if p.path != nil {
+			matched = p.path.MatchString(rel)
+		} else {
+			matched, _ = filepath.Match(p.glob, base)
+		}
+		if !matched {
+			continue
+		}
+		if p.negate {
+			return false
+		}
+		positive = true
+	}
+	return positive || !m.hasPositive
+}
+
+// MatchName reports whether rel, a slash-separated path relative to the
+// search root, matches the name patterns, as compiled by CompileNames.
+func MatchName(patterns []string, rel string, mode CaseMode) (bool, error) {
+	m, err := CompileNames(patterns, mode)
+	if err != nil {
+		return false, err
+	}
+	return m.Match(rel), nil
+}
+
+// lineMatcher returns the byte offsets of the first match in line, or
+// (-1, -1) if the line does not match.
+type lineMatcher func(line string) (start, end int)
+
+// newLineMatcher returns a matcher for the content terms. A line matches if
+// it contains any term. With regex set the terms are regular expressions;
+// otherwise they are literal strings. With word set a match only counts
+// when it is not part of a longer word.
+func newLineMatcher(terms []string, regex, word bool, mode CaseMode) (lineMatcher, error) {
+	return newFoldedMatcher(terms, regex, word, mode.fold(terms, regex))
+}
+
+// newFoldedMatcher is like newLineMatcher with case folding decided by the
+// caller.
+func newFoldedMatcher(terms []string, regex, word, fold bool) (lineMatcher, error) {
+	if !regex && !fold {
+		return func(line string) (int, int) {
+			pos, end := -1, -1
+			for _, term := range terms {
+				if i := indexTerm(line, term, word); i >= 0 && (pos < 0 || i < pos) {
+					pos, end = i, i+len(term)
+				}
+			}
+			return pos, end
+		}, nil
+	}
+
+	re, err := compileContentRegex(terms, regex, fold)
+	if err != nil {
+		return nil, err
+	}
+	if word {
+		return func(line string) (int, int) {
+			for _, loc := range re.FindAllStringIndex(line, -1) {
+				if wordBounded(line, loc[0], loc[1]) {
+					return loc[0], loc[1]
+				}
+			}
+			return -1, -1
+		}, nil
+	}
+	return func(line string) (int, int) {
+		if loc := re.FindStringIndex(line); loc != nil {
+			return loc[0], loc[1]
+		}
+		return -1, -1
+	}, nil
+}
+
+// indexTerm returns the index of the first occurrence of term in line, or
+// with word set of the first one that is a whole word, or -1.
+func indexTerm(line, term string, word bool) int {
+	if !word || term == "" {
+		return strings.Index(line, term)
+	}
+	for from := 0; ; {
+		i := strings.Index(line[from:], term)
+		if i < 0 {
+			return -1
+		}
+		if i += from; wordBounded(line, i, i+len(term)) {
+			return i
+		}
+		from = i + 1
+	}
+}
+
+// wordBounded reports whether line[start:end] is neither preceded nor
+// followed by a word character, as grep -w requires.
+func wordBounded(line string, start, end int) bool {
+	if before, _ := utf8.DecodeLastRuneInString(line[:start]); start > 0 && isWordRune(before) {
+		return false
+	}
+	if after, _ := utf8.DecodeRuneInString(line[end:]); end < len(line) && isWordRune(after) {
+		return false
+	}
+	return true
+}
+
+// isWordRune reports whether r is a letter, digit, or underscore.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// contentQuery holds the content conditions of a search, evaluated per
+// file by scanFile.
+type contentQuery struct {
+	// match finds the lines reported: those containing any term
+	match lineMatcher
+	// all, with Options.AllContents, holds a matcher per term, each of
+	// which must match some line of the file
+	all []lineMatcher
+	// not, if set, rejects a file in which any line matches
+	not lineMatcher
+	// prefilter, if set, reports whether a whole file may hold a line
+	// that match finds; it is false only when none can
+	prefilter func(data []byte) bool
+}
+
+// newContentQuery compiles the content conditions of o. match is nil when
+// o has no Contents, and not when it has no NotContents.
+func newContentQuery(o Options) (contentQuery, error) {
+	var q contentQuery
+	if len(o.Contents) > 0 {
+		fold := o.Case.fold(o.Contents, o.Regex)
+		m, err := newFoldedMatcher(o.Contents, o.Regex, o.Word, fold)
+		if err != nil {
+			return q, err
+		}
+		q.match = m
+		if !o.Regex {
+			q.prefilter = newPrefilter(o.Contents, fold)
+		}
+		if o.AllContents && len(o.Contents) > 1 {
+			for _, term := range o.Contents {
+				m, _ := newFoldedMatcher([]string{term}, o.Regex, o.Word, fold)
+				q.all = append(q.all, m)
+			}
+		}
+	}
+	if len(o.NotContents) > 0 {
+		m, err := newLineMatcher(o.NotContents, o.Regex, o.Word, o.Case)
+		if err != nil {
+			return q, err
+		}
+		q.not = m
+	}
+	return q, nil
+}
+
+// newPrefilter returns a contentQuery.prefilter for literal terms. A term
+// cannot span lines, so one found in no part of the file is in no line,
+// and the whole file is searched at once instead of line by line.
+func newPrefilter(terms []string, fold bool) func(data []byte) bool {
+	lits := make([][]byte, len(terms))
+	longest := 1
+	for i, term := range terms {
+		if fold {
+			for j := 0; j < len(term); j++ {
+				if term[j] >= utf8.RuneSelf {
+					// Unicode folding can change lengths; leave it to the lines
+					return nil
+				}
+			}
+			term = strings.ToLower(term)
+		}
+		lits[i] = []byte(term)
+		longest = max(longest, len(term))
+	}
+	if !fold {
+		return func(data []byte) bool {
+			for _, lit := range lits {
+				if bytes.Contains(data, lit) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	// Lower-case the file a block at a time, each overlapping the last by
+	// enough to hold a term that straddles them
+	return func(data []byte) bool {
+		buf := make([]byte, prefilterBlock+longest)
+		for start := 0; start < len(data); start += prefilterBlock {
+			block := buf[:copy(buf, data[start:min(len(data), start+prefilterBlock+longest-1)])]
+			for i, c := range block {
+				if 'A' <= c && c <= 'Z' {
+					block[i] = c + 'a' - 'A'
+				}
+			}
+			for _, lit := range lits {
+				if bytes.Contains(block, lit) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// prefilterBlock is how many bytes a case-insensitive prefilter lower-cases
+// at a time.
+const prefilterBlock = 64 << 10
+
+// compileContentRegex compiles terms into a single regular expression in
+// which each term is an alternative. Literal terms are quoted; fold makes
+// the expression case-insensitive.
+func compileContentRegex(terms []string, regex, fold bool) (*regexp.Regexp, error) {
+	alts := make([]string, len(terms))
+	for i, term := range terms {
+		if !regex {
+			term = regexp.QuoteMeta(term)
+		} else if _, err := regexp.Compile(term); err != nil {
+			// Report errors against the term as written
+			return nil, err
+		}
+		alts[i] = term
+	}
+	expr := alts[0]
+	if len(alts) > 1 {
+		expr = "(?:" + strings.Join(alts, ")|(?:") + ")"
+	}
+	if fold {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// ContentRegexp compiles terms into the single regular expression used for
+// content matching, applying mode the same way Search does. Literal terms
+// are quoted; with regex set their capture groups are kept, so the result
+// can drive replacements.
+func ContentRegexp(terms []string, regex bool, mode CaseMode) (*regexp.Regexp, error) {
+	if len(terms) == 0 {
+		return nil, errors.New("no content terms given")
+	}
+	return compileContentRegex(terms, regex, mode.fold(terms, regex))
+}
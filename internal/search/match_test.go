@@ -0,0 +1,94 @@
+package search
+
+import "testing"
+
+func TestNameMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		mode     CaseMode
+		match    []string
+		noMatch  []string
+	}{
+		{
+			name:     "only positives",
+			patterns: []string{"*.go", "*.md"},
+			match:    []string{"main.go", "cmd/root.go", "README.md"},
+			noMatch:  []string{"go.mod", "cmd/run.py"},
+		},
+		{
+			name:     "only negations",
+			patterns: []string{"!*_test.go", "!vendor/**"},
+			match:    []string{"main.go", "cmd/run.py", "docs/vendor.md"},
+			noMatch:  []string{"main_test.go", "cmd/root_test.go", "vendor/x/y.go"},
+		},
+		{
+			name:     "positives and negations",
+			patterns: []string{"*.go", "!*_test.go"},
+			match:    []string{"main.go", "internal/search/match.go"},
+			noMatch:  []string{"main_test.go", "README.md"},
+		},
+		{
+			name:     "negation overrides a positive",
+			patterns: []string{"cmd/**/*.go", "!cmd/internal/**", "!gen.go"},
+			match:    []string{"cmd/root.go", "cmd/tool/main.go"},
+			noMatch:  []string{"cmd/internal/x.go", "cmd/tool/gen.go", "main.go"},
+		},
+		{
+			name:     "negation listed first still overrides",
+			patterns: []string{"!*.min.js", "*.js"},
+			match:    []string{"app.js"},
+			noMatch:  []string{"app.min.js"},
+		},
+		{
+			name:     "smart case folds lowercase patterns",
+			patterns: []string{"*.go", "!*_test.go"},
+			match:    []string{"Main.GO", "cmd/ROOT.go"},
+			noMatch:  []string{"Main_TEST.go"},
+		},
+		{
+			name:     "smart case is sensitive when any pattern has uppercase",
+			patterns: []string{"*.go", "!README.go"},
+			match:    []string{"main.go", "readme.go"},
+			noMatch:  []string{"Main.GO", "README.go"},
+		},
+		{
+			name:     "ignore case",
+			patterns: []string{"*.GO", "!*_Test.go"},
+			mode:     IgnoreCase,
+			match:    []string{"main.go", "MAIN.GO"},
+			noMatch:  []string{"main_test.go"},
+		},
+		{
+			name:     "case sensitive",
+			patterns: []string{"*.go", "!*_test.go"},
+			mode:     CaseSensitive,
+			match:    []string{"main.go", "main_TEST.go"},
+			noMatch:  []string{"main.GO", "main_test.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := CompileNames(tt.patterns, tt.mode)
+			if err != nil {
+				t.Fatalf("CompileNames(%q): %v", tt.patterns, err)
+			}
+			for _, rel := range tt.match {
+				if !m.Match(rel) {
+					t.Errorf("%q does not match %q, want a match", rel, tt.patterns)
+				}
+			}
+			for _, rel := range tt.noMatch {
+				if m.Match(rel) {
+					t.Errorf("%q matches %q, want no match", rel, tt.patterns)
+				}
+			}
+		})
+	}
+}
+
+func TestCompileNamesError(t *testing.T) {
+	if _, err := CompileNames([]string{"*.go", "![bad"}, SmartCase); err == nil {
+		t.Error("CompileNames accepted a malformed negated pattern")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package search
+
+import "os"
+
+// mapFile reports that memory mapping is unavailable, so files are read.
+func mapFile(file *os.File, size int64) ([]byte, func(), bool) {
+	return nil, nil, false
+}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package search
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the size bytes of file into memory read-only. The returned
+// function unmaps them.
+func mapFile(file *os.File, size int64) ([]byte, func(), bool) {
+	if size <= 0 || int64(int(size)) != size {
+		return nil, nil, false
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, false
+	}
+	return data, func() { syscall.Munmap(data) }, true
+}
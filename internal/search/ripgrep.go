@@ -0,0 +1,308 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ripgrep is the Engine that runs rg --json for content searches, which
+// on large trees is faster than the native walker. Results of different
+// files come in the order rg finishes them unless Options.Sort is set.
+type ripgrep struct{}
+
+func (ripgrep) Name() string { return EngineRipgrep }
+
+func (ripgrep) Search(ctx context.Context, opts Options, dirs []string, fn func(Match)) (Summary, error) {
+	if len(dirs) == 0 {
+		dirs = []string{opts.dir()}
+	}
+	rg, err := exec.LookPath("rg")
+	reason := "rg is not installed or not in PATH"
+	if err == nil {
+		reason = rgUnsupported(opts, dirs)
+	}
+	if reason != "" {
+		sum, err := Native.Search(ctx, opts, dirs, fn)
+		sum.Fallback = reason
+		return sum, err
+	}
+	for _, dir := range dirs {
+		o := opts
+		o.Dir = dir
+		if err := o.Validate(); err != nil {
+			return Summary{}, err
+		}
+	}
+	if opts.Sort == "" && len(opts.Boost) > 0 {
+		return rankRipgrep(ctx, rg, opts, dirs, fn)
+	}
+	return runRipgrep(ctx, rg, opts, dirs, fn)
+}
+
+// rankRipgrep runs rg for the search like runRipgrep but gathers its
+// results by file, so as to report those of the files in opts.Boost first.
+// MaxResults is applied once they are ranked.
+func rankRipgrep(ctx context.Context, rg string, opts Options, dirs []string, fn func(Match)) (Summary, error) {
+	o := opts
+	o.MaxResults = 0
+	var groups []fileResults
+	var file []Match
+	flush := func() {
+		if len(file) > 0 {
+			groups = append(groups, newFileResults(file, sortBoost, opts.Boost))
+			file = nil
+		}
+	}
+	sum, err := runRipgrep(ctx, rg, o, dirs, func(m Match) {
+		// rg reports each file's results together
+		if len(file) > 0 && m.Path != file[0].Path {
+			flush()
+		}
+		file = append(file, m)
+	})
+	flush()
+	sortResults(groups, sortBoost, false)
+
+	page := &pager{limit: opts.MaxResults, before: opts.Before, after: opts.After}
+	sum.Files = 0
+	for _, g := range groups {
+		emitted := false
+		for _, m := range g.matches {
+			out, stop := page.add(m)
+			if stop {
+				sum.Truncated = true
+				break
+			}
+			for _, m := range out {
+				emitted = true
+				fn(m)
+			}
+		}
+		if emitted {
+			sum.Files++
+		}
+		if sum.Truncated {
+			break
+		}
+	}
+	return sum, err
+}
+
+// rgUnsupported returns why rg cannot run the search, or "" if it can.
+func rgUnsupported(o Options, dirs []string) string {
+	switch {
+	case len(o.Contents) == 0:
+		return "rg only runs content searches"
+	case len(o.Names) > 0 || o.Fuzzy:
+		return "rg does not match file names"
+	case o.AllContents || len(o.NotContents) > 0:
+		return "rg does not combine content conditions"
+	case o.statFiltered():
+		return "rg does not filter by size or modification time"
+	case o.Archives:
+		return "rg does not search archives"
+	case o.Files != nil:
+		return "rg does not take a list of files"
+	case o.Offset > 0:
+		return "rg does not page results"
+	case o.Sort != "" && o.Sort != SortPath && o.Sort != SortModified:
+		return fmt.Sprintf("rg does not sort by %s", o.Sort)
+	case o.Allow != nil:
+		// rg follows links to files, and with --follow to directories,
+		// without asking
+		return "rg cannot keep to the allowed directories"
+	}
+	for i, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err.Error()
+		}
+		for _, other := range dirs[:i] {
+			if o, err := filepath.Abs(other); err == nil && (within(abs, o) || within(o, abs)) {
+				return "rg would report files under overlapping directories twice"
+			}
+		}
+	}
+	return ""
+}
+
+// rgArgs returns the arguments that make rg search dirs as o describes.
+func rgArgs(o Options, dirs []string) []string {
+	args := []string{"--json", "--hidden", "--no-config"}
+	switch o.Case {
+	case IgnoreCase:
+		args = append(args, "--ignore-case")
+	case CaseSensitive:
+		args = append(args, "--case-sensitive")
+	default:
+		args = append(args, "--smart-case")
+	}
+	if !o.Regex {
+		args = append(args, "--fixed-strings")
+	}
+	if o.Word {
+		args = append(args, "--word-regexp")
+	}
+	if o.Before > 0 {
+		args = append(args, "--before-context", strconv.Itoa(o.Before))
+	}
+	if o.After > 0 {
+		args = append(args, "--after-context", strconv.Itoa(o.After))
+	}
+	if o.Binary {
+		args = append(args, "--text")
+	}
+	if o.NoIgnore {
+		args = append(args, "--no-ignore")
+	} else {
+		args = append(args, "--glob", "!.git")
+	}
+	if o.FollowSymlinks {
+		args = append(args, "--follow")
+	}
+	if o.MaxFileSize > 0 {
+		args = append(args, "--max-filesize", strconv.FormatInt(o.MaxFileSize, 10))
+	}
+	if enc, _ := ParseEncoding(o.Encoding); enc != "" {
+		args = append(args, "--encoding", enc)
+	}
+	if o.Jobs > 0 {
+		args = append(args, "--threads", strconv.Itoa(o.Jobs))
+	}
+	if o.Sort != "" {
+		key := map[string]string{SortPath: "path", SortModified: "modified"}[o.Sort]
+		if o.Reverse {
+			args = append(args, "--sortr", key)
+		} else {
+			args = append(args, "--sort", key)
+		}
+	}
+	types := FileTypes(o.TypeDefs)
+	for _, name := range o.Types {
+		for _, g := range types[name] {
+			args = append(args, "--glob", g)
+		}
+	}
+	for _, name := range o.TypesNot {
+		for _, g := range types[name] {
+			args = append(args, "--glob", "!"+g)
+		}
+	}
+	for _, ex := range o.Excludes {
+		args = append(args, "--glob", "!"+ex)
+	}
+	for _, term := range o.Contents {
+		args = append(args, "--regexp", term)
+	}
+	return append(append(args, "--"), dirs...)
+}
+
+// rgText is a string in rg's JSON output, which holds bytes that are not
+// valid UTF-8 in base64 instead.
+type rgText struct {
+	Text  *string `json:"text"`
+	Bytes string  `json:"bytes"`
+}
+
+func (t rgText) String() string {
+	if t.Text != nil {
+		return *t.Text
+	}
+	b, _ := base64.StdEncoding.DecodeString(t.Bytes)
+	return string(b)
+}
+
+// rgMessage is a line of rg --json output.
+type rgMessage struct {
+	Type string `json:"type"`
+	Data struct {
+		Path       rgText `json:"path"`
+		Lines      rgText `json:"lines"`
+		LineNumber int    `json:"line_number"`
+		Submatches []struct {
+			Match rgText `json:"match"`
+			Start int    `json:"start"`
+		} `json:"submatches"`
+		Stats struct {
+			Searches int `json:"searches"`
+		} `json:"stats"`
+	} `json:"data"`
+}
+
+// runRipgrep runs rg for the search and reports its matches to fn.
+func runRipgrep(ctx context.Context, rg string, opts Options, dirs []string, fn func(Match)) (sum Summary, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, rg, rgArgs(opts, dirs)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return sum, err
+	}
+	if err := cmd.Start(); err != nil {
+		return sum, fmt.Errorf("unable to run rg: %w", err)
+	}
+
+	results, lastPath, finished := 0, "", false
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64<<10), opts.maxLineSize()*4+64<<10)
+	for scanner.Scan() {
+		var msg rgMessage
+		if json.Unmarshal(scanner.Bytes(), &msg) != nil {
+			continue
+		}
+		d := msg.Data
+		switch msg.Type {
+		case "match", "context":
+			m := Match{Kind: ContextLine, Path: filepath.Clean(d.Path.String()), Line: d.LineNumber, Text: strings.TrimRight(d.Lines.String(), "\r\n")}
+			if msg.Type == "match" {
+				if opts.MaxResults > 0 && results == opts.MaxResults {
+					sum.Truncated = true
+					cancel()
+					break
+				}
+				results++
+				m.Kind = ContentMatch
+				if len(d.Submatches) > 0 {
+					m.Column, m.MatchedText = d.Submatches[0].Start+1, d.Submatches[0].Match.String()
+				}
+				if m.Path != lastPath {
+					sum.Files++
+					lastPath = m.Path
+				}
+			}
+			fn(m)
+		case "summary":
+			sum.Scanned, finished = d.Stats.Searches, true
+		}
+		if sum.Truncated {
+			break
+		}
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		// Stopped by us at MaxResults, or by the caller
+		sum.Cancelled = !sum.Truncated
+		return sum, nil
+	}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && (exit.ExitCode() == 1 || exit.ExitCode() == 2 && finished) {
+		// 1 means no matches, and 2 after a summary that some files could
+		// not be read, which the native engine passes over too
+		err = nil
+	}
+	if err != nil {
+		return sum, fmt.Errorf("rg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return sum, nil
+}
@@ -0,0 +1,916 @@
+// Package search implements file search by name pattern and content.
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"vscode-helper-file-find/internal/index"
+)
+
+// tracer records a span per search. It does nothing unless the program
+// installs an OpenTelemetry tracer provider, as the Go MCP server does.
+var tracer = otel.Tracer("vscode-helper-file-find/internal/search")
+
+// Options describes a search.
+type Options struct {
+	// Dir is the root directory to walk. Defaults to ".".
+	Dir string
+	// Names are glob patterns matched against file base names, or, when
+	// they contain a slash, against paths relative to Dir with '**'
+	// matching across directories. A leading '!' negates a pattern; see
+	// CompileNames.
+	Names []string
+	// Fuzzy treats Names as fuzzy queries instead of globs: a file matches
+	// if the characters of some query appear in its base name in order, and
+	// results are ranked by FuzzyScore, best first, instead of walk order.
+	Fuzzy bool
+	// Contents are the terms searched for inside files. A line matches if it
+	// contains any of them.
+	Contents []string
+	// AllContents only reports the lines of files that contain every one
+	// of Contents, each on some line, rather than any of them.
+	AllContents bool
+	// NotContents are terms that rule a file out: no result of any kind is
+	// reported for a file with a line containing one of them. Given
+	// without Names or Contents, every other file matches by name.
+	NotContents []string
+	// Regex treats Contents as regular expressions, each one an alternative
+	// of a single pattern.
+	Regex bool
+	// Word only counts content matches that are whole words: neither
+	// preceded nor followed by a letter, digit, or underscore.
+	Word bool
+	// Before and After are the number of context lines reported before and
+	// after each content match.
+	Before int
+	After  int
+	// Case selects case sensitivity for both name and content matching.
+	// The zero value is SmartCase.
+	Case CaseMode
+	// Jobs is the number of files scanned concurrently. Zero or less means
+	// runtime.NumCPU().
+	Jobs int
+	// Excludes are glob patterns for files and directories to skip. A
+	// pattern without a slash matches a base name at any depth; one with a
+	// slash is matched against the path relative to Dir. "**" matches across
+	// directories, and a pattern ending in "/**" prunes the whole subtree.
+	Excludes []string
+	// NoIgnore disables .gitignore, .ignore, and global git excludes. By
+	// default ignored files and directories, as well as .git itself, are
+	// skipped.
+	NoIgnore bool
+	// MaxFileSize, if positive, skips files larger than that many bytes
+	// when scanning content; Summary.LargeSkipped counts them.
+	MaxFileSize int64
+	// MaxLineSize is the longest line, in bytes, matched as a whole; longer
+	// ones, as in minified files, are matched in chunks of this size, so a
+	// match spanning two chunks is missed. Zero means DefaultMaxLineSize.
+	MaxLineSize int
+	// Archives also searches the entries of zip (and jar, war, ear), tar,
+	// and gzipped tar archives, reporting each as the archive's path and the
+	// entry's joined by ArchiveSeparator. Names are matched against an
+	// entry's path within its archive, except when Fuzzy. Archives nested
+	// in archives are searched too, to a limited depth.
+	Archives bool
+	// ArchiveBudget caps the bytes decompressed from one archive; the rest
+	// of it is left unsearched and counted in Summary.ArchivesLimited.
+	// Zero means DefaultArchiveBudget.
+	ArchiveBudget int64
+	// Encoding is the encoding of the files searched, as accepted by
+	// ParseEncoding. Empty detects that of each file: a byte order mark,
+	// UTF-16 without one, and otherwise UTF-8 or, where that is not valid,
+	// Windows-1252. Matches are reported in UTF-8 whatever the encoding.
+	Encoding string
+	// Binary also scans binary files for content. By default a file with a
+	// NUL byte in its first BinarySniffSize bytes is skipped.
+	Binary bool
+	// FollowSymlinks descends into symbolic links to directories, visiting
+	// each directory once. By default such links are skipped; links to
+	// files are always searched.
+	FollowSymlinks bool
+	// Types, if set, only searches files of these types, and TypesNot skips
+	// files of these types. A type names a set of glob patterns matched
+	// against base names; see FileTypes.
+	Types, TypesNot []string
+	// TypeDefs adds file types to the built-in ones, or redefines them.
+	TypeDefs map[string][]string
+	// MinSize and MaxSize, if positive, only search files of at least and
+	// at most that many bytes.
+	MinSize, MaxSize int64
+	// NewerThan and OlderThan, if not zero, only search files modified
+	// after and before that time. When these, the size limits, or the
+	// types are set without Names or Contents, every file that passes
+	// them matches by name.
+	NewerThan, OlderThan time.Time
+	// Offset skips the first Offset results and MaxResults, if positive,
+	// stops the search after that many. Name and content matches count as
+	// results; context lines do not.
+	Offset     int
+	MaxResults int
+	// Sort orders the results by file: SortPath, SortSize, and SortModified
+	// ascending, and SortScore best first. A file's own results stay in
+	// line order. Without it results come in walk order, which is lexical
+	// within each directory, or with Fuzzy by score.
+	Sort string
+	// Reverse reverses the order chosen by Sort.
+	Reverse bool
+	// Files, if not nil, limits the search to these files, given as
+	// absolute paths or relative to the working directory. Other files
+	// below Dir are passed over, and directories holding none of them are
+	// not walked.
+	Files []string
+	// Boost ranks files by weight, keyed by absolute path: unless Sort is
+	// set, the files with the greatest weights are reported first, and
+	// those without one after them in the usual order. As with Sort, no
+	// result is reported before the search ends, so it is best limited to
+	// files below Dir. SearchDirs ranks the files of each directory apart.
+	Boost map[string]float64
+	// NoIndex always walks the file system, even when a fresh index built by
+	// the index command covers Dir.
+	NoIndex bool
+	// Allow, if set, is asked about every symbolic link met and, when
+	// following links, every directory; entries it rejects are skipped.
+	// It keeps a search confined to a sandbox that links could escape.
+	Allow func(path string) bool `json:"-"`
+	// Progress, if set, is called with the totals so far about every
+	// progressInterval while the walk runs, from the goroutine calling fn.
+	Progress func(Progress) `json:"-"`
+
+	// prune lists absolute directories skipped because SearchDirs has
+	// already searched them.
+	prune []string
+}
+
+// cancelCheckLines is how many lines, or chunks of long ones, scanFile
+// reads between checks for cancellation.
+const cancelCheckLines = 4096
+
+// progressInterval is how often Options.Progress is called.
+const progressInterval = 250 * time.Millisecond
+
+// Progress reports how far a running search has got.
+type Progress struct {
+	// Files is the number of files checked so far.
+	Files int
+	// Matches is the number of results found so far, context lines aside.
+	Matches int
+}
+
+// BinarySniffSize is how much of a file is checked for NUL bytes when
+// deciding whether it is binary.
+const BinarySniffSize = 8 << 10
+
+// Summary reports totals for a completed search.
+type Summary struct {
+	// Files is the number of distinct files that matched.
+	Files int
+	// Scanned is the number of files checked, matching or not.
+	Scanned int
+	// BinarySkipped is the number of binary files not scanned for content.
+	BinarySkipped int
+	// LargeSkipped is the number of files over MaxFileSize not scanned for
+	// content.
+	LargeSkipped int
+	// ArchivesLimited is the number of archives only partly searched
+	// because they exceeded ArchiveBudget.
+	ArchivesLimited int
+	// Truncated is set when the search stopped at MaxResults and more
+	// results remain.
+	Truncated bool
+	// Cancelled is set when the context was done before the search
+	// finished; the results reported are then partial.
+	Cancelled bool
+	// Fallback says why an Engine other than Native handed the search to
+	// Native, if it did.
+	Fallback string
+}
+
+func (o Options) dir() string {
+	if o.Dir == "" {
+		return "."
+	}
+	return o.Dir
+}
+
+// Validate checks that the search directory exists and that the name
+// patterns and, in regex mode, the content terms are well formed.
+func (o Options) Validate() error {
+	if _, err := os.Stat(o.dir()); os.IsNotExist(err) {
+		return fmt.Errorf("directory '%s' does not exist", o.dir())
+	}
+	if o.Offset < 0 || o.MaxResults < 0 {
+		return fmt.Errorf("offset and result limit must not be negative")
+	}
+	if o.MaxFileSize < 0 || o.MaxLineSize < 0 || o.ArchiveBudget < 0 {
+		return fmt.Errorf("file, line, and archive size limits must not be negative")
+	}
+	if o.Sort != "" && !slices.Contains(sortKeys, o.Sort) {
+		return fmt.Errorf("unknown sort key '%s' (expected path, mtime, size, or score)", o.Sort)
+	}
+	if err := o.validateTypes(); err != nil {
+		return err
+	}
+	if _, err := ParseEncoding(o.Encoding); err != nil {
+		return err
+	}
+	if o.MinSize > 0 && o.MaxSize > 0 && o.MinSize > o.MaxSize {
+		return fmt.Errorf("minimum size %d is larger than maximum size %d", o.MinSize, o.MaxSize)
+	}
+	if !o.NewerThan.IsZero() && !o.OlderThan.IsZero() && !o.NewerThan.Before(o.OlderThan) {
+		return fmt.Errorf("no file can be newer than %s and older than %s", o.NewerThan.Format(time.RFC3339), o.OlderThan.Format(time.RFC3339))
+	}
+	for _, pattern := range o.Names {
+		if o.Fuzzy {
+			break
+		}
+		if _, err := CompileNames([]string{pattern}, o.Case); err != nil {
+			return fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+		}
+	}
+	if _, err := newContentQuery(o); err != nil {
+		return fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return nil
+}
+
+func (o Options) jobs() int {
+	if o.Jobs > 0 {
+		return o.Jobs
+	}
+	return runtime.NumCPU()
+}
+
+// MatchKind says why a file matched.
+type MatchKind int
+
+const (
+	// NameMatch means the file name matched one of the name patterns.
+	NameMatch MatchKind = iota
+	// ContentMatch means a line of the file matched a content term.
+	ContentMatch
+	// ContextLine is a non-matching line surrounding a content match,
+	// reported when Options.Before or Options.After are set.
+	ContextLine
+)
+
+// String returns "name", "content", or "context".
+func (k MatchKind) String() string {
+	switch k {
+	case ContentMatch:
+		return "content"
+	case ContextLine:
+		return "context"
+	default:
+		return "name"
+	}
+}
+
+// MarshalText encodes k as its String form so JSON output is readable.
+func (k MatchKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes the String form of a MatchKind.
+func (k *MatchKind) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "name":
+		*k = NameMatch
+	case "content":
+		*k = ContentMatch
+	case "context":
+		*k = ContextLine
+	default:
+		return fmt.Errorf("unknown match type %q", b)
+	}
+	return nil
+}
+
+// Match is a single search result.
+type Match struct {
+	Path string `json:"path"`
+	// Line is the 1-based line number of a content match.
+	Line int `json:"line,omitempty"`
+	// Column is the 1-based byte column where the first match on the line
+	// starts.
+	Column int `json:"column,omitempty"`
+	// MatchedText is the text that matched the content term.
+	MatchedText string    `json:"matched_text,omitempty"`
+	Kind        MatchKind `json:"match_type"`
+	// Text is the full matching line.
+	Text string `json:"text,omitempty"`
+	// Score ranks fuzzy name matches; higher is better.
+	Score int `json:"score,omitempty"`
+}
+
+// Format renders m as a line of text: the path for name matches,
+// path:line: text for content matches, and path-line- text for context
+// lines. With column set, content matches include the column as
+// path:line:column: text.
+func (m Match) Format(column bool) string {
+	switch {
+	case m.Kind == NameMatch:
+		return m.Path
+	case m.Kind == ContextLine:
+		return fmt.Sprintf("%s-%d- %s", m.Path, m.Line, m.Text)
+	case column:
+		return fmt.Sprintf("%s:%d:%d: %s", m.Path, m.Line, m.Column, m.Text)
+	default:
+		return fmt.Sprintf("%s:%d: %s", m.Path, m.Line, m.Text)
+	}
+}
+
+// NeedsSeparator reports whether a "--" group separator belongs between prev
+// and m when printing content matches with context: that is, when m starts
+// a new file or does not directly follow prev.
+func NeedsSeparator(prev, m Match) bool {
+	if prev.Kind == NameMatch || m.Kind == NameMatch {
+		return false
+	}
+	return prev.Path != m.Path || m.Line != prev.Line+1
+}
+
+// Search walks opts.Dir and calls fn for each match in walk order. Files are
+// scanned by opts.Jobs workers, but fn is always called from a single
+// goroutine and in the same order as a sequential walk. A file whose name
+// matches is reported once and not scanned for content. Search returns
+// totals for the files it visited.
+//
+// Unless opts.NoIndex is set, Search lists files from the on-disk index
+// covering opts.Dir when it is still fresh, and uses its trigram index, if
+// any, to skip files that cannot contain a literal content term.
+func Search(opts Options, fn func(Match)) (Summary, error) {
+	return SearchContext(context.Background(), opts, fn)
+}
+
+// SearchContext is like Search but stops walking and scanning soon after
+// ctx is done. The matches already reported stand, and the summary is
+// marked Cancelled rather than an error returned.
+func SearchContext(ctx context.Context, opts Options, fn func(Match)) (sum Summary, err error) {
+	if err := opts.Validate(); err != nil {
+		return sum, err
+	}
+	dir := opts.dir()
+	ctx, span := tracer.Start(ctx, "search", trace.WithAttributes(
+		attribute.String("search.directory", dir),
+		attribute.StringSlice("search.names", opts.Names),
+		attribute.Int("search.contents", len(opts.Contents)),
+		attribute.Bool("search.regex", opts.Regex),
+	))
+	defer span.End()
+
+	query, _ := newContentQuery(opts)
+	// Without names or content, the filters and NotContents alone select
+	// files, each reported by name
+	listAll := len(opts.Names) == 0 && query.match == nil && (opts.filtered() || query.not != nil)
+
+	var names *NameMatcher
+	if len(opts.Names) > 0 && !opts.Fuzzy {
+		names, _ = CompileNames(opts.Names, opts.Case)
+	}
+
+	walk := walker(filepath.WalkDir)
+	var mayContain func(path string) bool
+	if opts.FollowSymlinks {
+		// The index records links without following them
+		walk = walkFollow
+	} else if !opts.NoIndex {
+		if ix, err := index.Load(dir); err == nil && ix.Fresh(dir) {
+			walk = ix.Walk
+			span.SetAttributes(attribute.Bool("search.indexed", true))
+			if !opts.Regex {
+				mayContain = ix.Filter(dir, opts.Contents, opts.Case.fold(opts.Contents, false))
+			}
+		}
+	}
+
+	var binarySkipped, largeSkipped, archivesLimited atomic.Int64
+	check := func(path string) []Match {
+		// Check filename match if name patterns are provided
+		var named []Match
+		if opts.Fuzzy {
+			if score, ok := fuzzyBest(opts.Names, filepath.Base(path), opts.Case); ok {
+				named = []Match{{Kind: NameMatch, Path: path, Score: score}}
+			}
+		} else if names != nil && names.Match(relPath(dir, path)) || listAll {
+			named = []Match{{Kind: NameMatch, Path: path}}
+		}
+		if named != nil {
+			if query.not != nil {
+				o := opts
+				o.Before, o.After = 0, 0
+				if scanFile(ctx, path, contentQuery{not: query.not}, o).rejected {
+					return nil
+				}
+			}
+			return named
+		}
+		if opts.Archives {
+			if kind := archiveKind(path); kind != "" {
+				var inner *NameMatcher
+				if !opts.Fuzzy {
+					inner = names
+				}
+				matches, a := scanArchive(ctx, path, kind, query, inner, opts)
+				binarySkipped.Add(int64(a.binary))
+				largeSkipped.Add(int64(a.large))
+				if a.limited {
+					archivesLimited.Add(1)
+				}
+				return matches
+			}
+		}
+		// Check content match if content terms are provided
+		if query.match != nil && (mayContain == nil || mayContain(path)) {
+			res := scanFile(ctx, path, query, opts)
+			if res.binary {
+				binarySkipped.Add(1)
+			}
+			if res.large {
+				largeSkipped.Add(1)
+			}
+			return res.matches
+		}
+		return nil
+	}
+
+	excludes := compileExcludes(dir, opts.Excludes)
+	types := FileTypes(opts.TypeDefs)
+	isType, isTypeNot := typeMatcher(types, opts.Types), typeMatcher(types, opts.TypesNot)
+	var ig *ignorer
+	if !opts.NoIgnore {
+		ig = newIgnorer(dir)
+		ig.loadDir(dir)
+	}
+	only, onlyDirs := fileSet(opts.Files)
+	include := func(path string, d fs.DirEntry) bool {
+		if only != nil {
+			abs, err := filepath.Abs(path)
+			if err != nil || d.IsDir() && !onlyDirs[abs] || !d.IsDir() && !only[abs] {
+				return false
+			}
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			// Reached only when not following links: skip links to directories
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				return false
+			}
+		}
+		if opts.Allow != nil && (d.Type()&fs.ModeSymlink != 0 || d.IsDir() && opts.FollowSymlinks) && !opts.Allow(path) {
+			return false
+		}
+		if excludes.matches(path, d.IsDir()) {
+			return false
+		}
+		if d.IsDir() && len(opts.prune) > 0 {
+			if abs, err := filepath.Abs(path); err == nil && slices.Contains(opts.prune, abs) {
+				return false
+			}
+		}
+		if !d.IsDir() {
+			if len(opts.Types) > 0 && !isType(d.Name()) || isTypeNot(d.Name()) {
+				return false
+			}
+			if opts.statFiltered() && !opts.keepFile(path, d) {
+				return false
+			}
+		}
+		if ig == nil {
+			return true
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return false
+		}
+		if ig.ignored(path, d.IsDir()) {
+			return false
+		}
+		if d.IsDir() {
+			ig.loadDir(path)
+		}
+		return true
+	}
+
+	page := &pager{offset: opts.Offset, limit: opts.MaxResults, before: opts.Before, after: opts.After}
+	emit := func(matches []Match) bool {
+		emitted := false
+		for _, m := range matches {
+			out, stop := page.add(m)
+			if stop {
+				sum.Truncated = true
+				break
+			}
+			for _, m := range out {
+				emitted = true
+				fn(m)
+			}
+		}
+		if emitted {
+			sum.Files++
+		}
+		return !sum.Truncated
+	}
+
+	var progress Progress
+	lastProgress := time.Now()
+	tick := func(matches []Match) {
+		progress.Files++
+		for _, m := range matches {
+			if m.Kind != ContextLine {
+				progress.Matches++
+			}
+		}
+		if opts.Progress != nil && time.Since(lastProgress) >= progressInterval {
+			opts.Progress(progress)
+			lastProgress = time.Now()
+		}
+	}
+
+	if key := opts.sortKey(); key != "" {
+		// Sorting needs every result before the first can be emitted
+		var groups []fileResults
+		err = walkOrdered(ctx, walk, dir, opts.jobs(), include, check, func(matches []Match) bool {
+			tick(matches)
+			if len(matches) > 0 {
+				groups = append(groups, newFileResults(matches, key, opts.boosts()))
+			}
+			return true
+		})
+		sortResults(groups, key, opts.Reverse)
+		for _, g := range groups {
+			if !emit(g.matches) {
+				break
+			}
+		}
+	} else {
+		err = walkOrdered(ctx, walk, dir, opts.jobs(), include, check, func(matches []Match) bool {
+			tick(matches)
+			return emit(matches)
+		})
+	}
+	sum.Scanned = progress.Files
+	sum.BinarySkipped = int(binarySkipped.Load())
+	sum.LargeSkipped = int(largeSkipped.Load())
+	sum.ArchivesLimited = int(archivesLimited.Load())
+	sum.Cancelled = ctx.Err() != nil
+	span.SetAttributes(
+		attribute.Int("search.files_scanned", sum.Scanned),
+		attribute.Int("search.files_matched", sum.Files),
+		attribute.Int("search.matches", progress.Matches),
+		attribute.Bool("search.truncated", sum.Truncated),
+		attribute.Bool("search.cancelled", sum.Cancelled),
+	)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return sum, err
+}
+
+// SearchDirs is like SearchContext but searches each of dirs in turn, as
+// if each were opts.Dir. Every file is reported once even when the
+// directories overlap: one inside a directory already searched is skipped,
+// and one searched earlier is pruned from the walks of those after it.
+// MaxResults applies across all of them; Offset is only supported with a
+// single directory. The returned totals are those of all the searches.
+func SearchDirs(ctx context.Context, opts Options, dirs []string, fn func(Match)) (Summary, error) {
+	if len(dirs) <= 1 {
+		if len(dirs) == 1 {
+			opts.Dir = dirs[0]
+		}
+		return SearchContext(ctx, opts, fn)
+	}
+	if opts.Offset > 0 {
+		return Summary{}, fmt.Errorf("an offset cannot be combined with several directories")
+	}
+	var total Summary
+	results := 0
+	var searched []string
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return total, err
+		}
+		if slices.ContainsFunc(searched, func(s string) bool { return within(abs, s) }) {
+			continue
+		}
+		o := opts
+		o.Dir, o.prune = dir, searched
+		if opts.MaxResults > 0 {
+			o.MaxResults = opts.MaxResults - results
+		}
+		sum, err := SearchContext(ctx, o, func(m Match) {
+			if m.Kind != ContextLine {
+				results++
+			}
+			fn(m)
+		})
+		total.Files += sum.Files
+		total.Scanned += sum.Scanned
+		total.BinarySkipped += sum.BinarySkipped
+		total.LargeSkipped += sum.LargeSkipped
+		total.ArchivesLimited += sum.ArchivesLimited
+		total.Truncated = sum.Truncated
+		total.Cancelled = sum.Cancelled
+		if err != nil || sum.Truncated || sum.Cancelled {
+			return total, err
+		}
+		searched = append(searched, abs)
+	}
+	return total, nil
+}
+
+// fileSet returns the absolute paths of files, and those of the directories
+// above them, as sets; both are nil when files is.
+func fileSet(files []string) (only, dirs map[string]bool) {
+	if files == nil {
+		return nil, nil
+	}
+	only, dirs = map[string]bool{}, map[string]bool{}
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			continue
+		}
+		only[abs] = true
+		for dir := filepath.Dir(abs); !dirs[dir]; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	return only, dirs
+}
+
+// within reports whether the absolute path is dir or lies below it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// relPath returns path relative to dir with forward slashes, for matching
+// name patterns.
+func relPath(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// fuzzyBest returns the best FuzzyScore of base against any of queries.
+func fuzzyBest(queries []string, base string, mode CaseMode) (score int, ok bool) {
+	for _, q := range queries {
+		if s, matched := FuzzyScore(q, base, mode); matched && (!ok || s > score) {
+			score, ok = s, true
+		}
+	}
+	return score, ok
+}
+
+// pager applies Options.Offset and Options.MaxResults to the match stream,
+// keeping the context lines that belong to the results it lets through.
+type pager struct {
+	offset, limit int
+	before, after int
+
+	seen      int     // results seen so far, emitted or not
+	pending   []Match // context lines held back while skipping
+	afterLeft int     // trailing context lines owed to the last result
+}
+
+// add returns the matches to emit for m, if any, and reports whether m is
+// a result past the limit, meaning the search should stop.
+func (p *pager) add(m Match) (out []Match, stop bool) {
+	if m.Kind == ContextLine {
+		switch {
+		case p.seen < p.offset:
+			p.pending = append(p.pending, m)
+		case p.limit <= 0 || p.seen < p.offset+p.limit:
+			out = []Match{m}
+		case p.afterLeft > 0:
+			p.afterLeft--
+			out = []Match{m}
+		}
+		return out, false
+	}
+
+	p.seen++
+	switch {
+	case p.seen <= p.offset:
+		p.pending = p.pending[:0]
+		return nil, false
+	case p.limit > 0 && p.seen > p.offset+p.limit:
+		return nil, true
+	}
+	if p.seen == p.offset+1 {
+		// The first result after the offset keeps its leading context
+		for _, c := range p.pending {
+			if c.Path == m.Path && c.Line >= m.Line-p.before {
+				out = append(out, c)
+			}
+		}
+		p.pending = nil
+	}
+	p.afterLeft = p.after
+	return append(out, m), false
+}
+
+// scanResult is what scanFile found in a file.
+type scanResult struct {
+	matches []Match
+	// binary and large are set for files skipped as binary or as larger
+	// than Options.MaxFileSize
+	binary, large bool
+	// rejected is set when q rules the file out
+	rejected bool
+}
+
+// scanFile returns the content matches of q in the file at path, along
+// with up to opts.Before and opts.After context lines around each.
+// Overlapping context is reported once. A file is rejected, with no
+// matches, when a line matches q.not or, with q.all, when some term matches
+// no line. Files that cannot be opened are skipped, as are binary files
+// unless opts.Binary is set and files over opts.MaxFileSize. Files in
+// opts.Encoding, or in the encoding detected, are read as UTF-8, so
+// columns count bytes of the transcoded line. A large file
+// is memory-mapped where the platform allows, and dismissed without
+// splitting it into lines when q.prefilter finds no term in it. A line longer
+// than opts.MaxLineSize is matched in chunks of that size, reporting the
+// chunk holding its first match as its text. A long file is abandoned
+// partway once ctx is done.
+func scanFile(ctx context.Context, path string, q contentQuery, opts Options) (res scanResult) {
+	file, err := os.Open(path)
+	if err != nil {
+		return res
+	}
+	defer file.Close()
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	if opts.MaxFileSize > 0 && size > opts.MaxFileSize {
+		res.large = true
+		return res
+	}
+
+	if data, unmap, ok := mapThreshold(file, size); ok {
+		defer unmap()
+		return scanText(ctx, path, nil, data, q, opts)
+	}
+	return scanText(ctx, path, file, nil, q, opts)
+}
+
+// scanText is scanFile for content read from src or, if src is nil, held
+// in data; path is only reported in the matches.
+func scanText(ctx context.Context, path string, src io.Reader, data []byte, q contentQuery, opts Options) (res scanResult) {
+	var r io.Reader
+	var head []byte
+	var br *bufio.Reader
+	if src == nil {
+		head = data[:min(len(data), BinarySniffSize)]
+	} else {
+		br = bufio.NewReaderSize(src, BinarySniffSize)
+		head, _ = br.Peek(BinarySniffSize)
+	}
+	given, _ := ParseEncoding(opts.Encoding)
+	enc, bom := detectEncoding(head, given)
+	wide := enc == EncodingUTF16LE || enc == EncodingUTF16BE
+	if !opts.Binary && !wide && bytes.IndexByte(head, 0) >= 0 {
+		res.binary = true
+		return res
+	}
+	if src == nil {
+		r = bytes.NewReader(data[bom:])
+	} else {
+		br.Discard(bom)
+		r = br
+	}
+	r = decodeReader(r, enc)
+	if src == nil && enc == EncodingUTF8 && q.prefilter != nil && !q.prefilter(data) {
+		// No line can match, so the file has no matches whatever else q
+		// says about it
+		return res
+	}
+
+	before, after := opts.Before, opts.After
+	var matches, pending []Match
+	afterLeft := 0
+	missing := len(q.all)
+	found := make([]bool, len(q.all))
+	scanner, partial := newLineScanner(r, opts.maxLineSize())
+	lineNum, offset := 1, 0 // offset is where the chunk starts in a long line
+	lineMatched := false
+	for tokens := 1; scanner.Scan(); tokens++ {
+		if tokens%cancelCheckLines == 0 && ctx.Err() != nil {
+			break
+		}
+		line := scanner.Text()
+		if q.not != nil {
+			if start, _ := q.not(line); start >= 0 {
+				res.rejected = true
+				return res
+			}
+		}
+		for i, m := range q.all {
+			if !found[i] {
+				if start, _ := m(line); start >= 0 {
+					found[i] = true
+					missing--
+				}
+			}
+		}
+		cont := offset > 0
+		if q.match != nil && !lineMatched {
+			if start, end := q.match(line); start >= 0 {
+				matches = append(matches, pending...)
+				pending = pending[:0]
+				matches = append(matches, Match{Kind: ContentMatch, Path: path, Line: lineNum, Column: offset + start + 1, MatchedText: line[start:end], Text: line})
+				afterLeft = after
+				lineMatched = true
+			} else if cont {
+				// Context shows the first chunk of a long line only
+			} else if afterLeft > 0 {
+				matches = append(matches, Match{Kind: ContextLine, Path: path, Line: lineNum, Text: line})
+				afterLeft--
+			} else if before > 0 {
+				// Keep the last `before` lines in case the next line matches
+				if len(pending) == before {
+					pending = append(pending[:0], pending[1:]...)
+				}
+				pending = append(pending, Match{Kind: ContextLine, Path: path, Line: lineNum, Text: line})
+			}
+		}
+		if *partial {
+			offset += len(line)
+		} else {
+			lineNum, offset, lineMatched = lineNum+1, 0, false
+		}
+	}
+	if missing > 0 {
+		res.rejected = true
+		return res
+	}
+	res.matches = matches
+	return res
+}
+
+// mmapMinSize is the size from which scanFile maps a file into memory
+// rather than reading it, where the platform allows. Below it the cost of
+// mapping outweighs that of the reads it saves.
+const mmapMinSize = 1 << 20
+
+// mapThreshold maps file into memory if it is at least mmapMinSize bytes.
+func mapThreshold(file *os.File, size int64) ([]byte, func(), bool) {
+	if size < mmapMinSize {
+		return nil, nil, false
+	}
+	return mapFile(file, size)
+}
+
+// DefaultMaxLineSize is the longest line matched whole when
+// Options.MaxLineSize is not set.
+const DefaultMaxLineSize = 1 << 20
+
+func (o Options) maxLineSize() int {
+	if o.MaxLineSize > 0 {
+		return o.MaxLineSize
+	}
+	return DefaultMaxLineSize
+}
+
+// newLineScanner returns a scanner over the lines of r, without their line
+// endings, that returns a line longer than max in chunks of max bytes
+// instead of failing. After each Scan, *partial reports whether the token
+// was such a chunk, to be continued by the next one.
+func newLineScanner(r io.Reader, max int) (*bufio.Scanner, *bool) {
+	partial := new(bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, min(max, 64<<10)), max)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		*partial = false
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			return i + 1, bytes.TrimSuffix(data[:i], []byte("\r")), nil
+		}
+		if len(data) >= max {
+			*partial = true
+			return max, data[:max], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), bytes.TrimSuffix(data, []byte("\r")), nil
+		}
+		return 0, nil, nil
+	})
+	return scanner, partial
+}
@@ -0,0 +1,104 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Keys for Options.Sort.
+const (
+	SortPath     = "path"
+	SortModified = "mtime"
+	SortSize     = "size"
+	SortScore    = "score"
+)
+
+var sortKeys = []string{SortPath, SortModified, SortSize, SortScore}
+
+// sortBoost is the key results are sorted by to bring the files in
+// Options.Boost forward, with the rest left in walk order.
+const sortBoost = "boost"
+
+// sortKey returns the key results are sorted by, if any: Sort, the score
+// when ranking fuzzy matches, or sortBoost when boosting files.
+func (o Options) sortKey() string {
+	switch {
+	case o.Sort == "" && o.Fuzzy:
+		return SortScore
+	case o.Sort == "" && len(o.Boost) > 0:
+		return sortBoost
+	}
+	return o.Sort
+}
+
+// boosts returns the weights files are ranked by ahead of the sort key:
+// Boost, unless an explicit Sort overrides it.
+func (o Options) boosts() map[string]float64 {
+	if o.Sort != "" {
+		return nil
+	}
+	return o.Boost
+}
+
+// fileResults are the results of one file, with what sorting needs to know
+// about it.
+type fileResults struct {
+	matches []Match
+	size    int64
+	modTime time.Time
+	boost   float64
+}
+
+// newFileResults groups matches, statting their file when key requires
+// and looking it up in boost, keyed by absolute path.
+func newFileResults(matches []Match, key string, boost map[string]float64) fileResults {
+	g := fileResults{matches: matches}
+	if len(boost) > 0 {
+		// An archive's entries share its weight
+		path, _, _ := strings.Cut(matches[0].Path, ArchiveSeparator)
+		if abs, err := filepath.Abs(path); err == nil {
+			g.boost = boost[abs]
+		}
+	}
+	if key == SortSize || key == SortModified {
+		if info, err := os.Stat(matches[0].Path); err == nil {
+			g.size, g.modTime = info.Size(), info.ModTime()
+		}
+	}
+	return g
+}
+
+// sortResults sorts groups by key, keeping walk order among equals. Groups
+// with a greater boost come first whatever the key and direction.
+func sortResults(groups []fileResults, key string, reverse bool) {
+	less := func(a, b fileResults) bool {
+		switch key {
+		case sortBoost:
+			return false
+		case SortPath:
+			return a.matches[0].Path < b.matches[0].Path
+		case SortSize:
+			return a.size < b.size
+		case SortModified:
+			return a.modTime.Before(b.modTime)
+		}
+		x, y := a.matches[0], b.matches[0]
+		if x.Score != y.Score {
+			return x.Score > y.Score
+		}
+		// Among equal scores prefer shorter names, as fzf does
+		return len(filepath.Base(x.Path)) < len(filepath.Base(y.Path))
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].boost != groups[j].boost {
+			return groups[i].boost > groups[j].boost
+		}
+		if reverse {
+			return less(groups[j], groups[i])
+		}
+		return less(groups[i], groups[j])
+	})
+}
@@ -0,0 +1,92 @@
+package search
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// defaultTypes maps file type names, as given to Options.Types, to glob
+// patterns matched against base names. Short and long names are both
+// offered where people use both (py and python).
+var defaultTypes = map[string][]string{
+	"c":          {"*.c", "*.h"},
+	"cpp":        {"*.cc", "*.cpp", "*.cxx", "*.hh", "*.hpp", "*.hxx", "*.h"},
+	"cs":         {"*.cs", "*.csx"},
+	"css":        {"*.css", "*.scss", "*.sass", "*.less"},
+	"docker":     {"Dockerfile", "Dockerfile.*", "*.dockerfile", ".dockerignore"},
+	"go":         {"*.go"},
+	"gomod":      {"go.mod", "go.sum", "go.work"},
+	"html":       {"*.html", "*.htm"},
+	"java":       {"*.java"},
+	"js":         {"*.js", "*.mjs", "*.cjs", "*.jsx"},
+	"json":       {"*.json", "*.jsonc"},
+	"kotlin":     {"*.kt", "*.kts"},
+	"lua":        {"*.lua"},
+	"make":       {"Makefile", "GNUmakefile", "makefile", "*.mk"},
+	"md":         {"*.md", "*.markdown"},
+	"php":        {"*.php"},
+	"proto":      {"*.proto"},
+	"py":         {"*.py", "*.pyi"},
+	"python":     {"*.py", "*.pyi"},
+	"rb":         {"*.rb", "Gemfile", "Rakefile"},
+	"ruby":       {"*.rb", "Gemfile", "Rakefile"},
+	"rust":       {"*.rs"},
+	"sh":         {"*.sh", "*.bash", "*.zsh", ".bashrc", ".zshrc"},
+	"sql":        {"*.sql"},
+	"swift":      {"*.swift"},
+	"terraform":  {"*.tf", "*.tfvars"},
+	"toml":       {"*.toml"},
+	"ts":         {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"txt":        {"*.txt"},
+	"typescript": {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"xml":        {"*.xml"},
+	"yaml":       {"*.yaml", "*.yml"},
+}
+
+// FileTypes returns the built-in file types with those in defs added,
+// replacing any built-in type of the same name.
+func FileTypes(defs map[string][]string) map[string][]string {
+	types := make(map[string][]string, len(defaultTypes)+len(defs))
+	for name, globs := range defaultTypes {
+		types[name] = globs
+	}
+	for name, globs := range defs {
+		types[name] = globs
+	}
+	return types
+}
+
+// validateTypes checks that every type in Types and TypesNot is defined
+// and that the patterns of those given are well formed.
+func (o Options) validateTypes() error {
+	types := FileTypes(o.TypeDefs)
+	for _, name := range append(append([]string(nil), o.Types...), o.TypesNot...) {
+		globs, ok := types[name]
+		if !ok {
+			return fmt.Errorf("unknown file type '%s' (see search --type-list)", name)
+		}
+		for _, g := range globs {
+			if _, err := filepath.Match(g, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q for file type '%s': %w", g, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// typeMatcher returns a function reporting whether a base name is of one
+// of the named types.
+func typeMatcher(types map[string][]string, names []string) func(base string) bool {
+	var globs []string
+	for _, name := range names {
+		globs = append(globs, types[name]...)
+	}
+	return func(base string) bool {
+		for _, g := range globs {
+			if ok, _ := filepath.Match(g, base); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
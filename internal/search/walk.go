@@ -0,0 +1,156 @@
+package search
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileJob is a file queued for checking. The result is delivered on done so
+// that results can be consumed in walk order regardless of which worker
+// finishes first.
+type fileJob struct {
+	path string
+	done chan []Match
+}
+
+// walker visits root and every entry below it in lexical order, with the
+// semantics of filepath.WalkDir.
+type walker func(root string, fn fs.WalkDirFunc) error
+
+// walkOrdered walks root with walk and runs check on every regular file using jobs
+// workers. emit is called from the calling goroutine with each file's
+// result, in the order the walk visited the files; returning false stops
+// the walk. If include is non-nil it is consulted for every entry below
+// root; returning false skips a file or prunes a directory. Walk errors stop
+// the walk; results for files already queued are still emitted. Once ctx is
+// done the walk stops and queued files are no longer checked.
+func walkOrdered(ctx context.Context, walk walker, root string, jobs int, include func(path string, d fs.DirEntry) bool, check func(path string) []Match, emit func([]Match) bool) error {
+	work := make(chan fileJob)
+	// order bounds how far the walk may run ahead of emission
+	order := make(chan fileJob, jobs*4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range work {
+				if ctx.Err() != nil {
+					job.done <- nil
+					continue
+				}
+				job.done <- check(job.path)
+			}
+		}()
+	}
+
+	var walkErr error
+	stop := make(chan struct{})
+	go func() {
+		defer close(order)
+		defer close(work)
+		walkErr = walk(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			select {
+			case <-stop:
+				return filepath.SkipAll
+			case <-ctx.Done():
+				return filepath.SkipAll
+			default:
+			}
+			if include != nil && path != root && !include(path, d) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// Skip directories
+			if d.IsDir() {
+				return nil
+			}
+			job := fileJob{path: path, done: make(chan []Match, 1)}
+			order <- job
+			work <- job
+			return nil
+		})
+	}()
+
+	stopped := false
+	for job := range order {
+		matches := <-job.done
+		if !stopped && !emit(matches) {
+			// Keep draining so the walk and workers can finish
+			stopped = true
+			close(stop)
+		}
+	}
+	wg.Wait()
+	return walkErr
+}
+
+// walkFollow is like filepath.WalkDir but also descends into symbolic links
+// to directories, reporting them as directories under the link's path. Each
+// directory is visited at most once, identified by its device and inode, so
+// symlink cycles and links to directories already walked are skipped.
+func walkFollow(root string, fn fs.WalkDirFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	visited := make(map[fileID]bool)
+	if id, ok := fileIdentity(root, info); ok {
+		visited[id] = true
+	}
+	err = walkFollowDir(root, fs.FileInfoToDirEntry(info), visited, fn)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkFollowDir(path string, d fs.DirEntry, visited map[fileID]bool, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		// Report the error and let fn decide whether to carry on
+		if err = fn(path, d, err); err != nil {
+			if err == filepath.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, e := range entries {
+		p := filepath.Join(path, e.Name())
+		if e.IsDir() || e.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(p)
+			if err == nil && info.IsDir() {
+				if id, ok := fileIdentity(p, info); ok {
+					if visited[id] {
+						continue
+					}
+					visited[id] = true
+				}
+				e = fs.FileInfoToDirEntry(info)
+			}
+		}
+		if err := walkFollowDir(p, e, visited, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
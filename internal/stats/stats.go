@@ -0,0 +1,252 @@
+// Package stats summarizes the files of a project: how many there are of
+// each language, how many lines they hold, and which are the largest and
+// the most recently changed.
+package stats
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vscode-helper-file-find/internal/files"
+	"vscode-helper-file-find/internal/search"
+)
+
+// DefaultTop is how many of the largest and newest files Collect lists
+// when Options.Top is not set.
+const DefaultTop = 10
+
+// Binary is the language under which files that are not text are counted.
+const Binary = "binary"
+
+// Options controls what Collect counts.
+type Options struct {
+	// Search selects the files: those it would report by name, every file
+	// by default.
+	Search search.Options
+	// Top is how many of the largest and newest files to list. Zero means
+	// DefaultTop.
+	Top int
+}
+
+// Language totals the files of one language.
+type Language struct {
+	// Name is the VS Code language identifier, the extension (as ".ext")
+	// when the language is not known, Binary, or "" for files with
+	// neither.
+	Name string `json:"name"`
+	// Extensions are those of the files counted, in order.
+	Extensions []string `json:"extensions,omitempty"`
+	Files      int      `json:"files"`
+	Lines      int      `json:"lines"`
+	Bytes      int64    `json:"bytes"`
+}
+
+// File is a file listed among the largest or newest.
+type File struct {
+	// Path is relative to the directory counted, using '/' separators.
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Lines   int       `json:"lines"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// Stats are the totals for a directory.
+type Stats struct {
+	Dir   string `json:"dir"`
+	Files int    `json:"files"`
+	Lines int    `json:"lines"`
+	Bytes int64  `json:"bytes"`
+	// Languages are ordered by lines, then files, most first.
+	Languages []Language `json:"languages"`
+	// Largest are the largest files, largest first.
+	Largest []File `json:"largest"`
+	// Newest are the most recently modified files, newest first.
+	Newest []File `json:"newest"`
+}
+
+// Collect counts the files in opts.Search.Dir that opts selects. It stops
+// early, returning the error of ctx, once ctx is done.
+func Collect(ctx context.Context, opts Options) (*Stats, error) {
+	so := opts.Search
+	if len(so.Names) == 0 {
+		so.Names = []string{"*"}
+	}
+	so.Contents, so.Fuzzy, so.Sort, so.Boost, so.Offset, so.MaxResults = nil, false, "", nil, 0, 0
+	dir := so.Dir
+	if dir == "" {
+		dir = "."
+	}
+	top := opts.Top
+	if top <= 0 {
+		top = DefaultTop
+	}
+
+	paths := make(chan string)
+	var (
+		mu    sync.Mutex
+		found []fileInfo
+		wg    sync.WaitGroup
+	)
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if fi, ok := inspect(path); ok {
+					mu.Lock()
+					found = append(found, fi)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	sum, err := search.SearchContext(ctx, so, func(m search.Match) {
+		if m.Kind == search.NameMatch {
+			paths <- m.Path
+		}
+	})
+	close(paths)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if sum.Cancelled {
+		return nil, ctx.Err()
+	}
+
+	s := &Stats{Dir: dir, Languages: []Language{}, Largest: []File{}, Newest: []File{}}
+	langs := map[string]*Language{}
+	for i := range found {
+		fi := &found[i]
+		if rel, err := filepath.Rel(dir, fi.Path); err == nil {
+			fi.Path = filepath.ToSlash(rel)
+		}
+		s.Files++
+		s.Lines += fi.Lines
+		s.Bytes += fi.Size
+		l := langs[fi.language]
+		if l == nil {
+			l = &Language{Name: fi.language}
+			langs[fi.language] = l
+		}
+		l.Files++
+		l.Lines += fi.Lines
+		l.Bytes += fi.Size
+		if ext := strings.ToLower(filepath.Ext(fi.Path)); ext != "" && !slices.Contains(l.Extensions, ext) {
+			l.Extensions = append(l.Extensions, ext)
+		}
+	}
+	for _, l := range langs {
+		sort.Strings(l.Extensions)
+		s.Languages = append(s.Languages, *l)
+	}
+	sort.Slice(s.Languages, func(i, j int) bool {
+		a, b := s.Languages[i], s.Languages[j]
+		if a.Lines != b.Lines {
+			return a.Lines > b.Lines
+		}
+		if a.Files != b.Files {
+			return a.Files > b.Files
+		}
+		return a.Name < b.Name
+	})
+
+	// Ties are broken by path, so that the lists do not depend on the order
+	// files were read in
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Size != found[j].Size {
+			return found[i].Size > found[j].Size
+		}
+		return found[i].Path < found[j].Path
+	})
+	for _, fi := range found[:min(top, len(found))] {
+		s.Largest = append(s.Largest, fi.File)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].ModTime.Equal(found[j].ModTime) {
+			return found[i].ModTime.After(found[j].ModTime)
+		}
+		return found[i].Path < found[j].Path
+	})
+	for _, fi := range found[:min(top, len(found))] {
+		s.Newest = append(s.Newest, fi.File)
+	}
+	return s, nil
+}
+
+// fileInfo is a File with what it is counted under.
+type fileInfo struct {
+	File
+	language string
+}
+
+// inspect stats and reads the file at path, counting its lines unless it
+// is binary. It reports false for files that cannot be read.
+func inspect(path string) (fileInfo, bool) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return fileInfo{}, false
+	}
+	fi := fileInfo{File: File{Path: path, Size: info.Size(), ModTime: info.ModTime()}}
+	lines, binary, err := countLines(path)
+	if err != nil {
+		return fileInfo{}, false
+	}
+	switch lang := files.DetectLanguage(path); {
+	case binary:
+		fi.language = Binary
+	case lang != "":
+		fi.language = lang
+	default:
+		fi.language = strings.ToLower(filepath.Ext(path))
+	}
+	if !binary {
+		fi.Lines = lines
+	}
+	return fi, true
+}
+
+// countLines counts the lines of the file at path, a last one without a
+// newline included, and reports whether it is binary: whether it has a NUL
+// byte in its first search.BinarySniffSize bytes, which is all that is
+// read of such a file.
+func countLines(path string) (int, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+
+	lines, read, last := 0, 0, byte('\n')
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if read < search.BinarySniffSize && bytes.IndexByte(buf[:min(n, search.BinarySniffSize-read)], 0) >= 0 {
+				return 0, true, nil
+			}
+			read += n
+			lines += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, false, err
+		}
+	}
+	if last != '\n' {
+		lines++
+	}
+	return lines, false, nil
+}
@@ -0,0 +1,95 @@
+package symbols
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+func init() {
+	Register("go", []string{"*.go"}, goParser{})
+}
+
+// goParser finds the functions, methods, types, constants, and variables
+// declared at the top level of Go files, and the methods of the interfaces
+// among those types.
+type goParser struct{}
+
+func (goParser) Parse(path string, src []byte) ([]Symbol, error) {
+	fset := token.NewFileSet()
+	// A file with errors still yields the declarations parsed before them
+	f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if f == nil {
+		return nil, err
+	}
+	var syms []Symbol
+	add := func(id *ast.Ident, kind, recv string) {
+		pos := fset.Position(id.Pos())
+		start := pos.Offset - (pos.Column - 1)
+		end := bytes.IndexByte(src[start:], '\n')
+		if end < 0 {
+			end = len(src) - start
+		}
+		syms = append(syms, Symbol{Name: id.Name, Kind: kind, Receiver: recv, Line: pos.Line, Column: pos.Column, Text: strings.TrimSpace(string(src[start : start+end]))})
+	}
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				add(d.Name, Method, receiverName(d.Recv.List[0].Type))
+			} else {
+				add(d.Name, Function, "")
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					add(spec.Name, Type, "")
+					if it, ok := spec.Type.(*ast.InterfaceType); ok {
+						for _, m := range it.Methods.List {
+							if _, ok := m.Type.(*ast.FuncType); ok {
+								for _, n := range m.Names {
+									add(n, Method, spec.Name.Name)
+								}
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					kind := Var
+					if d.Tok == token.CONST {
+						kind = Const
+					}
+					for _, n := range spec.Names {
+						if n.Name != "_" {
+							add(n, kind, "")
+						}
+					}
+				}
+			}
+		}
+	}
+	return syms, err
+}
+
+// receiverName returns the name of the type of a method receiver, without
+// a pointer or type parameters.
+func receiverName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
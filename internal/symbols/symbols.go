@@ -0,0 +1,231 @@
+// Package symbols finds where functions, methods, and types are defined by
+// parsing source files, so that a definition is told apart from the places
+// its name is merely used. Go is parsed with go/ast; parsers for other
+// languages are added with Register.
+package symbols
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"vscode-helper-file-find/internal/search"
+)
+
+// Kinds of Symbol.
+const (
+	Function = "function"
+	Method   = "method"
+	Type     = "type"
+	Const    = "const"
+	Var      = "var"
+)
+
+// Symbol is a definition found in a file.
+type Symbol struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Receiver is the type a method belongs to, without any pointer.
+	Receiver string `json:"receiver,omitempty"`
+	// Path is the file as search reports it.
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Language string `json:"language"`
+	// Text is the line the definition starts on, trimmed.
+	Text string `json:"text"`
+}
+
+// A Parser finds the definitions in the source of one language.
+type Parser interface {
+	// Parse returns the symbols defined in src, the contents of the file at
+	// path; Path and Language need not be set.
+	Parse(path string, src []byte) ([]Symbol, error)
+}
+
+// language is a registered Parser and the files it reads.
+type language struct {
+	name   string
+	globs  []string
+	parser Parser
+}
+
+var languages []language
+
+// Register makes p the parser of the files whose base names match one of
+// globs, under the language identifier name. It is meant to be called
+// from init functions.
+func Register(name string, globs []string, p Parser) {
+	languages = append(languages, language{name: name, globs: globs, parser: p})
+}
+
+// Options controls what Find looks for.
+type Options struct {
+	// Search selects the files: those it would report by name. Names
+	// default to the files of every language registered.
+	Search search.Options
+	// Name is matched against the names defined: exactly, as a glob when
+	// it holds *, ?, or [, and as Receiver.Name for a method of a type.
+	Name string
+	// IgnoreCase matches Name regardless of case.
+	IgnoreCase bool
+	// Kinds, if not empty, limits the symbols to these kinds.
+	Kinds []string
+	// Limit, if positive, caps the symbols returned.
+	Limit int
+}
+
+// Result is what Find found.
+type Result struct {
+	// Symbols are ordered by path, then line.
+	Symbols []Symbol `json:"symbols"`
+	// Truncated is set when Options.Limit left symbols out.
+	Truncated bool `json:"truncated,omitempty"`
+	// Failed counts the files that could not be parsed.
+	Failed int `json:"failed,omitempty"`
+}
+
+// Find returns the definitions matching opts in the files of
+// opts.Search.Dir that it selects.
+func Find(ctx context.Context, opts Options) (*Result, error) {
+	name := strings.TrimSpace(opts.Name)
+	if name == "" {
+		return nil, errors.New("a symbol name is required")
+	}
+	for _, k := range opts.Kinds {
+		if !slices.Contains([]string{Function, Method, Type, Const, Var}, k) {
+			return nil, fmt.Errorf("unknown symbol kind '%s' (expected function, method, type, const, or var)", k)
+		}
+	}
+	receiver, base := "", name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		receiver, base = name[:i], name[i+1:]
+	}
+	if opts.IgnoreCase {
+		receiver, base = strings.ToLower(receiver), strings.ToLower(base)
+	}
+	if _, err := path.Match(base, ""); err != nil {
+		return nil, fmt.Errorf("invalid name pattern '%s': %w", name, err)
+	}
+	// Files not holding an exact name need not be parsed at all
+	var literal []byte
+	if !strings.ContainsAny(base, "*?[") {
+		literal = []byte(base)
+	}
+
+	so := opts.Search
+	if len(so.Names) == 0 {
+		for _, l := range languages {
+			so.Names = append(so.Names, l.globs...)
+		}
+	}
+	so.Contents, so.Fuzzy, so.Sort, so.Boost, so.Offset, so.MaxResults = nil, false, "", nil, 0, 0
+
+	paths := make(chan string)
+	var (
+		mu  sync.Mutex
+		res = &Result{Symbols: []Symbol{}}
+		wg  sync.WaitGroup
+	)
+	matches := func(s Symbol) bool {
+		if len(opts.Kinds) > 0 && !slices.Contains(opts.Kinds, s.Kind) {
+			return false
+		}
+		n, r := s.Name, s.Receiver
+		if opts.IgnoreCase {
+			n, r = strings.ToLower(n), strings.ToLower(r)
+		}
+		if ok, _ := path.Match(base, n); !ok {
+			return false
+		}
+		if receiver != "" {
+			ok, _ := path.Match(receiver, r)
+			return ok
+		}
+		return true
+	}
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range paths {
+				l := languageOf(p)
+				if l == nil {
+					continue
+				}
+				src, err := os.ReadFile(p)
+				if err != nil {
+					continue
+				}
+				if literal != nil {
+					hay := src
+					if opts.IgnoreCase {
+						hay = bytes.ToLower(src)
+					}
+					if !bytes.Contains(hay, literal) {
+						continue
+					}
+				}
+				syms, err := l.parser.Parse(p, src)
+				mu.Lock()
+				if err != nil {
+					res.Failed++
+				}
+				for _, s := range syms {
+					if matches(s) {
+						s.Path, s.Language = p, l.name
+						res.Symbols = append(res.Symbols, s)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	sum, err := search.SearchContext(ctx, so, func(m search.Match) {
+		if m.Kind == search.NameMatch {
+			paths <- m.Path
+		}
+	})
+	close(paths)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if sum.Cancelled {
+		return nil, ctx.Err()
+	}
+
+	sort.Slice(res.Symbols, func(i, j int) bool {
+		a, b := res.Symbols[i], res.Symbols[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Line < b.Line
+	})
+	if opts.Limit > 0 && len(res.Symbols) > opts.Limit {
+		res.Symbols, res.Truncated = res.Symbols[:opts.Limit], true
+	}
+	return res, nil
+}
+
+// languageOf returns the registered language of the file at p, or nil.
+func languageOf(p string) *language {
+	base := filepath.Base(p)
+	for i, l := range languages {
+		for _, g := range l.globs {
+			if ok, _ := path.Match(g, base); ok {
+				return &languages[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Package todos finds the TODO, FIXME, and HACK markers, or others, left in
+// source files, optionally with who wrote each line as git blame has it.
+package todos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"vscode-helper-file-find/internal/git"
+	"vscode-helper-file-find/internal/search"
+)
+
+// DefaultTags are the markers Scan looks for when Options.Tags is empty.
+var DefaultTags = []string{"TODO", "FIXME", "HACK"}
+
+// Options controls what Scan looks for.
+type Options struct {
+	// Search selects the files: those it would search by content. Its
+	// MaxResults caps the items found.
+	Search search.Options
+	// Tags are the markers, matched as whole words and case-sensitively.
+	// Empty means DefaultTags.
+	Tags []string
+	// Blame sets the Author of each item from git blame.
+	Blame bool
+}
+
+// Item is a marker found in a file.
+type Item struct {
+	// Path is the file as search reports it.
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Tag    string `json:"tag"`
+	// Owner is the name in a marker written as TAG(name).
+	Owner string `json:"owner,omitempty"`
+	// Text is what follows the marker, up to the end of the line.
+	Text string `json:"text"`
+	// Author is who last changed the line, as git blame has it, when asked
+	// for and known.
+	Author string `json:"author,omitempty"`
+}
+
+// Result is what Scan found.
+type Result struct {
+	// Items are ordered by path, then line.
+	Items []Item `json:"items"`
+	// Truncated is set when Options.Search.MaxResults stopped the scan.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Scan returns the markers in the files of opts.Search.Dir that opts
+// selects.
+func Scan(ctx context.Context, opts Options) (*Result, error) {
+	tags := opts.Tags
+	if len(tags) == 0 {
+		tags = DefaultTags
+	}
+	quoted := make([]string, len(tags))
+	for i, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			return nil, errors.New("tags must not be empty")
+		}
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	alt := strings.Join(quoted, "|")
+	// A marker ends the word it is in; TODO(name): and TODO: are both
+	// common, the owner and colon optional
+	marker := regexp.MustCompile(`\b(` + alt + `)\b(?:\(([^)]*)\))?:?\s*(.*)`)
+
+	so := opts.Search
+	so.Names, so.Fuzzy, so.Sort, so.Boost, so.Offset = nil, false, "", nil, 0
+	so.Contents, so.AllContents, so.NotContents = []string{`\b(?:` + alt + `)\b`}, false, nil
+	so.Regex, so.Word, so.Case, so.Before, so.After = true, false, search.CaseSensitive, 0, 0
+
+	res := &Result{Items: []Item{}}
+	sum, err := search.SearchContext(ctx, so, func(m search.Match) {
+		if m.Kind != search.ContentMatch {
+			return
+		}
+		sub := marker.FindStringSubmatchIndex(m.Text)
+		if sub == nil {
+			return
+		}
+		item := Item{Path: m.Path, Line: m.Line, Column: sub[0] + 1, Tag: m.Text[sub[2]:sub[3]], Text: cleanText(m.Text[sub[6]:sub[7]])}
+		if sub[4] >= 0 {
+			item.Owner = strings.TrimSpace(m.Text[sub[4]:sub[5]])
+		}
+		res.Items = append(res.Items, item)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sum.Cancelled {
+		return nil, ctx.Err()
+	}
+	res.Truncated = sum.Truncated
+	sort.Slice(res.Items, func(i, j int) bool {
+		a, b := res.Items[i], res.Items[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Line < b.Line
+	})
+
+	if opts.Blame && len(res.Items) > 0 {
+		dir := so.Dir
+		if dir == "" {
+			dir = "."
+		}
+		if _, err := git.Root(ctx, dir); err != nil {
+			return nil, fmt.Errorf("blame: %w", err)
+		}
+		// Files git does not track have no authors; their items are left
+		// without one
+		var authors []string
+		for i := range res.Items {
+			it := &res.Items[i]
+			if i == 0 || it.Path != res.Items[i-1].Path {
+				authors, _ = git.Authors(ctx, it.Path)
+			}
+			if it.Line <= len(authors) {
+				it.Author = authors[it.Line-1]
+			}
+		}
+	}
+	return res, nil
+}
+
+// cleanText trims the text after a marker, and the end of a block comment
+// closed on its line.
+func cleanText(s string) string {
+	s = strings.TrimSpace(s)
+	for _, end := range []string{"*/", "-->", "#}", "%>"} {
+		s = strings.TrimSpace(strings.TrimSuffix(s, end))
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+// Package tree summarizes the layout of a directory: the directories and
+// files below it, as far down as asked, with how many files each directory
+// holds in all.
+package tree
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"vscode-helper-file-find/internal/search"
+)
+
+// DefaultMaxEntries is the entry limit used by Build when none is given.
+const DefaultMaxEntries = 1000
+
+// Node is a directory or file in a tree.
+type Node struct {
+	Name string `json:"name"`
+	// Path is relative to the root of the tree, using '/' separators; it
+	// is "." for the root.
+	Path string `json:"path"`
+	Type string `json:"type"` // "directory" or "file"
+	// Files and Dirs count the files and directories below a directory at
+	// any depth, including those not listed in Children.
+	Files int `json:"files,omitempty"`
+	Dirs  int `json:"dirs,omitempty"`
+	// Children are the entries of a directory listed, directories first,
+	// each group in name order. A directory at the depth limit has none.
+	Children []*Node `json:"children,omitempty"`
+	// Omitted counts the entries of a directory left out of Children to
+	// stay within the entry limit.
+	Omitted int `json:"omitted,omitempty"`
+}
+
+// Options controls how a tree is built.
+type Options struct {
+	// Search selects the files: those it would report by name. Names
+	// default to every file, and directories holding no file selected are
+	// left out.
+	Search search.Options
+	// Depth is how many levels are listed below the root; zero lists all.
+	Depth int
+	// MaxEntries caps the entries listed, shallower ones first, so that a
+	// large tree keeps its outline. Zero means DefaultMaxEntries.
+	MaxEntries int
+}
+
+// Build returns the tree of the files in opts.Search.Dir that opts
+// selects.
+func Build(ctx context.Context, opts Options) (*Node, error) {
+	so := opts.Search
+	if len(so.Names) == 0 {
+		so.Names = []string{"*"}
+	}
+	so.Contents, so.Fuzzy, so.Sort, so.Boost, so.Offset, so.MaxResults = nil, false, "", nil, 0, 0
+	dir := so.Dir
+	if dir == "" {
+		dir = "."
+	}
+	root := &Node{Name: dir, Path: ".", Type: "directory"}
+	dirs := map[string]*Node{".": root}
+	sum, err := search.SearchContext(ctx, so, func(m search.Match) {
+		rel, err := filepath.Rel(dir, m.Path)
+		if err != nil || m.Kind != search.NameMatch {
+			return
+		}
+		add(dirs, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sum.Cancelled {
+		return nil, ctx.Err()
+	}
+	sortChildren(root)
+	if opts.Depth > 0 {
+		prune(root, opts.Depth)
+	}
+	limit := opts.MaxEntries
+	if limit <= 0 {
+		limit = DefaultMaxEntries
+	}
+	truncate(root, limit)
+	return root, nil
+}
+
+// add records the file at rel, creating the directories leading to it and
+// counting it, and any directory created, in their ancestors.
+func add(dirs map[string]*Node, rel string) {
+	parts := strings.Split(rel, "/")
+	ancestors := []*Node{dirs["."]}
+	for i := range parts[:len(parts)-1] {
+		p := path.Join(parts[:i+1]...)
+		d, ok := dirs[p]
+		if !ok {
+			d = &Node{Name: parts[i], Path: p, Type: "directory"}
+			dirs[p] = d
+			parent := ancestors[len(ancestors)-1]
+			parent.Children = append(parent.Children, d)
+			for _, a := range ancestors {
+				a.Dirs++
+			}
+		}
+		ancestors = append(ancestors, d)
+	}
+	parent := ancestors[len(ancestors)-1]
+	parent.Children = append(parent.Children, &Node{Name: parts[len(parts)-1], Path: rel, Type: "file"})
+	for _, a := range ancestors {
+		a.Files++
+	}
+}
+
+// sortChildren orders the entries of n and those below it.
+func sortChildren(n *Node) {
+	sort.Slice(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if a.Type != b.Type {
+			return a.Type == "directory"
+		}
+		return a.Name < b.Name
+	})
+	for _, c := range n.Children {
+		sortChildren(c)
+	}
+}
+
+// prune drops the entries more than depth levels below n.
+func prune(n *Node, depth int) {
+	if depth == 0 {
+		n.Children = nil
+		return
+	}
+	for _, c := range n.Children {
+		prune(c, depth-1)
+	}
+}
+
+// truncate keeps the first limit entries in breadth-first order, counting
+// the rest in Omitted.
+func truncate(root *Node, limit int) {
+	queue := []*Node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		keep := min(limit, len(n.Children))
+		n.Omitted = len(n.Children) - keep
+		n.Children = n.Children[:keep]
+		limit -= keep
+		for _, c := range n.Children {
+			if c.Type == "directory" {
+				queue = append(queue, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// apiRoute is an endpoint of the REST API served beside the MCP handler in
+// HTTP mode: a tool called with the query parameters of a GET or the JSON
+// body of a POST, answering with the tool's structuredContent.
+type apiRoute struct {
+	Method, Path string
+	Tool         string
+	Summary      string
+	// Params and Result are the tool's argument and result types, for the
+	// OpenAPI description.
+	Params, Result reflect.Type
+	Handler        http.HandlerFunc
+}
+
+// apiRoutes returns the endpoints of the REST API; open is left out with
+// -read-only, as its tool is.
+func apiRoutes() []apiRoute {
+	routes := []apiRoute{
+		newAPIRoute[SearchFilesParams, SearchFilesResult](http.MethodGet, "/api/search", "search_files", "Search files by name and/or content", searchFiles),
+	}
+	if !readOnly {
+		routes = append(routes,
+			newAPIRoute[OpenFileParams, OpenFileResult](http.MethodPost, "/api/open", "open_file", "Open a file or directory in VS Code", openFile),
+		)
+	}
+	return routes
+}
+
+// newAPIRoute returns the route calling the tool h, wrapped as addTool
+// wraps it, so that calls are limited, traced, audited, and counted alike.
+// There is no session, so the client's roots do not apply and progress is
+// not reported.
+func newAPIRoute[In, Out any](method, path, tool, summary string, h mcp.ToolHandlerFor[In, any]) apiRoute {
+	h = wrapTool(tool, h)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); r.Method == http.MethodPost && mt != "application/json" {
+			writeAPIError(w, http.StatusUnsupportedMediaType, "the request body must be application/json")
+			return
+		}
+		var args In
+		if err := decodeArgs(w, r, &args); err != nil {
+			writeAPIError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		res, err := h(r.Context(), nil, &mcp.CallToolParamsFor[In]{Name: tool, Arguments: args})
+		switch {
+		case err != nil:
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+		case res.IsError:
+			writeAPIError(w, http.StatusBadRequest, strings.TrimPrefix(errorText(res), "Error: "))
+		default:
+			writeJSON(w, http.StatusOK, res.StructuredContent)
+		}
+	}
+	return apiRoute{Method: method, Path: path, Tool: tool, Summary: summary, Params: reflect.TypeFor[In](), Result: reflect.TypeFor[Out](), Handler: handler}
+}
+
+// apiHandler serves the REST API and its OpenAPI description at
+// /api/openapi.json.
+func apiHandler() http.Handler {
+	routes := apiRoutes()
+	mux := http.NewServeMux()
+	for _, rt := range routes {
+		mux.HandleFunc(rt.Method+" "+rt.Path, rt.Handler)
+	}
+	spec := openAPISpec(routes)
+	mux.HandleFunc("GET /api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, spec)
+	})
+	return mux
+}
+
+// decodeArgs fills args, a tool's argument struct, from the JSON body of a
+// POST or from the query parameters named as
+// its JSON fields; a list is given by repeating its parameter.
+func decodeArgs(w http.ResponseWriter, r *http.Request, args any) error {
+	if r.Method == http.MethodPost {
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(args); err != nil {
+			return fmt.Errorf("invalid request body: %v", err)
+		}
+		return nil
+	}
+	fields := make(map[string]reflect.Type)
+	t := reflect.TypeOf(args).Elem()
+	for i := range t.NumField() {
+		if name := jsonName(t.Field(i)); name != "" {
+			fields[name] = t.Field(i).Type
+		}
+	}
+	values := make(map[string]any)
+	for name, vs := range r.URL.Query() {
+		ft, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("unknown parameter '%s'", name)
+		}
+		v, err := queryValue(ft, vs)
+		if err != nil {
+			return fmt.Errorf("parameter '%s': %v", name, err)
+		}
+		values[name] = v
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, args)
+}
+
+// queryValue converts the values of a query parameter to the type of the
+// field it sets.
+func queryValue(t reflect.Type, vs []string) (any, error) {
+	if t.Kind() == reflect.Slice {
+		return vs, nil
+	}
+	v := vs[len(vs)-1]
+	switch t.Kind() {
+	case reflect.Bool:
+		if v == "" {
+			return true, nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not true or false", v)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not an integer", v)
+		}
+		return n, nil
+	}
+	return v, nil
+}
+
+// jsonName returns the name encoding/json gives field f, or "" if it is
+// not encoded.
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch {
+	case name == "-" || !f.IsExported():
+		return ""
+	case name == "":
+		return f.Name
+	}
+	return name
+}
+
+// writeJSON writes v as the JSON response with status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// writeAPIError writes {"error": msg} with status code.
+func writeAPIError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+// openAPISpec returns the OpenAPI 3.1 description of routes, with their
+// parameters and results described by the schemas tools declare.
+func openAPISpec(routes []apiRoute) map[string]any {
+	errorSchema := &jsonschema.Schema{Type: "object", Properties: map[string]*jsonschema.Schema{"error": {Type: "string"}}, Required: []string{"error"}}
+	errorResponse := map[string]any{"description": "The call failed", "content": map[string]any{"application/json": map[string]any{"schema": errorSchema}}}
+	paths := make(map[string]any)
+	for _, rt := range routes {
+		params := schemaOf(rt.Params, nil)
+		op := map[string]any{
+			"operationId": rt.Tool,
+			"summary":     rt.Summary,
+			"responses": map[string]any{
+				"200": map[string]any{"description": "The tool's structured result", "content": map[string]any{"application/json": map[string]any{"schema": schemaOf(rt.Result, nil)}}},
+				"400": errorResponse,
+			},
+		}
+		if rt.Method == http.MethodGet {
+			var list []map[string]any
+			for i := range rt.Params.NumField() {
+				f := rt.Params.Field(i)
+				name := jsonName(f)
+				if name == "" {
+					continue
+				}
+				p := params.Properties[name]
+				list = append(list, map[string]any{"name": name, "in": "query", "description": p.Description, "schema": p})
+			}
+			op["parameters"] = list
+		} else {
+			op["requestBody"] = map[string]any{"required": true, "content": map[string]any{"application/json": map[string]any{"schema": params}}}
+		}
+		paths[rt.Path] = map[string]any{strings.ToLower(rt.Method): op}
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "vscode-helper",
+			"version":     impl.Version,
+			"description": "Tools of the vscode-helper MCP server as a plain JSON API. Bad arguments and tool errors are answered with 400 and {\"error\": message}.",
+		},
+		"paths": paths,
+	}
+}
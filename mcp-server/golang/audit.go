@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"vscode-helper-file-find/internal/audit"
+)
+
+// auditLog records every tool call when -audit-log is set; nil otherwise.
+var auditLog *audit.Log
+
+// auditTool wraps a tool handler so that each call is recorded in
+// auditLog with its session (none for the REST API), OIDC user, arguments, and outcome.
+func auditTool[In any](name string, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[In]) (*mcp.CallToolResultFor[any], error) {
+		start := time.Now()
+		res, err := h(ctx, ss, params)
+		session := ""
+		if ss != nil {
+			session = ss.ID()
+		}
+		e := audit.Entry{
+			Time:     start.UTC(),
+			Session:  session,
+			User:     userOf(ctx),
+			Tool:     name,
+			Args:     params.Arguments,
+			Outcome:  audit.OK,
+			Duration: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		switch {
+		case err != nil:
+			e.Outcome, e.Error = audit.Error, err.Error()
+		case failed(res):
+			e.Outcome, e.Error = audit.Error, errorText(res)
+		}
+		if err := auditLog.Record(e); err != nil {
+			log.Printf("Warning: unable to write audit log: %v", err)
+		}
+		return res, err
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"vscode-helper-file-find/internal/oidc"
+)
+
+// authTokenEnv names the environment variable read when -auth-token is not
+// given, which keeps the token out of the process list.
+const authTokenEnv = "VSCODE_HELPER_AUTH_TOKEN"
+
+// loadTokens returns the bearer tokens accepted in HTTP mode: token, or the
+// authTokenEnv variable if token is empty, plus every line of the file at
+// path if one is given. Blank lines and lines starting with '#' in the file
+// are ignored.
+func loadTokens(token, path string) ([]string, error) {
+	var tokens []string
+	if token == "" {
+		token = os.Getenv(authTokenEnv)
+	}
+	if token = strings.TrimSpace(token); token != "" {
+		tokens = append(tokens, token)
+	}
+	if path == "" {
+		return tokens, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read tokens file: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read tokens file: %w", err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("tokens file %s has no tokens", path)
+	}
+	return tokens, nil
+}
+
+// requireBearer wraps next so that only requests carrying one of the
+// authTokens, or a token verifier accepts, in an "Authorization: Bearer"
+// header reach it; others get 401. Either may be empty, and while both are
+// every request passes. The user a verified token was issued to is
+// attached to the request's context for the audit log, and a Streamable
+// HTTP session of server started by one user is refused to others.
+func requireBearer(verifier *oidc.Verifier, server *mcp.Server, next http.Handler) http.Handler {
+	owners := newSessionOwners(server)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		tokens := authTokens()
+		if len(tokens) == 0 && verifier == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user := ""
+		if !authorized(tokens, header) {
+			var err error
+			if user, err = verifyOIDC(r.Context(), verifier, header); err != nil {
+				challenge := `Bearer realm="mcp"`
+				if verifier != nil && header != "" {
+					challenge += fmt.Sprintf(`, error="invalid_token", error_description=%q`, err.Error())
+				}
+				w.Header().Set("WWW-Authenticate", challenge)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		id := r.Header.Get(sessionIDHeader)
+		if id != "" {
+			if owner, ok := owners.get(id); ok && owner != user {
+				http.Error(w, "session belongs to another user", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(withUser(r.Context(), user)))
+		if id == "" {
+			if id = w.Header().Get(sessionIDHeader); id != "" {
+				owners.add(id, user)
+			}
+		}
+	})
+}
+
+// sessionOwners records the user who started each Streamable HTTP session
+// of a server, and forgets a session once it is closed: by a DELETE, for
+// being idle past -event-retention, or by a failed keepalive.
+type sessionOwners struct {
+	server *mcp.Server
+	mu     sync.Mutex
+	users  map[string]string
+}
+
+func newSessionOwners(server *mcp.Server) *sessionOwners {
+	return &sessionOwners{server: server, users: make(map[string]string)}
+}
+
+// get returns the user who started the session id, if it is open.
+func (o *sessionOwners) get(id string) (string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	user, ok := o.users[id]
+	return user, ok
+}
+
+// add records user as the owner of the session id until it closes.
+func (o *sessionOwners) add(id, user string) {
+	for ss := range o.server.Sessions() {
+		if ss.ID() == id {
+			o.mu.Lock()
+			o.users[id] = user
+			o.mu.Unlock()
+			go func() {
+				ss.Wait()
+				o.mu.Lock()
+				delete(o.users, id)
+				o.mu.Unlock()
+			}()
+			return
+		}
+	}
+}
+
+// verifyOIDC returns the user of the bearer token in header if verifier
+// accepts it.
+func verifyOIDC(ctx context.Context, verifier *oidc.Verifier, header string) (string, error) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if verifier == nil || !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", errors.New("no valid bearer token")
+	}
+	claims, err := verifier.Verify(ctx, strings.TrimSpace(token))
+	if err != nil {
+		return "", err
+	}
+	return claims.User(), nil
+}
+
+// userKey is the context key of the user a request was authenticated as.
+type userKey struct{}
+
+// withUser returns ctx carrying user, if there is one.
+func withUser(ctx context.Context, user string) context.Context {
+	if user == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// userOf returns the user ctx was authenticated as by an OIDC token, or ""
+// for stdio, the static tokens, and no authentication. A session's tool
+// calls run in the context of the request that started it.
+func userOf(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// authorized reports whether the Authorization header value presents one of
+// tokens, comparing in constant time.
+func authorized(tokens []string, header string) bool {
+	scheme, got, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	got = strings.TrimSpace(got)
+	match := 0
+	for _, t := range tokens {
+		match |= subtle.ConstantTimeCompare([]byte(got), []byte(t))
+	}
+	return match == 1
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestSessionOwnersForgetClosedSessions(t *testing.T) {
+	server := createServer()
+	ts := httptest.NewServer(mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil))
+	defer ts.Close()
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil)
+	cs, err := client.Connect(context.Background(), mcp.NewStreamableClientTransport(ts.URL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	owners := newSessionOwners(server)
+	id := cs.ID()
+	owners.add(id, "alice")
+	if user, ok := owners.get(id); !ok || user != "alice" {
+		t.Fatalf("owner of the open session = %q, %v; want alice", user, ok)
+	}
+
+	// End the session as retainSessions does; the SDK client's own DELETE
+	// lacks the Accept header the handler wants
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	req.Header.Set(sessionIDHeader, id)
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE = %s, want 204", resp.Status)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := owners.get(id); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the owner of a closed session is still recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
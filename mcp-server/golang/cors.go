@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// corsHeaders are the request headers browsers may send cross-origin: those
+// of Streamable HTTP and the REST API.
+const corsHeaders = "Authorization, Content-Type, Accept, Mcp-Session-Id, Mcp-Protocol-Version, Last-Event-ID"
+
+// originList collects the repeated -allowed-origin flag.
+type originList []string
+
+func (o *originList) String() string { return strings.Join(*o, ",") }
+
+func (o *originList) Set(origin string) error {
+	origin = strings.TrimSuffix(origin, "/")
+	if err := validOrigin(origin); err != nil {
+		return err
+	}
+	*o = append(*o, origin)
+	return nil
+}
+
+// validOrigin checks that origin is "*" or a scheme://host[:port] as
+// browsers send it in the Origin header.
+func validOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return fmt.Errorf("invalid origin '%s' (expected scheme://host[:port] or *)", origin)
+	}
+	return nil
+}
+
+// checkOrigin wraps next so that a request with an Origin header, which
+// browsers add to cross-origin requests, is refused with 403 unless the
+// origin is one of origins ("*" allows any). Allowed ones get the CORS
+// headers that let a browser-based client read the responses, and their
+// preflight requests are answered here, before any token is asked for.
+//
+// Requests without an Origin, from programs rather than web pages, pass;
+// refusing the others guards against web pages reaching a server on
+// localhost, including through DNS rebinding.
+func checkOrigin(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		if !slices.Contains(origins, "*") && !slices.Contains(origins, origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", "Mcp-Session-Id, WWW-Authenticate")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", corsHeaders)
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// defaultShutdownTimeout is how long -shutdown-timeout lets running tool
+// calls finish by default.
+const defaultShutdownTimeout = 30 * time.Second
+
+// cancelGrace is how long drainTools waits for cancelled calls to return.
+const cancelGrace = 5 * time.Second
+
+// toolCalls tracks the tool calls running, so that a shutdown can let them
+// finish before the server goes away. ctx is the parent of every call's
+// context, cancelled when they are given up on.
+var toolCalls = struct {
+	sync.Mutex
+	running int
+	closing bool
+	idle    chan struct{} // closed when the last call finishes while closing
+	ctx     context.Context
+	cancel  context.CancelFunc
+}{}
+
+func init() {
+	toolCalls.ctx, toolCalls.cancel = context.WithCancel(context.Background())
+}
+
+// drainTool wraps a tool handler so that its calls are tracked in
+// toolCalls, refused once a shutdown has begun, and cancelled when the
+// shutdown stops waiting for them.
+func drainTool[In any](name string, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[In]) (*mcp.CallToolResultFor[any], error) {
+		toolCalls.Lock()
+		if toolCalls.closing {
+			toolCalls.Unlock()
+			return errorResult("Error: the server is shutting down; retry once it is back"), nil
+		}
+		toolCalls.running++
+		toolCalls.Unlock()
+		defer func() {
+			toolCalls.Lock()
+			defer toolCalls.Unlock()
+			if toolCalls.running--; toolCalls.running == 0 && toolCalls.idle != nil {
+				close(toolCalls.idle)
+				toolCalls.idle = nil
+			}
+		}()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(toolCalls.ctx, cancel)
+		defer stop()
+		return h(ctx, ss, params)
+	}
+}
+
+// draining reports whether a shutdown has begun.
+func draining() bool {
+	toolCalls.Lock()
+	defer toolCalls.Unlock()
+	return toolCalls.closing
+}
+
+// drainTools refuses new tool calls and waits up to timeout for the running
+// ones to finish, then cancels those left, which stops their searches and
+// kills their subprocesses, and waits a little more for them to return.
+func drainTools(timeout time.Duration) {
+	toolCalls.Lock()
+	toolCalls.closing = true
+	n := toolCalls.running
+	if n == 0 {
+		toolCalls.Unlock()
+		return
+	}
+	idle := make(chan struct{})
+	toolCalls.idle = idle
+	toolCalls.Unlock()
+
+	log.Printf("Waiting up to %s for %d running tool calls to finish", timeout, n)
+	select {
+	case <-idle:
+		return
+	case <-time.After(timeout):
+	}
+	toolCalls.Lock()
+	n = toolCalls.running
+	toolCalls.Unlock()
+	log.Printf("Cancelling %d tool calls still running after %s", n, timeout)
+	toolCalls.cancel()
+	select {
+	case <-idle:
+	case <-time.After(cancelGrace):
+		log.Printf("Warning: tool calls did not return within %s of being cancelled", cancelGrace)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"vscode-helper-file-find/internal/index"
+	"vscode-helper-file-find/internal/opener"
+)
+
+// Check statuses. A failing check makes the server unhealthy; a warning
+// only reports something that makes tools slower or less useful.
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// Check is the outcome of one readiness check reported by /health.
+type Check struct {
+	Name    string `json:"name"`
+	Target  string `json:"target,omitempty"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Health is the /health response body. Status is "ok" unless a check
+// failed, in which case it is "degraded", or the server is shutting down,
+// in which case it is "draining".
+type Health struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+// checkHealth runs the readiness checks: the editor for open_file (unless
+// -read-only left it out), and that each root can be listed and whether an
+// index covering it is fresh.
+func checkHealth() Health {
+	var checks []Check
+	if !readOnly {
+		c := Check{Name: "editor", Status: checkOK}
+		if path, err := opener.LookPath(editor); err != nil {
+			c.Status, c.Message = checkFail, err.Error()
+		} else {
+			c.Target = path
+		}
+		checks = append(checks, c)
+	}
+	for _, root := range roots() {
+		checks = append(checks, checkRoot(root), checkIndex(root))
+	}
+
+	h := Health{Status: "ok", Checks: checks}
+	for _, c := range checks {
+		if c.Status == checkFail {
+			h.Status = "degraded"
+		}
+	}
+	if draining() {
+		// Not ready for more calls, so that load balancers move on
+		h.Status = "draining"
+	}
+	return h
+}
+
+// checkRoot verifies that root is still a directory the server can read.
+func checkRoot(root string) Check {
+	c := Check{Name: "root", Target: root, Status: checkOK}
+	f, err := os.Open(root)
+	if err == nil {
+		_, err = f.Readdirnames(1)
+		f.Close()
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.Status, c.Message = checkFail, err.Error()
+	}
+	return c
+}
+
+// checkIndex reports on the index covering root. Searches walk the file
+// system when there is none or it is stale, so neither is a failure.
+func checkIndex(root string) Check {
+	c := Check{Name: "index", Target: root, Status: checkOK}
+	ix, err := index.Load(root)
+	switch {
+	case errors.Is(err, index.ErrNotFound):
+		c.Message = "not indexed"
+	case err != nil:
+		c.Status, c.Message = checkWarn, err.Error()
+	case !ix.Fresh(root):
+		c.Status = checkWarn
+		c.Message = fmt.Sprintf("stale: built %s; run 'vscode-helper index' to rebuild", ix.Built.Format(time.RFC3339))
+	default:
+		c.Message = "fresh: built " + ix.Built.Format(time.RFC3339)
+	}
+	return c
+}
+
+// healthHandler serves checkHealth as JSON, with status 503 when degraded.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	h := checkHealth()
+	w.Header().Set("Content-Type", "application/json")
+	if h.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(h)
+}
+
+// liveHandler reports that the process is up and serving, without checking
+// anything else, for liveness probes.
+func liveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"status":"ok"}` + "\n"))
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Defaults for -tool-timeout, -max-output, -max-concurrent,
+// -session-burst, and -session-max-concurrent.
+const (
+	defaultToolTimeout          = 5 * time.Minute
+	defaultMaxOutput            = 1 << 20
+	defaultMaxConcurrent        = 8
+	defaultSessionBurst         = 10
+	defaultSessionMaxConcurrent = 4
+)
+
+var (
+	// toolTimeouts holds the -tool-timeout flags: tool names mapped to how
+	// long a call may run, the empty name holding the timeout of tools not
+	// listed. Zero means no timeout. They override the config's
+	// tool_timeout in the settings, which toolTimeout reads.
+	toolTimeouts = timeouts{}
+	// maxOutput caps the bytes of text a tool call returns; 0 disables it.
+	maxOutput = defaultMaxOutput
+	// slots holds a token per running tool call when -max-concurrent is
+	// set, and is nil otherwise.
+	slots chan struct{}
+)
+
+// sessionLimits holds, by session ID, the token bucket of each session's
+// calls under -session-rate and the number running under
+// -session-max-concurrent. REST API calls have no session and share the
+// entry of the empty ID.
+var sessionLimits = struct {
+	sync.Mutex
+	rate       float64 // calls per second; 0 for no limit
+	burst      int
+	maxRunning int // 0 for no limit
+	sessions   map[string]*sessionLimit
+	swept      time.Time
+}{burst: defaultSessionBurst, maxRunning: defaultSessionMaxConcurrent, sessions: map[string]*sessionLimit{}}
+
+// sessionLimit is the state of one session's limits.
+type sessionLimit struct {
+	tokens  float64
+	updated time.Time
+	running int
+}
+
+// admitSession takes a token from the bucket of session id and a place
+// among its running calls. It returns the func giving the place back, or
+// the error result to answer with when the session is over either limit.
+func admitSession(id string) (release func(), refused *mcp.CallToolResultFor[any]) {
+	l := &sessionLimits
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	if now.Sub(l.swept) > time.Minute {
+		// Forget sessions idle long enough for their buckets to be full
+		// again, ended ones among them
+		full := time.Minute
+		if l.rate > 0 {
+			full = max(full, time.Duration(float64(l.burst)/l.rate*float64(time.Second)))
+		}
+		for sid, s := range l.sessions {
+			if s.running == 0 && now.Sub(s.updated) > full {
+				delete(l.sessions, sid)
+			}
+		}
+		l.swept = now
+	}
+	s := l.sessions[id]
+	if s == nil {
+		s = &sessionLimit{tokens: float64(l.burst), updated: now}
+		l.sessions[id] = s
+	}
+	if l.rate > 0 {
+		s.tokens = min(float64(l.burst), s.tokens+now.Sub(s.updated).Seconds()*l.rate)
+		s.updated = now
+		if s.tokens < 1 {
+			wait := time.Duration((1 - s.tokens) / l.rate * float64(time.Second))
+			return nil, errorResult(fmt.Sprintf("Error: rate limit exceeded: a session may make %g tool calls per second, in bursts of up to %d; retry in %s", l.rate, l.burst, wait.Round(time.Millisecond)))
+		}
+	}
+	if l.maxRunning > 0 && s.running >= l.maxRunning {
+		return nil, errorResult(fmt.Sprintf("Error: too many tool calls at once: a session may run %d at a time; retry when one has finished", l.maxRunning))
+	}
+	s.tokens--
+	s.running++
+	s.updated = now
+	return func() {
+		l.Lock()
+		defer l.Unlock()
+		s.running--
+		s.updated = time.Now()
+	}, nil
+}
+
+// timeouts is the flag.Value of -tool-timeout: each use is either a
+// duration, which sets the default, or TOOL=DURATION.
+type timeouts map[string]time.Duration
+
+func (t timeouts) String() string {
+	var parts []string
+	for name, d := range t {
+		if name == "" {
+			parts = append(parts, d.String())
+		} else {
+			parts = append(parts, name+"="+d.String())
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (t timeouts) Set(v string) error {
+	name, value, ok := strings.Cut(v, "=")
+	if !ok {
+		name, value = "", v
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fmt.Errorf("invalid timeout %q (want a duration such as 30s, or TOOL=DURATION)", v)
+	}
+	t[name] = d
+	return nil
+}
+
+// of returns the timeout for the named tool.
+func (t timeouts) of(name string) time.Duration {
+	if d, ok := t[name]; ok {
+		return d
+	}
+	return t[""]
+}
+
+// limitTool wraps a tool handler so that it is refused when its session is
+// over -session-rate or -session-max-concurrent, waits for a free slot
+// under -max-concurrent, runs within the tool's timeout, and returns at most
+// maxOutput bytes of text. Handlers stop at the timeout because they honour
+// ctx; a search returns the matches found so far.
+func limitTool[In any](name string, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[In]) (*mcp.CallToolResultFor[any], error) {
+		session := ""
+		if ss != nil {
+			session = ss.ID()
+		}
+		release, refused := admitSession(session)
+		if refused != nil {
+			return refused, nil
+		}
+		defer release()
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				return errorResult("Error: cancelled while waiting for another tool call to finish"), nil
+			}
+		}
+		tctx := ctx
+		timeout := toolTimeout(name)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			tctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		res, err := h(tctx, ss, params)
+		if err != nil || res == nil {
+			return res, err
+		}
+		if tctx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			appendText(res, fmt.Sprintf("\n(stopped: %s exceeded its %s timeout)", name, timeout))
+		}
+		capOutput(res, maxOutput)
+		return res, nil
+	}
+}
+
+// appendText adds s to the last text content of res.
+func appendText(res *mcp.CallToolResultFor[any], s string) {
+	for i := len(res.Content) - 1; i >= 0; i-- {
+		if t, ok := res.Content[i].(*mcp.TextContent); ok {
+			t.Text += s
+			return
+		}
+	}
+	res.Content = append(res.Content, &mcp.TextContent{Text: strings.TrimPrefix(s, "\n")})
+}
+
+// capOutput truncates the text of res to limit bytes, at a line break when
+// there is one, and says so. The structured content, which holds the same
+// results, is dropped along with it, and the result is marked as an error
+// since it no longer matches the tool's output schema.
+func capOutput(res *mcp.CallToolResultFor[any], limit int) {
+	if limit <= 0 {
+		return
+	}
+	size := 0
+	for _, c := range res.Content {
+		if t, ok := c.(*mcp.TextContent); ok {
+			size += len(t.Text)
+		}
+	}
+	if size <= limit {
+		return
+	}
+	left := limit
+	content := res.Content[:0]
+	for _, c := range res.Content {
+		t, ok := c.(*mcp.TextContent)
+		if !ok {
+			content = append(content, c)
+			continue
+		}
+		if left == 0 {
+			continue
+		}
+		if len(t.Text) > left {
+			t.Text = truncate(t.Text, left)
+		}
+		left -= len(t.Text)
+		content = append(content, t)
+	}
+	res.Content = content
+	res.StructuredContent = nil
+	res.IsError = true
+	appendText(res, fmt.Sprintf("\n[output truncated to %d of %d bytes by -max-output; narrow the request or page through it]", limit-left, size))
+}
+
+// truncate returns the longest prefix of s no longer than n bytes that
+// ends at a line break, or failing that at a character boundary.
+func truncate(s string, n int) string {
+	if i := strings.LastIndexByte(s[:n], '\n'); i >= 0 {
+		return s[:i+1]
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"vscode-helper-file-find/internal/logfile"
+)
+
+// Rotation of -log-file: its size in MiB and how many old files are kept.
+const (
+	defaultLogMaxSize = 10
+	defaultLogBackups = 3
+)
+
+// loggerName is the logger of the log messages sent to clients.
+const loggerName = "vscode-helper"
+
+// logServer is the server whose sessions are sent the log. It is set over
+// stdio only, whose one client started the server; over HTTP, sessions of
+// other users have no business reading it.
+var logServer atomic.Pointer[mcp.Server]
+
+// clientLog queues log lines for the sessions, so that logging never waits
+// on a client; lines are dropped while it is full.
+var clientLog = make(chan string, 64)
+
+// setupLogging sends the log to the file at path, rotated past maxSize MiB
+// keeping backups old files, or to stderr when path is empty, and to the
+// sessions of logServer that asked for log messages with logging/setLevel.
+// It returns a func closing the file.
+func setupLogging(path string, maxSize, backups int) (func(), error) {
+	var out io.Writer = os.Stderr
+	closeLog := func() {}
+	if path != "" {
+		f, err := logfile.Open(path, int64(maxSize)<<20, backups)
+		if err != nil {
+			return nil, err
+		}
+		out, closeLog = f, func() { f.Close() }
+	}
+	log.SetOutput(logWriter{out})
+	go forwardLog()
+	return closeLog, nil
+}
+
+// logWriter writes the log to out and queues it for the sessions.
+type logWriter struct{ out io.Writer }
+
+func (w logWriter) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	select {
+	case clientLog <- string(p):
+	default:
+	}
+	return n, err
+}
+
+// forwardLog sends the queued log lines to every session of logServer as
+// notifications/message, at the level their text suggests.
+func forwardLog() {
+	for line := range clientLog {
+		server := logServer.Load()
+		if server == nil {
+			continue
+		}
+		level, msg := logLevel(line)
+		for ss := range server.Sessions() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			sessionLogger(ss).Log(ctx, level, msg)
+			cancel()
+		}
+	}
+}
+
+// logLevel returns the message of a log line, without its date and time,
+// and its level: warnings and errors start with "Warning" and "Error" or
+// "unable", as they are logged here.
+func logLevel(line string) (slog.Level, string) {
+	msg := strings.TrimSpace(line)
+	if log.Flags()&log.LstdFlags == log.LstdFlags {
+		if f := strings.SplitN(msg, " ", 3); len(f) == 3 {
+			msg = f[2]
+		}
+	}
+	switch {
+	case strings.HasPrefix(msg, "Warning"):
+		return slog.LevelWarn, msg
+	case strings.HasPrefix(msg, "Error"), strings.HasPrefix(msg, "unable"):
+		return slog.LevelError, msg
+	}
+	return slog.LevelInfo, msg
+}
+
+// sessionLogger returns a logger sending to ss, which drops messages below
+// the level the client set, and all of them until it sets one.
+func sessionLogger(ss *mcp.ServerSession) *slog.Logger {
+	return slog.New(mcp.NewLoggingHandler(ss, &mcp.LoggingHandlerOptions{LoggerName: loggerName}))
+}
+
+// logTool wraps a tool handler so that the session calling it is told, if
+// it asked for log messages, when the call finishes (at debug level) or
+// fails (as a warning), and how long it took.
+func logTool[In any](name string, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[In]) (*mcp.CallToolResultFor[any], error) {
+		start := time.Now()
+		res, err := h(ctx, ss, params)
+		if ss == nil {
+			return res, err
+		}
+		// Report a cancelled call too, on the request's stream
+		ctx = context.WithoutCancel(ctx)
+		l := sessionLogger(ss).With("tool", name, "duration", time.Since(start).Round(time.Millisecond).String())
+		switch {
+		case err != nil:
+			l.WarnContext(ctx, "tool call failed", "error", err.Error())
+		case failed(res):
+			l.WarnContext(ctx, "tool call failed", "error", errorText(res))
+		default:
+			l.DebugContext(ctx, "tool call finished")
+		}
+		return res, err
+	}
+}
@@ -1,127 +1,1191 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
-	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"vscode-helper-file-find/internal/audit"
+	"vscode-helper-file-find/internal/bookmark"
+	"vscode-helper-file-find/internal/cache"
+	"vscode-helper-file-find/internal/daemon"
+	"vscode-helper-file-find/internal/debug"
+	"vscode-helper-file-find/internal/files"
+	"vscode-helper-file-find/internal/git"
+	"vscode-helper-file-find/internal/gopls"
+	"vscode-helper-file-find/internal/oidc"
+	"vscode-helper-file-find/internal/opener"
+	"vscode-helper-file-find/internal/recent"
+	"vscode-helper-file-find/internal/replace"
+	"vscode-helper-file-find/internal/search"
+	"vscode-helper-file-find/internal/stats"
+	"vscode-helper-file-find/internal/symbols"
+	"vscode-helper-file-find/internal/todos"
+	"vscode-helper-file-find/internal/tree"
 )
 
 // Implementation metadata for the MCP server
 var impl = &mcp.Implementation{Name: "vscode-file-finder-go", Version: "0.1.0"}
 
+// editor opens files for open_file. It is chosen by the operator with
+// -editor, never by the client, since a command template runs arbitrary
+// programs.
+var editor = opener.DefaultEditor
+
+// withConfig fills in the search options left unset by a tool call from the
+// configuration for the directory searched (the working directory if none
+// was given). max_results applies only with limit; replace_in_files goes
+// without so that it never stops partway through the files. With -allow-dir
+// the directory defaults to the first allowed one and must lie inside them.
+func withConfig(opts search.Options, limit bool) (search.Options, error) {
+	start := opts.Dir
+	if start == "" {
+		start = "."
+	}
+	c, err := cfg().ForDir(start)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Dir == "" {
+		opts.Dir = c.Dir
+	}
+	if opts.Excludes == nil {
+		opts.Excludes = c.Exclude
+	}
+	if opts.MaxResults == 0 && limit {
+		opts.MaxResults = c.MaxResults
+	}
+	if opts.Jobs == 0 {
+		opts.Jobs = c.Jobs
+	}
+	opts.TypeDefs = c.Types
+	if sb := allowed(); sb != nil {
+		if opts.Dir == "" {
+			opts.Dir = sb.Dirs()[0]
+		}
+		if err := sb.Check(opts.Dir); err != nil {
+			return opts, err
+		}
+		opts.Allow = sb.Allows
+	}
+	return opts, nil
+}
+
 // SearchFilesParams defines inputs for the search_files tool
 // jsonschema tags are used by the SDK to derive the input schema
 // keeping names aligned with the Python server version.
 type SearchFilesParams struct {
-	Name      string `json:"name" jsonschema:"Glob or pattern for file names"`
-	Content   string `json:"content" jsonschema:"Substring / text to search inside files"`
-	Directory string `json:"directory" jsonschema:"Root directory to start search (default: ".")"`
+	Name           string   `json:"name" jsonschema:"Glob for file names, or comma-separated globs; one with a slash matches the path relative to directory, where ** spans directories (e.g. cmd/**/*_test.go)"`
+	Content        string   `json:"content" jsonschema:"Substring / text to search inside files"`
+	Directory      string   `json:"directory" jsonschema:"Root directory to start search (default: the client's first root, else .); must lie inside the client's roots if it advertises any"`
+	Fuzzy          bool     `json:"fuzzy,omitempty" jsonschema:"Treat name as a fuzzy query (e.g. usrsvc finds user_service.go) and rank matches best first"`
+	Regex          bool     `json:"regex,omitempty" jsonschema:"Treat content as a regular expression (RE2 syntax) and report match columns"`
+	Word           bool     `json:"word,omitempty" jsonschema:"Only match content as a whole word (cache does not match cached or CacheKey)"`
+	IgnoreCase     bool     `json:"ignore_case,omitempty" jsonschema:"Match name and content case-insensitively"`
+	CaseSensitive  bool     `json:"case_sensitive,omitempty" jsonschema:"Match name and content case-sensitively (default is smart-case: insensitive unless the pattern has uppercase)"`
+	ContextLines   int      `json:"context_lines,omitempty" jsonschema:"Lines of context to include before and after each content match"`
+	Exclude        []string `json:"exclude,omitempty" jsonschema:"Glob patterns of files or directories to skip (e.g. *.min.js, dist/**)"`
+	Binary         bool     `json:"binary,omitempty" jsonschema:"Also search the content of binary files (skipped by default)"`
+	FollowSymlinks bool     `json:"follow_symlinks,omitempty" jsonschema:"Descend into symbolic links to directories (skipped by default)"`
+	NoIgnore       bool     `json:"no_ignore,omitempty" jsonschema:"Also search files excluded by .gitignore, .ignore, and global git excludes"`
+	Type           []string `json:"type,omitempty" jsonschema:"Only search files of these types, e.g. [\"go\"] or [\"md\",\"yaml\"]; built-in types include c, cpp, cs, css, docker, go, html, java, js, json, md, proto, py, rust, sh, sql, ts, yaml, and more can be defined in the config"`
+	TypeNot        []string `json:"type_not,omitempty" jsonschema:"Skip files of these types"`
+	MinSize        string   `json:"min_size,omitempty" jsonschema:"Only search files of at least this size, in bytes or with a K, M, or G suffix (e.g. 10K)"`
+	MaxSize        string   `json:"max_size,omitempty" jsonschema:"Only search files of at most this size (e.g. 1M)"`
+	NewerThan      string   `json:"newer_than,omitempty" jsonschema:"Only search files modified within this duration (e.g. 2d, 36h) or since this date or time (e.g. 2024-05-01)"`
+	OlderThan      string   `json:"older_than,omitempty" jsonschema:"Only search files last modified longer ago than this duration, or before this date or time"`
+	Archives       bool     `json:"archives,omitempty" jsonschema:"Also search inside zip, jar, tar, and tar.gz archives; matches are reported with paths like archive.zip!inner/path"`
+	Encoding       string   `json:"encoding,omitempty" jsonschema:"Encoding of the files searched: auto (default; detects UTF-16 and Windows-1252), utf-8, utf-16le, utf-16be, latin1, or windows-1252"`
+	MaxFilesize    string   `json:"max_filesize,omitempty" jsonschema:"Skip the content of files larger than this (e.g. 50M); they are counted in large_skipped"`
+	Sort           string   `json:"sort,omitempty" jsonschema:"Sort results by file: path, mtime, or size (ascending), or score (fuzzy, best first); default is walk order"`
+	Reverse        bool     `json:"reverse,omitempty" jsonschema:"Reverse the sort order"`
+	NoFrecency     bool     `json:"no_frecency,omitempty" jsonschema:"Keep results in walk order instead of putting files often and recently opened first"`
+	Limit          int      `json:"limit,omitempty" jsonschema:"Maximum number of matches to return (default: no limit)"`
+	Cursor         string   `json:"cursor,omitempty" jsonschema:"Continuation cursor from a previous result's next_cursor"`
+	WarnOver       int      `json:"warn_over,omitempty" jsonschema:"Warn in the result metadata when more than this many files match (0 disables)"`
+	Stream         bool     `json:"stream,omitempty" jsonschema:"With a progress token, send matches as they are found in the _meta.matches of progress notifications instead of in the result"`
+}
+
+// applyFilters parses the size and modification time arguments, and the
+// file size limit, into opts, reading durations as time before now.
+func (p SearchFilesParams) applyFilters(opts *search.Options, now time.Time) error {
+	var err error
+	if p.MaxFilesize != "" {
+		if opts.MaxFileSize, err = search.ParseSize(p.MaxFilesize); err != nil {
+			return fmt.Errorf("max_filesize: %w", err)
+		}
+	}
+	if p.MinSize != "" {
+		if opts.MinSize, err = search.ParseSize(p.MinSize); err != nil {
+			return fmt.Errorf("min_size: %w", err)
+		}
+	}
+	if p.MaxSize != "" {
+		if opts.MaxSize, err = search.ParseSize(p.MaxSize); err != nil {
+			return fmt.Errorf("max_size: %w", err)
+		}
+	}
+	if p.NewerThan != "" {
+		if opts.NewerThan, err = search.ParseTime(p.NewerThan, now); err != nil {
+			return fmt.Errorf("newer_than: %w", err)
+		}
+	}
+	if p.OlderThan != "" {
+		if opts.OlderThan, err = search.ParseTime(p.OlderThan, now); err != nil {
+			return fmt.Errorf("older_than: %w", err)
+		}
+	}
+	return nil
+}
+
+// SearchFilesResult is the structured content returned by search_files.
+type SearchFilesResult struct {
+	Matches []search.Match `json:"matches"`
+	// BinarySkipped counts binary files not scanned for content.
+	BinarySkipped int `json:"binary_skipped,omitempty"`
+	// LargeSkipped counts files over max_filesize not scanned for content.
+	LargeSkipped int `json:"large_skipped,omitempty"`
+	// ArchivesLimited counts archives only partly searched because they
+	// decompress to more than the size budget.
+	ArchivesLimited int `json:"archives_limited,omitempty"`
+	// NextCursor is set when the limit was reached; pass it back as cursor
+	// with otherwise identical arguments to get the next page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Cancelled is set when the request was cancelled before the search
+	// finished; Matches holds what was found until then.
+	Cancelled bool `json:"cancelled,omitempty"`
+	// Streamed counts the matches sent in progress notifications instead
+	// of Matches when stream was requested.
+	Streamed int `json:"streamed,omitempty"`
+}
+
+// streamBatchSize is the most matches a streaming search_files call buffers
+// before sending them in a progress notification.
+const streamBatchSize = 200
+
+// encodeCursor returns an opaque cursor for resuming a search after offset
+// results.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset encoded by encodeCursor.
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if n, ok := strings.CutPrefix(string(b), "offset:"); ok {
+			if offset, err := strconv.Atoi(n); err == nil && offset >= 0 {
+				return offset, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid cursor %q", cursor)
 }
 
 // OpenFileParams defines inputs for the open_file tool
 type OpenFileParams struct {
-	Path    string `json:"path" jsonschema:"Path to file or directory"`
-	OpenDir bool   `json:"open_dir" jsonschema:"Treat path as directory"`
+	Path        string `json:"path" jsonschema:"Path to file or directory"`
+	OpenDir     bool   `json:"open_dir" jsonschema:"Treat path as directory"`
+	Line        int    `json:"line,omitempty" jsonschema:"Line of the file to place the cursor on (1-based)"`
+	Column      int    `json:"column,omitempty" jsonschema:"Column of that line to place the cursor on (1-based)"`
+	Workspace   bool   `json:"workspace,omitempty" jsonschema:"Open the enclosing .code-workspace or git root with the file in it"`
+	NewWindow   bool   `json:"new_window,omitempty" jsonschema:"Force a new VS Code window"`
+	ReuseWindow bool   `json:"reuse_window,omitempty" jsonschema:"Open in the last active VS Code window"`
+	Wait        bool   `json:"wait,omitempty" jsonschema:"Block until the user closes the file in VS Code before returning"`
+	Remote      string `json:"remote,omitempty" jsonschema:"SSH host; path is then an absolute folder path on that host, opened with the Remote - SSH extension"`
+}
+
+// OpenFileResult is the structured result of open_file.
+type OpenFileResult struct {
+	OpenedPath string `json:"opened_path" jsonschema:"Absolute path (or remote folder URI) handed to the editor"`
+	Closed     bool   `json:"closed,omitempty" jsonschema:"Set when wait was given: the user has closed the file"`
+}
+
+// OpenAtRevisionParams defines inputs for the open_at_revision tool
+type OpenAtRevisionParams struct {
+	Path  string `json:"path" jsonschema:"Path of the file in the work tree; it need not exist there any more"`
+	Rev   string `json:"rev,omitempty" jsonschema:"Git commit or branch to take the file from (default HEAD, or the work tree with blame)"`
+	Blame bool   `json:"blame,omitempty" jsonschema:"Open git blame's annotation of the file instead of its content"`
+	Line  int    `json:"line,omitempty" jsonschema:"Line to place the cursor on (1-based)"`
+}
+
+// RecentFilesParams defines inputs for the recent_files tool
+type RecentFilesParams struct {
+	Query string `json:"query,omitempty" jsonschema:"Fuzzy query the path must match, e.g. usrsvc for user_service.go"`
+	Limit int    `json:"limit,omitempty" jsonschema:"Maximum number of files to return (default 20)"`
+}
+
+// RecentFilesResult is the structured result of recent_files.
+type RecentFilesResult struct {
+	Files []recent.Entry `json:"files"`
+}
+
+// GetFileInfoParams defines inputs for the get_file_info tool
+type GetFileInfoParams struct {
+	Path string `json:"path" jsonschema:"Path to the file or directory"`
+}
+
+// WriteFileParams defines inputs for the write_file tool
+type WriteFileParams struct {
+	Path      string `json:"path" jsonschema:"Path of the file to create; missing parent directories are created"`
+	Content   string `json:"content" jsonschema:"Full content of the file"`
+	Overwrite bool   `json:"overwrite,omitempty" jsonschema:"Replace the file if it already exists (default: fail)"`
+	Open      bool   `json:"open,omitempty" jsonschema:"Open the file in VS Code after writing it"`
+}
+
+// ReadFileParams defines inputs for the read_file tool
+type ReadFileParams struct {
+	Path      string `json:"path" jsonschema:"Path to the file to read"`
+	StartLine int    `json:"start_line,omitempty" jsonschema:"First line to return (1-based, default 1)"`
+	EndLine   int    `json:"end_line,omitempty" jsonschema:"Last line to return, inclusive (default: end of file)"`
+	MaxBytes  int    `json:"max_bytes,omitempty" jsonschema:"Maximum bytes of content to return (default 262144)"`
+}
+
+// ListDirectoryParams defines inputs for the list_directory tool
+type ListDirectoryParams struct {
+	Path       string `json:"path,omitempty" jsonschema:"Directory to list (default: .)"`
+	Depth      int    `json:"depth,omitempty" jsonschema:"Number of directory levels to descend (default 1: immediate entries only)"`
+	MaxEntries int    `json:"max_entries,omitempty" jsonschema:"Maximum number of entries to return (default 1000)"`
+}
+
+// DirectoryTreeParams defines inputs for the directory_tree tool
+type DirectoryTreeParams struct {
+	Directory  string   `json:"directory,omitempty" jsonschema:"Root of the tree (default: the client's first root, else .)"`
+	Depth      *int     `json:"depth,omitempty" jsonschema:"Number of directory levels to list (default 3; 0 for all)"`
+	Include    []string `json:"include,omitempty" jsonschema:"Only count files whose name matches one of these globs, as search_files name takes them (e.g. [\"*.go\"])"`
+	Exclude    []string `json:"exclude,omitempty" jsonschema:"Glob patterns of files or directories to skip (e.g. testdata/**)"`
+	NoIgnore   bool     `json:"no_ignore,omitempty" jsonschema:"Also count files excluded by .gitignore, .ignore, and global git excludes"`
+	MaxEntries int      `json:"max_entries,omitempty" jsonschema:"Maximum number of entries to list, shallower ones first (default 1000); the rest are counted in omitted"`
+}
+
+// ProjectStatsParams defines inputs for the project_stats tool
+type ProjectStatsParams struct {
+	Directory string   `json:"directory,omitempty" jsonschema:"Directory to count (default: the client's first root, else .)"`
+	Top       int      `json:"top,omitempty" jsonschema:"Number of largest and newest files to list (default 10)"`
+	Include   []string `json:"include,omitempty" jsonschema:"Only count files whose name matches one of these globs, as search_files name takes them (e.g. [\"*.go\"])"`
+	Exclude   []string `json:"exclude,omitempty" jsonschema:"Glob patterns of files or directories to skip (e.g. testdata/**)"`
+	NoIgnore  bool     `json:"no_ignore,omitempty" jsonschema:"Also count files excluded by .gitignore, .ignore, and global git excludes"`
+}
+
+// ListTodosParams defines inputs for the list_todos tool
+type ListTodosParams struct {
+	Directory string   `json:"directory,omitempty" jsonschema:"Directory to scan (default: the client's first root, else .)"`
+	Tags      []string `json:"tags,omitempty" jsonschema:"Markers to look for, as whole words and case-sensitively (default: the config's todos.tags, else TODO, FIXME, and HACK)"`
+	Include   []string `json:"include,omitempty" jsonschema:"Only scan files whose name matches one of these globs, as search_files name takes them (e.g. [\"*.go\"])"`
+	Exclude   []string `json:"exclude,omitempty" jsonschema:"Glob patterns of files or directories to skip (e.g. vendor/**)"`
+	NoIgnore  bool     `json:"no_ignore,omitempty" jsonschema:"Also scan files excluded by .gitignore, .ignore, and global git excludes"`
+	Blame     bool     `json:"blame,omitempty" jsonschema:"Set each item's author from git blame"`
+	Limit     int      `json:"limit,omitempty" jsonschema:"Maximum number of items to return (default: no limit)"`
+}
+
+// SearchSymbolsParams defines inputs for the search_symbols tool
+type SearchSymbolsParams struct {
+	Name       string   `json:"name" jsonschema:"Name defined: exact, a glob such as New*, or Type.Method for a method of a type"`
+	Directory  string   `json:"directory,omitempty" jsonschema:"Directory to search (default: the client's first root, else .)"`
+	Kind       []string `json:"kind,omitempty" jsonschema:"Only find these kinds: function, method, type, const, or var"`
+	IgnoreCase bool     `json:"ignore_case,omitempty" jsonschema:"Match the name regardless of case"`
+	Include    []string `json:"include,omitempty" jsonschema:"Only parse files whose name matches one of these globs, as search_files name takes them"`
+	Exclude    []string `json:"exclude,omitempty" jsonschema:"Glob patterns of files or directories to skip (e.g. vendor/**)"`
+	NoIgnore   bool     `json:"no_ignore,omitempty" jsonschema:"Also parse files excluded by .gitignore, .ignore, and global git excludes"`
+	Limit      int      `json:"limit,omitempty" jsonschema:"Maximum number of definitions to return (default: no limit)"`
+}
+
+// FindDefinitionParams defines inputs for the find_definition tool
+type FindDefinitionParams struct {
+	Path      string `json:"path,omitempty" jsonschema:"Go file holding the identifier; give line and column with it"`
+	Line      int    `json:"line,omitempty" jsonschema:"Line of the identifier (1-based)"`
+	Column    int    `json:"column,omitempty" jsonschema:"Column of the identifier, in bytes (1-based)"`
+	Name      string `json:"name,omitempty" jsonschema:"Instead of a position, the name of a function, method, or type, or Type.Method"`
+	Directory string `json:"directory,omitempty" jsonschema:"Directory in the Go module or workspace to look name up in (default: the client's first root, else .)"`
+}
+
+// FindDefinitionResult is the structured result of find_definition.
+type FindDefinitionResult struct {
+	Definitions []gopls.Location `json:"definitions"`
+}
+
+// ChangedFilesParams defines inputs for the changed_files tool
+type ChangedFilesParams struct {
+	Directory string `json:"directory,omitempty" jsonschema:"Only list changed files below this directory (default: .)"`
+	Against   string `json:"against,omitempty" jsonschema:"Branch or commit; list the files changed since the current branch left it, not just uncommitted ones"`
+}
+
+// ChangedFilesResult is the structured result of changed_files.
+type ChangedFilesResult struct {
+	Files []git.Change `json:"files"`
+}
+
+// ListBookmarksResult is the structured result of list_bookmarks.
+type ListBookmarksResult struct {
+	Bookmarks []bookmark.Bookmark `json:"bookmarks"`
 }
 
-// resolve helper binary path: VS_CODE_HELPER_BIN or ./vscode-helper or LookPath("vscode-helper")
-func helperBin() (string, error) {
-	if env := strings.TrimSpace(os.Getenv("VS_CODE_HELPER_BIN")); env != "" {
-		return env, nil
+// RunBookmarkParams defines inputs for the run_bookmark tool
+type RunBookmarkParams struct {
+	Name      string `json:"name" jsonschema:"Name of the bookmark, as list_bookmarks lists it"`
+	Directory string `json:"directory,omitempty" jsonschema:"Search this directory instead of the bookmark's"`
+	Limit     int    `json:"limit,omitempty" jsonschema:"Maximum number of matches to return (default: the bookmark's, else no limit)"`
+	Cursor    string `json:"cursor,omitempty" jsonschema:"Continuation cursor from a previous result's next_cursor"`
+	Stream    bool   `json:"stream,omitempty" jsonschema:"With a progress token, send matches in the _meta.matches of progress notifications instead of in the result"`
+}
+
+// ReplaceInFilesParams defines inputs for the replace_in_files tool
+type ReplaceInFilesParams struct {
+	Content       string   `json:"content" jsonschema:"Text (or regular expression with regex) to replace"`
+	Replacement   string   `json:"replacement" jsonschema:"Replacement text; with regex, $1 or ${name} expand capture groups"`
+	Directory     string   `json:"directory,omitempty" jsonschema:"Root directory to search (default: .)"`
+	Name          string   `json:"name,omitempty" jsonschema:"Only change files whose name matches these comma-separated globs"`
+	Regex         bool     `json:"regex,omitempty" jsonschema:"Treat content as a regular expression (RE2 syntax)"`
+	IgnoreCase    bool     `json:"ignore_case,omitempty" jsonschema:"Match case-insensitively"`
+	CaseSensitive bool     `json:"case_sensitive,omitempty" jsonschema:"Match case-sensitively (default is smart-case)"`
+	Exclude       []string `json:"exclude,omitempty" jsonschema:"Glob patterns of files or directories to skip"`
+	NoIgnore      bool     `json:"no_ignore,omitempty" jsonschema:"Also change files excluded by .gitignore, .ignore, and global git excludes"`
+	Backup        bool     `json:"backup,omitempty" jsonschema:"Save each original file as <file>.bak before rewriting it"`
+	Confirm       bool     `json:"confirm,omitempty" jsonschema:"Apply the changes; without it the tool only returns a diff preview"`
+}
+
+// ReplaceInFilesResult is the structured content returned by
+// replace_in_files.
+type ReplaceInFilesResult struct {
+	Applied bool                 `json:"applied"`
+	Changes []replace.FileChange `json:"changes"`
+	replace.Summary
+}
+
+// resultCache, set by -cache-ttl, answers a search_files call from the
+// results of the same search made recently, when nothing it saw has
+// changed. It is nil when results are not cached.
+var resultCache *cache.Cache
+
+// noDaemon, set by -no-daemon, keeps search_files from handing its
+// searches to a running daemon.
+var noDaemon bool
+
+// searchCached runs the search for search_files, in the daemon when one is
+// running, and through resultCache when there is one.
+func searchCached(ctx context.Context, opts search.Options, p SearchFilesParams, fn func(search.Match)) (search.Summary, error) {
+	run := func(fn func(search.Match)) (search.Summary, error) {
+		if !noDaemon {
+			if d := daemon.Detect(allowed()); d != nil {
+				return d.Search(ctx, opts, []string{opts.Dir}, fn)
+			}
+		}
+		return search.SearchContext(ctx, opts, fn)
 	}
-	// Prefer local project binary like Python server
-	local := "./vscode-helper"
-	if st, err := os.Stat(local); err == nil && !st.IsDir() {
-		return local, nil
+	if resultCache == nil {
+		return run(fn)
 	}
-	if lp, err := exec.LookPath("vscode-helper"); err == nil {
-		return lp, nil
+	wd, _ := os.Getwd()
+	var sandbox []string
+	if sb := allowed(); sb != nil {
+		sandbox = sb.Dirs()
 	}
-	return "", fmt.Errorf("vscode-helper binary not found; build it with: go build -o vscode-helper")
+	key := opts
+	key.Allow, key.Progress = nil, nil
+	// Times given relative to now are keyed as given
+	key.NewerThan, key.OlderThan = time.Time{}, time.Time{}
+	sum, _, err := resultCache.Search(cache.Key(wd, sandbox, key, p.NewerThan, p.OlderThan), []string{opts.Dir}, run, fn)
+	return sum, err
 }
 
-func runHelper(ctx context.Context, args ...string) (string, error) {
-	bin, err := helperBin()
+// searchFiles implements the search_files tool using the search package.
+func searchFiles(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[SearchFilesParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	opts, err := p.options(ctx, ss)
 	if err != nil {
-		return "", err
+		return errorResult("Error: " + err.Error()), nil
 	}
-	cmd := exec.CommandContext(ctx, bin, args...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		msg := strings.TrimSpace(stderr.String())
-		if msg == "" {
-			msg = strings.TrimSpace(stdout.String())
+	return runSearchFiles(ctx, ss, params.GetProgressToken(), p, opts)
+}
+
+// options returns the search options p describes, with the defaults of
+// the client's roots and the configuration filled in.
+func (p SearchFilesParams) options(ctx context.Context, ss *mcp.ServerSession) (search.Options, error) {
+	opts := search.Options{Dir: strings.TrimSpace(p.Directory), Regex: p.Regex, Word: p.Word, Excludes: p.Exclude, NoIgnore: p.NoIgnore, Binary: p.Binary, Encoding: p.Encoding, Archives: p.Archives, FollowSymlinks: p.FollowSymlinks, Fuzzy: p.Fuzzy, Types: p.Type, TypesNot: p.TypeNot, Sort: p.Sort, Reverse: p.Reverse}
+	if name := strings.TrimSpace(p.Name); name != "" {
+		// Comma-separated patterns, as accepted by the CLI --name flag
+		opts.Names = strings.Split(name, ",")
+	}
+	if strings.TrimSpace(p.Content) != "" {
+		opts.Contents = []string{p.Content}
+	}
+	opts.Before, opts.After = p.ContextLines, p.ContextLines
+	opts.MaxResults = p.Limit
+	dir, err := withClientRoots(ctx, ss, opts.Dir)
+	if err != nil {
+		return opts, err
+	}
+	opts.Dir = dir
+	opts, err = withConfig(opts, true)
+	if err != nil {
+		return opts, err
+	}
+	if err := p.applyFilters(&opts, time.Now()); err != nil {
+		return opts, err
+	}
+	if !p.NoFrecency {
+		if store, err := recent.Default(); err == nil {
+			// Without a history results are merely left in walk order
+			opts.Boost, _ = store.Boosts([]string{opts.Dir})
 		}
-		if msg == "" {
-			msg = err.Error()
+	}
+	if p.Cursor != "" {
+		offset, err := decodeCursor(p.Cursor)
+		if err != nil {
+			return opts, err
 		}
-		return "", fmt.Errorf(msg)
+		opts.Offset = offset
 	}
-	out := strings.TrimSpace(stdout.String())
-	return out, nil
+	switch {
+	case p.IgnoreCase && p.CaseSensitive:
+		return opts, fmt.Errorf("'ignore_case' and 'case_sensitive' cannot both be set")
+	case p.IgnoreCase:
+		opts.Case = search.IgnoreCase
+	case p.CaseSensitive:
+		opts.Case = search.CaseSensitive
+	}
+	return opts, nil
 }
 
-// searchFiles implements the ToolHandlerFor signature by delegating to the helper binary.
-func searchFiles(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[SearchFilesParams]) (*mcp.CallToolResultFor[any], error) {
+// runSearchFiles runs the search for a search_files or run_bookmark call
+// and builds its result, reporting progress against token when the client
+// gave one.
+func runSearchFiles(ctx context.Context, ss *mcp.ServerSession, token any, p SearchFilesParams, opts search.Options) (*mcp.CallToolResultFor[any], error) {
+	var out strings.Builder
+	matches := []search.Match{}
+	stream := p.Stream && token != nil
+	var (
+		progress search.Progress
+		streamed int
+	)
+	// notify sends a progress notification. When streaming it carries the
+	// matches found since the previous one, and counts them along with the
+	// files scanned since batches can be sent between file counts
+	notify := func() {
+		pn := &mcp.ProgressNotificationParams{
+			ProgressToken: token,
+			Progress:      float64(progress.Files),
+			Message:       fmt.Sprintf("%d files scanned, %d matches", progress.Files, progress.Matches),
+		}
+		if stream {
+			if len(matches) > 0 {
+				pn.Meta = mcp.Meta{"matches": matches}
+				streamed += len(matches)
+				matches = []search.Match{}
+			}
+			pn.Progress = float64(progress.Files + streamed)
+			pn.Message = fmt.Sprintf("%d matches sent, %d files scanned", streamed, progress.Files)
+		}
+		_ = ss.NotifyProgress(ctx, pn)
+	}
+	if token != nil {
+		// Keep clients informed while a big walk runs
+		opts.Progress = func(pr search.Progress) {
+			progress = pr
+			notify()
+		}
+	}
+
+	start := time.Now()
+	sum, err := searchCached(ctx, opts, p, func(m search.Match) {
+		if stream {
+			if matches = append(matches, m); len(matches) >= streamBatchSize {
+				notify()
+			}
+			return
+		}
+		if p.ContextLines > 0 && len(matches) > 0 && search.NeedsSeparator(matches[len(matches)-1], m) {
+			out.WriteString("--\n")
+		}
+		matches = append(matches, m)
+		out.WriteString(m.Format(p.Regex))
+		out.WriteByte('\n')
+	})
+	recordSearch(time.Since(start), sum.Scanned)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("search.directory", opts.Dir),
+		attribute.Int("search.results", len(matches)+streamed),
+	)
+	if stream && len(matches) > 0 {
+		notify()
+	}
+	if err != nil {
+		return errorResult("Error searching: " + err.Error()), nil
+	}
+	text := strings.TrimSpace(out.String())
+	switch {
+	case stream:
+		text = fmt.Sprintf("(%d matches streamed in progress notifications)", streamed)
+	case text == "":
+		text = "(no matches)"
+	}
+	result := SearchFilesResult{Matches: matches, BinarySkipped: sum.BinarySkipped, LargeSkipped: sum.LargeSkipped, ArchivesLimited: sum.ArchivesLimited, Cancelled: sum.Cancelled, Streamed: streamed}
+	if sum.Truncated {
+		result.NextCursor = encodeCursor(opts.Offset + opts.MaxResults)
+		text += fmt.Sprintf("\n(more results: pass cursor %q to continue)", result.NextCursor)
+	}
+	if sum.Cancelled {
+		text += "\n(cancelled: results are partial)"
+	}
+	res := textResult(text)
+	res.StructuredContent = result
+	if p.WarnOver > 0 && sum.Files > p.WarnOver {
+		res.Meta = mcp.Meta{"warning": fmt.Sprintf("%d matches exceed the warn_over threshold of %d; consider a more specific name or content", sum.Files, p.WarnOver)}
+	}
+	return res, nil
+}
+
+// listBookmarks implements the list_bookmarks tool using the bookmark
+// package.
+func listBookmarks(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[struct{}]) (*mcp.CallToolResultFor[any], error) {
+	store, err := bookmark.Default()
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	bookmarks, err := store.List()
+	if err != nil {
+		return errorResult("Error reading bookmarks: " + err.Error()), nil
+	}
+	var out strings.Builder
+	for _, b := range bookmarks {
+		fmt.Fprintf(&out, "%s\t%s\t%s\n", b.Name, b.Query.Dir, b.Description)
+	}
+	text := strings.TrimSpace(out.String())
+	if text == "" {
+		text = "(no bookmarks)"
+	}
+	if bookmarks == nil {
+		bookmarks = []bookmark.Bookmark{}
+	}
+	res := textResult(text)
+	res.StructuredContent = ListBookmarksResult{Bookmarks: bookmarks}
+	return res, nil
+}
+
+// runBookmark implements the run_bookmark tool: it runs the saved search
+// as search_files would, returning the same result.
+func runBookmark(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[RunBookmarkParams]) (*mcp.CallToolResultFor[any], error) {
+	a := params.Arguments
+	if strings.TrimSpace(a.Name) == "" {
+		return errorResult("Error: 'name' is required"), nil
+	}
+	store, err := bookmark.Default()
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	b, err := store.Get(strings.TrimSpace(a.Name))
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	q := b.Query
+	p := SearchFilesParams{
+		Name:           strings.Join(q.Name, ","),
+		Directory:      q.Dir,
+		Fuzzy:          q.Fuzzy,
+		Regex:          q.Regex,
+		Word:           q.Word,
+		IgnoreCase:     q.IgnoreCase,
+		CaseSensitive:  q.CaseSensitive,
+		ContextLines:   max(q.Before, q.After),
+		Exclude:        q.Exclude,
+		Binary:         q.Binary,
+		FollowSymlinks: q.FollowSymlinks,
+		NoIgnore:       q.NoIgnore,
+		Type:           q.Type,
+		TypeNot:        q.TypeNot,
+		MinSize:        q.MinSize,
+		MaxSize:        q.MaxSize,
+		NewerThan:      q.NewerThan,
+		OlderThan:      q.OlderThan,
+		Archives:       q.Archives,
+		Sort:           q.Sort,
+		Reverse:        q.Reverse,
+		Limit:          q.MaxResults,
+		Cursor:         a.Cursor,
+		Stream:         a.Stream,
+	}
+	if d := strings.TrimSpace(a.Directory); d != "" {
+		p.Directory = d
+	}
+	if a.Limit > 0 {
+		p.Limit = a.Limit
+	}
+	opts, err := p.options(ctx, ss)
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	// Unlike search_files, a bookmark can hold several content terms, and
+	// different context before and after
+	opts.Contents, opts.NotContents, opts.AllContents = q.Content, q.NotContent, q.All
+	opts.Before, opts.After = q.Before, q.After
+	return runSearchFiles(ctx, ss, params.GetProgressToken(), p, opts)
+}
+
+// replaceInFiles implements the replace_in_files tool using the replace
+// package. Unless confirm is set it is a dry run returning the diff.
+func replaceInFiles(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[ReplaceInFilesParams]) (*mcp.CallToolResultFor[any], error) {
 	p := params.Arguments
-	var args []string
-	args = append(args, "search")
-	if strings.TrimSpace(p.Name) != "" {
-		args = append(args, "--name", p.Name)
+	if p.Content == "" {
+		return errorResult("Error: 'content' is required"), nil
 	}
-	if strings.TrimSpace(p.Content) != "" {
-		args = append(args, "--content", p.Content)
+	dir, err := withClientRoots(ctx, ss, strings.TrimSpace(p.Directory))
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	opts := replace.Options{
+		Search: search.Options{
+			Dir:      dir,
+			Contents: []string{p.Content},
+			Regex:    p.Regex,
+			Excludes: p.Exclude,
+			NoIgnore: p.NoIgnore,
+		},
+		Replacement: p.Replacement,
+		DryRun:      !p.Confirm,
+		Backup:      p.Backup,
+	}
+	if opts.Search, err = withConfig(opts.Search, false); err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	if name := strings.TrimSpace(p.Name); name != "" {
+		opts.Search.Names = strings.Split(name, ",")
 	}
-	if dir := strings.TrimSpace(p.Directory); dir != "" && dir != "." {
-		args = append(args, "--dir", dir)
+	switch {
+	case p.IgnoreCase && p.CaseSensitive:
+		return errorResult("Error: 'ignore_case' and 'case_sensitive' cannot both be set"), nil
+	case p.IgnoreCase:
+		opts.Search.Case = search.IgnoreCase
+	case p.CaseSensitive:
+		opts.Search.Case = search.CaseSensitive
 	}
-	out, err := runHelper(ctx, args...)
+
+	var out strings.Builder
+	changes := []replace.FileChange{}
+	sum, err := replace.RunContext(ctx, opts, func(c replace.FileChange) {
+		changes = append(changes, c)
+		out.WriteString(c.Diff)
+	})
 	if err != nil {
-		return textResult("Error searching: " + err.Error()), nil
+		return errorResult("Error replacing: " + err.Error()), nil
+	}
+	if p.Confirm {
+		fmt.Fprintf(&out, "Replaced %d occurrences in %d files", sum.Replacements, sum.Files)
+	} else {
+		fmt.Fprintf(&out, "Dry run: would replace %d occurrences in %d files; call again with confirm: true to apply", sum.Replacements, sum.Files)
 	}
-	if out == "" {
-		out = "(no matches)"
+	if len(sum.Skipped) > 0 {
+		fmt.Fprintf(&out, "\nSkipped %d files that are not UTF-8: %s", len(sum.Skipped), strings.Join(sum.Skipped, ", "))
 	}
-	return textResult(out), nil
+	res := textResult(out.String())
+	res.StructuredContent = ReplaceInFilesResult{Applied: p.Confirm, Changes: changes, Summary: sum}
+	return res, nil
 }
 
-// openFile delegates to helper binary 'open' command exactly like Python server
+// openFile implements the open_file tool using the opener package.
 func openFile(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[OpenFileParams]) (*mcp.CallToolResultFor[any], error) {
 	p := params.Arguments
 	if strings.TrimSpace(p.Path) == "" {
-		return textResult("Error: 'path' is required"), nil
+		return errorResult("Error: 'path' is required"), nil
+	}
+	abs, err := opener.OpenContext(ctx, p.Path, opener.Options{Dir: p.OpenDir, Line: p.Line, Column: p.Column, Workspace: p.Workspace, NewWindow: p.NewWindow, ReuseWindow: p.ReuseWindow, Wait: p.Wait, Remote: p.Remote, Editor: editor, Check: allowed().Check, Record: recordOpen})
+	if err != nil {
+		return errorResult("Error opening: " + err.Error()), nil
+	}
+	text := "Opened in VS Code: " + abs
+	if p.Wait {
+		text = "Closed in VS Code: " + abs
+	}
+	res := textResult(text)
+	res.StructuredContent = OpenFileResult{OpenedPath: abs, Closed: p.Wait}
+	return res, nil
+}
+
+// changedFiles implements the changed_files tool using the git package.
+func changedFiles(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[ChangedFilesParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	dir, err := withClientRoots(ctx, ss, strings.TrimSpace(p.Directory))
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	if dir == "" {
+		dir = "."
+	}
+	if err := allowed().Check(dir); err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	changes, err := git.Changed(ctx, dir, strings.TrimSpace(p.Against))
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	var out strings.Builder
+	for _, c := range changes {
+		fmt.Fprintf(&out, "%s\t%s\n", c.Status, c.Path)
+	}
+	text := strings.TrimSpace(out.String())
+	if text == "" {
+		text = "(no changed files)"
+	}
+	if changes == nil {
+		changes = []git.Change{}
+	}
+	res := textResult(text)
+	res.StructuredContent = ChangedFilesResult{Files: changes}
+	return res, nil
+}
+
+// openAtRevision implements the open_at_revision tool: it opens a
+// read-only copy of the file as of a commit, or its blame.
+func openAtRevision(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[OpenAtRevisionParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	if strings.TrimSpace(p.Path) == "" {
+		return errorResult("Error: 'path' is required"), nil
 	}
-	var args []string
-	args = append(args, "open")
-	if p.OpenDir {
-		args = append(args, "--dir")
+	if err := allowed().Check(p.Path); err != nil {
+		return errorResult("Error: " + err.Error()), nil
 	}
-	// Pass the path as provided; the helper will resolve/validate and call 'code'
-	args = append(args, p.Path)
-	out, err := runHelper(ctx, args...)
+	rev, err := git.ExtractRevision(ctx, p.Path, strings.TrimSpace(p.Rev), p.Blame)
 	if err != nil {
-		return textResult("Error opening: " + err.Error()), nil
+		return errorResult("Error: " + err.Error()), nil
 	}
-	if out == "" {
-		// CLI prints confirmation; but ensure some response
-		abs, _ := filepath.Abs(p.Path)
-		out = "Opened: " + abs
+	// The copy lies outside any allowed directory; the file it was taken
+	// from has been checked
+	if _, err := opener.OpenContext(ctx, rev.Path, opener.Options{Line: p.Line, Editor: editor}); err != nil {
+		return errorResult("Error opening: " + err.Error()), nil
 	}
-	return textResult(out), nil
+	at := "the work tree"
+	if rev.Commit != "" {
+		at = rev.Commit
+	}
+	what := rev.Source
+	if rev.Blame {
+		what = "blame of " + what
+	}
+	res := textResult(fmt.Sprintf("Opened in VS Code: %s (%s at %s)", rev.Path, what, at))
+	res.StructuredContent = rev
+	return res, nil
+}
+
+// recentFiles implements the recent_files tool using the recent package.
+func recentFiles(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[RecentFilesParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	limit := p.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	store, err := recent.Default()
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	entries, err := store.Find(strings.TrimSpace(p.Query), limit, allowed().Allows)
+	if err != nil {
+		return errorResult("Error reading the recent files history: " + err.Error()), nil
+	}
+	var out strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&out, "%s\t%d\t%s\n", e.Last.Format(time.RFC3339), e.Count, e.Path)
+	}
+	text := strings.TrimSpace(out.String())
+	if text == "" {
+		text = "(no recent files)"
+	}
+	if entries == nil {
+		entries = []recent.Entry{}
+	}
+	res := textResult(text)
+	res.StructuredContent = RecentFilesResult{Files: entries}
+	return res, nil
+}
+
+// recordOpen adds the absolute path to the recent files history shared
+// with the CLI. An open that succeeded is not failed for want of one.
+func recordOpen(path string) {
+	store, err := recent.Default()
+	if err == nil {
+		err = store.Record(path)
+	}
+	if err != nil {
+		log.Printf("Warning: unable to record the opened file: %v", err)
+	}
+}
+
+// getFileInfo implements the get_file_info tool using the files package.
+func getFileInfo(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[GetFileInfoParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	if strings.TrimSpace(p.Path) == "" {
+		return errorResult("Error: 'path' is required"), nil
+	}
+	if err := allowed().Check(p.Path); err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	fi, err := files.Info(p.Path)
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	text := fmt.Sprintf("%s: %s, %d bytes, %s, modified %s", fi.Path, fi.Type, fi.Size, fi.Mode, fi.ModTime.Format(time.RFC3339))
+	if fi.Language != "" {
+		text += ", language " + fi.Language
+	}
+	switch {
+	case fi.Text != nil && fi.Text.Binary:
+		text += ", binary"
+	case fi.Text != nil:
+		text += fmt.Sprintf(", %d lines", fi.Lines)
+	}
+	if fi.GitTracked != nil {
+		text += fmt.Sprintf(", git tracked: %t", *fi.GitTracked)
+	}
+	out := textResult(text)
+	out.StructuredContent = fi
+	return out, nil
+}
+
+// writeFile implements the write_file tool using the files package.
+func writeFile(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[WriteFileParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	if strings.TrimSpace(p.Path) == "" {
+		return errorResult("Error: 'path' is required"), nil
+	}
+	if err := allowed().Check(p.Path); err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	res, err := files.Write(p.Path, []byte(p.Content), files.WriteOptions{Overwrite: p.Overwrite})
+	if err != nil {
+		return errorResult("Error writing: " + err.Error()), nil
+	}
+	verb := "Wrote"
+	if res.Created {
+		verb = "Created"
+	}
+	text := fmt.Sprintf("%s %s (%d bytes)", verb, res.Path, res.Bytes)
+	if p.Open {
+		if _, err := opener.OpenContext(ctx, res.Path, opener.Options{Editor: editor, Check: allowed().Check, Record: recordOpen}); err != nil {
+			text += "\nError opening: " + err.Error()
+		} else {
+			text += "\nOpened in VS Code: " + res.Path
+		}
+	}
+	out := textResult(text)
+	out.StructuredContent = res
+	return out, nil
+}
+
+// readFile implements the read_file tool using the files package.
+func readFile(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[ReadFileParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	if strings.TrimSpace(p.Path) == "" {
+		return errorResult("Error: 'path' is required"), nil
+	}
+	if err := allowed().Check(p.Path); err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	res, err := files.Read(p.Path, files.ReadOptions{
+		StartLine: p.StartLine,
+		EndLine:   p.EndLine,
+		MaxBytes:  p.MaxBytes,
+	})
+	if err != nil {
+		return errorResult("Error reading: " + err.Error()), nil
+	}
+	text := res.Content
+	if res.Truncated {
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		text += fmt.Sprintf("[truncated after line %d; request a later start_line to continue]", res.EndLine)
+	}
+	out := textResult(text)
+	out.StructuredContent = res
+	return out, nil
+}
+
+// directoryTree implements the directory_tree tool using the tree package.
+func directoryTree(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[DirectoryTreeParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	depth := 3
+	if p.Depth != nil {
+		depth = *p.Depth
+	}
+	if depth < 0 || p.MaxEntries < 0 {
+		return errorResult("Error: 'depth' and 'max_entries' must not be negative"), nil
+	}
+	dir, err := withClientRoots(ctx, ss, strings.TrimSpace(p.Directory))
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	opts, err := withConfig(search.Options{Dir: dir, Names: p.Include, Excludes: p.Exclude, NoIgnore: p.NoIgnore}, false)
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	root, err := tree.Build(ctx, tree.Options{Search: opts, Depth: depth, MaxEntries: p.MaxEntries})
+	if err != nil {
+		return errorResult("Error building tree: " + err.Error()), nil
+	}
+	var out strings.Builder
+	writeTree(&out, root, "")
+	res := textResult(fmt.Sprintf("%s (directories: %d, files: %d)\n%s", root.Name, root.Dirs, root.Files, strings.TrimRight(out.String(), "\n")))
+	res.StructuredContent = root
+	return res, nil
+}
+
+// writeTree writes the entries of n to w, one per line indented by depth,
+// with each directory's file count.
+func writeTree(w *strings.Builder, n *tree.Node, indent string) {
+	for _, c := range n.Children {
+		if c.Type == "directory" {
+			fmt.Fprintf(w, "%s%s/ (files: %d)\n", indent, c.Name, c.Files)
+			writeTree(w, c, indent+"  ")
+		} else {
+			fmt.Fprintf(w, "%s%s\n", indent, c.Name)
+		}
+	}
+	if n.Omitted > 0 {
+		fmt.Fprintf(w, "%s[%d more]\n", indent, n.Omitted)
+	}
+}
+
+// projectStats implements the project_stats tool using the stats package.
+func projectStats(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[ProjectStatsParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	if p.Top < 0 {
+		return errorResult("Error: 'top' must not be negative"), nil
+	}
+	dir, err := withClientRoots(ctx, ss, strings.TrimSpace(p.Directory))
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	opts, err := withConfig(search.Options{Dir: dir, Names: p.Include, Excludes: p.Exclude, NoIgnore: p.NoIgnore}, false)
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	s, err := stats.Collect(ctx, stats.Options{Search: opts, Top: p.Top})
+	if err != nil {
+		return errorResult("Error collecting stats: " + err.Error()), nil
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s (files: %d, lines: %d, bytes: %d)\n", s.Dir, s.Files, s.Lines, s.Bytes)
+	for _, l := range s.Languages {
+		fmt.Fprintf(&out, "%s: files %d, lines %d, bytes %d\n", l.Name, l.Files, l.Lines, l.Bytes)
+	}
+	out.WriteString("Largest:\n")
+	for _, f := range s.Largest {
+		fmt.Fprintf(&out, "  %s (%d bytes)\n", f.Path, f.Size)
+	}
+	out.WriteString("Newest:\n")
+	for _, f := range s.Newest {
+		fmt.Fprintf(&out, "  %s (%s)\n", f.Path, f.ModTime.Format(time.RFC3339))
+	}
+	res := textResult(strings.TrimRight(out.String(), "\n"))
+	res.StructuredContent = s
+	return res, nil
+}
+
+// listTodos implements the list_todos tool using the todos package.
+func listTodos(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[ListTodosParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	if p.Limit < 0 {
+		return errorResult("Error: 'limit' must not be negative"), nil
+	}
+	dir, err := withClientRoots(ctx, ss, strings.TrimSpace(p.Directory))
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	opts, err := withConfig(search.Options{Dir: dir, Names: p.Include, Excludes: p.Exclude, NoIgnore: p.NoIgnore, MaxResults: p.Limit}, true)
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	tags := p.Tags
+	if len(tags) == 0 {
+		c, err := cfg().ForDir(opts.Dir)
+		if err != nil {
+			return errorResult("Error: " + err.Error()), nil
+		}
+		tags = c.Todos.Tags
+	}
+	res, err := todos.Scan(ctx, todos.Options{Search: opts, Tags: tags, Blame: p.Blame})
+	if err != nil {
+		return errorResult("Error scanning for markers: " + err.Error()), nil
+	}
+	if len(res.Items) == 0 {
+		out := textResult("No markers found")
+		out.StructuredContent = res
+		return out, nil
+	}
+	var b strings.Builder
+	for _, it := range res.Items {
+		tag := it.Tag
+		if it.Owner != "" {
+			tag += "(" + it.Owner + ")"
+		}
+		fmt.Fprintf(&b, "%s:%d: %s %s", it.Path, it.Line, tag, it.Text)
+		if it.Author != "" {
+			fmt.Fprintf(&b, " (author: %s)", it.Author)
+		}
+		b.WriteString("\n")
+	}
+	if res.Truncated {
+		fmt.Fprintf(&b, "[truncated at %d items]\n", len(res.Items))
+	}
+	out := textResult(strings.TrimRight(b.String(), "\n"))
+	out.StructuredContent = res
+	return out, nil
+}
+
+// searchSymbols implements the search_symbols tool using the symbols
+// package.
+func searchSymbols(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[SearchSymbolsParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	if strings.TrimSpace(p.Name) == "" {
+		return errorResult("Error: 'name' is required"), nil
+	}
+	if p.Limit < 0 {
+		return errorResult("Error: 'limit' must not be negative"), nil
+	}
+	dir, err := withClientRoots(ctx, ss, strings.TrimSpace(p.Directory))
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	opts, err := withConfig(search.Options{Dir: dir, Names: p.Include, Excludes: p.Exclude, NoIgnore: p.NoIgnore, MaxResults: p.Limit}, true)
+	if err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	res, err := symbols.Find(ctx, symbols.Options{Search: opts, Name: p.Name, IgnoreCase: p.IgnoreCase, Kinds: p.Kind, Limit: opts.MaxResults})
+	if err != nil {
+		return errorResult("Error searching symbols: " + err.Error()), nil
+	}
+	if len(res.Symbols) == 0 {
+		out := textResult("No definitions found")
+		out.StructuredContent = res
+		return out, nil
+	}
+	var b strings.Builder
+	for _, s := range res.Symbols {
+		fmt.Fprintf(&b, "%s:%d: %s %s\n", s.Path, s.Line, s.Kind, s.Text)
+	}
+	if res.Truncated {
+		fmt.Fprintf(&b, "[truncated at %d definitions]\n", len(res.Symbols))
+	}
+	out := textResult(strings.TrimRight(b.String(), "\n"))
+	out.StructuredContent = res
+	return out, nil
+}
+
+// findDefinition implements the find_definition tool using gopls.
+func findDefinition(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[FindDefinitionParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	path, name := strings.TrimSpace(p.Path), strings.TrimSpace(p.Name)
+	if (path == "") == (name == "") {
+		return errorResult("Error: give either 'path' with 'line' and 'column', or 'name'"), nil
+	}
+	res := FindDefinitionResult{Definitions: []gopls.Location{}}
+	if path != "" {
+		if err := allowed().Check(path); err != nil {
+			return errorResult("Error: " + err.Error()), nil
+		}
+		loc, err := gopls.Definition(ctx, path, p.Line, p.Column)
+		if err != nil {
+			return errorResult("Error finding definition: " + err.Error()), nil
+		}
+		res.Definitions = append(res.Definitions, *loc)
+	} else {
+		dir, err := withClientRoots(ctx, ss, strings.TrimSpace(p.Directory))
+		if err != nil {
+			return errorResult("Error: " + err.Error()), nil
+		}
+		if dir == "" {
+			dir = "."
+		}
+		if err := allowed().Check(dir); err != nil {
+			return errorResult("Error: " + err.Error()), nil
+		}
+		locs, err := gopls.Symbols(ctx, dir, name)
+		if err != nil {
+			return errorResult("Error finding definition: " + err.Error()), nil
+		}
+		for _, l := range locs {
+			if allowed().Allows(l.Path) {
+				res.Definitions = append(res.Definitions, l)
+			}
+		}
+	}
+	if len(res.Definitions) == 0 {
+		out := textResult("No definition found")
+		out.StructuredContent = res
+		return out, nil
+	}
+	var b strings.Builder
+	for _, l := range res.Definitions {
+		fmt.Fprintf(&b, "%s:%d:%d", l.Path, l.Line, l.Column)
+		if l.Name != "" {
+			fmt.Fprintf(&b, ": %s %s", l.Kind, l.Name)
+		}
+		b.WriteString("\n")
+		if l.Description != "" {
+			b.WriteString(l.Description + "\n")
+		}
+	}
+	out := textResult(strings.TrimRight(b.String(), "\n"))
+	out.StructuredContent = res
+	return out, nil
+}
+
+// listDirectory implements the list_directory tool using the files package.
+func listDirectory(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[ListDirectoryParams]) (*mcp.CallToolResultFor[any], error) {
+	p := params.Arguments
+	dir := strings.TrimSpace(p.Path)
+	if dir == "" {
+		dir = "."
+	}
+	if err := allowed().Check(dir); err != nil {
+		return errorResult("Error: " + err.Error()), nil
+	}
+	res, err := files.List(dir, files.ListOptions{Depth: p.Depth, MaxEntries: p.MaxEntries})
+	if err != nil {
+		return errorResult("Error listing: " + err.Error()), nil
+	}
+	var out strings.Builder
+	for _, e := range res.Entries {
+		name := e.Path
+		if e.Type == "directory" {
+			name += "/"
+		}
+		fmt.Fprintf(&out, "%s\t%s\t%d\t%s\n", name, e.Type, e.Size, e.ModTime.Format(time.RFC3339))
+	}
+	if res.Truncated {
+		fmt.Fprintf(&out, "[truncated at %d entries]\n", len(res.Entries))
+	}
+	text := strings.TrimSpace(out.String())
+	if text == "" {
+		text = "(empty directory)"
+	}
+	result := textResult(text)
+	result.StructuredContent = res
+	return result, nil
 }
 
 func textResult(s string) *mcp.CallToolResultFor[any] {
@@ -130,42 +1194,222 @@ func textResult(s string) *mcp.CallToolResultFor[any] {
 	}
 }
 
-// createServer constructs the MCP server and registers tools.
+// errorResult is a textResult marked as an error, which tells clients not
+// to expect structuredContent.
+func errorResult(s string) *mcp.CallToolResultFor[any] {
+	res := textResult(s)
+	res.IsError = true
+	return res
+}
+
+// readHints annotates a tool that only reads files. No tool reaches
+// beyond the local machine, so none is open-world.
+func readHints(title string) *mcp.ToolAnnotations {
+	return &mcp.ToolAnnotations{Title: title, ReadOnlyHint: true, IdempotentHint: true, OpenWorldHint: new(bool)}
+}
+
+// writeHints annotates a tool with side effects: destructive when it can
+// change or replace existing files, idempotent when repeating a call has
+// no further effect.
+func writeHints(title string, destructive, idempotent bool) *mcp.ToolAnnotations {
+	return &mcp.ToolAnnotations{Title: title, DestructiveHint: &destructive, IdempotentHint: idempotent, OpenWorldHint: new(bool)}
+}
+
+// readOnly is set by -read-only: only tools that neither change files nor
+// drive the editor are registered, so the server can be offered to agents
+// that are not trusted with more.
+var readOnly bool
+
+// createServer constructs the MCP server and registers tools and resources.
 func createServer() *mcp.Server {
 	server := mcp.NewServer(impl, nil)
-	mcp.AddTool(server, &mcp.Tool{Name: "search_files", Description: "Search files by name and/or content starting at a directory."}, searchFiles)
-	mcp.AddTool(server, &mcp.Tool{Name: "open_file", Description: "Open a file or directory in VS Code (uses 'code' CLI)."}, openFile)
+	addTool(server, &mcp.Tool{Name: "search_files", Description: "Search files by name and/or content starting at a directory.", Annotations: readHints("Search files"), OutputSchema: outputSchema[SearchFilesResult]()}, searchFiles)
+	addTool(server, &mcp.Tool{Name: "search_symbols", Description: "Find where functions, methods, types, constants, and variables of a name are defined, by parsing source files (Go), returning path:line for each. Unlike a content search it skips the places the name is only used.", Annotations: readHints("Search symbols"), OutputSchema: outputSchema[symbols.Result]()}, searchSymbols)
+	addTool(server, &mcp.Tool{Name: "find_definition", Description: "Find where a Go identifier is defined, from its position in a file or by name, using gopls (which must be installed). Returns path, line, and column, and the declaration for a position; open the result with open_file.", Annotations: readHints("Find definition"), OutputSchema: outputSchema[FindDefinitionResult]()}, findDefinition)
+	addTool(server, &mcp.Tool{Name: "read_file", Description: "Read a file's contents, optionally limited to a line range and byte budget.", Annotations: readHints("Read file"), OutputSchema: outputSchema[files.ReadResult]()}, readFile)
+	addTool(server, &mcp.Tool{Name: "list_directory", Description: "List entries under a directory with type, size, and modification time, optionally recursing to a given depth.", Annotations: readHints("List directory"), OutputSchema: outputSchema[files.ListResult]()}, listDirectory)
+	addTool(server, &mcp.Tool{Name: "directory_tree", Description: "Get the directories and files below a directory as a nested tree, down to a depth, with how many files each directory holds at any depth. A cheap overview of a project's structure before targeted searches.", Annotations: readHints("Directory tree"), OutputSchema: outputSchema[tree.Node]()}, directoryTree)
+	addTool(server, &mcp.Tool{Name: "project_stats", Description: "Count the files and lines below a directory in all and per language, and list the largest and most recently modified files. A quick sense of a project's size and makeup.", Annotations: readHints("Project stats"), OutputSchema: outputSchema[stats.Stats]()}, projectStats)
+	addTool(server, &mcp.Tool{Name: "list_todos", Description: "List the TODO, FIXME, and HACK markers, or other tags, in the files below a directory, with the text after each, the owner of markers written TODO(name), and optionally the line's author from git blame.", Annotations: readHints("List TODOs"), OutputSchema: outputSchema[todos.Result]()}, listTodos)
+	addTool(server, &mcp.Tool{Name: "get_file_info", Description: "Get metadata for a path: type, size, mode, mtime, symlink target, detected language, line count, text characteristics, and git-tracked status. Useful to decide whether a file is worth reading in full.", Annotations: readHints("Get file info"), OutputSchema: outputSchema[files.FileInfo]()}, getFileInfo)
+	addTool(server, &mcp.Tool{Name: "recent_files", Description: "List the files recently opened in VS Code through this server or the CLI, most recent first, with how often each was opened; optionally filtered by a fuzzy query. Use it to find a file opened earlier.", Annotations: readHints("Recent files"), OutputSchema: outputSchema[RecentFilesResult]()}, recentFiles)
+	addTool(server, &mcp.Tool{Name: "list_bookmarks", Description: "List the searches saved as bookmarks with the CLI's bookmark add, with the directory each searches and what it is for. Run one with run_bookmark.", Annotations: readHints("List bookmarks"), OutputSchema: outputSchema[ListBookmarksResult]()}, listBookmarks)
+	addTool(server, &mcp.Tool{Name: "run_bookmark", Description: "Run a search saved as a bookmark and return its matches as search_files does.", Annotations: readHints("Run bookmark"), OutputSchema: outputSchema[SearchFilesResult]()}, runBookmark)
+	addTool(server, &mcp.Tool{Name: "changed_files", Description: "List the files changed in the git work tree (staged, unstaged, and untracked), or since the current branch left another branch or commit, with their status. Most tasks only concern these files.", Annotations: readHints("Changed files"), OutputSchema: outputSchema[ChangedFilesResult]()}, changedFiles)
+	addResources(server)
+	addPrompts(server)
+	if readOnly {
+		return server
+	}
+	addTool(server, &mcp.Tool{Name: "replace_in_files", Description: "Replace text (literal or regex) across files. Returns a diff preview unless confirm is true, in which case the files are rewritten.", Annotations: writeHints("Replace in files", true, false), OutputSchema: outputSchema[ReplaceInFilesResult]()}, replaceInFiles)
+	addTool(server, &mcp.Tool{Name: "write_file", Description: "Create a file with the given content, creating parent directories; refuses to replace an existing file unless overwrite is true. Optionally opens it in VS Code.", Annotations: writeHints("Write file", true, true), OutputSchema: outputSchema[files.WriteResult]()}, writeFile)
+	addTool(server, &mcp.Tool{Name: "open_at_revision", Description: "Open a read-only copy of a file as of a git commit or branch, or its git blame, in VS Code, to review an earlier version.", Annotations: writeHints("Open at revision", false, true), OutputSchema: outputSchema[git.Revision]()}, openAtRevision)
+	addTool(server, &mcp.Tool{Name: "open_file", Description: "Open a file or directory in VS Code (uses the 'code' CLI unless the server was started with -editor).", Annotations: writeHints("Open in editor", false, true), OutputSchema: outputSchema[OpenFileResult]()}, openFile)
 	return server
 }
 
 func main() {
 	// Flags to choose transport and address/path for HTTP mode
 	httpMode := flag.Bool("http", false, "Serve over Streamable HTTP instead of stdio")
+	sseMode := flag.Bool("sse", false, "Also serve the legacy HTTP+SSE transport at "+ssePath+", for clients that predate Streamable HTTP; implies -http")
 	addr := flag.String("addr", ":8081", "HTTP listen address (host:port)")
 	mcpPath := flag.String("path", "/mcp", "HTTP path to mount the MCP handler")
+	editorSpec := flag.String("editor", "", "Editor for open_file: "+strings.Join(opener.Editors, ", ")+", or a command template (default code)")
+	configPath := flag.String("config", "", "Config file with default settings (default ~/.config/vscode-helper/config.yaml)")
+	authToken := flag.String("auth-token", "", "Bearer token required on HTTP requests to the MCP endpoint (default $"+authTokenEnv+")")
+	tokensFile := flag.String("auth-tokens-file", "", "File of accepted bearer tokens, one per line, for HTTP mode")
+	oidcIssuer := flag.String("oidc-issuer", "", "Also accept bearer tokens this OpenID Connect provider issues (its issuer URL), for HTTP mode; requires -oidc-audience")
+	oidcAudience := flag.String("oidc-audience", "", "Audience (aud claim) an -oidc-issuer token must be issued for, typically this server's client ID")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file; serves HTTPS in HTTP mode (with -tls-key)")
+	tlsKey := flag.String("tls-key", "", "TLS private key file for -tls-cert")
+	tlsSelfSigned := flag.Bool("tls-self-signed", false, "Serve HTTPS with a generated self-signed certificate for localhost")
+	flag.BoolVar(&readOnly, "read-only", false, "Register only the tools that do not change files or open the editor (search_files, search_symbols, find_definition, read_file, list_directory, directory_tree, project_stats, list_todos, get_file_info, changed_files, recent_files, list_bookmarks, run_bookmark)")
+	flag.Var(toolTimeouts, "tool-timeout", "How long a tool call may run: DURATION for every tool, or TOOL=DURATION for one; repeatable, 0 for none")
+	flag.IntVar(&maxOutput, "max-output", defaultMaxOutput, "Most bytes of text a tool call returns before it is truncated; 0 for no limit")
+	maxConcurrent := flag.Int("max-concurrent", defaultMaxConcurrent, "Most tool calls run at once, across sessions; others wait. 0 for no limit")
+	flag.Float64Var(&sessionLimits.rate, "session-rate", 0, "Most tool calls a session may make per second, on average; calls over it fail. 0 for no limit")
+	flag.IntVar(&sessionLimits.burst, "session-burst", defaultSessionBurst, "Most tool calls a session may make at once under -session-rate before it is held to the rate")
+	flag.IntVar(&sessionLimits.maxRunning, "session-max-concurrent", defaultSessionMaxConcurrent, "Most tool calls one session may run at once; calls over it fail. 0 for no limit")
+	cacheTTL := flag.Duration("cache-ttl", 0, "Reuse search_files results for the same search made within this long while no directory or matching file changed (default the config's cache_ttl; 0 disables)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", defaultShutdownTimeout, "On SIGINT or SIGTERM in HTTP mode, how long to let running tool calls finish, refusing new ones, before they are cancelled")
+	eventRetention := flag.Duration("event-retention", defaultEventRetention, "How long a Streamable HTTP session with no requests or open streams keeps its events, for a client whose stream broke to resume with Last-Event-ID; then it is closed. 0 keeps sessions until their client ends them")
+	flag.BoolVar(&noDaemon, "no-daemon", false, "Run search_files searches in this process even when a vscode-helper daemon is running")
+	debugAddr := flag.String("debug-addr", "", "Serve pprof profiles and runtime stats (goroutines, indexes in memory, result cache) on this address, such as localhost:6060")
+	logPath := flag.String("log-file", "", "Write the server's log to this file instead of stderr, rotating it past -log-max-size")
+	logMaxSize := flag.Int("log-max-size", defaultLogMaxSize, "Size in MiB past which -log-file is rotated; 0 never rotates")
+	logBackups := flag.Int("log-backups", defaultLogBackups, "How many rotated -log-file files to keep")
+	auditPath := flag.String("audit-log", "", "Append a JSON line for every tool call (time, session, arguments, outcome) to this file")
+	var rootDirs, allowDirs dirList
+	var origins originList
+	flag.Var(&origins, "allowed-origin", "Origin (scheme://host[:port], or * for any) whose web pages may call the server in HTTP mode; repeatable. Requests from other origins get 403 (default the config's allowed_origins)")
+	flag.Var(&rootDirs, "root", "Project directory to expose as a resource; repeatable (default the configured dir, the allowed directories, else the working directory)")
+	flag.Var(&allowDirs, "allow-dir", "Only let tools search, read, write, and open paths inside this directory; repeatable")
+	helperSocket := flag.String("helper-socket", "", "Deprecated: the socket of a 'vscode-helper serve --socket' or daemon process to search in; use $"+daemon.EnvSocket)
+	hideFlags("helper-socket")
 	flag.Parse()
 
-	if !*httpMode {
+	// Before searches ran in-process, -helper-socket and VS_CODE_HELPER_BIN
+	// chose how the helper was reached; the socket is still searched in
+	if *helperSocket != "" {
+		log.Printf("Warning: -helper-socket is deprecated; set $%s instead", daemon.EnvSocket)
+		os.Setenv(daemon.EnvSocket, *helperSocket)
+	}
+	if os.Getenv("VS_CODE_HELPER_BIN") != "" {
+		log.Print("Warning: VS_CODE_HELPER_BIN is ignored; the server no longer runs the vscode-helper binary")
+	}
+
+	// Over stdio, stdout carries the protocol: keep it for the transport
+	// and send anything else written to it, such as the output of a
+	// subprocess, to stderr
+	var stdio *mcp.StdioTransport
+	if !*httpMode && !*sseMode {
+		stdio = mcp.NewStdioTransport()
+		os.Stdout = os.Stderr
+	}
+	if *logMaxSize < 0 || *logBackups < 0 {
+		log.Fatal("-log-max-size and -log-backups must not be negative")
+	}
+	closeLog, err := setupLogging(*logPath, *logMaxSize, *logBackups)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeLog()
+
+	// The tokens matter in HTTP mode only; others should not fail on them
+	src := sources{configPath: *configPath, allowDirs: allowDirs, rootDirs: rootDirs}
+	if *httpMode || *sseMode {
+		src.authToken, src.tokensFile = *authToken, *tokensFile
+	}
+	if err := loadSettings(src); err != nil {
+		log.Fatal(err)
+	}
+	c := cfg()
+	if *editorSpec == "" {
+		*editorSpec = c.Editor
+	}
+	if editor, err = opener.NewEditor(*editorSpec); err != nil {
+		log.Fatal(err)
+	}
+	if *auditPath == "" {
+		*auditPath = c.AuditLog
+	}
+	if *auditPath != "" {
+		if auditLog, err = audit.Open(*auditPath); err != nil {
+			log.Fatal(err)
+		}
+		defer auditLog.Close()
+	}
+	if *maxConcurrent > 0 {
+		slots = make(chan struct{}, *maxConcurrent)
+	}
+	if sessionLimits.rate < 0 || sessionLimits.burst < 1 || sessionLimits.maxRunning < 0 {
+		log.Fatal("-session-rate and -session-max-concurrent must not be negative, and -session-burst must be at least 1")
+	}
+	if *cacheTTL == 0 {
+		*cacheTTL = c.CacheTTL
+	}
+	if *cacheTTL > 0 {
+		if resultCache, err = cache.New(*cacheTTL); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *debugAddr != "" {
+		a, err := debug.Start(*debugAddr, resultCache)
+		if err != nil {
+			log.Fatalf("unable to serve debug endpoints: %v", err)
+		}
+		log.Printf("Serving pprof and runtime stats at http://%s/debug/", a)
+	}
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		log.Fatalf("unable to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+	server := createServer()
+	reloadOnHangup(server, src)
+
+	if stdio != nil {
 		// Default: stdio transport
-		server := createServer()
-		if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
+		logServer.Store(server)
+		if err := server.Run(context.Background(), stdio); err != nil {
 			log.Fatal(err)
 		}
 		return
 	}
 
 	// HTTP Streamable transport using StreamableHTTPHandler
-	server := createServer()
-	handler := mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server { return server }, nil)
+	certFile, keyFile, err := tlsFiles(*tlsCert, *tlsKey, *tlsSelfSigned)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *tlsSelfSigned {
+		log.Printf("Using self-signed certificate %s; have clients trust it to connect", certFile)
+	}
+	var verifier *oidc.Verifier
+	if *oidcIssuer != "" {
+		if verifier, err = oidc.New(context.Background(), *oidcIssuer, *oidcAudience); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// protect puts a handler behind the tokens, which a reload can change
+	protect := func(h http.Handler) http.Handler { return requireBearer(verifier, server, h) }
+	if len(authTokens()) == 0 && verifier == nil {
+		log.Printf("Warning: no -auth-token or -oidc-issuer set; anyone who can reach %s can use this server", *addr)
+	}
+	var handler http.Handler = mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server { return server }, nil)
+	handler = protect(retainSessions(handler, *eventRetention))
 
 	mux := http.NewServeMux()
 	// Health endpoints
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte("ok"))
 	})
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write([]byte("healthy"))
-	})
+	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/live", liveHandler)
+	mux.HandleFunc("/metrics", metricsHandler(server))
+	// REST API over the same tools, behind the same tokens
+	mux.Handle("/api/", protect(apiHandler()))
+	mux.Handle("POST /config/reload", protect(reloadHandler(server, src)))
 	// Mount handler at both /path and /path/ to avoid redirects/edge cases
 	p := *mcpPath
 	if p == "" {
@@ -180,7 +1424,22 @@ func main() {
 		mux.Handle(p+"/", handler)
 	}
 
-	srv := &http.Server{Addr: *addr, Handler: mux}
+	if len(origins) == 0 {
+		for _, o := range c.AllowedOrigins {
+			if err := origins.Set(o); err != nil {
+				log.Fatalf("invalid config: allowed_origins: %v", err)
+			}
+		}
+	}
+	srv := &http.Server{Addr: *addr, Handler: checkOrigin(origins, mux)}
+	if *sseMode {
+		if p == ssePath || p == ssePath+"/" {
+			log.Fatalf("-path %s is taken by -sse", p)
+		}
+		sse, endSSE := sseHandler(server)
+		mux.Handle(ssePath, protect(sse))
+		srv.RegisterOnShutdown(endSSE)
+	}
 	go func() {
 		var host string
 		if strings.HasPrefix(*addr, ":") {
@@ -188,8 +1447,21 @@ func main() {
 		} else {
 			host = *addr
 		}
-		log.Printf("MCP streamable HTTP server listening at http://%s%s\n", host, p)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		scheme := "http"
+		if certFile != "" {
+			scheme = "https"
+		}
+		log.Printf("MCP streamable HTTP server listening at %s://%s%s\n", scheme, host, p)
+		if *sseMode {
+			log.Printf("MCP SSE server listening at %s://%s%s\n", scheme, host, ssePath)
+		}
+		var err error
+		if certFile != "" {
+			err = srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("HTTP server error: %v", err)
 		}
 	}()
@@ -198,7 +1470,28 @@ func main() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 	<-stop
+	// Keep serving while the running calls finish, so their results still
+	// reach their clients, then close the connections
+	drainTools(*shutdownTimeout)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	_ = srv.Shutdown(ctx)
 }
+
+// hideFlags leaves the named flags, kept for old invocations, out of the
+// usage message.
+func hideFlags(names ...string) {
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage of %s:\n", os.Args[0])
+		shown := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		shown.SetOutput(out)
+		flag.VisitAll(func(f *flag.Flag) {
+			if !slices.Contains(names, f.Name) {
+				shown.Var(f.Value, f.Name, f.Usage)
+				shown.Lookup(f.Name).DefValue = f.DefValue
+			}
+		})
+		shown.PrintDefaults()
+	}
+}
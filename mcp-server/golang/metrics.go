@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// durationBuckets are the histogram bucket upper bounds, in seconds.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// histogram is a cumulative Prometheus histogram over durationBuckets.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(durationBuckets))
+	}
+	for i, b := range durationBuckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// write prints the series of h for metric name, with labels (such as
+// `tool="read_file"`) if not empty.
+func (h *histogram) write(w *strings.Builder, name, labels string) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, b := range durationBuckets {
+		var n uint64
+		if h.counts != nil {
+			n = h.counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{%s%sle=\"%g\"} %d\n", name, labels, sep, b, n)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
+
+// metrics collects what /metrics reports. Tool calls are counted in stdio
+// mode too; they are only served over HTTP.
+var metrics = struct {
+	mu             sync.Mutex
+	calls          map[string]uint64
+	failures       map[string]uint64
+	toolDuration   map[string]*histogram
+	searchDuration histogram
+	filesScanned   uint64
+	inFlight       int
+}{
+	calls:        map[string]uint64{},
+	failures:     map[string]uint64{},
+	toolDuration: map[string]*histogram{},
+}
+
+// recordTool counts a finished call of the named tool that took d.
+func recordTool(name string, d time.Duration, failed bool) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.inFlight--
+	metrics.calls[name]++
+	if failed {
+		metrics.failures[name]++
+	}
+	h := metrics.toolDuration[name]
+	if h == nil {
+		h = &histogram{}
+		metrics.toolDuration[name] = h
+	}
+	h.observe(d.Seconds())
+}
+
+// recordSearch counts a search walk that took d and checked scanned files.
+func recordSearch(d time.Duration, scanned int) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.searchDuration.observe(d.Seconds())
+	metrics.filesScanned += uint64(scanned)
+}
+
+// addTool registers a tool like mcp.AddTool, subject to limitTool and
+// drainTool, traced by traceTool, recorded by auditTool, and counted by
+// countTool.
+func addTool[In any](server *mcp.Server, t *mcp.Tool, h mcp.ToolHandlerFor[In, any]) {
+	mcp.AddTool(server, t, wrapTool(t.Name, h))
+}
+
+// wrapTool wraps the handler of the named tool as every way of calling it
+// is: limited, drained at shutdown, traced, audited, and counted.
+func wrapTool[In any](name string, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return countTool(name, logTool(name, auditTool(name, traceTool(name, drainTool(name, limitTool(name, h))))))
+}
+
+// countTool wraps a tool handler so that its calls and failures are
+// counted. A call fails when the handler errors or, as the tools here
+// report problems, its text starts with "Error".
+func countTool[In any](name string, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[In]) (*mcp.CallToolResultFor[any], error) {
+		metrics.mu.Lock()
+		metrics.inFlight++
+		metrics.mu.Unlock()
+		start := time.Now()
+		res, err := h(ctx, ss, params)
+		recordTool(name, time.Since(start), err != nil || failed(res))
+		return res, err
+	}
+}
+
+// failed reports whether a tool result describes an error.
+func failed(res *mcp.CallToolResultFor[any]) bool {
+	return res != nil && res.IsError
+}
+
+// errorText returns the first line of the text of a failed result.
+func errorText(res *mcp.CallToolResultFor[any]) string {
+	if len(res.Content) > 0 {
+		if t, ok := res.Content[0].(*mcp.TextContent); ok {
+			line, _, _ := strings.Cut(t.Text, "\n")
+			return line
+		}
+	}
+	return "tool returned an error"
+}
+
+// metricsHandler serves the metrics in the Prometheus text format, with
+// the sessions currently connected to server.
+func metricsHandler(server *mcp.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var b strings.Builder
+		metrics.mu.Lock()
+		tools := make([]string, 0, len(metrics.calls))
+		for name := range metrics.calls {
+			tools = append(tools, name)
+		}
+		sort.Strings(tools)
+
+		b.WriteString("# HELP vscode_helper_tool_calls_total Tool invocations by tool name.\n")
+		b.WriteString("# TYPE vscode_helper_tool_calls_total counter\n")
+		for _, name := range tools {
+			fmt.Fprintf(&b, "vscode_helper_tool_calls_total{tool=%q} %d\n", name, metrics.calls[name])
+		}
+		b.WriteString("# HELP vscode_helper_tool_failures_total Tool invocations that returned an error.\n")
+		b.WriteString("# TYPE vscode_helper_tool_failures_total counter\n")
+		for _, name := range tools {
+			fmt.Fprintf(&b, "vscode_helper_tool_failures_total{tool=%q} %d\n", name, metrics.failures[name])
+		}
+		b.WriteString("# HELP vscode_helper_tool_duration_seconds Time taken by tool invocations, including any wait for -max-concurrent.\n")
+		b.WriteString("# TYPE vscode_helper_tool_duration_seconds histogram\n")
+		for _, name := range tools {
+			metrics.toolDuration[name].write(&b, "vscode_helper_tool_duration_seconds", fmt.Sprintf("tool=%q", name))
+		}
+		b.WriteString("# HELP vscode_helper_search_duration_seconds Time taken walking and scanning files for search_files.\n")
+		b.WriteString("# TYPE vscode_helper_search_duration_seconds histogram\n")
+		metrics.searchDuration.write(&b, "vscode_helper_search_duration_seconds", "")
+		b.WriteString("# HELP vscode_helper_files_scanned_total Files checked by searches.\n")
+		b.WriteString("# TYPE vscode_helper_files_scanned_total counter\n")
+		fmt.Fprintf(&b, "vscode_helper_files_scanned_total %d\n", metrics.filesScanned)
+		b.WriteString("# HELP vscode_helper_tool_calls_in_flight Tool invocations in progress, including those waiting for -max-concurrent.\n")
+		b.WriteString("# TYPE vscode_helper_tool_calls_in_flight gauge\n")
+		fmt.Fprintf(&b, "vscode_helper_tool_calls_in_flight %d\n", metrics.inFlight)
+		metrics.mu.Unlock()
+
+		sessions := 0
+		for range server.Sessions() {
+			sessions++
+		}
+		b.WriteString("# HELP vscode_helper_sessions Client sessions currently connected.\n")
+		b.WriteString("# TYPE vscode_helper_sessions gauge\n")
+		fmt.Fprintf(&b, "vscode_helper_sessions %d\n", sessions)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write([]byte(b.String()))
+	}
+}
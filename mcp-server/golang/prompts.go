@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// definitionKeywords precede a declared name in the languages people
+// commonly search: Go, Python, JavaScript/TypeScript, Rust, Java, C#, Ruby.
+const definitionKeywords = `func|def|class|interface|type|struct|enum|trait|fn|function|const|let|var|module`
+
+// testFilePatterns name test files across common ecosystems.
+var testFilePatterns = []string{"*_test.go", "test_*.py", "*_test.py", "*.test.*", "*.spec.*", "*Test.java", "*Tests.cs", "*_spec.rb"}
+
+// addPrompts registers prompts that walk a client through common navigation
+// tasks with the server's own tools.
+func addPrompts(server *mcp.Server) {
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "find_implementation",
+		Description: "Find where a function, type, or class is defined, and open it unless the server is read-only.",
+		Arguments: []*mcp.PromptArgument{
+			{Name: "symbol", Description: "Name of the function, method, type, or class", Required: true},
+			{Name: "directory", Description: "Directory to search (default the server's)"},
+		},
+	}, findImplementationPrompt)
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "find_failing_test",
+		Description: "Locate a failing test by name or by text from its failure, and open it unless the server is read-only.",
+		Arguments: []*mcp.PromptArgument{
+			{Name: "test", Description: "Test name, or text from the failure message", Required: true},
+			{Name: "directory", Description: "Directory to search (default the server's)"},
+		},
+	}, findFailingTestPrompt)
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "find_references",
+		Description: "List the places a symbol is used, grouped by file.",
+		Arguments: []*mcp.PromptArgument{
+			{Name: "symbol", Description: "Identifier to look for", Required: true},
+			{Name: "directory", Description: "Directory to search (default the server's)"},
+		},
+	}, findReferencesPrompt)
+}
+
+func findImplementationPrompt(ctx context.Context, ss *mcp.ServerSession, params *mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
+	symbol, err := promptArg(params, "symbol")
+	if err != nil {
+		return nil, err
+	}
+	quoted := regexp.QuoteMeta(symbol)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Find the implementation of `%s`%s.\n\n", symbol, inDirectory(params))
+	fmt.Fprintf(&b, "1. Call search_files with %s to find declarations in most languages. ", toolArgs(params, map[string]any{
+		"content": fmt.Sprintf(`\b(%s)\s+(\([^)]*\)\s*)?%s\b`, definitionKeywords, quoted),
+		"regex":   true,
+		"limit":   50,
+	}))
+	fmt.Fprintf(&b, "If nothing matches, try assignments such as `%s = ` or `%s: ` and a plain content search for `%s`.\n", symbol, symbol, symbol)
+	b.WriteString("2. If there are several candidates, prefer non-test, non-vendored files and the declaration whose signature fits best; use read_file with start_line and end_line around each match to compare them.\n")
+	b.WriteString("3. Read enough of the chosen file with read_file to show the full definition.\n")
+	if readOnly {
+		b.WriteString("4. Reply with its path and line, and summarize what it does.")
+	} else {
+		b.WriteString("4. Open it with open_file, passing its path and line so the editor jumps to the definition, then summarize what it does.")
+	}
+	return promptResult("Find the implementation of "+symbol, b.String()), nil
+}
+
+func findFailingTestPrompt(ctx context.Context, ss *mcp.ServerSession, params *mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
+	test, err := promptArg(params, "test")
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Locate the failing test `%s`%s.\n\n", test, inDirectory(params))
+	// The test name may come with a package or subtest prefix, e.g. TestFoo/case
+	name := test
+	if i := strings.LastIndexAny(name, "/.:"); i >= 0 && i < len(name)-1 && !strings.ContainsAny(name, " ") {
+		name = name[i+1:]
+	}
+	fmt.Fprintf(&b, "1. Call search_files with %s to search the test files only. ", toolArgs(params, map[string]any{
+		"name":    strings.Join(testFilePatterns, ","),
+		"content": name,
+		"limit":   50,
+	}))
+	b.WriteString("If the input is a failure message rather than a name, search for its most distinctive literal part instead.\n")
+	b.WriteString("2. If there is no match, repeat without name so that tests kept outside the usual file names are found too.\n")
+	b.WriteString("3. Use read_file around the match to find the start of the test function or case and the assertion that fails.\n")
+	if readOnly {
+		b.WriteString("4. Reply with the path and line of the test and of the code under test it exercises.")
+	} else {
+		b.WriteString("4. Open the test with open_file at its path and line, then name the code under test it exercises.")
+	}
+	return promptResult("Locate the failing test "+test, b.String()), nil
+}
+
+func findReferencesPrompt(ctx context.Context, ss *mcp.ServerSession, params *mcp.GetPromptParams) (*mcp.GetPromptResult, error) {
+	symbol, err := promptArg(params, "symbol")
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Find the references to `%s`%s.\n\n", symbol, inDirectory(params))
+	fmt.Fprintf(&b, "1. Call search_files with %s to match the whole identifier. ", toolArgs(params, map[string]any{
+		"content":        `\b` + regexp.QuoteMeta(symbol) + `\b`,
+		"regex":          true,
+		"case_sensitive": true,
+		"limit":          200,
+	}))
+	b.WriteString("If the result has a next_cursor, call it again with cursor to get the rest.\n")
+	b.WriteString("2. Separate the declaration from the uses, and skip matches in comments and strings where they are clearly unrelated.\n")
+	b.WriteString("3. Reply with the uses grouped by file, with line numbers and a few words on each.")
+	return promptResult("Find the references to "+symbol, b.String()), nil
+}
+
+// promptArg returns the named argument, which must be present and not
+// blank.
+func promptArg(params *mcp.GetPromptParams, name string) (string, error) {
+	v := strings.TrimSpace(params.Arguments[name])
+	if v == "" {
+		return "", fmt.Errorf("prompt %s requires the '%s' argument", params.Name, name)
+	}
+	return v, nil
+}
+
+// inDirectory describes the directory argument for the prompt text.
+func inDirectory(params *mcp.GetPromptParams) string {
+	if dir := strings.TrimSpace(params.Arguments["directory"]); dir != "" {
+		return " in " + dir
+	}
+	return ""
+}
+
+// toolArgs renders tool arguments as JSON for the prompt text, adding the
+// directory argument when one was given.
+func toolArgs(params *mcp.GetPromptParams, args map[string]any) string {
+	if dir := strings.TrimSpace(params.Arguments["directory"]); dir != "" {
+		args["directory"] = dir
+	}
+	data, _ := json.Marshal(args)
+	return "`" + string(data) + "`"
+}
+
+func promptResult(description, text string) *mcp.GetPromptResult {
+	return &mcp.GetPromptResult{
+		Description: description,
+		Messages:    []*mcp.PromptMessage{{Role: "user", Content: &mcp.TextContent{Text: text}}},
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"vscode-helper-file-find/internal/config"
+	"vscode-helper-file-find/internal/sandbox"
+)
+
+// settings holds what the config file and the tokens file decide, which a
+// reload replaces while sessions go on. Handlers read it through cfg,
+// allowed, roots, toolTimeout, and authTokens, since a reload can happen
+// during a call.
+var settings struct {
+	sync.RWMutex
+	cfg      config.Config
+	allowed  *sandbox.Sandbox
+	roots    []string
+	timeouts timeouts
+	tokens   []string
+}
+
+// cfg returns the defaults loaded from the config file given by -config, or
+// the default one; the project configuration of the directory a tool works
+// in is layered on top, and explicit tool arguments take precedence.
+func cfg() config.Config {
+	settings.RLock()
+	defer settings.RUnlock()
+	return settings.cfg
+}
+
+// allowed returns the sandbox confining every tool to the directories given
+// with -allow-dir, or the configured allow_dirs. It is nil, allowing
+// everything, when neither names any.
+func allowed() *sandbox.Sandbox {
+	settings.RLock()
+	defer settings.RUnlock()
+	return settings.allowed
+}
+
+// roots returns the project directories exposed as resources, as absolute
+// paths with symlinks resolved. They come from -root, or default to the
+// configured dir, the allowed directories, or else the working directory.
+func roots() []string {
+	settings.RLock()
+	defer settings.RUnlock()
+	return settings.roots
+}
+
+// toolTimeout returns how long a call of the named tool may run.
+func toolTimeout(name string) time.Duration {
+	settings.RLock()
+	defer settings.RUnlock()
+	return settings.timeouts.of(name)
+}
+
+// authTokens returns the static bearer tokens accepted in HTTP mode.
+func authTokens() []string {
+	settings.RLock()
+	defer settings.RUnlock()
+	return settings.tokens
+}
+
+// sources are where the settings come from: the flags, which win, and the
+// files they name.
+type sources struct {
+	configPath          string
+	allowDirs, rootDirs dirList
+	authToken           string
+	tokensFile          string
+}
+
+// loadSettings reads the config and tokens files named by src and replaces
+// the settings with those they and the flags give. On an error the
+// settings are left as they were.
+func loadSettings(src sources) error {
+	c, err := config.Load(src.configPath)
+	if err != nil {
+		return err
+	}
+	allowDirs := src.allowDirs
+	if len(allowDirs) == 0 {
+		allowDirs = c.AllowDirs
+	}
+	sb, err := sandbox.New(allowDirs)
+	if err != nil {
+		return err
+	}
+	rootDirs := src.rootDirs
+	if len(rootDirs) == 0 {
+		switch {
+		case c.Dir != "":
+			rootDirs = dirList{c.Dir}
+		case sb != nil:
+			rootDirs = sb.Dirs()
+		default:
+			rootDirs = dirList{"."}
+		}
+	}
+	rs, err := resolveRoots(rootDirs, sb)
+	if err != nil {
+		return err
+	}
+	tokens, err := loadTokens(src.authToken, src.tokensFile)
+	if err != nil {
+		return err
+	}
+	// -tool-timeout wins over tool_timeout, tool by tool
+	t := timeouts{"": defaultToolTimeout}
+	if c.ToolTimeout > 0 {
+		t[""] = c.ToolTimeout
+	}
+	for name, d := range toolTimeouts {
+		t[name] = d
+	}
+
+	settings.Lock()
+	defer settings.Unlock()
+	settings.cfg, settings.allowed, settings.roots, settings.timeouts, settings.tokens = c, sb, rs, t, tokens
+	return nil
+}
+
+// reloadMu keeps reloads from interleaving.
+var reloadMu sync.Mutex
+
+// reload loads the settings again and, when the roots changed, replaces
+// the resources of server, which tells the connected clients so.
+func reload(server *mcp.Server, src sources) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	old := roots()
+	if err := loadSettings(src); err != nil {
+		return err
+	}
+	if now := roots(); !slices.Equal(old, now) {
+		for _, root := range old {
+			uri := fileURI(root)
+			server.RemoveResources(uri)
+			server.RemoveResourceTemplates(filesTemplate(uri))
+		}
+		addResources(server)
+	}
+	if len(authTokens()) == 0 && src.tokensFile != "" {
+		log.Printf("Warning: the reloaded tokens file has no tokens")
+	}
+	log.Printf("Reloaded settings; roots: %s", strings.Join(roots(), ", "))
+	return nil
+}
+
+// reloadOnHangup reloads the settings whenever the process gets SIGHUP,
+// logging a failed reload, which leaves the settings as they were.
+func reloadOnHangup(server *mcp.Server, src sources) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reload(server, src); err != nil {
+				log.Printf("Error reloading settings: %v", err)
+			}
+		}
+	}()
+}
+
+// reloadHandler serves POST /config/reload, reloading the settings as
+// SIGHUP does and answering with the roots now served.
+func reloadHandler(server *mcp.Server, src sources) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := reload(server, src); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "reloaded", "roots": roots()})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"vscode-helper-file-find/internal/files"
+)
+
+// maxResourceSize is the largest file resources/read returns; larger files
+// can still be read in parts with the read_file tool.
+const maxResourceSize = 10 << 20
+
+// fileURI returns the file:// URI of an absolute path.
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// filesTemplate returns the URI template of the files below the root with
+// the given URI.
+func filesTemplate(uri string) string {
+	return strings.TrimSuffix(uri, "/") + "/{+path}"
+}
+
+// addResources registers each root as a resource listing its entries, and a
+// template matching the paths below it whose reads return file contents.
+func addResources(server *mcp.Server) {
+	for _, root := range roots() {
+		uri := fileURI(root)
+		server.AddResource(&mcp.Resource{
+			Name:        filepath.Base(root),
+			Description: "Project directory " + root + "; reading it lists its entries.",
+			MIMEType:    "text/plain",
+			URI:         uri,
+		}, readResource)
+		server.AddResourceTemplate(&mcp.ResourceTemplate{
+			Name:        filepath.Base(root) + " files",
+			Description: "Files and directories under " + root + ".",
+			URITemplate: filesTemplate(uri),
+		}, readResource)
+	}
+}
+
+// readResource implements resources/read for the roots and the paths below
+// them. Text files are returned as text, other files as a blob, and
+// directories as a listing like list_directory's.
+func readResource(ctx context.Context, ss *mcp.ServerSession, params *mcp.ReadResourceParams) (*mcp.ReadResourceResult, error) {
+	uri := params.URI
+	path, err := resourcePath(uri)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, mcp.ResourceNotFoundError(uri)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contents := &mcp.ResourceContents{URI: uri}
+	switch {
+	case info.IsDir():
+		res, err := files.List(path, files.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		for _, e := range res.Entries {
+			name := e.Path
+			if e.Type == "directory" {
+				name += "/"
+			}
+			fmt.Fprintf(&b, "%s\t%s\t%d\t%s\n", name, e.Type, e.Size, e.ModTime.Format(time.RFC3339))
+		}
+		if res.Truncated {
+			fmt.Fprintf(&b, "[truncated at %d entries]\n", len(res.Entries))
+		}
+		contents.MIMEType, contents.Text = "text/plain", b.String()
+	case !info.Mode().IsRegular():
+		return nil, fmt.Errorf("'%s' is not a regular file", path)
+	case info.Size() > maxResourceSize:
+		return nil, fmt.Errorf("'%s' is %d bytes, over the %d byte resource limit; use read_file", path, info.Size(), maxResourceSize)
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		ti, err := files.DetectText(path, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		contents.MIMEType = mime.TypeByExtension(filepath.Ext(path))
+		if ti.Binary {
+			if contents.MIMEType == "" {
+				contents.MIMEType = "application/octet-stream"
+			}
+			contents.Blob = data
+		} else {
+			if contents.MIMEType == "" {
+				contents.MIMEType = "text/plain"
+			}
+			contents.Text = string(data)
+		}
+	}
+	return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{contents}}, nil
+}
+
+// resourcePath returns the file path named by a file:// URI, checking that
+// it lies under one of the roots once symlinks are resolved so that a link
+// cannot reach outside them.
+func resourcePath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" || u.Path == "" {
+		return "", fmt.Errorf("'%s' is not a file URI", uri)
+	}
+	path := filepath.Clean(filepath.FromSlash(u.Path))
+	resolved, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		return "", mcp.ResourceNotFoundError(uri)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !within(roots(), resolved) {
+		// Treat paths outside the roots as missing so as not to reveal them
+		return "", mcp.ResourceNotFoundError(uri)
+	}
+	return resolved, nil
+}
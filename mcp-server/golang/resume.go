@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sessionIDHeader carries the Streamable HTTP session a request belongs to.
+const sessionIDHeader = "Mcp-Session-Id"
+
+// defaultEventRetention is how long -event-retention keeps a disconnected
+// session by default: long enough to outlast a laptop's nap.
+const defaultEventRetention = time.Hour
+
+// sessionActivity is what retainSessions knows of a session: its requests
+// in progress, open streams among them, and when the last one ended.
+type sessionActivity struct {
+	active int
+	last   time.Time
+}
+
+// retainSessions wraps the Streamable HTTP handler next so that a session
+// no request has been made in, and no stream been open on, for retention
+// is closed as if its client had sent DELETE.
+//
+// The handler keeps every message it sends a session, each with an event
+// ID, so that a client whose stream broke, as a sleeping laptop's does, can
+// GET the rest with Last-Event-ID while the call goes on running; but it
+// keeps them until the client ends the session, which few do. Retention
+// bounds that. Zero keeps sessions as the handler does.
+func retainSessions(next http.Handler, retention time.Duration) http.Handler {
+	if retention <= 0 {
+		return next
+	}
+	var (
+		mu       sync.Mutex
+		sessions = make(map[string]*sessionActivity)
+	)
+	go func() {
+		tick := max(retention/10, time.Second)
+		for range time.Tick(tick) {
+			var expired []string
+			mu.Lock()
+			for id, s := range sessions {
+				if s.active == 0 && time.Since(s.last) > retention {
+					expired = append(expired, id)
+					delete(sessions, id)
+				}
+			}
+			mu.Unlock()
+			for _, id := range expired {
+				r, _ := http.NewRequest(http.MethodDelete, "/", nil)
+				r.Header.Set(sessionIDHeader, id)
+				r.Header.Set("Accept", "application/json, text/event-stream")
+				next.ServeHTTP(discardResponse{http.Header{}}, r)
+			}
+		}
+	}()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(sessionIDHeader)
+		mu.Lock()
+		if s := sessions[id]; s != nil {
+			s.active++
+		}
+		mu.Unlock()
+
+		next.ServeHTTP(w, r)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if s := sessions[id]; s != nil {
+			s.active--
+			s.last = time.Now()
+		}
+		switch {
+		case r.Method == http.MethodDelete:
+			delete(sessions, id)
+		case id == "":
+			// A request without a session starts one
+			if id := w.Header().Get(sessionIDHeader); id != "" {
+				sessions[id] = &sessionActivity{last: time.Now()}
+			}
+		}
+	})
+}
+
+// discardResponse is the ResponseWriter of the DELETEs retainSessions
+// sends.
+type discardResponse struct{ h http.Header }
+
+func (d discardResponse) Header() http.Header         { return d.h }
+func (d discardResponse) Write(b []byte) (int, error) { return len(b), nil }
+func (d discardResponse) WriteHeader(int)             {}
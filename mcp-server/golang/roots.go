@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"vscode-helper-file-find/internal/sandbox"
+)
+
+// rootsTimeout bounds how long a tool call waits for the client to answer
+// roots/list.
+const rootsTimeout = 5 * time.Second
+
+// dirList collects repeated directory flags such as -root and -allow-dir.
+type dirList []string
+
+func (r *dirList) String() string { return strings.Join(*r, ",") }
+
+func (r *dirList) Set(dir string) error {
+	*r = append(*r, dir)
+	return nil
+}
+
+// resolveRoots turns the directories given on the command line into roots,
+// failing if one is not a directory or lies outside those sb allows.
+func resolveRoots(dirs []string, sb *sandbox.Sandbox) ([]string, error) {
+	var out []string
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if abs, err = filepath.EvalSymlinks(abs); err != nil {
+			return nil, fmt.Errorf("root '%s': %w", dir, err)
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			return nil, fmt.Errorf("root '%s': %w", dir, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("root '%s' is not a directory", dir)
+		}
+		if err := sb.Check(abs); err != nil {
+			return nil, fmt.Errorf("root %w", err)
+		}
+		out = append(out, abs)
+	}
+	return out, nil
+}
+
+// within reports whether the absolute path is one of dirs or lies below one.
+func within(dirs []string, path string) bool {
+	for _, dir := range dirs {
+		if rel, err := filepath.Rel(dir, path); err == nil && (rel == "." || filepath.IsLocal(rel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// realPath returns the absolute form of path with symlinks resolved where
+// it exists, so that it can be compared against roots.
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	return abs, nil
+}
+
+// clientRoots returns the file:// roots the client advertises, as local
+// paths in the order given. It returns none if the client does not support
+// roots or fails to answer in time; roots with other schemes are skipped.
+func clientRoots(ctx context.Context, ss *mcp.ServerSession) []string {
+	if ss == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, rootsTimeout)
+	defer cancel()
+	res, err := ss.ListRoots(ctx, nil)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, r := range res.Roots {
+		u, err := url.Parse(r.URI)
+		if err != nil || u.Scheme != "file" || u.Path == "" {
+			continue
+		}
+		if dir, err := realPath(filepath.FromSlash(u.Path)); err == nil {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// withClientRoots defaults dir to the first of the client's roots, if it
+// advertises any, and otherwise resolves a relative dir against that root
+// and checks that it lies inside one of them. dir is returned unchanged
+// when the client has no roots.
+func withClientRoots(ctx context.Context, ss *mcp.ServerSession, dir string) (string, error) {
+	dirs := clientRoots(ctx, ss)
+	if len(dirs) == 0 {
+		return dir, nil
+	}
+	if dir == "" {
+		return dirs[0], nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(dirs[0], dir)
+	}
+	abs, err := realPath(dir)
+	if err != nil {
+		return "", err
+	}
+	if !within(dirs, abs) {
+		return "", fmt.Errorf("directory '%s' is outside the client's roots (%s)", dir, strings.Join(dirs, ", "))
+	}
+	return dir, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// connectWithRoots connects a client advertising roots to a new server.
+func connectWithRoots(t *testing.T, roots ...string) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	st, ct := mcp.NewInMemoryTransports()
+	ss, err := createServer().Connect(ctx, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ss.Close() })
+	client := mcp.NewClient(&mcp.Implementation{Name: "test"}, nil)
+	for _, root := range roots {
+		client.AddRoots(&mcp.Root{URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(root)}).String()})
+	}
+	cs, err := client.Connect(ctx, ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+func resultText(res *mcp.CallToolResult) string {
+	var b strings.Builder
+	for _, c := range res.Content {
+		if tc, ok := c.(*mcp.TextContent); ok {
+			b.WriteString(tc.Text)
+		}
+	}
+	return b.String()
+}
+
+func TestReplaceInFilesClientRoots(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	inPath, outPath := filepath.Join(root, "sub", "a.txt"), filepath.Join(outside, "a.txt")
+	for _, path := range []string{inPath, outPath} {
+		if err := os.WriteFile(path, []byte("foo\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cs := connectWithRoots(t, root)
+	replaceIn := func(dir string) *mcp.CallToolResult {
+		t.Helper()
+		res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{
+			Name:      "replace_in_files",
+			Arguments: map[string]any{"content": "foo", "replacement": "bar", "directory": dir, "confirm": true},
+		})
+		if err != nil {
+			t.Fatalf("replace_in_files in %s: %v", dir, err)
+		}
+		return res
+	}
+
+	res := replaceIn(outside)
+	if !res.IsError || !strings.Contains(resultText(res), "outside the client's roots") {
+		t.Errorf("replace_in_files outside the roots = %q, want an error", resultText(res))
+	}
+	if b, _ := os.ReadFile(outPath); string(b) != "foo\n" {
+		t.Errorf("file outside the roots was rewritten to %q", b)
+	}
+
+	// A relative directory is resolved against the first root
+	res = replaceIn("sub")
+	if res.IsError {
+		t.Fatalf("replace_in_files in sub: %s", resultText(res))
+	}
+	if b, _ := os.ReadFile(inPath); string(b) != "bar\n" {
+		t.Errorf("file inside the roots = %q, want it rewritten", b)
+	}
+}
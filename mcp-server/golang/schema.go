@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+)
+
+var textMarshaler = reflect.TypeFor[encoding.TextMarshaler]()
+
+// outputSchema returns the JSON schema of T as encoding/json marshals it,
+// for a tool's structuredContent. Unlike jsonschema.For it describes types
+// that marshal as text (time.Time, search.MatchKind) as strings, and
+// inlines the fields of embedded structs. T is a struct; where it holds
+// itself, as a tree node holds its children, the schema refers back to the
+// whole.
+func outputSchema[T any]() *jsonschema.Schema {
+	return schemaOf(reflect.TypeFor[T](), nil)
+}
+
+// schemaOf returns the schema of t, a type held by root, the struct whose
+// schema is being built, or root itself if that is nil.
+func schemaOf(t, root reflect.Type) *jsonschema.Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		nullable, t = true, t.Elem()
+	}
+	s := &jsonschema.Schema{}
+	switch {
+	case t == root:
+		s.Ref = "#"
+		return s
+	case t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textMarshaler):
+		s.Type = "string"
+	case t.Kind() == reflect.Bool:
+		s.Type = "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s.Type = "integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s.Type = "number"
+	case t.Kind() == reflect.String:
+		s.Type = "string"
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		s.Type = "string" // base64
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		// A nil slice marshals as null
+		s.Types, s.Items = []string{"array", "null"}, schemaOf(t.Elem(), root)
+		return s
+	case t.Kind() == reflect.Map:
+		s.Type, s.AdditionalProperties = "object", schemaOf(t.Elem(), root)
+	case t.Kind() == reflect.Struct:
+		s.Type = "object"
+		if root == nil {
+			root = t
+		}
+		addFields(s, t, root)
+	}
+	if nullable && s.Type != "" {
+		s.Types, s.Type = []string{s.Type, "null"}, ""
+	}
+	return s
+}
+
+// addFields adds the properties of struct type t to s, including those of
+// embedded structs without a JSON name of their own.
+func addFields(s *jsonschema.Schema, t, root reflect.Type) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" || !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addFields(s, f.Type, root)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs := schemaOf(f.Type, root)
+		fs.Description = f.Tag.Get("jsonschema")
+		if s.Properties == nil {
+			s.Properties = map[string]*jsonschema.Schema{}
+		}
+		s.Properties[name] = fs
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
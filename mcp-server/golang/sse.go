@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ssePath is where -sse serves the legacy HTTP+SSE transport: a GET opens
+// a session's event stream, whose first event names the URL, /sse with a
+// sessionid, to POST its messages to.
+const ssePath = "/sse"
+
+// sseHandler returns the handler of the HTTP+SSE transport for server, and
+// a func ending its event streams, which would otherwise hold up a
+// shutdown until their clients went away.
+func sseHandler(server *mcp.Server) (http.Handler, func()) {
+	ctx, end := context.WithCancel(context.Background())
+	h := mcp.NewSSEHandler(func(r *http.Request) *mcp.Server { return server })
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			rctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			stop := context.AfterFunc(ctx, cancel)
+			defer stop()
+			r = r.WithContext(rctx)
+		}
+		h.ServeHTTP(w, r)
+	}), end
+}
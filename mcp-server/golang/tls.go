@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// selfSignedValidity is how long a generated certificate is valid for.
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// selfSignedCert returns the certificate and key files of a self-signed
+// certificate for localhost, kept under the user cache directory
+// (~/.cache/vscode-helper/tls) so that a client told to trust it once keeps
+// working across restarts. A new one is generated when none exists or the
+// existing one expires within a day.
+func selfSignedCert() (certFile, keyFile string, err error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	dir = filepath.Join(dir, "vscode-helper", "tls")
+	certFile, keyFile = filepath.Join(dir, "localhost.pem"), filepath.Join(dir, "localhost-key.pem")
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil && time.Until(pair.Leaf.NotAfter) > 24*time.Hour {
+		return certFile, keyFile, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "localhost", Organization: []string{"vscode-helper"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+// tlsFiles returns the certificate and key to serve HTTPS with, or empty
+// strings for plain HTTP. cert and key must be given together; selfSigned
+// generates them instead.
+func tlsFiles(cert, key string, selfSigned bool) (string, string, error) {
+	switch {
+	case selfSigned && (cert != "" || key != ""):
+		return "", "", errors.New("-tls-self-signed cannot be combined with -tls-cert or -tls-key")
+	case selfSigned:
+		c, k, err := selfSignedCert()
+		if err != nil {
+			return "", "", fmt.Errorf("unable to create self-signed certificate: %w", err)
+		}
+		return c, k, nil
+	case (cert == "") != (key == ""):
+		return "", "", errors.New("-tls-cert and -tls-key must be given together")
+	}
+	if cert != "" {
+		// Fail at startup rather than on the first connection
+		if _, err := tls.LoadX509KeyPair(cert, key); err != nil {
+			return "", "", fmt.Errorf("unable to load TLS certificate: %w", err)
+		}
+	}
+	return cert, key, nil
+}
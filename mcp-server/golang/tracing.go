@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records a span per tool call; the search package adds a child
+// span for the walk.
+var tracer = otel.Tracer("vscode-helper-file-find/mcp-server")
+
+// setupTracing exports spans over OTLP/HTTP when an endpoint is configured
+// with OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT.
+// The exporter reads the other standard OTEL_EXPORTER_OTLP_* variables
+// (headers, timeout, certificates), and OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES describe the server. The returned function
+// flushes pending spans; it does nothing when tracing is off.
+func setupTracing(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	shutdown = func(context.Context) error { return nil }
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return shutdown, nil
+	}
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") || os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return shutdown, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return shutdown, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(impl.Name),
+		semconv.ServiceVersion(impl.Version),
+	))
+	if err != nil {
+		return shutdown, err
+	}
+	// Let OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the above
+	if env, err := resource.New(ctx, resource.WithFromEnv()); err == nil {
+		if merged, err := resource.Merge(res, env); err == nil {
+			res = merged
+		}
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
+
+// traceTool wraps a tool handler in a span named after the tool. A client
+// that passes a W3C traceparent in the request's _meta gets the span
+// parented to its own trace.
+func traceTool[In any](name string, h mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[In]) (*mcp.CallToolResultFor[any], error) {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metaCarrier(params.Meta))
+		ctx, span := tracer.Start(ctx, "tools/call "+name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attribute.String("mcp.tool.name", name)))
+		defer span.End()
+		res, err := h(ctx, ss, params)
+		switch {
+		case err != nil:
+			span.SetStatus(codes.Error, err.Error())
+		case failed(res):
+			span.SetStatus(codes.Error, errorText(res))
+		}
+		return res, err
+	}
+}
+
+// metaCarrier reads trace context from a request's _meta, where clients
+// put traceparent and tracestate.
+type metaCarrier mcp.Meta
+
+func (m metaCarrier) Get(key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+func (m metaCarrier) Set(key, value string) {}
+
+func (m metaCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
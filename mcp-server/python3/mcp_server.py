@@ -30,6 +30,10 @@ def _build_cmd(base: List[str]) -> List[str]:
         )
     return [BIN_PATH, *base]
 
+# Helper exit codes: 0 success (something matched), 1 ran fine but nothing
+# matched, 2 usage or I/O error.
+EXIT_NO_MATCH = 1
+
 async def _run_cmd(cmd: List[str]) -> str:
     logger.debug("Running command: %s", " ".join(shlex.quote(c) for c in cmd))
     proc = await asyncio.create_subprocess_exec(
@@ -38,8 +42,8 @@ async def _run_cmd(cmd: List[str]) -> str:
         stderr=asyncio.subprocess.PIPE,
     )
     stdout, stderr = await proc.communicate()
-    if proc.returncode != 0:
-        err = (stderr.decode() or stdout.decode() or f"exit code {proc.returncode}").strip()
+    if proc.returncode not in (0, EXIT_NO_MATCH):
+        err = (stderr.decode() or stdout.decode() or f"exit code {proc.returncode}").strip().removeprefix("Error: ")
         raise RuntimeError(err)
     return stdout.decode().strip()
 